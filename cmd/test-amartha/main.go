@@ -1,17 +1,23 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/adityaokke/test-amartha/internal/delivery/rest"
 	"github.com/adityaokke/test-amartha/internal/entity"
 	pkgMail "github.com/adityaokke/test-amartha/internal/pkg/mail"
+	"github.com/adityaokke/test-amartha/internal/pkg/phash"
 	"github.com/adityaokke/test-amartha/internal/repository/db/sqlite"
 	"github.com/adityaokke/test-amartha/internal/repository/db/sqlite/migration"
-	"github.com/adityaokke/test-amartha/internal/repository/mail"
-	"github.com/adityaokke/test-amartha/internal/repository/pdf"
+	"github.com/adityaokke/test-amartha/internal/repository/document"
+	"github.com/adityaokke/test-amartha/internal/repository/notification"
+	"github.com/adityaokke/test-amartha/internal/repository/scoring"
+	"github.com/adityaokke/test-amartha/internal/repository/storage"
 	"github.com/adityaokke/test-amartha/internal/service"
 	driver "github.com/glebarez/sqlite"
 	"github.com/joho/godotenv"
@@ -23,11 +29,8 @@ import (
 func main() {
 	godotenv.Load(".env")
 
-	// ensure dir
-	os.MkdirAll(entity.LocalUploadPath, 0o755)
-	os.MkdirAll(entity.LocalAggrementLetterPath, 0o755)
-
-	db, err := gorm.Open(driver.Open("amartha.db"), &gorm.Config{})
+	// the job workers write concurrently with requests, wait for the lock instead of failing
+	db, err := gorm.Open(driver.Open("amartha.db?_pragma=busy_timeout(5000)"), &gorm.Config{})
 	if err != nil {
 		panic("failed to connect database")
 	}
@@ -35,18 +38,31 @@ func main() {
 
 	mailFrom := os.Getenv("MAIL_FROM")
 	smtpHost := os.Getenv("SMTP_HOST")
-	smtpPortEnv := os.Getenv("SMTP_PORT")
-	smtpPort := 0
-	if smtpPortEnv != "" {
-		smtpPort, err = strconv.Atoi(smtpPortEnv)
-		if err != nil {
-			panic("invalid SMTP_PORT")
-		}
-	}
+	smtpPort := getEnvInt("SMTP_PORT", 0)
 	smtpUser := os.Getenv("SMTP_USER")
 	smtpPass := os.Getenv("SMTP_PASS")
 	mailer := pkgMail.NewMailer(mailFrom, smtpHost, smtpPort, smtpUser, smtpPass)
 
+	creditScoringConfigPath := os.Getenv("CREDIT_SCORING_CONFIG")
+	if creditScoringConfigPath == "" {
+		creditScoringConfigPath = "config/credit-scoring.json"
+	}
+	creditScoringConfig, err := scoring.LoadConfig(creditScoringConfigPath)
+	if err != nil {
+		panic("invalid CREDIT_SCORING_CONFIG: " + err.Error())
+	}
+
+	retentionPolicyPath := os.Getenv("RETENTION_CONFIG")
+	if retentionPolicyPath == "" {
+		retentionPolicyPath = "config/retention.json"
+	}
+	retentionPolicy, err := service.LoadRetentionPolicy(retentionPolicyPath)
+	if err != nil {
+		panic("invalid RETENTION_CONFIG: " + err.Error())
+	}
+	// the scheduled job only reports until RETENTION_DRY_RUN=false
+	retentionPolicy.DryRun = os.Getenv("RETENTION_DRY_RUN") != "false"
+
 	// initialize echo
 	e := echo.New()
 	e.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{
@@ -72,41 +88,412 @@ func main() {
 	investorRepo := sqlite.NewInvestorRepository().
 		SetDBConnection(db).
 		Build()
-	mailApi := mail.NewMailApi().
-		SetMailer(&mailer).
+	borrowerRepo := sqlite.NewBorrowerRepository().
+		SetDBConnection(db).
+		Build()
+	loanProductRepo := sqlite.NewLoanProductRepository().
+		SetDBConnection(db).
+		Build()
+	fileRepo := sqlite.NewFileRepository().
+		SetDBConnection(db).
+		Build()
+	fileVariantRepo := sqlite.NewFileVariantRepository().
+		SetDBConnection(db).
+		Build()
+	photoProofDuplicateRepo := sqlite.NewPhotoProofDuplicateRepository().
+		SetDBConnection(db).
+		Build()
+	agreementSignatureRepo := sqlite.NewAgreementSignatureRepository().
+		SetDBConnection(db).
+		Build()
+	agreementDocumentRepo := sqlite.NewAgreementDocumentRepository().
+		SetDBConnection(db).
+		Build()
+	outboxEventRepo := sqlite.NewOutboxEventRepository().
+		SetDBConnection(db).
+		Build()
+	jobRepo := sqlite.NewJobRepository().
+		SetDBConnection(db).
+		Build()
+	notificationRepo := sqlite.NewNotificationRepository().
+		SetDBConnection(db).
+		Build()
+	notificationPreferenceRepo := sqlite.NewNotificationPreferenceRepository().
+		SetDBConnection(db).
+		Build()
+	webhookSubscriptionRepo := sqlite.NewWebhookSubscriptionRepository().
+		SetDBConnection(db).
+		Build()
+	webhookEventRepo := sqlite.NewWebhookEventRepository().
+		SetDBConnection(db).
+		Build()
+	webhookDeliveryRepo := sqlite.NewWebhookDeliveryRepository().
+		SetDBConnection(db).
+		Build()
+	var objectStorage storage.Storage
+	switch os.Getenv("STORAGE_DRIVER") {
+	case "", "local":
+		localStorage := storage.NewLocalStorage()
+		if root := os.Getenv("STORAGE_LOCAL_ROOT"); root != "" {
+			localStorage = localStorage.SetRoot(root)
+		}
+		objectStorage = localStorage.Build()
+	case "s3":
+		objectStorage = storage.NewS3Storage().
+			SetConfig(storage.S3Config{
+				Endpoint:  os.Getenv("S3_ENDPOINT"),
+				Region:    os.Getenv("S3_REGION"),
+				AccessKey: os.Getenv("S3_ACCESS_KEY"),
+				SecretKey: os.Getenv("S3_SECRET_KEY"),
+				Bucket:    os.Getenv("S3_BUCKET"),
+				UseSSL:    os.Getenv("S3_USE_SSL") == "true",
+			}).
+			Build()
+	default:
+		panic("invalid STORAGE_DRIVER")
+	}
+	notificationApi := notification.NewNotificationApi().
+		SetProvider(entity.NotificationChannelEmail, newNotificationProvider(entity.NotificationChannelEmail, mailer)).
+		SetProvider(entity.NotificationChannelSMS, newNotificationProvider(entity.NotificationChannelSMS, mailer)).
+		SetProvider(entity.NotificationChannelWhatsApp, newNotificationProvider(entity.NotificationChannelWhatsApp, mailer)).
+		SetProvider(entity.NotificationChannelPush, newNotificationProvider(entity.NotificationChannelPush, mailer)).
+		Build()
+	notificationPollInterval := time.Duration(getEnvPositiveInt("NOTIFICATION_POLL_INTERVAL_SECONDS", 5)) * time.Second
+	notificationMaxAttempts := getEnvPositiveInt("NOTIFICATION_MAX_ATTEMPTS", 6)
+	notificationService := service.NewNotificationService().
+		SetRepository(notificationRepo).
+		SetNotificationPreferenceRepository(notificationPreferenceRepo).
+		SetNotificationApi(notificationApi).
+		SetStorage(objectStorage).
+		SetPolicy(entity.NotificationPolicy{
+			PollInterval: notificationPollInterval,
+			MaxAttempts:  notificationMaxAttempts,
+			Backoff:      time.Minute,
+			MaxBackoff:   time.Hour,
+			LockTimeout:  10 * time.Minute,
+		}).
+		Build()
+	notificationService.Start(context.Background())
+	webhookPollInterval := time.Duration(getEnvPositiveInt("WEBHOOK_POLL_INTERVAL_SECONDS", 5)) * time.Second
+	webhookMaxAttempts := getEnvPositiveInt("WEBHOOK_MAX_ATTEMPTS", 8)
+	webhookTimeout := time.Duration(getEnvPositiveInt("WEBHOOK_TIMEOUT_SECONDS", 10)) * time.Second
+	webhookService := service.NewWebhookService().
+		SetWebhookSubscriptionRepository(webhookSubscriptionRepo).
+		SetWebhookEventRepository(webhookEventRepo).
+		SetWebhookDeliveryRepository(webhookDeliveryRepo).
+		SetPolicy(entity.WebhookPolicy{
+			PollInterval: webhookPollInterval,
+			MaxAttempts:  webhookMaxAttempts,
+			Backoff:      30 * time.Second,
+			MaxBackoff:   time.Hour,
+			LockTimeout:  10 * time.Minute,
+			Timeout:      webhookTimeout,
+			// loopback receivers are only reachable when WEBHOOK_ALLOW_PRIVATE_NETWORKS=true
+			AllowPrivateNetworks: os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS") == "true",
+		}).
+		Build()
+	webhookService.Start(context.Background())
+	eventBus := service.NewEventBus().Build()
+	documentApi := document.NewDocumentApi().
+		SetStorage(objectStorage).
+		Build()
+	creditScorer := scoring.NewRuleBasedCreditScorer().
+		SetConfig(creditScoringConfig).
+		Build()
+
+	eligibilityEngine := service.NewEligibilityEngine().
+		SetLimits(entity.EligibilityLimits{
+			MaxBorrowerExposure: requireEnvInt("BORROWER_MAX_EXPOSURE"),
+		}).
+		Build()
+
+	downloadURLSecret := os.Getenv("DOWNLOAD_URL_SECRET")
+	if downloadURLSecret == "" {
+		panic("DOWNLOAD_URL_SECRET is required")
+	}
+	downloadURLTTL := time.Duration(getEnvPositiveInt("DOWNLOAD_URL_TTL_MINUTES", 15)) * time.Minute
+	fileService := service.NewFileService().
+		SetRepository(fileRepo).
+		SetFileVariantRepository(fileVariantRepo).
+		SetStorage(objectStorage).
+		SetDownloadPolicy(entity.DownloadPolicy{
+			Secret: downloadURLSecret,
+			TTL:    downloadURLTTL,
+		}).
 		Build()
-	pdfApi := pdf.NewPdfApi().
+
+	authTokenSecret := os.Getenv("AUTH_TOKEN_SECRET")
+	if authTokenSecret == "" {
+		panic("AUTH_TOKEN_SECRET is required")
+	}
+	authAPIKey := os.Getenv("AUTH_API_KEY")
+	if authAPIKey == "" {
+		panic("AUTH_API_KEY is required")
+	}
+	authService := service.NewAuthService().
+		SetPolicy(entity.AuthPolicy{
+			Secret: authTokenSecret,
+			TTL:    time.Duration(getEnvPositiveInt("AUTH_TOKEN_TTL_MINUTES", 60)) * time.Minute,
+			APIKey: authAPIKey,
+		}).
 		Build()
 
+	photoProofDuplicateAction := entity.PhotoProofDuplicateAction(os.Getenv("PHOTO_PROOF_DUPLICATE_ACTION"))
+	if photoProofDuplicateAction != "" && !photoProofDuplicateAction.IsValid() {
+		panic("invalid PHOTO_PROOF_DUPLICATE_ACTION")
+	}
+	photoProofDuplicateMaxDistance := getEnvInt("PHOTO_PROOF_DUPLICATE_MAX_DISTANCE", 6)
+	if photoProofDuplicateMaxDistance > phash.MaxLookupDistance {
+		panic(fmt.Sprintf("PHOTO_PROOF_DUPLICATE_MAX_DISTANCE must be at most %d", phash.MaxLookupDistance))
+	}
+	agreementPassword := entity.AgreementPassword(os.Getenv("AGREEMENT_PDF_PASSWORD"))
+	if !agreementPassword.IsValid() {
+		panic("invalid AGREEMENT_PDF_PASSWORD")
+	}
+
 	loanService := service.NewLoanService().
 		SetRepository(loanRepo).
 		SetLoanInvestmentRepository(loanInvestmentRepo).
 		SetInvestorRepository(investorRepo).
-		SetMailApi(mailApi).
-		SetPdfApi(pdfApi).
+		SetBorrowerRepository(borrowerRepo).
+		SetLoanProductRepository(loanProductRepo).
+		SetPhotoProofDuplicateRepository(photoProofDuplicateRepo).
+		SetAgreementSignatureRepository(agreementSignatureRepo).
+		SetAgreementDocumentRepository(agreementDocumentRepo).
+		SetOutboxEventRepository(outboxEventRepo).
+		SetNotificationService(notificationService).
+		SetEventBus(eventBus).
+		SetDocumentApi(documentApi).
+		SetCreditScorer(creditScorer).
+		SetEligibilityEngine(eligibilityEngine).
+		SetFileService(fileService).
+		SetStorage(objectStorage).
+		SetPhotoProofPolicy(entity.PhotoProofPolicy{
+			MaxAge:               time.Duration(requireEnvInt("PHOTO_PROOF_MAX_AGE_HOURS")) * time.Hour,
+			MaxDistanceMeters:    float64(requireEnvInt("PHOTO_PROOF_MAX_DISTANCE_METERS")),
+			DuplicateAction:      photoProofDuplicateAction,
+			DuplicateMaxDistance: photoProofDuplicateMaxDistance,
+		}).
+		SetAgreementPassword(agreementPassword).
+		Build()
+	analyticsService := service.NewAnalyticsService().Build()
+	loanNotificationService := service.NewLoanNotificationService().
+		SetLoanInvestmentRepository(loanInvestmentRepo).
+		SetInvestorRepository(investorRepo).
+		SetBorrowerRepository(borrowerRepo).
+		SetNotificationService(notificationService).
 		Build()
+
+	// subscribers run in this order, partners and the borrower hear about a funded loan
+	// before the agreement it leads to
+	eventBus.Subscribe("webhooks", webhookService.HandleDomainEvent,
+		entity.DomainEventTypeLoanProposed,
+		entity.DomainEventTypeLoanApproved,
+		entity.DomainEventTypeInvestmentPlaced,
+		entity.DomainEventTypeLoanFullyFunded,
+		entity.DomainEventTypeLoanAgreementGenerated,
+		entity.DomainEventTypeLoanDisbursed,
+	)
+	eventBus.Subscribe("notifications", loanNotificationService.HandleDomainEvent,
+		entity.DomainEventTypeLoanApproved,
+		entity.DomainEventTypeInvestmentPlaced,
+		entity.DomainEventTypeLoanFullyFunded,
+		entity.DomainEventTypeLoanDisbursed,
+		entity.DomainEventTypeLoanExpired,
+	)
+	eventBus.Subscribe("agreement", loanService.HandleLoanFullyFunded, entity.DomainEventTypeLoanFullyFunded)
+	eventBus.SubscribeAsync("analytics", analyticsService.HandleDomainEvent,
+		entity.DomainEventTypeLoanProposed,
+		entity.DomainEventTypeLoanApproved,
+		entity.DomainEventTypeInvestmentPlaced,
+		entity.DomainEventTypeLoanFullyFunded,
+		entity.DomainEventTypeLoanDisbursed,
+		entity.DomainEventTypeLoanExpired,
+	)
 	loanInvestmentService := service.NewLoanInvestmentService().
 		SetRepository(loanInvestmentRepo).
 		Build()
-	fileService := service.NewFileService().
-		Build()
 	investorService := service.NewInvestorService().
 		SetRepository(investorRepo).
 		Build()
+	borrowerService := service.NewBorrowerService().
+		SetRepository(borrowerRepo).
+		Build()
+	loanProductService := service.NewLoanProductService().
+		SetRepository(loanProductRepo).
+		Build()
+	outboxPollInterval := time.Duration(getEnvPositiveInt("OUTBOX_POLL_INTERVAL_SECONDS", 5)) * time.Second
+	outboxMaxAttempts := getEnvPositiveInt("OUTBOX_MAX_ATTEMPTS", 8)
+	outboxService := service.NewOutboxService().
+		SetRepository(outboxEventRepo).
+		SetProcessor(loanService).
+		SetPolicy(entity.OutboxPolicy{
+			PollInterval: outboxPollInterval,
+			MaxAttempts:  outboxMaxAttempts,
+			Backoff:      30 * time.Second,
+			MaxBackoff:   time.Hour,
+			LockTimeout:  10 * time.Minute,
+		}).
+		Build()
+	outboxService.Start(context.Background())
+	retentionService := service.NewRetentionService().
+		SetFileRepository(fileRepo).
+		SetLoanRepository(loanRepo).
+		SetAgreementDocumentRepository(agreementDocumentRepo).
+		SetFileService(fileService).
+		SetStorage(objectStorage).
+		SetPolicy(retentionPolicy).
+		Build()
+
+	jobWorkers := getEnvPositiveInt("JOB_WORKERS", 4)
+	jobPollInterval := time.Duration(getEnvPositiveInt("JOB_POLL_INTERVAL_SECONDS", 1)) * time.Second
+	jobMaxAttempts := getEnvPositiveInt("JOB_MAX_ATTEMPTS", 5)
+	jobLease := time.Duration(getEnvPositiveInt("JOB_LEASE_SECONDS", 300)) * time.Second
+	jobService := service.NewJobService().
+		SetRepository(jobRepo).
+		SetHandler(entity.JobTypeRetentionRun, retentionService.RunJob).
+		SetHandler(entity.JobTypeLoanExpiry, loanService.RunExpiryJob).
+		SetHandler(entity.JobTypePerceptualHashBackfill, fileService.RunHashBackfillJob).
+		SetPolicy(entity.JobQueuePolicy{
+			Workers:      jobWorkers,
+			PollInterval: jobPollInterval,
+			MaxAttempts:  jobMaxAttempts,
+			Lease:        jobLease,
+			Backoff:      30 * time.Second,
+			MaxBackoff:   time.Hour,
+		}).
+		Build()
+	jobService.Start(context.Background())
+
+	retentionInterval := time.Duration(getEnvInt("RETENTION_INTERVAL_MINUTES", 0)) * time.Minute
+	if retentionInterval > 0 {
+		_, err = jobService.Enqueue(context.Background(), entity.EnqueueJobInput{
+			Type:        entity.JobTypeRetentionRun,
+			RepeatEvery: retentionInterval,
+		})
+		if err != nil {
+			panic("failed to schedule retention: " + err.Error())
+		}
+	}
+	// the key makes the backfill run once, a later start finds it done
+	_, err = jobService.Enqueue(context.Background(), entity.EnqueueJobInput{
+		Type: entity.JobTypePerceptualHashBackfill,
+		Key:  string(entity.JobTypePerceptualHashBackfill),
+	})
+	if err != nil {
+		panic("failed to schedule the perceptual hash backfill: " + err.Error())
+	}
+	loanExpiryInterval := time.Duration(getEnvPositiveInt("LOAN_EXPIRY_INTERVAL_MINUTES", 15)) * time.Minute
+	_, err = jobService.Enqueue(context.Background(), entity.EnqueueJobInput{
+		Type:        entity.JobTypeLoanExpiry,
+		RepeatEvery: loanExpiryInterval,
+	})
+	if err != nil {
+		panic("failed to schedule loan expiry: " + err.Error())
+	}
 
 	loanHandler := rest.NewLoanHandler(loanService)
 	loanInvestmentHandler := rest.NewLoanInvestmentHandler(loanInvestmentService)
 	fileHandler := rest.NewFileHandler(fileService)
 	InvestorHandler := rest.NewInvestorHandler(investorService)
+	borrowerHandler := rest.NewBorrowerHandler(borrowerService)
+	loanProductHandler := rest.NewLoanProductHandler(loanProductService)
+	retentionHandler := rest.NewRetentionHandler(retentionService)
+	outboxHandler := rest.NewOutboxHandler(outboxService)
+	jobHandler := rest.NewJobHandler(jobService)
+	notificationHandler := rest.NewNotificationHandler(notificationService)
+	webhookHandler := rest.NewWebhookHandler(webhookService)
+	authHandler := rest.NewAuthHandler(authService)
 	rest.Router(
 		e,
 		loanHandler,
 		loanInvestmentHandler,
 		fileHandler,
 		InvestorHandler,
+		borrowerHandler,
+		loanProductHandler,
+		retentionHandler,
+		outboxHandler,
+		jobHandler,
+		notificationHandler,
+		webhookHandler,
+		authHandler,
 	)
 
 	host := "localhost"
 	port := 3000
 	e.Logger.Fatal(e.Start(fmt.Sprintf("%s:%d", host, port)))
 }
+
+// newNotificationProvider picks the provider of a channel from NOTIFICATION_<CHANNEL>_DRIVER,
+// email defaults to SMTP and the other channels to the fake provider that only writes to a local file.
+func newNotificationProvider(channel entity.NotificationChannel, mailer pkgMail.Mailer) notification.Provider {
+	driver := os.Getenv("NOTIFICATION_" + string(channel) + "_DRIVER")
+	if driver == "" {
+		driver = "fake"
+		if channel == entity.NotificationChannelEmail {
+			driver = "smtp"
+		}
+	}
+	switch {
+	case driver == "fake":
+		fakeProvider := notification.NewFakeProvider()
+		if path := os.Getenv("NOTIFICATION_FAKE_PATH"); path != "" {
+			fakeProvider = fakeProvider.SetPath(path)
+		}
+		return fakeProvider.Build()
+	case driver == "smtp" && channel == entity.NotificationChannelEmail:
+		return notification.NewSMTPProvider().
+			SetMailer(mailer).
+			Build()
+	case driver == "twilio" && (channel == entity.NotificationChannelSMS || channel == entity.NotificationChannelWhatsApp):
+		return notification.NewTwilioProvider().
+			SetConfig(notification.TwilioConfig{
+				AccountSID: os.Getenv("TWILIO_ACCOUNT_SID"),
+				AuthToken:  os.Getenv("TWILIO_AUTH_TOKEN"),
+				From:       os.Getenv("TWILIO_" + string(channel) + "_FROM"),
+			}).
+			SetWhatsApp(channel == entity.NotificationChannelWhatsApp).
+			Build()
+	case driver == "onesignal" && channel == entity.NotificationChannelPush:
+		return notification.NewOneSignalProvider().
+			SetConfig(notification.OneSignalConfig{
+				AppID:  os.Getenv("ONESIGNAL_APP_ID"),
+				APIKey: os.Getenv("ONESIGNAL_API_KEY"),
+			}).
+			Build()
+	}
+	panic("invalid NOTIFICATION_" + string(channel) + "_DRIVER")
+}
+
+// getEnvInt returns fallback when the variable is not set, a value that is not
+// a whole number of zero or more stops the service.
+func getEnvInt(key string, fallback int) int {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		panic(fmt.Sprintf("%s must be a whole number of zero or more, got %q", key, value))
+	}
+	return parsed
+}
+
+// getEnvPositiveInt is getEnvInt for intervals, attempts and sizes, where zero is not a usable value.
+func getEnvPositiveInt(key string, fallback int) int {
+	parsed := getEnvInt(key, fallback)
+	if parsed == 0 {
+		panic(fmt.Sprintf("%s must be greater than zero", key))
+	}
+	return parsed
+}
+
+// requireEnvInt reads the limits where zero turns a check off, so they can not
+// be turned off by leaving the variable out.
+func requireEnvInt(key string) int {
+	if strings.TrimSpace(os.Getenv(key)) == "" {
+		panic(fmt.Sprintf("%s is required, set it to 0 to turn the check off", key))
+	}
+	return getEnvInt(key, 0)
+}
@@ -0,0 +1,132 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/labstack/echo/v4"
+)
+
+func (d LoanHandler) VerifyAgreement(c echo.Context) error {
+	input := entity.VerifyAgreementInput{
+		Code: c.FormValue("code"),
+	}
+	// the pdf is optional, a code alone is enough to look up the agreement
+	file, err := c.FormFile("file")
+	if err == nil {
+		input.File = file
+	} else if err != http.ErrMissingFile && err != http.ErrNotMultipart {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid file",
+		})
+	}
+	if input.File == nil && input.Code == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "file or code is required",
+		})
+	}
+	result, err := d.loanService.VerifyAgreement(c.Request().Context(), input)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"verification": result,
+		},
+	})
+}
+
+func (d LoanHandler) RegenerateAgreement(c echo.Context) error {
+	caller := callerOf(c)
+	if !caller.IsEmployee() {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": entity.ErrAccessDenied.Error()})
+	}
+	id := c.Param("id")
+	parsedID, err := strconv.Atoi(id)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid id",
+		})
+	}
+	var form entity.RegenerateAgreementInput
+	if err := c.Bind(&form); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid JSON",
+		})
+	}
+	form.LoanID = parsedID
+	result, err := d.loanService.RegenerateLoanAgreement(c.Request().Context(), form)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"loan": result,
+		},
+	})
+}
+
+func (d LoanHandler) GetAgreementVersions(c echo.Context) error {
+	id := c.Param("id")
+	parsedID, err := strconv.Atoi(id)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid id",
+		})
+	}
+	input := entity.AgreementDocumentsInput{
+		LoanID: &parsedID,
+	}
+
+	variant := c.QueryParam("variant")
+	if variant != "" {
+		if variant != entity.AggrementLetterVariantDraft && variant != entity.AggrementLetterVariantSign {
+			return c.JSON(http.StatusBadRequest, echo.Map{
+				"error": "Invalid variant",
+			})
+		}
+		input.Variant = &variant
+	}
+
+	investorID := c.QueryParam("investorId")
+	if investorID != "" {
+		parsedInvestorID, err := strconv.Atoi(investorID)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{
+				"error": "Invalid investorId",
+			})
+		}
+		input.InvestorID = &parsedInvestorID
+	}
+
+	language := entity.Language(c.QueryParam("lang"))
+	if language != "" {
+		if !language.IsValid() {
+			return c.JSON(http.StatusBadRequest, echo.Map{
+				"error": "Invalid lang",
+			})
+		}
+		input.Language = &language
+	}
+
+	format := entity.DocumentFormat(c.QueryParam("format"))
+	if format != "" {
+		if !format.IsValid() {
+			return c.JSON(http.StatusBadRequest, echo.Map{
+				"error": "Invalid format",
+			})
+		}
+		input.Format = &format
+	}
+
+	result, err := d.loanService.AgreementVersions(c.Request().Context(), input)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"versions": result,
+		},
+	})
+}
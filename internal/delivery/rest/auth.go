@@ -0,0 +1,89 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/service"
+	"github.com/labstack/echo/v4"
+)
+
+// callerContextKey holds the authenticated caller in the echo context.
+const callerContextKey = "caller"
+
+// accessTokenCookie carries the access token for links opened from an email,
+// the portal sets it when the user logs in.
+const accessTokenCookie = "access_token"
+
+type AuthHandler struct {
+	authService service.AuthService
+}
+
+func NewAuthHandler(
+	authService service.AuthService,
+) AuthHandler {
+	return AuthHandler{
+		authService: authService,
+	}
+}
+
+// IssueAccessToken is called by the gateway once it logged a user in.
+func (d AuthHandler) IssueAccessToken(c echo.Context) error {
+	var form entity.IssueAccessTokenInput
+	if err := c.Bind(&form); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid request body",
+		})
+	}
+	form.APIKey = c.Request().Header.Get("X-Api-Key")
+
+	result, err := d.authService.IssueAccessToken(c.Request().Context(), form)
+	if errors.Is(err, entity.ErrAccessDenied) {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "api key is invalid"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"access_token": result,
+		},
+	})
+}
+
+// Authenticate rejects requests without a valid access token and keeps the caller for the handler.
+func (d AuthHandler) Authenticate(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		token, found := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+		if !found {
+			if cookie, err := c.Cookie(accessTokenCookie); err == nil {
+				token = cookie.Value
+			}
+		}
+		if token == "" {
+			return c.JSON(http.StatusUnauthorized, echo.Map{"error": "access token is required"})
+		}
+		caller, err := d.authService.Authenticate(c.Request().Context(), token)
+		if err != nil {
+			return c.JSON(http.StatusUnauthorized, echo.Map{"error": err.Error()})
+		}
+		c.Set(callerContextKey, caller)
+		return next(c)
+	}
+}
+
+// callerOf returns the caller Authenticate stored, a zero caller on routes without it.
+func callerOf(c echo.Context) entity.Caller {
+	caller, _ := c.Get(callerContextKey).(entity.Caller)
+	return caller
+}
+
+// errorStatus maps a denied access to 403, every other error to status.
+func errorStatus(err error, status int) int {
+	if errors.Is(err, entity.ErrAccessDenied) {
+		return http.StatusForbidden
+	}
+	return status
+}
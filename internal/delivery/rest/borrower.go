@@ -0,0 +1,76 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/service"
+	"github.com/labstack/echo/v4"
+)
+
+type BorrowerHandler struct {
+	borrowerService service.BorrowerService
+}
+
+func NewBorrowerHandler(
+	borrowerService service.BorrowerService,
+) BorrowerHandler {
+	return BorrowerHandler{
+		borrowerService: borrowerService,
+	}
+}
+
+func (d BorrowerHandler) AddBorrower(c echo.Context) error {
+	var form entity.AddBorrowerInput
+	if err := c.Bind(&form); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid JSON",
+		})
+	}
+
+	result, err := d.borrowerService.AddBorrower(c.Request().Context(), form)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"borrower": result,
+		},
+	})
+}
+
+func (d BorrowerHandler) GetBorrowers(c echo.Context) error {
+	var filter entity.BorrowersInput
+	result, err := d.borrowerService.Borrowers(c.Request().Context(), filter)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"borrowers": result,
+		},
+	})
+}
+
+func (d BorrowerHandler) GetBorrower(c echo.Context) error {
+	id := c.Param("id")
+	parsedID, err := strconv.Atoi(id)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid id",
+		})
+	}
+	result, err := d.borrowerService.Borrower(c.Request().Context(), entity.BorrowerInput{
+		ID: &parsedID,
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"borrower": result,
+		},
+	})
+}
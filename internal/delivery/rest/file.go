@@ -1,7 +1,11 @@
 package rest
 
 import (
+	"errors"
 	"net/http"
+	"path"
+	"strconv"
+	"time"
 
 	"github.com/adityaokke/test-amartha/internal/entity"
 	"github.com/adityaokke/test-amartha/internal/service"
@@ -26,18 +30,244 @@ func (d FileHandler) Upload(c echo.Context) error {
 		return c.String(http.StatusBadRequest, "missing file")
 	}
 
-	fileURL, err := d.fileService.UploadFile(c.Request().Context(), entity.UploadFileInput{
-		File: file,
-	})
+	input := entity.UploadFileInput{
+		File:    file,
+		Purpose: entity.FilePurpose(c.FormValue("purpose")),
+	}
+	// an employee uploads as themselves, nobody else can upload on behalf of an employee
+	caller := callerOf(c)
+	employeeID := c.FormValue("employeeId")
+	if employeeID != "" {
+		input.EmployeeID, err = strconv.Atoi(employeeID)
+		if err != nil {
+			return c.String(http.StatusBadRequest, "invalid employeeId")
+		}
+		if !caller.IsEmployee() || input.EmployeeID != caller.SubjectID {
+			return c.String(http.StatusForbidden, "employeeId does not match the caller")
+		}
+	}
+	if caller.IsEmployee() {
+		input.EmployeeID = caller.SubjectID
+	}
+	loanID := c.FormValue("loanId")
+	if loanID != "" {
+		input.LoanID, err = strconv.Atoi(loanID)
+		if err != nil {
+			return c.String(http.StatusBadRequest, "invalid loanId")
+		}
+	}
+	investorID := c.FormValue("investorId")
+	if investorID != "" {
+		input.InvestorID, err = strconv.Atoi(investorID)
+		if err != nil {
+			return c.String(http.StatusBadRequest, "invalid investorId")
+		}
+	}
+
+	result, err := d.fileService.UploadFile(c.Request().Context(), input)
 	if err != nil {
 		return c.String(http.StatusBadRequest, err.Error())
 	}
+	// the file url identifies the upload, reading it back needs a signed link
+	downloadURL, err := d.fileService.DownloadURL(c.Request().Context(), result.URL, caller.Grant(0))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	variantURLs, err := d.variantDownloadURLs(c, result, caller.Grant(0))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"file":        result,
+			"downloadUrl": downloadURL,
+			"variantUrls": variantURLs,
+		},
+	})
+}
+
+// variantDownloadURLs signs a download link for every resized copy of an image upload, keyed by variant kind.
+func (d FileHandler) variantDownloadURLs(c echo.Context, file entity.File, grant entity.DownloadGrant) (result map[entity.FileVariantKind]string, err error) {
+	result = map[entity.FileVariantKind]string{}
+	for _, variant := range file.Variants {
+		result[variant.Kind], err = d.fileService.DownloadURL(c.Request().Context(), variant.URL, grant)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (d FileHandler) GetFiles(c echo.Context) error {
+	caller := callerOf(c)
+	if !caller.IsEmployee() {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": entity.ErrAccessDenied.Error()})
+	}
+	input := entity.FilesInput{}
+
+	loanID := c.QueryParam("loanId")
+	if loanID != "" {
+		parsedLoanID, err := strconv.Atoi(loanID)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{
+				"error": "Invalid loanId",
+			})
+		}
+		input.LoanID = &parsedLoanID
+	}
+
+	investorID := c.QueryParam("investorId")
+	if investorID != "" {
+		parsedInvestorID, err := strconv.Atoi(investorID)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{
+				"error": "Invalid investorId",
+			})
+		}
+		input.InvestorID = &parsedInvestorID
+	}
+
+	purpose := entity.FilePurpose(c.QueryParam("purpose"))
+	if purpose != "" {
+		if !purpose.IsValid() {
+			return c.JSON(http.StatusBadRequest, echo.Map{
+				"error": "Invalid purpose",
+			})
+		}
+		input.Purpose = &purpose
+	}
+
+	result, err := d.fileService.Files(c.Request().Context(), input)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"files": result,
+		},
+	})
+}
+
+func (d FileHandler) GetFile(c echo.Context) error {
+	caller := callerOf(c)
+	if !caller.IsEmployee() {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": entity.ErrAccessDenied.Error()})
+	}
+	id := c.Param("id")
+	parsedID, err := strconv.Atoi(id)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid id",
+		})
+	}
 
+	result, err := d.fileService.File(c.Request().Context(), entity.FileInput{
+		ID: &parsedID,
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	grant := caller.Grant(0)
+	downloadURL, err := d.fileService.DownloadURL(c.Request().Context(), result.URL, grant)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	variantURLs, err := d.variantDownloadURLs(c, result, grant)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"data": map[string]interface{}{
-			"file": map[string]interface{}{
-				"url": fileURL,
-			},
+			"file":        result,
+			"downloadUrl": downloadURL,
+			"variantUrls": variantURLs,
 		},
 	})
 }
+
+// ReviewFile records whether an employee approved or rejected a KYC document.
+func (d FileHandler) ReviewFile(c echo.Context) error {
+	caller := callerOf(c)
+	if !caller.IsEmployee() {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": entity.ErrAccessDenied.Error()})
+	}
+	id := c.Param("id")
+	parsedID, err := strconv.Atoi(id)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid id",
+		})
+	}
+	var form entity.ReviewFileInput
+	if err := c.Bind(&form); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid request body",
+		})
+	}
+	form.ID = parsedID
+	form.EmployeeID = caller.SubjectID
+
+	result, err := d.fileService.ReviewFile(c.Request().Context(), form)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"file": result,
+		},
+	})
+}
+
+// presignExpiry caps how long a redirect to the storage backend stays valid.
+const presignExpiry = 15 * time.Minute
+
+// Download serves a stored object to the holder of a signed download url,
+// the files are not reachable under their stored url anymore.
+func (d FileHandler) Download(c echo.Context) error {
+	prefix := c.Param("prefix")
+	name := c.Param("name")
+	if (prefix != entity.UploadStoragePrefix && prefix != entity.AgreementStoragePrefix && prefix != entity.VariantStoragePrefix) || name == "" || name != path.Base(name) {
+		return echo.ErrNotFound
+	}
+	input := entity.DownloadInput{
+		Key:       path.Join(prefix, name),
+		Audience:  entity.DownloadAudience(c.QueryParam("aud")),
+		Signature: c.QueryParam("sig"),
+		Caller:    callerOf(c),
+	}
+	var err error
+	input.SubjectID, err = strconv.Atoi(c.QueryParam("sub"))
+	if err != nil {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": "download link is invalid"})
+	}
+	input.ExpiresAt, err = strconv.ParseInt(c.QueryParam("exp"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": "download link is invalid"})
+	}
+	err = d.fileService.VerifyDownload(c.Request().Context(), input)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": err.Error()})
+	}
+	c.Response().Header().Set(echo.HeaderCacheControl, "private, no-store")
+
+	// backends that can hand out urls are not proxied through this service
+	expiry := min(time.Until(time.Unix(input.ExpiresAt, 0)), presignExpiry)
+	presignedURL, err := d.fileService.PresignObject(c.Request().Context(), input.Key, expiry)
+	if err == nil {
+		return c.Redirect(http.StatusFound, presignedURL)
+	}
+	if !errors.Is(err, entity.ErrPresignNotSupported) {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	src, object, err := d.fileService.OpenObject(c.Request().Context(), input.Key)
+	if errors.Is(err, entity.ErrStorageObjectNotFound) {
+		return echo.ErrNotFound
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	defer src.Close()
+	return c.Stream(http.StatusOK, object.ContentType, src)
+}
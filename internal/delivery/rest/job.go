@@ -0,0 +1,127 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/service"
+	"github.com/labstack/echo/v4"
+)
+
+type JobHandler struct {
+	jobService service.JobService
+}
+
+func NewJobHandler(
+	jobService service.JobService,
+) JobHandler {
+	return JobHandler{
+		jobService: jobService,
+	}
+}
+
+func (d JobHandler) GetJobs(c echo.Context) error {
+	caller := callerOf(c)
+	if !caller.IsEmployee() {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": entity.ErrAccessDenied.Error()})
+	}
+	input := entity.JobsInput{}
+
+	status := entity.JobStatus(c.QueryParam("status"))
+	if status != "" {
+		if !status.IsValid() {
+			return c.JSON(http.StatusBadRequest, echo.Map{
+				"error": "Invalid status",
+			})
+		}
+		input.Status = &status
+	}
+
+	jobType := entity.JobType(c.QueryParam("type"))
+	if jobType != "" {
+		input.Type = &jobType
+	}
+
+	result, err := d.jobService.Jobs(c.Request().Context(), input)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"jobs": result,
+		},
+	})
+}
+
+func (d JobHandler) GetJob(c echo.Context) error {
+	caller := callerOf(c)
+	if !caller.IsEmployee() {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": entity.ErrAccessDenied.Error()})
+	}
+	id := c.Param("id")
+	parsedID, err := strconv.Atoi(id)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid id",
+		})
+	}
+
+	result, err := d.jobService.Job(c.Request().Context(), parsedID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"job": result,
+		},
+	})
+}
+
+func (d JobHandler) RetryJob(c echo.Context) error {
+	caller := callerOf(c)
+	if !caller.IsEmployee() {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": entity.ErrAccessDenied.Error()})
+	}
+	id := c.Param("id")
+	parsedID, err := strconv.Atoi(id)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid id",
+		})
+	}
+
+	result, err := d.jobService.RetryJob(c.Request().Context(), parsedID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"job": result,
+		},
+	})
+}
+
+func (d JobHandler) CancelJob(c echo.Context) error {
+	caller := callerOf(c)
+	if !caller.IsEmployee() {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": entity.ErrAccessDenied.Error()})
+	}
+	id := c.Param("id")
+	parsedID, err := strconv.Atoi(id)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid id",
+		})
+	}
+
+	result, err := d.jobService.CancelJob(c.Request().Context(), parsedID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"job": result,
+		},
+	})
+}
@@ -1,8 +1,10 @@
 package rest
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/adityaokke/test-amartha/internal/entity"
 	"github.com/adityaokke/test-amartha/internal/service"
@@ -30,6 +32,13 @@ func (d LoanHandler) ProposeLoan(c echo.Context) error {
 	}
 
 	result, err := d.loanService.ProposeLoan(c.Request().Context(), form)
+	var eligibilityErr entity.EligibilityError
+	if errors.As(err, &eligibilityErr) {
+		return c.JSON(http.StatusUnprocessableEntity, echo.Map{
+			"error":   "loan proposal is not eligible",
+			"reasons": eligibilityErr.Reasons,
+		})
+	}
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
@@ -178,18 +187,52 @@ func (d LoanHandler) GetAgreementLetter(c echo.Context) error {
 		})
 	}
 
+	options := entity.AgreementLetterOptions{
+		Language: entity.Language(c.QueryParam("lang")),
+		Format:   entity.DocumentFormat(c.QueryParam("format")),
+	}
+	if options.Language != "" && !options.Language.IsValid() {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid lang",
+		})
+	}
+	if options.Format == "" {
+		options.Format = acceptedDocumentFormat(c.Request().Header.Get(echo.HeaderAccept))
+	}
+	if !options.Format.IsValid() {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid format",
+		})
+	}
+
+	// an investor only gets the letter that covers their own participation
+	investorID := c.QueryParam("investorId")
+	if investorID != "" {
+		parsedInvestorID, err := strconv.Atoi(investorID)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{
+				"error": "Invalid investorId",
+			})
+		}
+		result, err := d.loanService.GetInvestorLoanAgreementLetter(c.Request().Context(), callerOf(c), parsedID, parsedInvestorID, options)
+		if err != nil {
+			return c.JSON(errorStatus(err, http.StatusInternalServerError), echo.Map{"error": err.Error()})
+		}
+		return c.Redirect(http.StatusFound, result)
+	}
+
 	variant := c.QueryParam("variant")
 	result := ""
 	switch variant {
 	case entity.AggrementLetterVariantDraft:
-		result, err = d.loanService.GetDraftLoanAgreementLetter(c.Request().Context(), parsedID)
+		result, err = d.loanService.GetDraftLoanAgreementLetter(c.Request().Context(), callerOf(c), parsedID, options)
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+			return c.JSON(errorStatus(err, http.StatusInternalServerError), echo.Map{"error": err.Error()})
 		}
 	case entity.AggrementLetterVariantSign:
-		result, err = d.loanService.GetSignedLoanAgreementLetter(c.Request().Context(), parsedID)
+		result, err = d.loanService.GetSignedLoanAgreementLetter(c.Request().Context(), callerOf(c), parsedID, options)
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+			return c.JSON(errorStatus(err, http.StatusInternalServerError), echo.Map{"error": err.Error()})
 		}
 	default:
 		return c.JSON(http.StatusBadRequest, echo.Map{
@@ -200,6 +243,66 @@ func (d LoanHandler) GetAgreementLetter(c echo.Context) error {
 	return c.Redirect(http.StatusFound, result)
 }
 
+// acceptedDocumentFormat picks the first agreement format named in an Accept
+// header, falling back to pdf when none of them is listed.
+func acceptedDocumentFormat(accept string) entity.DocumentFormat {
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType := strings.TrimSpace(strings.Split(mediaRange, ";")[0])
+		for _, format := range []entity.DocumentFormat{entity.DocumentFormatPDF, entity.DocumentFormatHTML, entity.DocumentFormatDOCX} {
+			if mediaType == format.ContentType() {
+				return format
+			}
+		}
+	}
+	return entity.DocumentFormatPDF
+}
+
+func (d LoanHandler) GetPhotoProofDuplicates(c echo.Context) error {
+	input := entity.PhotoProofDuplicatesInput{}
+
+	loanID := c.QueryParam("loanId")
+	if loanID != "" {
+		loanIDParsed, err := strconv.Atoi(loanID)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{
+				"error": "Invalid loanId",
+			})
+		}
+		input.LoanID = &loanIDParsed
+	}
+
+	employeeID := c.QueryParam("employeeId")
+	if employeeID != "" {
+		employeeIDParsed, err := strconv.Atoi(employeeID)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{
+				"error": "Invalid employeeId",
+			})
+		}
+		input.EmployeeID = &employeeIDParsed
+	}
+
+	action := entity.PhotoProofDuplicateAction(c.QueryParam("action"))
+	if action != "" {
+		if !action.IsValid() {
+			return c.JSON(http.StatusBadRequest, echo.Map{
+				"error": "Invalid action",
+			})
+		}
+		input.Action = &action
+	}
+
+	result, err := d.loanService.PhotoProofDuplicates(c.Request().Context(), input)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"photo_proof_duplicates": result,
+		},
+	})
+}
+
 func (d LoanHandler) GetLoanQuotes(c echo.Context) error {
 	id := c.Param("id")
 	parsedID, err := strconv.Atoi(id)
@@ -209,9 +312,9 @@ func (d LoanHandler) GetLoanQuotes(c echo.Context) error {
 		})
 	}
 
-	result, err := d.loanService.GetLoanQuote(c.Request().Context(), parsedID)
+	result, err := d.loanService.GetLoanQuote(c.Request().Context(), callerOf(c), parsedID)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		return c.JSON(errorStatus(err, http.StatusInternalServerError), echo.Map{"error": err.Error()})
 	}
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"data": map[string]interface{}{
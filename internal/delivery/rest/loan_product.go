@@ -0,0 +1,117 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/service"
+	"github.com/labstack/echo/v4"
+)
+
+type LoanProductHandler struct {
+	loanProductService service.LoanProductService
+}
+
+func NewLoanProductHandler(
+	loanProductService service.LoanProductService,
+) LoanProductHandler {
+	return LoanProductHandler{
+		loanProductService: loanProductService,
+	}
+}
+
+func (d LoanProductHandler) AddLoanProduct(c echo.Context) error {
+	var form entity.AddLoanProductInput
+	if err := c.Bind(&form); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid JSON",
+		})
+	}
+
+	result, err := d.loanProductService.AddLoanProduct(c.Request().Context(), form)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"loan_product": result,
+		},
+	})
+}
+
+func (d LoanProductHandler) UpdateLoanProduct(c echo.Context) error {
+	id := c.Param("id")
+	parsedID, err := strconv.Atoi(id)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid id",
+		})
+	}
+
+	var form entity.UpdateLoanProductInput
+	if err := c.Bind(&form); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid JSON",
+		})
+	}
+	form.ID = parsedID
+
+	result, err := d.loanProductService.UpdateLoanProduct(c.Request().Context(), form)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"loan_product": result,
+		},
+	})
+}
+
+func (d LoanProductHandler) GetLoanProducts(c echo.Context) error {
+	input := entity.LoanProductsInput{}
+
+	isActive := c.QueryParam("isActive")
+	if isActive != "" {
+		isActiveParsed, err := strconv.ParseBool(isActive)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{
+				"error": "Invalid isActive",
+			})
+		}
+		input.IsActive = &isActiveParsed
+	}
+
+	result, err := d.loanProductService.LoanProducts(c.Request().Context(), input)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"loan_products": result,
+		},
+	})
+}
+
+func (d LoanProductHandler) GetLoanProduct(c echo.Context) error {
+	id := c.Param("id")
+	parsedID, err := strconv.Atoi(id)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid id",
+		})
+	}
+	result, err := d.loanProductService.LoanProduct(c.Request().Context(), entity.LoanProductInput{
+		ID: &parsedID,
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"loan_product": result,
+		},
+	})
+}
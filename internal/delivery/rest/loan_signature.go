@@ -0,0 +1,75 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/labstack/echo/v4"
+)
+
+func (d LoanHandler) GetAgreementSigningRequest(c echo.Context) error {
+	result, err := d.loanService.AgreementSigningRequest(c.Request().Context(), c.Param("token"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"signature":     result.Signature,
+			"agreement_url": result.AgreementURL,
+		},
+	})
+}
+
+func (d LoanHandler) SignAgreement(c echo.Context) error {
+	var form entity.SignAgreementInput
+	if err := c.Bind(&form); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid JSON",
+		})
+	}
+	form.Token = c.Param("token")
+	form.IPAddress = c.RealIP()
+	form.UserAgent = c.Request().UserAgent()
+	result, err := d.loanService.SignAgreement(c.Request().Context(), form)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"signature": result,
+		},
+	})
+}
+
+func (d LoanHandler) RenewSigningLink(c echo.Context) error {
+	err := d.loanService.RenewSigningLink(c.Request().Context(), c.Param("token"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	// the new link only goes to the signer, it is never returned here
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"message": "a new signing link is sent to the signer",
+		},
+	})
+}
+
+func (d LoanHandler) GetAgreementSignatures(c echo.Context) error {
+	id := c.Param("id")
+	parsedID, err := strconv.Atoi(id)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid id",
+		})
+	}
+	result, err := d.loanService.AgreementSignatures(c.Request().Context(), parsedID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"signatures": result,
+		},
+	})
+}
@@ -0,0 +1,206 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/service"
+	"github.com/labstack/echo/v4"
+)
+
+type NotificationHandler struct {
+	notificationService service.NotificationService
+}
+
+func NewNotificationHandler(
+	notificationService service.NotificationService,
+) NotificationHandler {
+	return NotificationHandler{
+		notificationService: notificationService,
+	}
+}
+
+func (d NotificationHandler) GetNotifications(c echo.Context) error {
+	caller := callerOf(c)
+	if !caller.IsEmployee() {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": entity.ErrAccessDenied.Error()})
+	}
+	input := entity.NotificationsInput{}
+
+	channel := entity.NotificationChannel(c.QueryParam("channel"))
+	if channel != "" {
+		if !channel.IsValid() {
+			return c.JSON(http.StatusBadRequest, echo.Map{
+				"error": "Invalid channel",
+			})
+		}
+		input.Channel = &channel
+	}
+	return d.getNotifications(c, input, "notifications")
+}
+
+// GetEmails lists the email channel of the notification log.
+func (d NotificationHandler) GetEmails(c echo.Context) error {
+	caller := callerOf(c)
+	if !caller.IsEmployee() {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": entity.ErrAccessDenied.Error()})
+	}
+	channel := entity.NotificationChannelEmail
+	input := entity.NotificationsInput{
+		Channel: &channel,
+	}
+	return d.getNotifications(c, input, "emails")
+}
+
+func (d NotificationHandler) getNotifications(c echo.Context, input entity.NotificationsInput, key string) error {
+	loanID := c.QueryParam("loanId")
+	if loanID != "" {
+		parsedLoanID, err := strconv.Atoi(loanID)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{
+				"error": "Invalid loanId",
+			})
+		}
+		input.LoanID = &parsedLoanID
+	}
+
+	status := entity.NotificationStatus(c.QueryParam("status"))
+	if status != "" {
+		if !status.IsValid() {
+			return c.JSON(http.StatusBadRequest, echo.Map{
+				"error": "Invalid status",
+			})
+		}
+		input.Status = &status
+	}
+
+	recipient := c.QueryParam("recipient")
+	if recipient != "" {
+		input.Recipient = &recipient
+	}
+
+	result, err := d.notificationService.Notifications(c.Request().Context(), input)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			key: result,
+		},
+	})
+}
+
+func (d NotificationHandler) ResendNotification(c echo.Context) error {
+	caller := callerOf(c)
+	if !caller.IsEmployee() {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": entity.ErrAccessDenied.Error()})
+	}
+	id := c.Param("id")
+	parsedID, err := strconv.Atoi(id)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid id",
+		})
+	}
+
+	result, err := d.notificationService.ResendNotification(c.Request().Context(), parsedID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"notification": result,
+		},
+	})
+}
+
+func (d NotificationHandler) GetNotificationPreference(c echo.Context) error {
+	recipientType := entity.NotificationRecipientType(c.QueryParam("recipientType"))
+	if !recipientType.IsValid() {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid recipientType",
+		})
+	}
+	recipientID, err := strconv.Atoi(c.QueryParam("recipientId"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid recipientId",
+		})
+	}
+	caller := callerOf(c)
+	if !caller.IsEmployee() && !caller.IsRecipient(recipientType, recipientID) {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": entity.ErrAccessDenied.Error()})
+	}
+
+	result, err := d.notificationService.NotificationPreference(c.Request().Context(), recipientType, recipientID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"preference": result,
+		},
+	})
+}
+
+func (d NotificationHandler) SetNotificationPreference(c echo.Context) error {
+	var form entity.SetNotificationPreferenceInput
+	if err := c.Bind(&form); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid JSON",
+		})
+	}
+	// investors and borrowers only choose their own channels, employees set them for anyone
+	caller := callerOf(c)
+	if !caller.IsEmployee() && !caller.IsRecipient(form.RecipientType, form.RecipientID) {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": entity.ErrAccessDenied.Error()})
+	}
+
+	result, err := d.notificationService.SetNotificationPreference(c.Request().Context(), form)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"preference": result,
+		},
+	})
+}
+
+// PreviewNotification renders a template with sample data. With format=html or format=text the
+// email body is answered as is, so it can be opened in a browser.
+func (d NotificationHandler) PreviewNotification(c echo.Context) error {
+	input := entity.PreviewNotificationInput{
+		Channel:  entity.NotificationChannel(c.QueryParam("channel")),
+		Template: entity.NotificationTemplate(c.Param("template")),
+		Language: entity.Language(c.QueryParam("language")),
+	}
+	if input.Channel == "" {
+		input.Channel = entity.NotificationChannelEmail
+	}
+	format := c.QueryParam("format")
+	if format != "" && format != "json" && format != "html" && format != "text" {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid format",
+		})
+	}
+
+	result, err := d.notificationService.PreviewNotification(c.Request().Context(), input)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	switch {
+	case format == "html" && input.Channel == entity.NotificationChannelEmail:
+		return c.HTML(http.StatusOK, result.Body)
+	case format == "text" && input.Channel == entity.NotificationChannelEmail:
+		return c.String(http.StatusOK, result.TextBody)
+	case format == "html", format == "text":
+		return c.String(http.StatusOK, result.Body)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"message": result,
+		},
+	})
+}
@@ -0,0 +1,95 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/service"
+	"github.com/labstack/echo/v4"
+)
+
+type OutboxHandler struct {
+	outboxService service.OutboxService
+}
+
+func NewOutboxHandler(
+	outboxService service.OutboxService,
+) OutboxHandler {
+	return OutboxHandler{
+		outboxService: outboxService,
+	}
+}
+
+func (d OutboxHandler) GetOutboxEvents(c echo.Context) error {
+	caller := callerOf(c)
+	if !caller.IsEmployee() {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": entity.ErrAccessDenied.Error()})
+	}
+	input := entity.OutboxEventsInput{}
+
+	status := entity.OutboxEventStatus(c.QueryParam("status"))
+	if status != "" {
+		if !status.IsValid() {
+			return c.JSON(http.StatusBadRequest, echo.Map{
+				"error": "Invalid status",
+			})
+		}
+		input.Status = &status
+	}
+
+	eventType := entity.OutboxEventType(c.QueryParam("type"))
+	if eventType != "" {
+		if !eventType.IsValid() {
+			return c.JSON(http.StatusBadRequest, echo.Map{
+				"error": "Invalid type",
+			})
+		}
+		input.Type = &eventType
+	}
+
+	loanID := c.QueryParam("loanId")
+	if loanID != "" {
+		parsedLoanID, err := strconv.Atoi(loanID)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{
+				"error": "Invalid loanId",
+			})
+		}
+		input.LoanID = &parsedLoanID
+	}
+
+	result, err := d.outboxService.OutboxEvents(c.Request().Context(), input)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"events": result,
+		},
+	})
+}
+
+func (d OutboxHandler) RetryOutboxEvent(c echo.Context) error {
+	caller := callerOf(c)
+	if !caller.IsEmployee() {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": entity.ErrAccessDenied.Error()})
+	}
+	id := c.Param("id")
+	parsedID, err := strconv.Atoi(id)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid id",
+		})
+	}
+
+	result, err := d.outboxService.RetryOutboxEvent(c.Request().Context(), parsedID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"event": result,
+		},
+	})
+}
@@ -1,9 +1,6 @@
 package rest
 
 import (
-	"fmt"
-
-	"github.com/adityaokke/test-amartha/internal/entity"
 	echo "github.com/labstack/echo/v4"
 )
 
@@ -13,18 +10,67 @@ func Router(
 	loanInvestmentHandler LoanInvestmentHandler,
 	fileHandler FileHandler,
 	InvestorHandler InvestorHandler,
+	borrowerHandler BorrowerHandler,
+	loanProductHandler LoanProductHandler,
+	retentionHandler RetentionHandler,
+	outboxHandler OutboxHandler,
+	jobHandler JobHandler,
+	notificationHandler NotificationHandler,
+	webhookHandler WebhookHandler,
+	authHandler AuthHandler,
 ) {
-	e.POST("/files", fileHandler.Upload)
+	// routes that hand out download links are only served to an authenticated caller
+	auth := authHandler.Authenticate
+	e.POST("/auth/tokens", authHandler.IssueAccessToken)
+	e.POST("/files", fileHandler.Upload, auth)
+	e.GET("/files", fileHandler.GetFiles, auth)
+	e.GET("/files/:id", fileHandler.GetFile, auth)
+	e.POST("/files/:id/review", fileHandler.ReviewFile, auth)
 	e.POST("/loans", loanHandler.ProposeLoan)
 	e.GET("loans", loanHandler.GetLoans)
 	e.GET("/loans/:id", loanHandler.GetLoan)
 	e.PATCH("/loans/:id", loanHandler.PatchLoan)
 	e.POST("/loans/:id/investments", loanHandler.InvestLoan)
 	e.GET("/loans/:id/investments", loanInvestmentHandler.GetLoanInvestments)
-	e.Static(fmt.Sprintf("/%s", entity.PublicUploadPath), entity.LocalUploadPath)
-	e.Static(fmt.Sprintf("/%s", entity.PublicAggrementLetterPath), entity.LocalAggrementLetterPath)
+	e.GET("/downloads/:prefix/:name", fileHandler.Download, auth)
 	e.POST("/investors", InvestorHandler.AddInvestor)
 	e.GET("/investors", InvestorHandler.GetInvestors)
-	e.GET("/loans/:id/agreement/contents", loanHandler.GetAgreementLetter)
-	e.GET("/loans/:id/quotes", loanHandler.GetLoanQuotes)
+	e.POST("/borrowers", borrowerHandler.AddBorrower)
+	e.GET("/borrowers", borrowerHandler.GetBorrowers)
+	e.GET("/borrowers/:id", borrowerHandler.GetBorrower)
+	e.POST("/loan-products", loanProductHandler.AddLoanProduct)
+	e.GET("/loan-products", loanProductHandler.GetLoanProducts)
+	e.GET("/loan-products/:id", loanProductHandler.GetLoanProduct)
+	e.PUT("/loan-products/:id", loanProductHandler.UpdateLoanProduct)
+	e.GET("/loans/:id/agreement/contents", loanHandler.GetAgreementLetter, auth)
+	e.POST("/loans/:id/agreement/regenerate", loanHandler.RegenerateAgreement, auth)
+	e.GET("/loans/:id/agreement/versions", loanHandler.GetAgreementVersions)
+	e.GET("/loans/:id/quotes", loanHandler.GetLoanQuotes, auth)
+	e.GET("/photo-proofs/duplicates", loanHandler.GetPhotoProofDuplicates)
+	e.GET("/loans/:id/signatures", loanHandler.GetAgreementSignatures)
+	e.GET("/signatures/:token", loanHandler.GetAgreementSigningRequest)
+	e.POST("/signatures/:token", loanHandler.SignAgreement)
+	e.POST("/signatures/:token/renew", loanHandler.RenewSigningLink)
+	e.GET("/agreements/verify", loanHandler.VerifyAgreement)
+	e.POST("/agreements/verify", loanHandler.VerifyAgreement)
+	e.POST("/retention/run", retentionHandler.RunRetention, auth)
+	e.GET("/outbox-events", outboxHandler.GetOutboxEvents, auth)
+	e.POST("/outbox-events/:id/retry", outboxHandler.RetryOutboxEvent, auth)
+	e.GET("/jobs", jobHandler.GetJobs, auth)
+	e.GET("/jobs/:id", jobHandler.GetJob, auth)
+	e.POST("/jobs/:id/retry", jobHandler.RetryJob, auth)
+	e.POST("/jobs/:id/cancel", jobHandler.CancelJob, auth)
+	e.GET("/emails", notificationHandler.GetEmails, auth)
+	e.POST("/emails/:id/resend", notificationHandler.ResendNotification, auth)
+	e.GET("/notifications", notificationHandler.GetNotifications, auth)
+	e.POST("/notifications/:id/resend", notificationHandler.ResendNotification, auth)
+	e.GET("/notification-preferences", notificationHandler.GetNotificationPreference, auth)
+	e.PUT("/notification-preferences", notificationHandler.SetNotificationPreference, auth)
+	e.GET("/notification-templates/:template/preview", notificationHandler.PreviewNotification)
+	e.POST("/webhooks", webhookHandler.AddWebhookSubscription, auth)
+	e.GET("/webhooks", webhookHandler.GetWebhookSubscriptions, auth)
+	e.GET("/webhooks/:id", webhookHandler.GetWebhookSubscription, auth)
+	e.PUT("/webhooks/:id", webhookHandler.UpdateWebhookSubscription, auth)
+	e.GET("/webhook-deliveries", webhookHandler.GetWebhookDeliveries, auth)
+	e.POST("/webhook-deliveries/:id/replay", webhookHandler.ReplayWebhookDelivery, auth)
 }
@@ -0,0 +1,53 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/service"
+	"github.com/labstack/echo/v4"
+)
+
+type RetentionHandler struct {
+	retentionService service.RetentionService
+}
+
+func NewRetentionHandler(
+	retentionService service.RetentionService,
+) RetentionHandler {
+	return RetentionHandler{
+		retentionService: retentionService,
+	}
+}
+
+// RunRetention only reports what would be deleted unless dryRun=false is passed.
+func (d RetentionHandler) RunRetention(c echo.Context) error {
+	caller := callerOf(c)
+	if !caller.IsEmployee() {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": entity.ErrAccessDenied.Error()})
+	}
+	input := entity.RetentionRunInput{
+		DryRun: true,
+	}
+	dryRun := c.QueryParam("dryRun")
+	if dryRun != "" {
+		parsedDryRun, err := strconv.ParseBool(dryRun)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{
+				"error": "Invalid dryRun",
+			})
+		}
+		input.DryRun = parsedDryRun
+	}
+
+	result, err := d.retentionService.Run(c.Request().Context(), input)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"report": result,
+		},
+	})
+}
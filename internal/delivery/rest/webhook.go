@@ -0,0 +1,216 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/service"
+	"github.com/labstack/echo/v4"
+)
+
+type WebhookHandler struct {
+	webhookService service.WebhookService
+}
+
+func NewWebhookHandler(
+	webhookService service.WebhookService,
+) WebhookHandler {
+	return WebhookHandler{
+		webhookService: webhookService,
+	}
+}
+
+// AddWebhookSubscription answers with the signing secret, it is not shown again afterwards.
+func (d WebhookHandler) AddWebhookSubscription(c echo.Context) error {
+	caller := callerOf(c)
+	if !caller.IsEmployee() {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": entity.ErrAccessDenied.Error()})
+	}
+	var form entity.AddWebhookSubscriptionInput
+	if err := c.Bind(&form); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid JSON",
+		})
+	}
+
+	result, err := d.webhookService.AddWebhookSubscription(c.Request().Context(), form)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"subscription": result,
+			"secret":       result.Secret,
+		},
+	})
+}
+
+func (d WebhookHandler) UpdateWebhookSubscription(c echo.Context) error {
+	caller := callerOf(c)
+	if !caller.IsEmployee() {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": entity.ErrAccessDenied.Error()})
+	}
+	id := c.Param("id")
+	parsedID, err := strconv.Atoi(id)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid id",
+		})
+	}
+
+	var form entity.UpdateWebhookSubscriptionInput
+	if err := c.Bind(&form); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid JSON",
+		})
+	}
+	form.ID = parsedID
+
+	result, err := d.webhookService.UpdateWebhookSubscription(c.Request().Context(), form)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"subscription": result,
+		},
+	})
+}
+
+func (d WebhookHandler) GetWebhookSubscriptions(c echo.Context) error {
+	caller := callerOf(c)
+	if !caller.IsEmployee() {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": entity.ErrAccessDenied.Error()})
+	}
+	input := entity.WebhookSubscriptionsInput{}
+
+	isActive := c.QueryParam("isActive")
+	if isActive != "" {
+		parsedIsActive, err := strconv.ParseBool(isActive)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{
+				"error": "Invalid isActive",
+			})
+		}
+		input.IsActive = &parsedIsActive
+	}
+
+	result, err := d.webhookService.WebhookSubscriptions(c.Request().Context(), input)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"subscriptions": result,
+		},
+	})
+}
+
+func (d WebhookHandler) GetWebhookSubscription(c echo.Context) error {
+	caller := callerOf(c)
+	if !caller.IsEmployee() {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": entity.ErrAccessDenied.Error()})
+	}
+	id := c.Param("id")
+	parsedID, err := strconv.Atoi(id)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid id",
+		})
+	}
+
+	result, err := d.webhookService.WebhookSubscription(c.Request().Context(), parsedID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"subscription": result,
+		},
+	})
+}
+
+func (d WebhookHandler) GetWebhookDeliveries(c echo.Context) error {
+	caller := callerOf(c)
+	if !caller.IsEmployee() {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": entity.ErrAccessDenied.Error()})
+	}
+	input := entity.WebhookDeliveriesInput{}
+
+	subscriptionID := c.QueryParam("subscriptionId")
+	if subscriptionID != "" {
+		parsedSubscriptionID, err := strconv.Atoi(subscriptionID)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{
+				"error": "Invalid subscriptionId",
+			})
+		}
+		input.SubscriptionID = &parsedSubscriptionID
+	}
+
+	eventType := entity.WebhookEventType(c.QueryParam("eventType"))
+	if eventType != "" {
+		if !eventType.IsValid() {
+			return c.JSON(http.StatusBadRequest, echo.Map{
+				"error": "Invalid eventType",
+			})
+		}
+		input.EventType = &eventType
+	}
+
+	status := entity.WebhookDeliveryStatus(c.QueryParam("status"))
+	if status != "" {
+		if !status.IsValid() {
+			return c.JSON(http.StatusBadRequest, echo.Map{
+				"error": "Invalid status",
+			})
+		}
+		input.Status = &status
+	}
+
+	loanID := c.QueryParam("loanId")
+	if loanID != "" {
+		parsedLoanID, err := strconv.Atoi(loanID)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{
+				"error": "Invalid loanId",
+			})
+		}
+		input.LoanID = &parsedLoanID
+	}
+
+	result, err := d.webhookService.WebhookDeliveries(c.Request().Context(), input)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"deliveries": result,
+		},
+	})
+}
+
+func (d WebhookHandler) ReplayWebhookDelivery(c echo.Context) error {
+	caller := callerOf(c)
+	if !caller.IsEmployee() {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": entity.ErrAccessDenied.Error()})
+	}
+	id := c.Param("id")
+	parsedID, err := strconv.Atoi(id)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error": "Invalid id",
+		})
+	}
+
+	result, err := d.webhookService.ReplayWebhookDelivery(c.Request().Context(), parsedID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"delivery": result,
+		},
+	})
+}
@@ -0,0 +1,182 @@
+package entity
+
+import (
+	"mime/multipart"
+	"time"
+)
+
+type Language string
+
+const (
+	LanguageIndonesian Language = "id"
+	LanguageEnglish    Language = "en"
+
+	// DefaultAgreementLanguage is used for the agreements issued when a loan is funded.
+	DefaultAgreementLanguage = LanguageIndonesian
+)
+
+func (l Language) IsValid() bool {
+	switch l {
+	case LanguageIndonesian, LanguageEnglish:
+		return true
+	}
+	return false
+}
+
+type DocumentFormat string
+
+const (
+	DocumentFormatPDF  DocumentFormat = "pdf"
+	DocumentFormatHTML DocumentFormat = "html"
+	DocumentFormatDOCX DocumentFormat = "docx"
+)
+
+func (f DocumentFormat) IsValid() bool {
+	switch f {
+	case DocumentFormatPDF, DocumentFormatHTML, DocumentFormatDOCX:
+		return true
+	}
+	return false
+}
+
+func (f DocumentFormat) ContentType() string {
+	switch f {
+	case DocumentFormatHTML:
+		return "text/html"
+	case DocumentFormatDOCX:
+		return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	}
+	return "application/pdf"
+}
+
+// AgreementPassword selects which investor detail protects the investor agreement PDF.
+type AgreementPassword string
+
+const (
+	AgreementPasswordNone        AgreementPassword = ""
+	AgreementPasswordDateOfBirth AgreementPassword = "DATE_OF_BIRTH"
+	AgreementPasswordNIK         AgreementPassword = "NIK"
+)
+
+func (p AgreementPassword) IsValid() bool {
+	switch p {
+	case AgreementPasswordNone, AgreementPasswordDateOfBirth, AgreementPasswordNIK:
+		return true
+	}
+	return false
+}
+
+// For returns the PDF password of the investor: the date of birth as DDMMYYYY or the last
+// six digits of the NIK. It is empty when the investor did not provide the detail, the PDF
+// is then left unprotected.
+func (p AgreementPassword) For(investor Investor) string {
+	switch p {
+	case AgreementPasswordDateOfBirth:
+		if investor.DateOfBirth == nil {
+			return ""
+		}
+		dateOfBirth, err := time.Parse(time.DateOnly, *investor.DateOfBirth)
+		if err != nil {
+			return ""
+		}
+		return dateOfBirth.Format("02012006")
+	case AgreementPasswordNIK:
+		if investor.NIK == nil || len(*investor.NIK) < 6 {
+			return ""
+		}
+		return (*investor.NIK)[len(*investor.NIK)-6:]
+	}
+	return ""
+}
+
+type AgreementDocument struct {
+	ID               int            `json:"id" gorm:"primaryKey;autoIncrement"`
+	LoanID           int            `json:"loanId" gorm:"index;"`
+	InvestorID       *int           `json:"investorId" gorm:"index;"`
+	Version          int            `json:"version"`
+	Variant          string         `json:"variant" gorm:"type:VARCHAR(50);"`
+	Language         Language       `json:"language" gorm:"type:VARCHAR(10);"`
+	Format           DocumentFormat `json:"format" gorm:"type:VARCHAR(10);"`
+	TemplateVersion  int            `json:"templateVersion"`
+	Path             string         `json:"-" gorm:"type:TEXT;"`
+	URL              string         `json:"url" gorm:"type:TEXT;"`
+	SHA256           string         `json:"sha256" gorm:"type:VARCHAR(64);index;"`
+	VerificationCode string         `json:"verificationCode" gorm:"type:VARCHAR(50);uniqueIndex;"`
+	GeneratedAt      time.Time      `json:"generatedAt" gorm:"type:DATETIME;"`
+	BaseTimeStruct
+}
+
+func (AgreementDocument) TableName() string {
+	return "agreement_document"
+}
+
+type AgreementDocumentsInput struct {
+	LoanID        *int
+	InvestorID    *int
+	Variant       *string
+	Language      *Language
+	Format        *DocumentFormat
+	CreatedBefore *time.Time
+}
+
+type AgreementDocumentInput struct {
+	ID               *int
+	SHA256           *string
+	VerificationCode *string
+}
+
+type WhereAgreementDocument struct {
+	ID               *int
+	LoanID           *int
+	InvestorID       *int
+	Variant          *string
+	Language         *Language
+	Format           *DocumentFormat
+	SHA256           *string
+	VerificationCode *string
+	CreatedBefore    *time.Time
+}
+
+func (w *WhereAgreementDocument) Scan(input any) {
+	switch v := input.(type) {
+	case AgreementDocumentInput:
+		w.ID = v.ID
+		w.SHA256 = v.SHA256
+		w.VerificationCode = v.VerificationCode
+	case AgreementDocumentsInput:
+		w.LoanID = v.LoanID
+		w.InvestorID = v.InvestorID
+		w.Variant = v.Variant
+		w.Language = v.Language
+		w.Format = v.Format
+		w.CreatedBefore = v.CreatedBefore
+	}
+}
+
+// AgreementLetterOptions selects the language and file format of a requested letter.
+type AgreementLetterOptions struct {
+	Language Language
+	Format   DocumentFormat
+}
+
+func (o AgreementLetterOptions) IsDefault() bool {
+	return (o.Language == "" || o.Language == DefaultAgreementLanguage) &&
+		(o.Format == "" || o.Format == DocumentFormatPDF)
+}
+
+type RegenerateAgreementInput struct {
+	LoanID int
+	// ResendEmails mails the regenerated letters to every investor again
+	ResendEmails bool
+}
+
+type VerifyAgreementInput struct {
+	File *multipart.FileHeader
+	Code string
+}
+
+type AgreementVerification struct {
+	Verified bool               `json:"verified"`
+	Reason   string             `json:"reason"`
+	Document *AgreementDocument `json:"document"`
+}
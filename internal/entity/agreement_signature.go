@@ -0,0 +1,90 @@
+package entity
+
+import "time"
+
+type SignerType string
+
+const (
+	SignerTypeInvestor SignerType = "INVESTOR"
+	SignerTypeBorrower SignerType = "BORROWER"
+)
+
+type SignatureStatus string
+
+const (
+	SignatureStatusPending SignatureStatus = "PENDING"
+	SignatureStatusSigned  SignatureStatus = "SIGNED"
+)
+
+type AgreementSignature struct {
+	ID          int        `json:"id" gorm:"primaryKey;autoIncrement"`
+	LoanID      int        `json:"loanId" gorm:"index;"`
+	SignerType  SignerType `json:"signerType" gorm:"type:VARCHAR(50);"`
+	SignerID    int        `json:"signerId" gorm:"index;"`
+	SignerName  string     `json:"signerName" gorm:"type:VARCHAR(500);"`
+	SignerEmail string     `json:"signerEmail" gorm:"type:VARCHAR(500);"`
+	Token       string     `json:"-" gorm:"type:VARCHAR(64);uniqueIndex;"`
+	// a pending signature whose token expired gets a new token the next time signing is requested
+	// or when the signer asks for a new link
+	TokenExpiresAt *time.Time      `json:"tokenExpiresAt" gorm:"type:DATETIME;"`
+	Status         SignatureStatus `json:"status" gorm:"type:VARCHAR(50);"`
+	// captured on signing
+	TypedName          string     `json:"typedName" gorm:"type:VARCHAR(500);"`
+	SignatureImagePath string     `json:"-" gorm:"type:TEXT;"`
+	SignedAt           *time.Time `json:"signedAt" gorm:"type:DATETIME;"`
+	IPAddress          string     `json:"ipAddress" gorm:"type:VARCHAR(100);"`
+	UserAgent          string     `json:"userAgent" gorm:"type:TEXT;"`
+	BaseTimeStruct
+}
+
+func (AgreementSignature) TableName() string {
+	return "agreement_signature"
+}
+
+// IsExpired reports whether the signing token can no longer be used, signatures
+// requested before tokens expired never do.
+func (a AgreementSignature) IsExpired(now time.Time) bool {
+	return a.TokenExpiresAt != nil && !now.Before(*a.TokenExpiresAt)
+}
+
+type AgreementSignaturesInput struct {
+	LoanID *int
+	Status *SignatureStatus
+}
+
+type AgreementSignatureInput struct {
+	ID    *int
+	Token *string
+}
+
+type WhereAgreementSignature struct {
+	ID     *int
+	Token  *string
+	LoanID *int
+	Status *SignatureStatus
+}
+
+func (w *WhereAgreementSignature) Scan(input any) {
+	switch v := input.(type) {
+	case AgreementSignatureInput:
+		w.ID = v.ID
+		w.Token = v.Token
+	case AgreementSignaturesInput:
+		w.LoanID = v.LoanID
+		w.Status = v.Status
+	}
+}
+
+type SignAgreementInput struct {
+	Token     string
+	TypedName string
+	// base64 encoded png, a data url prefix is accepted
+	SignatureImage string
+	IPAddress      string
+	UserAgent      string
+}
+
+type AgreementSigningRequest struct {
+	Signature    AgreementSignature
+	AgreementURL string
+}
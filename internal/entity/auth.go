@@ -0,0 +1,53 @@
+package entity
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrAccessDenied is returned when the caller may not see what they asked for.
+var ErrAccessDenied = errors.New("access denied")
+
+// Caller is who sent the request, read from the access token. Download links are
+// issued to the caller and only work for them.
+type Caller struct {
+	Audience  DownloadAudience
+	SubjectID int
+}
+
+func (c Caller) IsEmployee() bool {
+	return c.Audience == DownloadAudienceEmployee
+}
+
+// IsRecipient reports whether the caller is the investor or borrower notifications are sent to.
+func (c Caller) IsRecipient(recipientType NotificationRecipientType, recipientID int) bool {
+	return string(c.Audience) == string(recipientType) && c.SubjectID == recipientID
+}
+
+// Grant issues a download link to the caller, zero TTL uses the download policy TTL.
+func (c Caller) Grant(ttl time.Duration) DownloadGrant {
+	return DownloadGrant{
+		Audience:  c.Audience,
+		SubjectID: c.SubjectID,
+		TTL:       ttl,
+	}
+}
+
+// AuthPolicy holds the key access tokens are signed with and the api key of the
+// gateway that logs users in and asks for their tokens.
+type AuthPolicy struct {
+	Secret string
+	TTL    time.Duration
+	APIKey string
+}
+
+type IssueAccessTokenInput struct {
+	Audience  DownloadAudience `json:"audience"`
+	SubjectID int              `json:"subjectId"`
+	APIKey    string           `json:"-"`
+}
+
+type AccessToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
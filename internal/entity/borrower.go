@@ -0,0 +1,50 @@
+package entity
+
+type Borrower struct {
+	ID                int    `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name              string `json:"name" gorm:"type:VARCHAR(500);"`
+	Email             string `json:"email" gorm:"type:VARCHAR(500);"`
+	Phone             string `json:"phone" gorm:"type:VARCHAR(50);"`
+	MonthlyIncome     int    `json:"monthlyIncome" gorm:"type:INTEGER;default:0;"`
+	BusinessAgeMonths int    `json:"businessAgeMonths" gorm:"type:INTEGER;default:0;"`
+	// registered business location, photo proofs are checked against it
+	Latitude  *float64 `json:"latitude" gorm:"type:FLOAT;"`
+	Longitude *float64 `json:"longitude" gorm:"type:FLOAT;"`
+	BaseTimeStruct
+}
+
+func (Borrower) TableName() string {
+	return "borrower"
+}
+
+type BorrowersInput struct {
+	IDs *[]int
+}
+
+type BorrowerInput struct {
+	ID *int
+}
+
+type WhereBorrower struct {
+	ID  *int
+	IDs *[]int
+}
+
+func (w *WhereBorrower) Scan(input any) {
+	switch v := input.(type) {
+	case BorrowerInput:
+		w.ID = v.ID
+	case BorrowersInput:
+		w.IDs = v.IDs
+	}
+}
+
+type AddBorrowerInput struct {
+	Name              string
+	Email             string
+	Phone             string
+	MonthlyIncome     int
+	BusinessAgeMonths int
+	Latitude          *float64
+	Longitude         *float64
+}
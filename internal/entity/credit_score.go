@@ -0,0 +1,74 @@
+package entity
+
+type RiskGrade string
+
+const (
+	RiskGradeA RiskGrade = "A"
+	RiskGradeB RiskGrade = "B"
+	RiskGradeC RiskGrade = "C"
+	RiskGradeD RiskGrade = "D"
+	RiskGradeE RiskGrade = "E"
+)
+
+func (g RiskGrade) IsValid() bool {
+	switch g {
+	case RiskGradeA, RiskGradeB, RiskGradeC, RiskGradeD, RiskGradeE:
+		return true
+	}
+	return false
+}
+
+type CreditFactor string
+
+const (
+	CreditFactorMonthlyIncome     CreditFactor = "MONTHLY_INCOME"
+	CreditFactorBusinessAgeMonths CreditFactor = "BUSINESS_AGE_MONTHS"
+	// repayment history, disbursed loans of the borrower whose term has elapsed
+	CreditFactorCompletedLoans CreditFactor = "COMPLETED_LOANS"
+	// disbursed loans of the borrower whose term has not elapsed yet
+	CreditFactorActiveLoans CreditFactor = "ACTIVE_LOANS"
+)
+
+func (f CreditFactor) IsValid() bool {
+	switch f {
+	case CreditFactorMonthlyIncome, CreditFactorBusinessAgeMonths, CreditFactorCompletedLoans, CreditFactorActiveLoans:
+		return true
+	}
+	return false
+}
+
+type CreditScoreInput struct {
+	Borrower      Borrower
+	PreviousLoans []Loan
+}
+
+type CreditScore struct {
+	Score int
+	Grade RiskGrade
+}
+
+// CreditScoringRule adds Points when the factor value is within [Min, Max).
+// Max is optional, a nil Max means no upper bound.
+type CreditScoringRule struct {
+	Factor CreditFactor `json:"factor"`
+	Min    int          `json:"min"`
+	Max    *int         `json:"max"`
+	Points int          `json:"points"`
+}
+
+// CreditScoringGrade maps a minimum score to a risk grade and the rate band
+// (annual, in percent) a loan with that grade may be proposed with.
+type CreditScoringGrade struct {
+	Grade    RiskGrade `json:"grade"`
+	MinScore int       `json:"minScore"`
+	MinRate  float64   `json:"minRate"`
+	MaxRate  float64   `json:"maxRate"`
+}
+
+type CreditScoringConfig struct {
+	BaseScore int                  `json:"baseScore"`
+	MinScore  int                  `json:"minScore"`
+	MaxScore  int                  `json:"maxScore"`
+	Rules     []CreditScoringRule  `json:"rules"`
+	Grades    []CreditScoringGrade `json:"grades"`
+}
@@ -0,0 +1,36 @@
+package entity
+
+import "time"
+
+type DomainEventType string
+
+const (
+	DomainEventTypeLoanProposed     DomainEventType = "LOAN_PROPOSED"
+	DomainEventTypeLoanApproved     DomainEventType = "LOAN_APPROVED"
+	DomainEventTypeInvestmentPlaced DomainEventType = "INVESTMENT_PLACED"
+	// DomainEventTypeLoanFullyFunded is published by the outbox dispatcher, so it is delivered at least once
+	DomainEventTypeLoanFullyFunded        DomainEventType = "LOAN_FULLY_FUNDED"
+	DomainEventTypeLoanAgreementGenerated DomainEventType = "LOAN_AGREEMENT_GENERATED"
+	DomainEventTypeLoanDisbursed          DomainEventType = "LOAN_DISBURSED"
+	DomainEventTypeLoanExpired            DomainEventType = "LOAN_EXPIRED"
+)
+
+func (t DomainEventType) IsValid() bool {
+	switch t {
+	case DomainEventTypeLoanProposed, DomainEventTypeLoanApproved, DomainEventTypeInvestmentPlaced,
+		DomainEventTypeLoanFullyFunded, DomainEventTypeLoanAgreementGenerated, DomainEventTypeLoanDisbursed,
+		DomainEventTypeLoanExpired:
+		return true
+	}
+	return false
+}
+
+// DomainEvent is something that happened to a loan, published after the change is stored.
+type DomainEvent struct {
+	Type DomainEventType
+	// the same for every time the event is published, subscribers use it to ignore a repeat
+	Key        string
+	Loan       Loan
+	Investment *LoanInvestment
+	OccurredAt time.Time
+}
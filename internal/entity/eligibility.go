@@ -0,0 +1,52 @@
+package entity
+
+import "strings"
+
+type EligibilityReasonCode string
+
+const (
+	EligibilityReasonActiveLoan      EligibilityReasonCode = "ACTIVE_LOAN_EXISTS"
+	EligibilityReasonExposureLimit   EligibilityReasonCode = "EXPOSURE_LIMIT_EXCEEDED"
+	EligibilityReasonAmountTooLow    EligibilityReasonCode = "AMOUNT_BELOW_MINIMUM"
+	EligibilityReasonAmountTooHigh   EligibilityReasonCode = "AMOUNT_ABOVE_MAXIMUM"
+	EligibilityReasonTermNotAllowed  EligibilityReasonCode = "TERM_NOT_ALLOWED"
+	EligibilityReasonTermUnitInvalid EligibilityReasonCode = "TERM_UNIT_NOT_ALLOWED"
+)
+
+type EligibilityReason struct {
+	Code    EligibilityReasonCode `json:"code"`
+	Message string                `json:"message"`
+}
+
+// EligibilityLimits holds the platform wide limits, a zero value means no limit.
+// Amount and term limits come from the loan product.
+type EligibilityLimits struct {
+	MaxBorrowerExposure int
+}
+
+type EligibilityInput struct {
+	UserID        int
+	Product       LoanProduct
+	Amount        int
+	Term          int
+	TermUnit      TermUnit
+	PreviousLoans []Loan
+}
+
+type EligibilityResult struct {
+	Eligible            bool
+	OutstandingExposure int
+	Reasons             []EligibilityReason
+}
+
+type EligibilityError struct {
+	Reasons []EligibilityReason
+}
+
+func (e EligibilityError) Error() string {
+	messages := make([]string, 0, len(e.Reasons))
+	for _, reason := range e.Reasons {
+		messages = append(messages, reason.Message)
+	}
+	return "loan proposal is not eligible: " + strings.Join(messages, "; ")
+}
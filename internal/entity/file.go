@@ -1,29 +1,275 @@
 package entity
 
-import "mime/multipart"
+import (
+	"errors"
+	"mime/multipart"
+	"path"
+	"time"
+)
 
 const (
-	LocalUploadPath           = "storage/uploads"
-	LocalAggrementLetterPath  = "storage/agreements"
+	// storage key prefixes
+	UploadStoragePrefix    = "uploads"
+	AgreementStoragePrefix = "agreements"
+	SignatureStoragePrefix = "signatures"
+	VariantStoragePrefix   = "variants"
+	// public url paths the stored objects are served from
 	PublicUploadPath          = "public/uploads"
+	PublicVariantPath         = "public/variants"
 	PublicAggrementLetterPath = "storage/agreements"
 )
 
+var (
+	ErrStorageObjectNotFound = errors.New("object not found")
+	// ErrPresignNotSupported is returned by storage backends that can only be read through Get.
+	ErrPresignNotSupported = errors.New("presigned urls are not supported by this storage")
+)
+
+// StorageObject describes a stored object without reading it.
+type StorageObject struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModifiedAt  time.Time
+}
+
+type File struct {
+	ID                   int    `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name                 string `json:"name" gorm:"type:VARCHAR(500);uniqueIndex;"`
+	URL                  string `json:"url" gorm:"type:TEXT;"`
+	UploadedByEmployeeID *int   `json:"uploadedByEmployeeId" gorm:"index;"`
+	// what was uploaded, as sniffed from the content and not from the file name
+	OriginalName string      `json:"originalName" gorm:"type:VARCHAR(500);"`
+	ContentType  string      `json:"contentType" gorm:"type:VARCHAR(100);"`
+	Size         int64       `json:"size" gorm:"type:INTEGER;default:0;"`
+	SHA256       string      `json:"sha256" gorm:"type:VARCHAR(64);index;"`
+	Purpose      FilePurpose `json:"purpose" gorm:"type:VARCHAR(50);index;"`
+	// the loan or investor a document was uploaded for
+	LoanID     *int `json:"loanId" gorm:"index;"`
+	InvestorID *int `json:"investorId" gorm:"index;"`
+	// hex encoded dHash, only set for images
+	PerceptualHash *string `json:"perceptualHash" gorm:"type:VARCHAR(16);index;"`
+	// the 16 bit bands of the hash, indexed so near-duplicates are looked up without comparing every image
+	PerceptualHashBand0 *int `json:"-" gorm:"index;"`
+	PerceptualHashBand1 *int `json:"-" gorm:"index;"`
+	PerceptualHashBand2 *int `json:"-" gorm:"index;"`
+	PerceptualHashBand3 *int `json:"-" gorm:"index;"`
+	// why an image uploaded before hashing could not be hashed, it is left out of the duplicate check
+	PerceptualHashError *string `json:"perceptualHashError" gorm:"type:TEXT;"`
+	// the outcome of the review of a KYC document, empty until it was reviewed
+	ReviewStatus         FileReviewStatus `json:"reviewStatus" gorm:"type:VARCHAR(50);index;"`
+	ReviewedAt           *time.Time       `json:"reviewedAt" gorm:"type:DATETIME;"`
+	ReviewedByEmployeeID *int             `json:"reviewedByEmployeeId"`
+	// resized copies of an image upload
+	Variants []FileVariant `json:"variants" gorm:"-"`
+	BaseTimeStruct
+}
+
+func (File) TableName() string {
+	return "file"
+}
+
+// StorageKey is where the uploaded file is kept in storage
+func (f File) StorageKey() string {
+	return path.Join(UploadStoragePrefix, f.Name)
+}
+
+type FilesInput struct {
+	IDs               *[]int
+	LoanID            *int
+	InvestorID        *int
+	Purpose           *FilePurpose
+	ContentTypes      *[]string
+	HasPerceptualHash *bool
+	// true lists the images whose hash or hash bands are still to be stored
+	PerceptualHashPending *bool
+	// the values looked up per hash band, a file matches when any of its bands has one of them
+	PerceptualHashBands *[][]int
+	CreatedBefore       *time.Time
+	ReviewStatus        *FileReviewStatus
+}
+
+type FileInput struct {
+	ID   *int
+	Name *string
+}
+
+type WhereFile struct {
+	ID                    *int
+	IDs                   *[]int
+	Name                  *string
+	LoanID                *int
+	InvestorID            *int
+	Purpose               *FilePurpose
+	ContentTypes          *[]string
+	HasPerceptualHash     *bool
+	PerceptualHashPending *bool
+	PerceptualHashBands   *[][]int
+	CreatedBefore         *time.Time
+	ReviewStatus          *FileReviewStatus
+}
+
+func (w *WhereFile) Scan(input any) {
+	switch v := input.(type) {
+	case FileInput:
+		w.ID = v.ID
+		w.Name = v.Name
+	case FilesInput:
+		w.IDs = v.IDs
+		w.LoanID = v.LoanID
+		w.InvestorID = v.InvestorID
+		w.Purpose = v.Purpose
+		w.ContentTypes = v.ContentTypes
+		w.HasPerceptualHash = v.HasPerceptualHash
+		w.PerceptualHashPending = v.PerceptualHashPending
+		w.PerceptualHashBands = v.PerceptualHashBands
+		w.CreatedBefore = v.CreatedBefore
+		w.ReviewStatus = v.ReviewStatus
+	}
+}
+
+type FileReviewStatus string
+
+const (
+	FileReviewStatusApproved FileReviewStatus = "APPROVED"
+	FileReviewStatusRejected FileReviewStatus = "REJECTED"
+)
+
+func (s FileReviewStatus) IsValid() bool {
+	switch s {
+	case FileReviewStatusApproved, FileReviewStatusRejected:
+		return true
+	}
+	return false
+}
+
+type ReviewFileInput struct {
+	ID         int              `json:"-"`
+	Status     FileReviewStatus `json:"status"`
+	EmployeeID int              `json:"-"`
+}
+
 type UploadFileInput struct {
-	File *multipart.FileHeader
+	File       *multipart.FileHeader
+	Purpose    FilePurpose
+	EmployeeID int
+	LoanID     int
+	InvestorID int
+}
+
+type FilePurpose string
+
+const (
+	FilePurposePhotoProof      FilePurpose = "PHOTO_PROOF"
+	FilePurposeSignedAgreement FilePurpose = "SIGNED_AGREEMENT"
+	FilePurposeKYC             FilePurpose = "KYC"
+)
+
+func (p FilePurpose) IsValid() bool {
+	switch p {
+	case FilePurposePhotoProof, FilePurposeSignedAgreement, FilePurposeKYC:
+		return true
+	}
+	return false
+}
+
+// MaxSize is the largest upload accepted for the purpose, in bytes.
+func (p FilePurpose) MaxSize() int64 {
+	switch p {
+	case FilePurposeSignedAgreement:
+		return 20 << 20
+	}
+	return 10 << 20
+}
+
+// ContentTypes lists the sniffed content types accepted for the purpose.
+func (p FilePurpose) ContentTypes() []string {
+	switch p {
+	case FilePurposePhotoProof:
+		return []string{"image/jpeg", "image/png"}
+	case FilePurposeSignedAgreement:
+		return []string{"application/pdf"}
+	}
+	return []string{"image/jpeg", "image/png", "application/pdf"}
+}
+
+// DownloadAudience is who a signed download url was issued to.
+type DownloadAudience string
+
+const (
+	DownloadAudienceInvestor DownloadAudience = "INVESTOR"
+	DownloadAudienceBorrower DownloadAudience = "BORROWER"
+	DownloadAudienceEmployee DownloadAudience = "EMPLOYEE"
+)
+
+func (a DownloadAudience) IsValid() bool {
+	switch a {
+	case DownloadAudienceInvestor, DownloadAudienceBorrower, DownloadAudienceEmployee:
+		return true
+	}
+	return false
+}
+
+// DownloadPolicy holds the key download urls are signed with.
+type DownloadPolicy struct {
+	Secret string
+	TTL    time.Duration
+}
+
+type DownloadGrant struct {
+	Audience  DownloadAudience
+	SubjectID int
+	// zero uses the policy TTL
+	TTL time.Duration
+}
+
+type DownloadInput struct {
+	Key       string
+	Audience  DownloadAudience
+	SubjectID int
+	ExpiresAt int64
+	Signature string
+	// the authenticated caller has to be the one the link was issued to
+	Caller Caller
+}
+
+type ImageMetadata struct {
+	ContentType string     `json:"contentType"`
+	TakenAt     *time.Time `json:"takenAt"`
+	Latitude    *float64   `json:"latitude"`
+	Longitude   *float64   `json:"longitude"`
 }
 
 type InvestorAgreementLetterInvestor struct {
-	Name    string
-	Amount  string
-	Percent float64
+	InvestorID int
+	Name       string
+	Amount     int
+	Percent    float64
+	// Password protects the rendered PDF, empty leaves it open
+	Password string
+}
+type AgreementLetterSignature struct {
+	SignerRole string
+	SignerName string
+	TypedName  string
+	ImagePath  string
+	SignedAt   time.Time
+	IPAddress  string
 }
+
 type InvestorAgreementLetterInput struct {
-	AgreementNo  string
-	EffectiveOn  string
-	BorrowerName string
-	Amount       string
-	Rate         string
-	Term         string
-	Investors    []InvestorAgreementLetterInvestor
+	Language       Language
+	Format         DocumentFormat
+	AgreementNo    string
+	EffectiveOn    time.Time
+	BorrowerName   string
+	Amount         int
+	Rate           float64
+	Term           int
+	TermUnit       TermUnit
+	InterestMethod InterestMethod
+	Investors      []InvestorAgreementLetterInvestor
+	// printed on every page so the issued document can be verified later
+	VerificationCode string
+	VerificationURL  string
 }
@@ -0,0 +1,71 @@
+package entity
+
+import "path"
+
+type FileVariantKind string
+
+const (
+	FileVariantKindThumbnail FileVariantKind = "THUMBNAIL"
+	FileVariantKindWeb       FileVariantKind = "WEB"
+)
+
+var FileVariantKinds = []FileVariantKind{FileVariantKindThumbnail, FileVariantKindWeb}
+
+func (k FileVariantKind) IsValid() bool {
+	switch k {
+	case FileVariantKindThumbnail, FileVariantKindWeb:
+		return true
+	}
+	return false
+}
+
+// MaxSide is the longest side of the variant in pixels.
+func (k FileVariantKind) MaxSide() int {
+	switch k {
+	case FileVariantKindThumbnail:
+		return 320
+	}
+	return 1600
+}
+
+// FileVariant is a resized copy of an uploaded image, variants are re-encoded
+// without the EXIF of the original so they can be shown safely.
+type FileVariant struct {
+	ID          int             `json:"id" gorm:"primaryKey;autoIncrement"`
+	FileID      int             `json:"fileId" gorm:"index;"`
+	Kind        FileVariantKind `json:"kind" gorm:"type:VARCHAR(50);"`
+	Name        string          `json:"name" gorm:"type:VARCHAR(500);uniqueIndex;"`
+	URL         string          `json:"url" gorm:"type:TEXT;"`
+	ContentType string          `json:"contentType" gorm:"type:VARCHAR(100);"`
+	Width       int             `json:"width" gorm:"type:INTEGER;"`
+	Height      int             `json:"height" gorm:"type:INTEGER;"`
+	Size        int64           `json:"size" gorm:"type:INTEGER;default:0;"`
+	BaseTimeStruct
+}
+
+func (FileVariant) TableName() string {
+	return "file_variant"
+}
+
+// StorageKey is where the variant is kept in storage
+func (v FileVariant) StorageKey() string {
+	return path.Join(VariantStoragePrefix, v.Name)
+}
+
+type FileVariantsInput struct {
+	FileIDs *[]int
+	Kind    *FileVariantKind
+}
+
+type WhereFileVariant struct {
+	FileIDs *[]int
+	Kind    *FileVariantKind
+}
+
+func (w *WhereFileVariant) Scan(input any) {
+	switch v := input.(type) {
+	case FileVariantsInput:
+		w.FileIDs = v.FileIDs
+		w.Kind = v.Kind
+	}
+}
@@ -3,6 +3,9 @@ package entity
 type Investor struct {
 	ID    int    `json:"id" gorm:"primaryKey;autoIncrement"`
 	Email string `json:"email" gorm:"type:VARCHAR(500);uniqueIndex;"`
+	// personal data, only used to derive the agreement PDF password so it is never returned
+	DateOfBirth *string `json:"-" gorm:"type:VARCHAR(10);"`
+	NIK         *string `json:"-" gorm:"type:VARCHAR(16);"`
 	BaseTimeStruct
 }
 
@@ -37,4 +40,7 @@ func (w *WhereInvestor) Scan(input any) {
 
 type AddInvestorInput struct {
 	Email string
+	// DateOfBirth is formatted as 2006-01-02
+	DateOfBirth *string
+	NIK         *string
 }
@@ -0,0 +1,116 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type JobType string
+
+const (
+	JobTypeRetentionRun JobType = "RETENTION_RUN"
+	JobTypeLoanExpiry   JobType = "LOAN_EXPIRY"
+	// JobTypePerceptualHashBackfill hashes the images uploaded before hashing, it runs once
+	JobTypePerceptualHashBackfill JobType = "PERCEPTUAL_HASH_BACKFILL"
+)
+
+type JobStatus string
+
+const (
+	JobStatusQueued    JobStatus = "QUEUED"
+	JobStatusRunning   JobStatus = "RUNNING"
+	JobStatusSucceeded JobStatus = "SUCCEEDED"
+	// JobStatusDead is the dead letter state of a job that failed every attempt
+	JobStatusDead      JobStatus = "DEAD"
+	JobStatusCancelled JobStatus = "CANCELLED"
+)
+
+func (s JobStatus) IsValid() bool {
+	switch s {
+	case JobStatusQueued, JobStatusRunning, JobStatusSucceeded, JobStatusDead, JobStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// Job is a unit of background work kept in the database, so it survives a restart.
+// A worker leases the job while running it, a job whose lease ran out is picked up again.
+type Job struct {
+	ID   int     `json:"id" gorm:"primaryKey;autoIncrement"`
+	Type JobType `json:"type" gorm:"type:VARCHAR(100);index;"`
+	// a job is only enqueued once per key, empty keys are never deduplicated
+	Key         *string         `json:"key" gorm:"type:VARCHAR(200);uniqueIndex;"`
+	Payload     json.RawMessage `json:"payload" gorm:"type:TEXT;serializer:json;"`
+	Status      JobStatus       `json:"status" gorm:"type:VARCHAR(50);index;"`
+	Attempts    int             `json:"attempts" gorm:"type:INTEGER;default:0;"`
+	MaxAttempts int             `json:"maxAttempts" gorm:"type:INTEGER;"`
+	// ScheduledAt is when the job was meant to run, RunAt moves with every retry
+	ScheduledAt time.Time `json:"scheduledAt" gorm:"type:DATETIME;"`
+	RunAt       time.Time `json:"runAt" gorm:"type:DATETIME;index;"`
+	// seconds between runs of a recurring job, 0 runs once
+	RepeatEvery    int        `json:"repeatEvery" gorm:"type:INTEGER;default:0;"`
+	LockToken      *string    `json:"-" gorm:"type:VARCHAR(100);index;"`
+	LockedBy       *string    `json:"lockedBy" gorm:"type:VARCHAR(100);"`
+	LeaseExpiresAt *time.Time `json:"leaseExpiresAt" gorm:"type:DATETIME;"`
+	LastError      *string    `json:"lastError" gorm:"type:TEXT;"`
+	FinishedAt     *time.Time `json:"finishedAt" gorm:"type:DATETIME;"`
+	BaseTimeStruct
+}
+
+func (Job) TableName() string {
+	return "job"
+}
+
+type EnqueueJobInput struct {
+	Type    JobType
+	Key     string
+	Payload any
+	// zero runs the job right away
+	RunAt time.Time
+	// zero uses the queue default
+	MaxAttempts int
+	RepeatEvery time.Duration
+}
+
+// JobQueuePolicy sizes the worker pool and sets how failed jobs are retried.
+type JobQueuePolicy struct {
+	Workers      int
+	PollInterval time.Duration
+	MaxAttempts  int
+	// how long a claimed job stays locked, running workers extend it
+	Lease time.Duration
+	// the delay before the first retry, doubled after every failed attempt up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+type JobsInput struct {
+	Status *JobStatus
+	Type   *JobType
+}
+
+type JobInput struct {
+	ID        *int
+	Key       *string
+	LockToken *string
+}
+
+type WhereJob struct {
+	ID        *int
+	Key       *string
+	LockToken *string
+	Status    *JobStatus
+	Type      *JobType
+}
+
+func (w *WhereJob) Scan(input any) {
+	switch v := input.(type) {
+	case JobInput:
+		w.ID = v.ID
+		w.Key = v.Key
+		w.LockToken = v.LockToken
+	case JobsInput:
+		w.Status = v.Status
+		w.Type = v.Type
+	}
+}
@@ -13,16 +13,23 @@ const (
 	LoanStatusApproved  LoanStatus = "APPROVED"
 	LoanStatusInvested  LoanStatus = "INVESTED"
 	LoanStatusDisbursed LoanStatus = "DISBURSED"
+	// LoanStatusExpired is an approved loan that was not fully funded before its funding deadline
+	LoanStatusExpired LoanStatus = "EXPIRED"
 )
 
 func (ls LoanStatus) IsValid() bool {
 	switch ls {
-	case LoanStatusProposed, LoanStatusApproved, LoanStatusInvested, LoanStatusDisbursed:
+	case LoanStatusProposed, LoanStatusApproved, LoanStatusInvested, LoanStatusDisbursed, LoanStatusExpired:
 		return true
 	}
 	return false
 }
 
+// IsTerminal reports whether the loan has left the proposal/funding lifecycle.
+func (ls LoanStatus) IsTerminal() bool {
+	return ls == LoanStatusDisbursed || ls == LoanStatusExpired
+}
+
 type Loan struct {
 	ID             int        `json:"id" gorm:"primaryKey;autoIncrement"`
 	UserID         int        `json:"userId" gorm:"index;"`
@@ -31,17 +38,36 @@ type Loan struct {
 	InvestedAmount int        `json:"investedAmount" gorm:"type:INTEGER;default:0;"`
 	Rate           float64    `json:"rate" gorm:"type:FLOAT;default:0;"`
 	Term           int        `json:"term" gorm:"type:INTEGER;default:0;"`
+	// product info
+	ProductID      int            `json:"productId" gorm:"index;"`
+	TermUnit       TermUnit       `json:"termUnit" gorm:"type:VARCHAR(50);"`
+	InterestMethod InterestMethod `json:"interestMethod" gorm:"type:VARCHAR(50);"`
+	FeeAmount      int            `json:"feeAmount" gorm:"type:INTEGER;default:0;"`
+	Fees           []LoanFee      `json:"fees" gorm:"type:TEXT;serializer:json;"`
+	// credit scoring info
+	CreditScore int       `json:"creditScore" gorm:"type:INTEGER;default:0;"`
+	RiskGrade   RiskGrade `json:"riskGrade" gorm:"type:VARCHAR(1);"`
 	// approval info
-	PhotoProofURL               *string    `json:"photoProofUrl" gorm:"type:TEXT;"`
-	ApprovedByEmployeeID        *int       `json:"employeeId" gorm:"index;"`
-	ApprovedAt                  *time.Time `json:"approvedAt" gorm:"type:DATETIME;"`
-	FullyInvestedAt             *time.Time `json:"fullyInvestedAt" gorm:"type:DATETIME;"`
-	DraftLoanAgreementLetterURL *string    `json:"draftLoanAgreementLetterUrl" gorm:"type:TEXT;"`
+	PhotoProofURL                *string    `json:"photoProofUrl" gorm:"type:TEXT;"`
+	PhotoProofFileID             *int       `json:"photoProofFileId" gorm:"index;"`
+	PhotoProofTakenAt            *time.Time `json:"photoProofTakenAt" gorm:"type:DATETIME;"`
+	PhotoProofLatitude           *float64   `json:"photoProofLatitude" gorm:"type:FLOAT;"`
+	PhotoProofLongitude          *float64   `json:"photoProofLongitude" gorm:"type:FLOAT;"`
+	PhotoProofDistanceMeters     *float64   `json:"photoProofDistanceMeters" gorm:"type:FLOAT;"`
+	PhotoProofDuplicateSuspected bool       `json:"photoProofDuplicateSuspected" gorm:"default:false;"`
+	ApprovedByEmployeeID         *int       `json:"employeeId" gorm:"index;"`
+	ApprovedAt                   *time.Time `json:"approvedAt" gorm:"type:DATETIME;"`
+	FundingDeadline              *time.Time `json:"fundingDeadline" gorm:"type:DATETIME;"`
+	FullyInvestedAt              *time.Time `json:"fullyInvestedAt" gorm:"type:DATETIME;"`
+	DraftLoanAgreementLetterURL  *string    `json:"draftLoanAgreementLetterUrl" gorm:"type:TEXT;"`
 	// disbursement info
-	LoanAgreementLetterURL         *string    `json:"loanAgreementLetterUrl" gorm:"type:TEXT;"`
-	AgreementCollectedByEmployeeID *int       `json:"agreementCollectedByEmployeeId" gorm:"index;"`
-	DisbursedByEmployeeID          *int       `json:"disbursedByEmployeeId" gorm:"index;"`
-	DisbursedAt                    *time.Time `json:"disbursedAt" gorm:"type:DATETIME;"`
+	LoanAgreementLetterURL *string `json:"loanAgreementLetterUrl" gorm:"type:TEXT;"`
+	// only set when a wet-signed scan was uploaded instead of signing electronically
+	LoanAgreementFileID               *int       `json:"loanAgreementFileId" gorm:"index;"`
+	LoanAgreementUploadedByEmployeeID *int       `json:"loanAgreementUploadedByEmployeeId" gorm:"index;"`
+	AgreementCollectedByEmployeeID    *int       `json:"agreementCollectedByEmployeeId" gorm:"index;"`
+	DisbursedByEmployeeID             *int       `json:"disbursedByEmployeeId" gorm:"index;"`
+	DisbursedAt                       *time.Time `json:"disbursedAt" gorm:"type:DATETIME;"`
 	BaseTimeStruct
 }
 
@@ -49,6 +75,28 @@ func (Loan) TableName() string {
 	return "loan"
 }
 
+// IsOutstanding reports whether the loan still counts towards the borrower exposure,
+// a disbursed loan is outstanding until its term has elapsed and an expired loan never was.
+func (l Loan) IsOutstanding(now time.Time) bool {
+	if l.Status == LoanStatusExpired {
+		return false
+	}
+	endsAt := l.EndsAt()
+	if endsAt == nil {
+		return true
+	}
+	return now.Before(*endsAt)
+}
+
+// EndsAt is when the term of a disbursed loan elapses, nil while the loan was not disbursed.
+func (l Loan) EndsAt() *time.Time {
+	if l.Status != LoanStatusDisbursed || l.DisbursedAt == nil {
+		return nil
+	}
+	endsAt := l.TermUnit.Add(*l.DisbursedAt, l.Term)
+	return &endsAt
+}
+
 func (l *Loan) BeforeCreate(tx *gorm.DB) (err error) {
 	if !l.Status.IsValid() {
 		l.Status = LoanStatusProposed
@@ -57,8 +105,12 @@ func (l *Loan) BeforeCreate(tx *gorm.DB) (err error) {
 }
 
 type LoansInput struct {
-	UserID *int
-	Status *LoanStatus
+	UserID            *int
+	Status            *LoanStatus
+	HasPhotoProof     *bool
+	PhotoProofFileIDs *[]int
+	// loans whose funding deadline is before the time
+	FundingDeadlineBefore *time.Time
 }
 
 type LoanInput struct {
@@ -66,9 +118,12 @@ type LoanInput struct {
 }
 
 type WhereLoan struct {
-	ID     *int
-	UserID *int
-	Status *LoanStatus
+	ID                    *int
+	UserID                *int
+	Status                *LoanStatus
+	HasPhotoProof         *bool
+	PhotoProofFileIDs     *[]int
+	FundingDeadlineBefore *time.Time
 }
 
 func (w *WhereLoan) Scan(input any) {
@@ -78,29 +133,50 @@ func (w *WhereLoan) Scan(input any) {
 	case LoansInput:
 		w.UserID = v.UserID
 		w.Status = v.Status
+		w.HasPhotoProof = v.HasPhotoProof
+		w.PhotoProofFileIDs = v.PhotoProofFileIDs
+		w.FundingDeadlineBefore = v.FundingDeadlineBefore
 	}
 }
 
 type TermUnit string
 
 const (
-	TermUnitWeek TermUnit = "WEEKLY"
+	TermUnitWeek  TermUnit = "WEEKLY"
+	TermUnitMonth TermUnit = "MONTHLY"
 )
 
 func (e TermUnit) IsValid() bool {
 	switch e {
-	case TermUnitWeek:
+	case TermUnitWeek, TermUnitMonth:
 		return true
 	}
 	return false
 }
 
+// PeriodsPerYear is used to derive the periodic rate from the annual rate,
+// loans created before term unit was stored are weekly.
+func (e TermUnit) PeriodsPerYear() int {
+	if e == TermUnitMonth {
+		return 12
+	}
+	return 52
+}
+
+func (e TermUnit) Add(t time.Time, periods int) time.Time {
+	if e == TermUnitMonth {
+		return t.AddDate(0, periods, 0)
+	}
+	return t.AddDate(0, 0, 7*periods)
+}
+
 type ProposeLoanInput struct {
-	UserID   int
-	Amount   int
-	Rate     float64
-	Term     int
-	TermUnit TermUnit
+	UserID    int
+	ProductID int
+	Amount    int
+	Rate      float64
+	Term      int
+	TermUnit  TermUnit
 }
 
 type PatchLoanInput struct {
@@ -121,6 +197,16 @@ type ApproveLoanInput struct {
 	PhotoProofURL string
 }
 
+// PhotoProofPolicy limits how old and how far from the borrower location
+// a photo proof may be, a zero value disables the check.
+type PhotoProofPolicy struct {
+	MaxAge            time.Duration
+	MaxDistanceMeters float64
+	// empty action disables the near-duplicate check
+	DuplicateAction      PhotoProofDuplicateAction
+	DuplicateMaxDistance int
+}
+
 type DisburseLoanInput struct {
 	ID                             int
 	DisbursedByEmployeeID          int
@@ -128,10 +214,21 @@ type DisburseLoanInput struct {
 	AgreementCollectedByEmployeeID int
 }
 
+type LoanFee struct {
+	Name   string  `json:"name"`
+	Type   FeeType `json:"type"`
+	Value  float64 `json:"value"`
+	Amount int     `json:"amount"`
+}
+
 type LoanQuote struct {
 	BorrowerID      int
 	PrincipalAmount int
 	Rate            float64
+	Term            int
+	TermUnit        TermUnit
+	InterestMethod  InterestMethod
+	FeeAmount       int
 	TotalROI        string
 	AgreementURL    string
 	Investors       []LoanQuoteInvestor
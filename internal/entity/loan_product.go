@@ -0,0 +1,126 @@
+package entity
+
+type InterestMethod string
+
+const (
+	InterestMethodFlat InterestMethod = "FLAT"
+	// declining balance, equal installment (annuity)
+	InterestMethodEffective InterestMethod = "EFFECTIVE"
+)
+
+func (m InterestMethod) IsValid() bool {
+	switch m {
+	case InterestMethodFlat, InterestMethodEffective:
+		return true
+	}
+	return false
+}
+
+type FeeType string
+
+const (
+	FeeTypeFlat    FeeType = "FLAT"
+	FeeTypePercent FeeType = "PERCENT"
+)
+
+func (t FeeType) IsValid() bool {
+	switch t {
+	case FeeTypeFlat, FeeTypePercent:
+		return true
+	}
+	return false
+}
+
+type LoanProductFee struct {
+	Name  string  `json:"name"`
+	Type  FeeType `json:"type"`
+	Value float64 `json:"value"`
+}
+
+type LoanProduct struct {
+	ID                int              `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name              string           `json:"name" gorm:"type:VARCHAR(500);uniqueIndex;"`
+	MinAmount         int              `json:"minAmount" gorm:"type:INTEGER;default:0;"`
+	MaxAmount         int              `json:"maxAmount" gorm:"type:INTEGER;default:0;"`
+	AllowedTerms      []int            `json:"allowedTerms" gorm:"type:TEXT;serializer:json;"`
+	TermUnits         []TermUnit       `json:"termUnits" gorm:"type:TEXT;serializer:json;"`
+	MinRate           float64          `json:"minRate" gorm:"type:FLOAT;default:0;"`
+	MaxRate           float64          `json:"maxRate" gorm:"type:FLOAT;default:0;"`
+	Fees              []LoanProductFee `json:"fees" gorm:"type:TEXT;serializer:json;"`
+	InterestMethod    InterestMethod   `json:"interestMethod" gorm:"type:VARCHAR(50);"`
+	FundingWindowDays int              `json:"fundingWindowDays" gorm:"type:INTEGER;default:0;"`
+	IsActive          bool             `json:"isActive" gorm:"default:true;"`
+	BaseTimeStruct
+}
+
+func (LoanProduct) TableName() string {
+	return "loan_product"
+}
+
+func (p LoanProduct) AllowsTerm(term int) bool {
+	for _, allowed := range p.AllowedTerms {
+		if allowed == term {
+			return true
+		}
+	}
+	return false
+}
+
+func (p LoanProduct) AllowsTermUnit(termUnit TermUnit) bool {
+	for _, allowed := range p.TermUnits {
+		if allowed == termUnit {
+			return true
+		}
+	}
+	return false
+}
+
+type LoanProductsInput struct {
+	IsActive *bool
+}
+
+type LoanProductInput struct {
+	ID *int
+}
+
+type WhereLoanProduct struct {
+	ID       *int
+	IsActive *bool
+}
+
+func (w *WhereLoanProduct) Scan(input any) {
+	switch v := input.(type) {
+	case LoanProductInput:
+		w.ID = v.ID
+	case LoanProductsInput:
+		w.IsActive = v.IsActive
+	}
+}
+
+type AddLoanProductInput struct {
+	Name              string
+	MinAmount         int
+	MaxAmount         int
+	AllowedTerms      []int
+	TermUnits         []TermUnit
+	MinRate           float64
+	MaxRate           float64
+	Fees              []LoanProductFee
+	InterestMethod    InterestMethod
+	FundingWindowDays int
+}
+
+type UpdateLoanProductInput struct {
+	ID                int
+	Name              string
+	MinAmount         int
+	MaxAmount         int
+	AllowedTerms      []int
+	TermUnits         []TermUnit
+	MinRate           float64
+	MaxRate           float64
+	Fees              []LoanProductFee
+	InterestMethod    InterestMethod
+	FundingWindowDays int
+	IsActive          *bool
+}
@@ -0,0 +1,332 @@
+package entity
+
+import "time"
+
+type NotificationChannel string
+
+const (
+	NotificationChannelEmail    NotificationChannel = "EMAIL"
+	NotificationChannelSMS      NotificationChannel = "SMS"
+	NotificationChannelWhatsApp NotificationChannel = "WHATSAPP"
+	NotificationChannelPush     NotificationChannel = "PUSH"
+)
+
+func (c NotificationChannel) IsValid() bool {
+	switch c {
+	case NotificationChannelEmail, NotificationChannelSMS, NotificationChannelWhatsApp, NotificationChannelPush:
+		return true
+	}
+	return false
+}
+
+type NotificationRecipientType string
+
+const (
+	NotificationRecipientTypeInvestor NotificationRecipientType = "INVESTOR"
+	NotificationRecipientTypeBorrower NotificationRecipientType = "BORROWER"
+)
+
+func (t NotificationRecipientType) IsValid() bool {
+	switch t {
+	case NotificationRecipientTypeInvestor, NotificationRecipientTypeBorrower:
+		return true
+	}
+	return false
+}
+
+type NotificationTemplate string
+
+const (
+	NotificationTemplateInvestmentAgreement NotificationTemplate = "investment-agreement"
+	NotificationTemplateSignatureRequest    NotificationTemplate = "signature-request"
+	NotificationTemplateLoanApproved        NotificationTemplate = "loan-approved"
+	NotificationTemplateInvestmentReceived  NotificationTemplate = "investment-received"
+	NotificationTemplateLoanFunded          NotificationTemplate = "loan-funded"
+	NotificationTemplateLoanDisbursed       NotificationTemplate = "loan-disbursed"
+	NotificationTemplateRepaymentReceived   NotificationTemplate = "repayment-received"
+	NotificationTemplateLoanExpired         NotificationTemplate = "loan-expired"
+)
+
+func (t NotificationTemplate) IsValid() bool {
+	switch t {
+	case NotificationTemplateInvestmentAgreement, NotificationTemplateSignatureRequest,
+		NotificationTemplateLoanApproved, NotificationTemplateInvestmentReceived, NotificationTemplateLoanFunded,
+		NotificationTemplateLoanDisbursed, NotificationTemplateRepaymentReceived, NotificationTemplateLoanExpired:
+		return true
+	}
+	return false
+}
+
+// DefaultNotificationLanguage is used for recipients who did not choose a language.
+const DefaultNotificationLanguage = LanguageIndonesian
+
+type NotificationStatus string
+
+const (
+	NotificationStatusPending NotificationStatus = "PENDING"
+	NotificationStatusSending NotificationStatus = "SENDING"
+	NotificationStatusSent    NotificationStatus = "SENT"
+	// NotificationStatusDead is the dead letter state of a notification that failed every attempt, it is only resent by hand
+	NotificationStatusDead NotificationStatus = "DEAD"
+)
+
+func (s NotificationStatus) IsValid() bool {
+	switch s {
+	case NotificationStatusPending, NotificationStatusSending, NotificationStatusSent, NotificationStatusDead:
+		return true
+	}
+	return false
+}
+
+// Notification is the delivery log entry of one message on one channel. The rendered message is kept
+// so a failed or lost notification can be sent again exactly as it was.
+type Notification struct {
+	ID int `json:"id" gorm:"primaryKey;autoIncrement"`
+	// the same notification is only recorded once per key, empty keys are never deduplicated
+	Key           *string                   `json:"key" gorm:"type:VARCHAR(200);uniqueIndex;"`
+	LoanID        *int                      `json:"loanId" gorm:"index;"`
+	Channel       NotificationChannel       `json:"channel" gorm:"type:VARCHAR(50);index;"`
+	RecipientType NotificationRecipientType `json:"recipientType" gorm:"type:VARCHAR(50);"`
+	RecipientID   int                       `json:"recipientId" gorm:"type:INTEGER;"`
+	// the address on the channel: an email address, a phone number or a push subscription id
+	Recipient string               `json:"recipient" gorm:"type:VARCHAR(255);index;"`
+	Template  NotificationTemplate `json:"template" gorm:"type:VARCHAR(100);"`
+	// the language the template was rendered in, it falls back when the channel lacks the one asked for
+	Language Language `json:"language" gorm:"type:VARCHAR(10);"`
+	// email subject or push title, other channels have none
+	Subject string `json:"subject" gorm:"type:VARCHAR(255);"`
+	// the bodies carry signing and download links, they are not exposed
+	Body string `json:"-" gorm:"type:TEXT;"`
+	// the plain-text alternative of an email body
+	TextBody          string             `json:"-" gorm:"type:TEXT;"`
+	Status            NotificationStatus `json:"status" gorm:"type:VARCHAR(50);index;"`
+	Attempts          int                `json:"attempts" gorm:"type:INTEGER;default:0;"`
+	AvailableAt       time.Time          `json:"availableAt" gorm:"type:DATETIME;index;"`
+	LockedAt          *time.Time         `json:"lockedAt" gorm:"type:DATETIME;"`
+	LastError         *string            `json:"lastError" gorm:"type:TEXT;"`
+	ProviderMessageID *string            `json:"providerMessageId" gorm:"type:VARCHAR(255);"`
+	SentAt            *time.Time         `json:"sentAt" gorm:"type:DATETIME;"`
+	// ResendOfID points to the notification this one is a copy of
+	ResendOfID *int `json:"resendOfId" gorm:"index;"`
+	// files sent along with an email, read from storage on every attempt
+	Attachments []NotificationAttachment `json:"attachments" gorm:"type:TEXT;serializer:json;"`
+	BaseTimeStruct
+}
+
+func (Notification) TableName() string {
+	return "notification"
+}
+
+// NotificationPreference lists the channels an investor or borrower wants to be notified on,
+// and the contact details those channels need beyond what the profile holds.
+type NotificationPreference struct {
+	ID            int                       `json:"id" gorm:"primaryKey;autoIncrement"`
+	RecipientType NotificationRecipientType `json:"recipientType" gorm:"type:VARCHAR(50);uniqueIndex:idx_notification_preference_recipient;"`
+	RecipientID   int                       `json:"recipientId" gorm:"type:INTEGER;uniqueIndex:idx_notification_preference_recipient;"`
+	Channels      []NotificationChannel     `json:"channels" gorm:"type:TEXT;serializer:json;"`
+	// empty means DefaultNotificationLanguage
+	Language Language `json:"language" gorm:"type:VARCHAR(10);"`
+	// overrides the phone number of the profile for SMS and WhatsApp
+	Phone     *string `json:"phone" gorm:"type:VARCHAR(50);"`
+	PushToken *string `json:"pushToken" gorm:"type:VARCHAR(255);"`
+	BaseTimeStruct
+}
+
+func (NotificationPreference) TableName() string {
+	return "notification_preference"
+}
+
+// NotificationMessage is a notification rendered for one channel, ready to be handed to its provider.
+type NotificationMessage struct {
+	Channel  NotificationChannel  `json:"channel"`
+	To       string               `json:"to,omitempty"`
+	Template NotificationTemplate `json:"template"`
+	Language Language             `json:"language"`
+	Subject  string               `json:"subject,omitempty"`
+	// html for email, plain text on every other channel
+	Body string `json:"body"`
+	// the plain-text alternative of an email
+	TextBody    string                   `json:"textBody,omitempty"`
+	Attachments []NotificationAttachment `json:"attachments,omitempty"`
+}
+
+// NotificationAttachment is a stored file sent along with an email. Only the storage key is
+// recorded, the content is loaded right before the email is handed to its provider.
+type NotificationAttachment struct {
+	Name        string `json:"name"`
+	ContentType string `json:"contentType"`
+	StorageKey  string `json:"storageKey"`
+	// an inline attachment is shown in the html body, which refers to it as cid:<Name>
+	Inline  bool   `json:"inline,omitempty"`
+	Content []byte `json:"-"`
+}
+
+// NotificationPolicy is how often the notification dispatcher polls and how failed sends are retried.
+type NotificationPolicy struct {
+	PollInterval time.Duration
+	MaxAttempts  int
+	// the delay before the first retry, doubled after every failed attempt up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// a SENDING notification whose lock is older than this was abandoned by a dead process
+	LockTimeout time.Duration
+}
+
+// NotificationRecipient is who a notification is for and the contact details their profile holds.
+type NotificationRecipient struct {
+	Type  NotificationRecipientType
+	ID    int
+	Email string
+	Phone string
+}
+
+type NotifyInput struct {
+	// suffixed with the channel, so every channel is queued once per key
+	Key       string
+	LoanID    *int
+	Recipient NotificationRecipient
+	Template  NotificationTemplate
+	Data      any
+	// only sent by email, other channels ignore them
+	Attachments []NotificationAttachment
+}
+
+type InvestorAgreementTemplateData struct {
+	InvestorName string
+	InvestedAt   time.Time
+	Amount       int
+	AgreementURL string
+	SigningURL   string
+	// Attached is set when the agreement PDF travels with the email, Password tells
+	// which detail of the investor opens it
+	Attached bool
+	Password AgreementPassword
+}
+
+type SignatureRequestTemplateData struct {
+	SignerName   string
+	LoanID       int
+	AgreementURL string
+	SigningURL   string
+}
+
+type LoanApprovedTemplateData struct {
+	BorrowerName string
+	LoanID       int
+	Amount       int
+	Rate         float64
+	Term         int
+	TermUnit     TermUnit
+	// zero when the product has no funding window
+	FundingDeadline time.Time
+}
+
+type InvestmentReceivedTemplateData struct {
+	InvestorName string
+	LoanID       int
+	Amount       int
+	InvestedAt   time.Time
+	// how much of the loan is funded including this investment
+	LoanAmount     int
+	InvestedAmount int
+}
+
+type LoanFundedTemplateData struct {
+	BorrowerName  string
+	LoanID        int
+	Amount        int
+	InvestorCount int
+}
+
+type LoanDisbursedTemplateData struct {
+	BorrowerName string
+	LoanID       int
+	Amount       int
+	DisbursedAt  time.Time
+	Term         int
+	TermUnit     TermUnit
+}
+
+type RepaymentReceivedTemplateData struct {
+	BorrowerName string
+	LoanID       int
+	Amount       int
+	PaidAt       time.Time
+	// what is left to repay after this payment
+	Outstanding int
+}
+
+type LoanExpiredTemplateData struct {
+	BorrowerName    string
+	LoanID          int
+	Amount          int
+	InvestedAmount  int
+	FundingDeadline time.Time
+}
+
+type PreviewNotificationInput struct {
+	Channel  NotificationChannel
+	Template NotificationTemplate
+	Language Language
+}
+
+type SetNotificationPreferenceInput struct {
+	RecipientType NotificationRecipientType
+	RecipientID   int
+	Channels      []NotificationChannel
+	Language      Language
+	Phone         *string
+	PushToken     *string
+}
+
+type NotificationsInput struct {
+	LoanID    *int
+	Channel   *NotificationChannel
+	Status    *NotificationStatus
+	Recipient *string
+}
+
+type NotificationInput struct {
+	ID  *int
+	Key *string
+}
+
+type WhereNotification struct {
+	ID        *int
+	Key       *string
+	LoanID    *int
+	Channel   *NotificationChannel
+	Status    *NotificationStatus
+	Recipient *string
+}
+
+func (w *WhereNotification) Scan(input any) {
+	switch v := input.(type) {
+	case NotificationInput:
+		w.ID = v.ID
+		w.Key = v.Key
+	case NotificationsInput:
+		w.LoanID = v.LoanID
+		w.Channel = v.Channel
+		w.Status = v.Status
+		w.Recipient = v.Recipient
+	}
+}
+
+type NotificationPreferenceInput struct {
+	RecipientType *NotificationRecipientType
+	RecipientID   *int
+}
+
+type WhereNotificationPreference struct {
+	RecipientType *NotificationRecipientType
+	RecipientID   *int
+}
+
+func (w *WhereNotificationPreference) Scan(input any) {
+	switch v := input.(type) {
+	case NotificationPreferenceInput:
+		w.RecipientType = v.RecipientType
+		w.RecipientID = v.RecipientID
+	}
+}
@@ -0,0 +1,106 @@
+package entity
+
+import "time"
+
+type OutboxEventType string
+
+const (
+	// OutboxEventTypeLoanFunded generates the draft agreement of a fully funded loan and opens its signing
+	OutboxEventTypeLoanFunded OutboxEventType = "LOAN_FUNDED"
+	// OutboxEventTypeInvestorAgreementMail mails one investor their agreement and signing link
+	OutboxEventTypeInvestorAgreementMail OutboxEventType = "INVESTOR_AGREEMENT_MAIL"
+	// OutboxEventTypeAgreementSigned renders the signed agreement once the last party signed
+	OutboxEventTypeAgreementSigned OutboxEventType = "AGREEMENT_SIGNED"
+)
+
+func (t OutboxEventType) IsValid() bool {
+	switch t {
+	case OutboxEventTypeLoanFunded, OutboxEventTypeInvestorAgreementMail, OutboxEventTypeAgreementSigned:
+		return true
+	}
+	return false
+}
+
+type OutboxEventStatus string
+
+const (
+	OutboxEventStatusPending    OutboxEventStatus = "PENDING"
+	OutboxEventStatusProcessing OutboxEventStatus = "PROCESSING"
+	OutboxEventStatusDone       OutboxEventStatus = "DONE"
+	// OutboxEventStatusFailed is set once every attempt failed, the event is only retried by hand
+	OutboxEventStatusFailed OutboxEventStatus = "FAILED"
+)
+
+func (s OutboxEventStatus) IsValid() bool {
+	switch s {
+	case OutboxEventStatusPending, OutboxEventStatusProcessing, OutboxEventStatusDone, OutboxEventStatusFailed:
+		return true
+	}
+	return false
+}
+
+type OutboxPayload struct {
+	LoanID     int `json:"loanId"`
+	InvestorID int `json:"investorId,omitempty"`
+}
+
+// OutboxEvent is a side effect recorded in the same transaction as the change that causes it,
+// the dispatcher processes it afterwards and retries it until it succeeds.
+type OutboxEvent struct {
+	ID     int             `json:"id" gorm:"primaryKey;autoIncrement"`
+	Type   OutboxEventType `json:"type" gorm:"type:VARCHAR(50);index;"`
+	LoanID int             `json:"loanId" gorm:"index;"`
+	// the same side effect is only recorded once per key
+	Key         string            `json:"key" gorm:"type:VARCHAR(200);uniqueIndex;"`
+	Payload     OutboxPayload     `json:"payload" gorm:"type:TEXT;serializer:json;"`
+	Status      OutboxEventStatus `json:"status" gorm:"type:VARCHAR(50);index;"`
+	Attempts    int               `json:"attempts" gorm:"type:INTEGER;default:0;"`
+	AvailableAt time.Time         `json:"availableAt" gorm:"type:DATETIME;index;"`
+	LockedAt    *time.Time        `json:"lockedAt" gorm:"type:DATETIME;"`
+	LastError   *string           `json:"lastError" gorm:"type:TEXT;"`
+	ProcessedAt *time.Time        `json:"processedAt" gorm:"type:DATETIME;"`
+	BaseTimeStruct
+}
+
+func (OutboxEvent) TableName() string {
+	return "outbox_event"
+}
+
+// OutboxPolicy is how often the dispatcher polls and how failed events are retried.
+type OutboxPolicy struct {
+	PollInterval time.Duration
+	MaxAttempts  int
+	// the delay before the first retry, doubled after every failed attempt up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// a PROCESSING event whose lock is older than this was abandoned by a dead process
+	LockTimeout time.Duration
+}
+
+type OutboxEventsInput struct {
+	Status *OutboxEventStatus
+	Type   *OutboxEventType
+	LoanID *int
+}
+
+type OutboxEventInput struct {
+	ID *int
+}
+
+type WhereOutboxEvent struct {
+	ID     *int
+	Status *OutboxEventStatus
+	Type   *OutboxEventType
+	LoanID *int
+}
+
+func (w *WhereOutboxEvent) Scan(input any) {
+	switch v := input.(type) {
+	case OutboxEventInput:
+		w.ID = v.ID
+	case OutboxEventsInput:
+		w.Status = v.Status
+		w.Type = v.Type
+		w.LoanID = v.LoanID
+	}
+}
@@ -0,0 +1,55 @@
+package entity
+
+type PhotoProofDuplicateAction string
+
+const (
+	PhotoProofDuplicateActionFlag   PhotoProofDuplicateAction = "FLAG"
+	PhotoProofDuplicateActionReject PhotoProofDuplicateAction = "REJECT"
+)
+
+func (a PhotoProofDuplicateAction) IsValid() bool {
+	switch a {
+	case PhotoProofDuplicateActionFlag, PhotoProofDuplicateActionReject:
+		return true
+	}
+	return false
+}
+
+// PhotoProofDuplicate records an approval attempt whose photo proof is a near-duplicate
+// of the photo proof of another loan.
+type PhotoProofDuplicate struct {
+	ID                int                       `json:"id" gorm:"primaryKey;autoIncrement"`
+	LoanID            int                       `json:"loanId" gorm:"index;uniqueIndex:idx_photo_proof_duplicate_loan_file;"`
+	FileID            int                       `json:"fileId" gorm:"index;"`
+	EmployeeID        int                       `json:"employeeId" gorm:"index;"`
+	DuplicateOfLoanID int                       `json:"duplicateOfLoanId" gorm:"index;"`
+	DuplicateOfFileID int                       `json:"duplicateOfFileId" gorm:"index;uniqueIndex:idx_photo_proof_duplicate_loan_file;"`
+	Distance          int                       `json:"distance" gorm:"type:INTEGER;"`
+	Action            PhotoProofDuplicateAction `json:"action" gorm:"type:VARCHAR(50);"`
+	BaseTimeStruct
+}
+
+func (PhotoProofDuplicate) TableName() string {
+	return "photo_proof_duplicate"
+}
+
+type PhotoProofDuplicatesInput struct {
+	LoanID     *int
+	EmployeeID *int
+	Action     *PhotoProofDuplicateAction
+}
+
+type WherePhotoProofDuplicate struct {
+	LoanID     *int
+	EmployeeID *int
+	Action     *PhotoProofDuplicateAction
+}
+
+func (w *WherePhotoProofDuplicate) Scan(input any) {
+	switch v := input.(type) {
+	case PhotoProofDuplicatesInput:
+		w.LoanID = v.LoanID
+		w.EmployeeID = v.EmployeeID
+		w.Action = v.Action
+	}
+}
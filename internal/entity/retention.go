@@ -0,0 +1,77 @@
+package entity
+
+import "time"
+
+// RetentionRule is how long uploads of one purpose are kept.
+type RetentionRule struct {
+	Purpose FilePurpose `json:"purpose"`
+	// days an upload that was never attached is kept, 0 uses orphanUploadDays
+	UnreferencedDays int `json:"unreferencedDays"`
+	// days an attached upload is kept, 0 keeps it forever
+	ReferencedDays int `json:"referencedDays"`
+	// days an upload is kept after it was rejected in review, 0 leaves it to the other periods
+	RejectedDays int `json:"rejectedDays"`
+}
+
+type RetentionPolicy struct {
+	// days an upload that is not attached to a loan or investor is kept when its purpose has no rule
+	OrphanUploadDays int `json:"orphanUploadDays"`
+	// days a generated agreement document is kept after the term of its loan ended, 0 keeps it forever
+	AgreementDays int             `json:"agreementDays"`
+	Rules         []RetentionRule `json:"rules"`
+	// the scheduled job only reports while DryRun is set, it is taken from RETENTION_DRY_RUN
+	DryRun bool `json:"-"`
+}
+
+// Rule returns the rule of a purpose, purposes without a rule only expire while unreferenced.
+func (p RetentionPolicy) Rule(purpose FilePurpose) (result RetentionRule) {
+	result = RetentionRule{
+		Purpose: purpose,
+	}
+	for _, rule := range p.Rules {
+		if rule.Purpose == purpose {
+			result = rule
+			break
+		}
+	}
+	if result.UnreferencedDays == 0 {
+		result.UnreferencedDays = p.OrphanUploadDays
+	}
+	return
+}
+
+type RetentionCandidateKind string
+
+const (
+	RetentionCandidateKindFile              RetentionCandidateKind = "FILE"
+	RetentionCandidateKindAgreementDocument RetentionCandidateKind = "AGREEMENT_DOCUMENT"
+)
+
+// RetentionCandidate is a stored object whose retention period is over.
+type RetentionCandidate struct {
+	Kind       RetentionCandidateKind `json:"kind"`
+	ID         int                    `json:"id"`
+	Key        string                 `json:"key"`
+	Purpose    FilePurpose            `json:"purpose,omitempty"`
+	Referenced bool                   `json:"referenced"`
+	Rejected   bool                   `json:"rejected"`
+	LoanID     *int                   `json:"loanId"`
+	URL        string                 `json:"-"`
+	CreatedAt  time.Time              `json:"createdAt"`
+	ExpiredAt  time.Time              `json:"expiredAt"`
+	Deleted    bool                   `json:"deleted"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+type RetentionReport struct {
+	DryRun     bool                 `json:"dryRun"`
+	RanAt      time.Time            `json:"ranAt"`
+	Candidates []RetentionCandidate `json:"candidates"`
+	Deleted    int                  `json:"deleted"`
+	Failed     int                  `json:"failed"`
+}
+
+type RetentionRunInput struct {
+	// DryRun only reports what would be deleted
+	DryRun bool
+}
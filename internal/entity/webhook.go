@@ -0,0 +1,263 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type WebhookEventType string
+
+const (
+	WebhookEventTypeLoanProposed           WebhookEventType = "LOAN_PROPOSED"
+	WebhookEventTypeLoanApproved           WebhookEventType = "LOAN_APPROVED"
+	WebhookEventTypeLoanInvested           WebhookEventType = "LOAN_INVESTED"
+	WebhookEventTypeLoanFunded             WebhookEventType = "LOAN_FUNDED"
+	WebhookEventTypeLoanAgreementGenerated WebhookEventType = "LOAN_AGREEMENT_GENERATED"
+	WebhookEventTypeLoanDisbursed          WebhookEventType = "LOAN_DISBURSED"
+)
+
+func (t WebhookEventType) IsValid() bool {
+	switch t {
+	case WebhookEventTypeLoanProposed, WebhookEventTypeLoanApproved, WebhookEventTypeLoanInvested,
+		WebhookEventTypeLoanFunded, WebhookEventTypeLoanAgreementGenerated, WebhookEventTypeLoanDisbursed:
+		return true
+	}
+	return false
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "PENDING"
+	WebhookDeliveryStatusSending   WebhookDeliveryStatus = "SENDING"
+	WebhookDeliveryStatusDelivered WebhookDeliveryStatus = "DELIVERED"
+	// WebhookDeliveryStatusDead is the dead letter state of a delivery that failed every attempt, it is only replayed by hand
+	WebhookDeliveryStatusDead WebhookDeliveryStatus = "DEAD"
+)
+
+func (s WebhookDeliveryStatus) IsValid() bool {
+	switch s {
+	case WebhookDeliveryStatusPending, WebhookDeliveryStatusSending, WebhookDeliveryStatusDelivered, WebhookDeliveryStatusDead:
+		return true
+	}
+	return false
+}
+
+// WebhookSubscription is a partner endpoint that receives the loan events it subscribed to.
+// Every delivery is signed with the secret, so the partner can tell it came from us.
+type WebhookSubscription struct {
+	ID         int                `json:"id" gorm:"primaryKey;autoIncrement"`
+	URL        string             `json:"url" gorm:"type:VARCHAR(2000);"`
+	Secret     string             `json:"-" gorm:"type:VARCHAR(255);"`
+	EventTypes []WebhookEventType `json:"eventTypes" gorm:"type:TEXT;serializer:json;"`
+	IsActive   bool               `json:"isActive" gorm:"default:true;"`
+	BaseTimeStruct
+}
+
+func (WebhookSubscription) TableName() string {
+	return "webhook_subscription"
+}
+
+func (s WebhookSubscription) Subscribes(eventType WebhookEventType) bool {
+	for _, t := range s.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookEvent is a loan lifecycle event as it is sent to every subscription, Data is kept as sent
+// so a replay delivers the same body.
+type WebhookEvent struct {
+	ID   int              `json:"id" gorm:"primaryKey;autoIncrement"`
+	Type WebhookEventType `json:"type" gorm:"type:VARCHAR(100);index;"`
+	// the same event is only emitted once per key
+	Key        string          `json:"key" gorm:"type:VARCHAR(200);uniqueIndex;"`
+	LoanID     int             `json:"loanId" gorm:"index;"`
+	Data       json.RawMessage `json:"data" gorm:"type:TEXT;serializer:json;"`
+	OccurredAt time.Time       `json:"occurredAt" gorm:"type:DATETIME;"`
+	BaseTimeStruct
+}
+
+func (WebhookEvent) TableName() string {
+	return "webhook_event"
+}
+
+// WebhookDelivery is the delivery log entry of one event to one subscription.
+type WebhookDelivery struct {
+	ID             int                   `json:"id" gorm:"primaryKey;autoIncrement"`
+	EventID        int                   `json:"eventId" gorm:"index;"`
+	SubscriptionID int                   `json:"subscriptionId" gorm:"index;"`
+	EventType      WebhookEventType      `json:"eventType" gorm:"type:VARCHAR(100);index;"`
+	LoanID         int                   `json:"loanId" gorm:"index;"`
+	Status         WebhookDeliveryStatus `json:"status" gorm:"type:VARCHAR(50);index;"`
+	Attempts       int                   `json:"attempts" gorm:"type:INTEGER;default:0;"`
+	AvailableAt    time.Time             `json:"availableAt" gorm:"type:DATETIME;index;"`
+	LockedAt       *time.Time            `json:"lockedAt" gorm:"type:DATETIME;"`
+	// the HTTP status of the last attempt, nil when the endpoint could not be reached
+	ResponseStatus *int       `json:"responseStatus" gorm:"type:INTEGER;"`
+	LastError      *string    `json:"lastError" gorm:"type:TEXT;"`
+	DeliveredAt    *time.Time `json:"deliveredAt" gorm:"type:DATETIME;"`
+	// ReplayOfID points to the delivery this one replays
+	ReplayOfID *int `json:"replayOfId" gorm:"index;"`
+	BaseTimeStruct
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_delivery"
+}
+
+// WebhookPayload is the body of every delivery.
+type WebhookPayload struct {
+	ID         int              `json:"id"`
+	Type       WebhookEventType `json:"type"`
+	LoanID     int              `json:"loanId"`
+	OccurredAt time.Time        `json:"occurredAt"`
+	Data       json.RawMessage  `json:"data"`
+}
+
+// WebhookEventData is what an event tells about the loan, Investment is only set on LOAN_INVESTED.
+type WebhookEventData struct {
+	Loan       WebhookLoan        `json:"loan"`
+	Investment *WebhookInvestment `json:"investment,omitempty"`
+}
+
+// WebhookLoan is the part of a loan partners are told about, the borrower, the photo proof,
+// the agreement letters and the employees handling the loan are left out.
+type WebhookLoan struct {
+	ID              int            `json:"id"`
+	Status          LoanStatus     `json:"status"`
+	ProductID       int            `json:"productId"`
+	Amount          int            `json:"amount"`
+	InvestedAmount  int            `json:"investedAmount"`
+	Rate            float64        `json:"rate"`
+	Term            int            `json:"term"`
+	TermUnit        TermUnit       `json:"termUnit"`
+	InterestMethod  InterestMethod `json:"interestMethod"`
+	RiskGrade       RiskGrade      `json:"riskGrade"`
+	ApprovedAt      *time.Time     `json:"approvedAt"`
+	FundingDeadline *time.Time     `json:"fundingDeadline"`
+	FullyInvestedAt *time.Time     `json:"fullyInvestedAt"`
+	DisbursedAt     *time.Time     `json:"disbursedAt"`
+	CreatedAt       time.Time      `json:"createdAt"`
+}
+
+// WebhookInvestment is an investment placed in the loan, without the investor who placed it.
+type WebhookInvestment struct {
+	ID         int       `json:"id"`
+	Amount     int       `json:"amount"`
+	InvestedAt time.Time `json:"investedAt"`
+}
+
+// WebhookPolicy is how often the webhook dispatcher polls and how failed deliveries are retried.
+type WebhookPolicy struct {
+	PollInterval time.Duration
+	MaxAttempts  int
+	// the delay before the first retry, doubled after every failed attempt up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// a SENDING delivery whose lock is older than this was abandoned by a dead process
+	LockTimeout time.Duration
+	// how long a partner endpoint gets to answer
+	Timeout time.Duration
+	// lets subscriptions point at loopback and private network addresses, only for local development
+	AllowPrivateNetworks bool
+}
+
+type EmitWebhookEventInput struct {
+	Key    string
+	Type   WebhookEventType
+	LoanID int
+	Data   WebhookEventData
+	// now when zero
+	OccurredAt time.Time
+}
+
+type AddWebhookSubscriptionInput struct {
+	URL string
+	// generated when empty
+	Secret     string
+	EventTypes []WebhookEventType
+}
+
+type UpdateWebhookSubscriptionInput struct {
+	ID  int
+	URL string
+	// the current secret is kept when empty
+	Secret     string
+	EventTypes []WebhookEventType
+	// the subscription stays as active as it is when left out
+	IsActive *bool
+}
+
+type WebhookSubscriptionsInput struct {
+	IsActive *bool
+}
+
+type WebhookSubscriptionInput struct {
+	ID *int
+}
+
+type WhereWebhookSubscription struct {
+	ID       *int
+	IsActive *bool
+}
+
+func (w *WhereWebhookSubscription) Scan(input any) {
+	switch v := input.(type) {
+	case WebhookSubscriptionInput:
+		w.ID = v.ID
+	case WebhookSubscriptionsInput:
+		w.IsActive = v.IsActive
+	}
+}
+
+type WebhookEventInput struct {
+	ID  *int
+	Key *string
+}
+
+type WhereWebhookEvent struct {
+	ID  *int
+	Key *string
+}
+
+func (w *WhereWebhookEvent) Scan(input any) {
+	switch v := input.(type) {
+	case WebhookEventInput:
+		w.ID = v.ID
+		w.Key = v.Key
+	}
+}
+
+type WebhookDeliveriesInput struct {
+	SubscriptionID *int
+	EventType      *WebhookEventType
+	Status         *WebhookDeliveryStatus
+	LoanID         *int
+}
+
+type WebhookDeliveryInput struct {
+	ID *int
+}
+
+type WhereWebhookDelivery struct {
+	ID             *int
+	SubscriptionID *int
+	EventType      *WebhookEventType
+	Status         *WebhookDeliveryStatus
+	LoanID         *int
+}
+
+func (w *WhereWebhookDelivery) Scan(input any) {
+	switch v := input.(type) {
+	case WebhookDeliveryInput:
+		w.ID = v.ID
+	case WebhookDeliveriesInput:
+		w.SubscriptionID = v.SubscriptionID
+		w.EventType = v.EventType
+		w.Status = v.Status
+		w.LoanID = v.LoanID
+	}
+}
@@ -0,0 +1,45 @@
+package exif
+
+import (
+	"io"
+	"time"
+
+	goexif "github.com/rwcarlsen/goexif/exif"
+)
+
+type Metadata struct {
+	TakenAt   *time.Time
+	Latitude  *float64
+	Longitude *float64
+	// EXIF orientation tag, 1 (upright) when missing
+	Orientation int
+}
+
+// Extract reads the capture time and gps position from the EXIF block of an image.
+// Fields that are not present are left nil, an image without EXIF returns an empty Metadata.
+func Extract(r io.Reader) (result Metadata) {
+	result.Orientation = 1
+	x, err := goexif.Decode(r)
+	if x == nil || (err != nil && goexif.IsCriticalError(err)) {
+		return
+	}
+
+	takenAt, err := x.DateTime()
+	if err == nil {
+		takenAtUTC := takenAt.UTC()
+		result.TakenAt = &takenAtUTC
+	}
+	lat, long, err := x.LatLong()
+	if err == nil {
+		result.Latitude = &lat
+		result.Longitude = &long
+	}
+	tag, err := x.Get(goexif.Orientation)
+	if err == nil {
+		orientation, err := tag.Int(0)
+		if err == nil {
+			result.Orientation = orientation
+		}
+	}
+	return
+}
@@ -1,13 +1,39 @@
 package mail
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"strings"
 
 	"gopkg.in/gomail.v2"
 )
 
+// Message is one mail. With both bodies set it is sent as multipart/alternative, so clients
+// that do not show html fall back to the text.
+type Message struct {
+	To          string
+	Subject     string
+	HTML        string
+	Text        string
+	Attachments []Attachment
+}
+
+// Attachment is a file sent along with a mail. An inline attachment is shown inside the html
+// body, which refers to it as cid:<Name>, for example <img src="cid:logo.png">.
+type Attachment struct {
+	Name string
+	// guessed from the extension of Name when empty
+	ContentType string
+	Content     []byte
+	Inline      bool
+}
+
 type Mailer interface {
-	SendMail(to string, subject string, body string) error
+	// SendMail returns the Message-ID the mail was sent with, so it can be traced at the provider
+	SendMail(mail Message) (messageID string, err error)
 }
 
 type mailer struct {
@@ -28,27 +54,83 @@ func NewMailer(from, smtpHost string, smtpPort int, username, password string) M
 	}
 }
 
-func (m *mailer) SendMail(to string, subject string, body string) error {
+func (m *mailer) SendMail(mail Message) (messageID string, err error) {
+	if mail.HTML == "" && mail.Text == "" {
+		err = errors.New("mail has no body")
+		return
+	}
+	messageID, err = m.newMessageID()
+	if err != nil {
+		return
+	}
+
 	// Create a new message
 	message := gomail.NewMessage()
 
 	// Set email headers
 	message.SetHeader("From", m.from)
-	message.SetHeader("To", to)
-	message.SetHeader("Subject", subject)
+	message.SetHeader("To", mail.To)
+	message.SetHeader("Subject", mail.Subject)
+	message.SetHeader("Message-ID", messageID)
 
-	// Set email body
-	message.SetBody("text/html", body)
+	// Set email body, the last alternative is the one clients prefer
+	switch {
+	case mail.Text == "":
+		message.SetBody("text/html", mail.HTML)
+	case mail.HTML == "":
+		message.SetBody("text/plain", mail.Text)
+	default:
+		message.SetBody("text/plain", mail.Text)
+		message.AddAlternative("text/html", mail.HTML)
+	}
+
+	for _, attachment := range mail.Attachments {
+		if attachment.Name == "" {
+			err = errors.New("attachment has no name")
+			return
+		}
+		content := attachment.Content
+		settings := []gomail.FileSetting{
+			gomail.SetCopyFunc(func(w io.Writer) error {
+				_, err := w.Write(content)
+				return err
+			}),
+		}
+		if attachment.ContentType != "" {
+			settings = append(settings, gomail.SetHeader(map[string][]string{
+				"Content-Type": {attachment.ContentType},
+			}))
+		}
+		if attachment.Inline {
+			message.Embed(attachment.Name, settings...)
+		} else {
+			message.Attach(attachment.Name, settings...)
+		}
+	}
 
 	// Set up the SMTP dialer
 	dialer := gomail.NewDialer(m.smtpHost, m.smtpPort, m.username, m.password)
 
 	// Send the email
-	if err := dialer.DialAndSend(message); err != nil {
-		fmt.Println("Error:", err)
-		panic(err)
-	} else {
-		fmt.Println("Email sent successfully!")
+	err = dialer.DialAndSend(message)
+	if err != nil {
+		messageID = ""
+		return
+	}
+	return
+}
+
+// newMessageID builds a unique Message-ID under the domain of the sender address.
+func (m *mailer) newMessageID() (messageID string, err error) {
+	b := make([]byte, 16)
+	_, err = rand.Read(b)
+	if err != nil {
+		return
+	}
+	domain := m.smtpHost
+	if at := strings.LastIndex(m.from, "@"); at >= 0 {
+		domain = strings.Trim(m.from[at+1:], "> ")
 	}
-	return nil
+	messageID = fmt.Sprintf("<%s@%s>", hex.EncodeToString(b), domain)
+	return
 }
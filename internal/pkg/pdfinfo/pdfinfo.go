@@ -0,0 +1,63 @@
+package pdfinfo
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"regexp"
+)
+
+// maxSize bounds how much of a document is read, agreement scans are far smaller.
+const maxSize = 50 << 20
+
+var (
+	pageObject   = regexp.MustCompile(`/Type\s*/Page\b`)
+	objectStream = regexp.MustCompile(`(?s)<<((?:[^<>]|<<[^<>]*>>)*)>>\s*stream\r?\n`)
+)
+
+// PageCount checks that r holds a complete pdf and counts its page objects.
+// Pages packed into compressed object streams are counted as well.
+func PageCount(r io.Reader) (result int, err error) {
+	content, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return
+	}
+	if len(content) > maxSize {
+		err = errors.New("pdf is too large")
+		return
+	}
+	if !bytes.HasPrefix(content, []byte("%PDF-")) {
+		err = errors.New("file is not a pdf")
+		return
+	}
+	tail := content[max(0, len(content)-1024):]
+	if !bytes.Contains(tail, []byte("%%EOF")) {
+		err = errors.New("pdf is truncated")
+		return
+	}
+
+	result = len(pageObject.FindAllIndex(content, -1))
+	for _, match := range objectStream.FindAllSubmatchIndex(content, -1) {
+		dict := content[match[2]:match[3]]
+		if !bytes.Contains(dict, []byte("/ObjStm")) || !bytes.Contains(dict, []byte("/FlateDecode")) {
+			continue
+		}
+		end := bytes.Index(content[match[1]:], []byte("endstream"))
+		if end < 0 {
+			continue
+		}
+		zr, zerr := zlib.NewReader(bytes.NewReader(content[match[1] : match[1]+end]))
+		if zerr != nil {
+			continue
+		}
+		objects, _ := io.ReadAll(io.LimitReader(zr, maxSize))
+		_ = zr.Close()
+		result += len(pageObject.FindAllIndex(objects, -1))
+	}
+	if result == 0 {
+		err = errors.New("pdf has no pages")
+		return
+	}
+	return
+}
@@ -0,0 +1,81 @@
+package pdfinfo
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+func renderPDF(t *testing.T, pages int) []byte {
+	t.Helper()
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Arial", "", 12)
+	for i := 1; i <= pages; i++ {
+		pdf.AddPage()
+		pdf.Cell(40, 10, fmt.Sprintf("page %d", i))
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// objectStreamPDF packs the page objects into a compressed object stream, as newer writers do.
+func objectStreamPDF(t *testing.T, pages int) []byte {
+	t.Helper()
+	var objects bytes.Buffer
+	zw := zlib.NewWriter(&objects)
+	for i := 0; i < pages; i++ {
+		fmt.Fprintf(zw, "<< /Type /Page /Parent 2 0 R >>\n")
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.5\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+	buf.WriteString("2 0 obj\n<< /Type /Pages /Count " + fmt.Sprint(pages) + " >>\nendobj\n")
+	fmt.Fprintf(&buf, "3 0 obj\n<< /Type /ObjStm /N %d /First 0 /Length %d /Filter /FlateDecode >>\nstream\n", pages, objects.Len())
+	buf.Write(objects.Bytes())
+	buf.WriteString("\nendstream\nendobj\n%%EOF\n")
+	return buf.Bytes()
+}
+
+func TestPageCount(t *testing.T) {
+	onePage := renderPDF(t, 1)
+	tests := []struct {
+		name    string
+		content []byte
+		want    int
+		wantErr string
+	}{
+		{name: "one page", content: onePage, want: 1},
+		{name: "several pages", content: renderPDF(t, 3), want: 3},
+		{name: "pages in an object stream", content: objectStreamPDF(t, 4), want: 4},
+		{name: "not a pdf", content: []byte("PK\x03\x04 not a pdf"), wantErr: "file is not a pdf"},
+		{name: "truncated", content: onePage[:len(onePage)/2], wantErr: "pdf is truncated"},
+		{name: "no pages", content: []byte("%PDF-1.4\n1 0 obj\n<< /Type /Pages /Count 0 >>\nendobj\n%%EOF\n"), wantErr: "pdf has no pages"},
+		{name: "too large", content: append([]byte("%PDF-1.4\n"), make([]byte, maxSize)...), wantErr: "pdf is too large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PageCount(bytes.NewReader(tt.content))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("PageCount() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PageCount() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("PageCount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package phash
+
+import (
+	"fmt"
+	"image"
+	"math/bits"
+	"strconv"
+)
+
+const (
+	hashWidth  = 9
+	hashHeight = 8
+	// samples taken per cell side, keeps hashing of large photos cheap
+	cellSamples = 8
+)
+
+// Compute returns the difference hash (dHash) of an image: the image is shrunk to
+// 9x8 grayscale cells and each bit records whether a cell is brighter than its right neighbour.
+// Resized, recompressed or slightly edited copies of a photo end up with a small hamming distance.
+func Compute(img image.Image) uint64 {
+	b := img.Bounds()
+	var cells [hashHeight][hashWidth]float64
+	for cy := 0; cy < hashHeight; cy++ {
+		y0 := b.Min.Y + cy*b.Dy()/hashHeight
+		y1 := b.Min.Y + (cy+1)*b.Dy()/hashHeight
+		for cx := 0; cx < hashWidth; cx++ {
+			x0 := b.Min.X + cx*b.Dx()/hashWidth
+			x1 := b.Min.X + (cx+1)*b.Dx()/hashWidth
+			cells[cy][cx] = averageLuminance(img, x0, y0, x1, y1)
+		}
+	}
+
+	var hash uint64
+	for cy := 0; cy < hashHeight; cy++ {
+		for cx := 0; cx < hashWidth-1; cx++ {
+			hash <<= 1
+			if cells[cy][cx] > cells[cy][cx+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+func averageLuminance(img image.Image, x0, y0, x1, y1 int) float64 {
+	if x1 <= x0 {
+		x1 = x0 + 1
+	}
+	if y1 <= y0 {
+		y1 = y0 + 1
+	}
+	stepX := max(1, (x1-x0)/cellSamples)
+	stepY := max(1, (y1-y0)/cellSamples)
+	total := 0.0
+	count := 0
+	for y := y0; y < y1; y += stepY {
+		for x := x0; x < x1; x += stepX {
+			r, g, b, _ := img.At(x, y).RGBA()
+			total += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+			count++
+		}
+	}
+	return total / float64(count)
+}
+
+// Distance is the number of differing bits between two hashes, 0 means identical.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// BandCount is how many 16 bit bands a hash is split into so near-duplicates can be looked up in
+// an index. Two hashes within distance d have a band that differs in at most d/BandCount bits.
+const BandCount = 4
+
+// MaxLookupDistance is the largest distance band lookups are done for, farther needs too many
+// band values to look up.
+const MaxLookupDistance = 3*BandCount - 1
+
+// Bands splits a hash into its 16 bit bands, the lowest bits first.
+func Bands(hash uint64) (result [BandCount]uint16) {
+	for i := range result {
+		result[i] = uint16(hash >> (16 * i))
+	}
+	return
+}
+
+// BandNeighbours lists the band values that differ from band in at most radius bits, band included.
+func BandNeighbours(band uint16, radius int) []uint16 {
+	result := []uint16{band}
+	var flip func(value uint16, from int, left int)
+	flip = func(value uint16, from int, left int) {
+		if left == 0 {
+			return
+		}
+		for bit := from; bit < 16; bit++ {
+			next := value ^ 1<<bit
+			result = append(result, next)
+			flip(next, bit+1, left-1)
+		}
+	}
+	flip(band, 0, radius)
+	return result
+}
+
+func Format(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+func Parse(hash string) (uint64, error) {
+	return strconv.ParseUint(hash, 16, 64)
+}
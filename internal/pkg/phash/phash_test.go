@@ -0,0 +1,67 @@
+package phash
+
+import (
+	"math/bits"
+	"testing"
+)
+
+func TestBands(t *testing.T) {
+	got := Bands(0x0123456789abcdef)
+	want := [BandCount]uint16{0xcdef, 0x89ab, 0x4567, 0x0123}
+	if got != want {
+		t.Errorf("Bands() = %04x, want %04x", got, want)
+	}
+}
+
+func TestBandNeighbours(t *testing.T) {
+	tests := []struct {
+		radius    int
+		wantCount int
+	}{
+		{radius: 0, wantCount: 1},
+		{radius: 1, wantCount: 17},
+		{radius: 2, wantCount: 137},
+	}
+	for _, tt := range tests {
+		band := uint16(0xa5a5)
+		got := BandNeighbours(band, tt.radius)
+		if len(got) != tt.wantCount {
+			t.Errorf("BandNeighbours(radius %d) has %d values, want %d", tt.radius, len(got), tt.wantCount)
+		}
+		seen := map[uint16]bool{}
+		for _, value := range got {
+			if seen[value] {
+				t.Errorf("BandNeighbours(radius %d) lists %04x twice", tt.radius, value)
+			}
+			seen[value] = true
+			if bits.OnesCount16(value^band) > tt.radius {
+				t.Errorf("BandNeighbours(radius %d) lists %04x, %d bits away", tt.radius, value, bits.OnesCount16(value^band))
+			}
+		}
+	}
+}
+
+// every hash within MaxLookupDistance shares a band neighbour with the hash it is compared to
+func TestBandLookupFindsNearHashes(t *testing.T) {
+	hash := uint64(0x0123456789abcdef)
+	// flip the bits spread over the bands, the worst case for the lookup
+	near := hash
+	for i := 0; i < MaxLookupDistance; i++ {
+		near ^= 1 << ((i%BandCount)*16 + i/BandCount)
+	}
+	if Distance(hash, near) != MaxLookupDistance {
+		t.Fatalf("distance = %d, want %d", Distance(hash, near), MaxLookupDistance)
+	}
+	nearBands := Bands(near)
+	found := false
+	for i, band := range Bands(hash) {
+		for _, value := range BandNeighbours(band, MaxLookupDistance/BandCount) {
+			if value == nearBands[i] {
+				found = true
+			}
+		}
+	}
+	if !found {
+		t.Error("a hash within MaxLookupDistance shares no band neighbour")
+	}
+}
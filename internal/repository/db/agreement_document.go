@@ -0,0 +1,16 @@
+package db
+
+import (
+	"context"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+)
+
+type AgreementDocumentRepository interface {
+	Create(ctx context.Context, item *entity.AgreementDocument) (err error)
+	Delete(ctx context.Context, item *entity.AgreementDocument) (err error)
+
+	AgreementDocuments(ctx context.Context, filter entity.AgreementDocumentsInput) (result []entity.AgreementDocument, err error)
+	CountAgreementDocuments(ctx context.Context, filter entity.AgreementDocumentsInput) (result int64, err error)
+	AgreementDocument(ctx context.Context, filter entity.AgreementDocumentInput) (result entity.AgreementDocument, err error)
+}
@@ -0,0 +1,18 @@
+package db
+
+import (
+	"context"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+)
+
+type AgreementSignatureRepository interface {
+	Create(ctx context.Context, item *entity.AgreementSignature) (err error)
+	Update(ctx context.Context, item *entity.AgreementSignature) (err error)
+	Sign(ctx context.Context, item *entity.AgreementSignature, signedEvent *entity.OutboxEvent) (completed bool, err error)
+	RenewToken(ctx context.Context, item *entity.AgreementSignature, previousToken string) (renewed bool, err error)
+
+	AgreementSignatures(ctx context.Context, filter entity.AgreementSignaturesInput) (result []entity.AgreementSignature, err error)
+	CountAgreementSignatures(ctx context.Context, filter entity.AgreementSignaturesInput) (result int64, err error)
+	AgreementSignature(ctx context.Context, filter entity.AgreementSignatureInput) (result entity.AgreementSignature, err error)
+}
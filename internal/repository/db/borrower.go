@@ -0,0 +1,17 @@
+package db
+
+import (
+	"context"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+)
+
+type BorrowerRepository interface {
+	Create(ctx context.Context, item *entity.Borrower) (err error)
+	Update(ctx context.Context, item *entity.Borrower) (err error)
+	Delete(ctx context.Context, item *entity.Borrower) (err error)
+
+	Borrowers(ctx context.Context, filter entity.BorrowersInput) (result []entity.Borrower, err error)
+	CountBorrowers(ctx context.Context, filter entity.BorrowersInput) (result int64, err error)
+	Borrower(ctx context.Context, filter entity.BorrowerInput) (result entity.Borrower, err error)
+}
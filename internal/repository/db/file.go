@@ -0,0 +1,17 @@
+package db
+
+import (
+	"context"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+)
+
+type FileRepository interface {
+	Create(ctx context.Context, item *entity.File) (err error)
+	Update(ctx context.Context, item *entity.File) (err error)
+	Delete(ctx context.Context, item *entity.File) (err error)
+
+	Files(ctx context.Context, filter entity.FilesInput) (result []entity.File, err error)
+	CountFiles(ctx context.Context, filter entity.FilesInput) (result int64, err error)
+	File(ctx context.Context, filter entity.FileInput) (result entity.File, err error)
+}
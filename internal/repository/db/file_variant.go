@@ -0,0 +1,14 @@
+package db
+
+import (
+	"context"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+)
+
+type FileVariantRepository interface {
+	Create(ctx context.Context, item *entity.FileVariant) (err error)
+	Delete(ctx context.Context, item *entity.FileVariant) (err error)
+
+	FileVariants(ctx context.Context, filter entity.FileVariantsInput) (result []entity.FileVariant, err error)
+}
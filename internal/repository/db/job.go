@@ -0,0 +1,19 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+)
+
+type JobRepository interface {
+	Create(ctx context.Context, item *entity.Job) (err error)
+	Update(ctx context.Context, item *entity.Job) (err error)
+	Claim(ctx context.Context, workerID string, now time.Time, lease time.Duration) (result entity.Job, claimed bool, err error)
+	ExtendLease(ctx context.Context, item *entity.Job, leaseExpiresAt time.Time) (err error)
+	Finish(ctx context.Context, item *entity.Job, lockToken string) (finished bool, err error)
+
+	Jobs(ctx context.Context, filter entity.JobsInput) (result []entity.Job, err error)
+	Job(ctx context.Context, filter entity.JobInput) (result entity.Job, err error)
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"time"
 
 	"github.com/adityaokke/test-amartha/internal/entity"
 )
@@ -10,6 +11,7 @@ type LoanRepository interface {
 	Create(ctx context.Context, item *entity.Loan) (err error)
 	Update(ctx context.Context, item *entity.Loan) (err error)
 	Delete(ctx context.Context, item *entity.Loan) (err error)
+	Expire(ctx context.Context, item *entity.Loan, now time.Time) (expired bool, err error)
 
 	Loans(ctx context.Context, filter entity.LoansInput) (result []entity.Loan, err error)
 	CountLoans(ctx context.Context, filter entity.LoansInput) (result int64, err error)
@@ -2,12 +2,13 @@ package db
 
 import (
 	"context"
+	"time"
 
 	"github.com/adityaokke/test-amartha/internal/entity"
 )
 
 type LoanInvestmentRepository interface {
-	InvestLoan(ctx context.Context, item *entity.LoanInvestment) (err error)
+	InvestLoan(ctx context.Context, item *entity.LoanInvestment, fundedEvent *entity.OutboxEvent) (fundedAt *time.Time, err error)
 
 	LoanInvestments(ctx context.Context, filter entity.LoanInvestmentsInput) (result []entity.LoanInvestment, err error)
 	CountLoanInvestments(ctx context.Context, filter entity.LoanInvestmentsInput) (result int64, err error)
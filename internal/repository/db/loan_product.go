@@ -0,0 +1,17 @@
+package db
+
+import (
+	"context"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+)
+
+type LoanProductRepository interface {
+	Create(ctx context.Context, item *entity.LoanProduct) (err error)
+	Update(ctx context.Context, item *entity.LoanProduct) (err error)
+	Delete(ctx context.Context, item *entity.LoanProduct) (err error)
+
+	LoanProducts(ctx context.Context, filter entity.LoanProductsInput) (result []entity.LoanProduct, err error)
+	CountLoanProducts(ctx context.Context, filter entity.LoanProductsInput) (result int64, err error)
+	LoanProduct(ctx context.Context, filter entity.LoanProductInput) (result entity.LoanProduct, err error)
+}
@@ -0,0 +1,18 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+)
+
+type NotificationRepository interface {
+	Create(ctx context.Context, item *entity.Notification) (err error)
+	Update(ctx context.Context, item *entity.Notification) (err error)
+	Claim(ctx context.Context, item *entity.Notification, lockedBefore time.Time) (claimed bool, err error)
+
+	Notifications(ctx context.Context, filter entity.NotificationsInput) (result []entity.Notification, err error)
+	DueNotifications(ctx context.Context, now time.Time, lockedBefore time.Time) (result []entity.Notification, err error)
+	Notification(ctx context.Context, filter entity.NotificationInput) (result entity.Notification, err error)
+}
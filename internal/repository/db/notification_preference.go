@@ -0,0 +1,14 @@
+package db
+
+import (
+	"context"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+)
+
+type NotificationPreferenceRepository interface {
+	Create(ctx context.Context, item *entity.NotificationPreference) (err error)
+	Update(ctx context.Context, item *entity.NotificationPreference) (err error)
+
+	NotificationPreference(ctx context.Context, filter entity.NotificationPreferenceInput) (result entity.NotificationPreference, err error)
+}
@@ -0,0 +1,18 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+)
+
+type OutboxEventRepository interface {
+	Create(ctx context.Context, item *entity.OutboxEvent) (err error)
+	Update(ctx context.Context, item *entity.OutboxEvent) (err error)
+	Claim(ctx context.Context, item *entity.OutboxEvent, lockedBefore time.Time) (claimed bool, err error)
+
+	OutboxEvents(ctx context.Context, filter entity.OutboxEventsInput) (result []entity.OutboxEvent, err error)
+	DueOutboxEvents(ctx context.Context, now time.Time, lockedBefore time.Time) (result []entity.OutboxEvent, err error)
+	OutboxEvent(ctx context.Context, filter entity.OutboxEventInput) (result entity.OutboxEvent, err error)
+}
@@ -0,0 +1,14 @@
+package db
+
+import (
+	"context"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+)
+
+type PhotoProofDuplicateRepository interface {
+	Create(ctx context.Context, item *entity.PhotoProofDuplicate) (err error)
+
+	PhotoProofDuplicates(ctx context.Context, filter entity.PhotoProofDuplicatesInput) (result []entity.PhotoProofDuplicate, err error)
+	CountPhotoProofDuplicates(ctx context.Context, filter entity.PhotoProofDuplicatesInput) (result int64, err error)
+}
@@ -0,0 +1,145 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/repository/db"
+	"gorm.io/gorm"
+)
+
+type agreementDocumentRepository struct {
+	db *gorm.DB
+}
+
+// Create numbers the document after the latest version of its series, the loan, investor,
+// variant, language and format it was rendered for. The unique version index rejects a
+// document numbered by a concurrent transaction instead of storing the version twice.
+func (r agreementDocumentRepository) Create(ctx context.Context, item *entity.AgreementDocument) (err error) {
+	err = r.db.Transaction(func(tx *gorm.DB) (errTx error) {
+		series := tx.Model(&entity.AgreementDocument{}).
+			Where("loan_id = ? AND variant = ? AND language = ? AND format = ?", item.LoanID, item.Variant, item.Language, item.Format)
+		if item.InvestorID != nil {
+			series = series.Where("investor_id = ?", *item.InvestorID)
+		} else {
+			series = series.Where("investor_id IS NULL")
+		}
+		var latest int
+		errTx = series.Select("COALESCE(MAX(version), 0)").Scan(&latest).Error
+		if errTx != nil {
+			return
+		}
+		item.Version = latest + 1
+		errTx = tx.Create(item).Error
+		return
+	})
+	return
+}
+
+func (r agreementDocumentRepository) Delete(ctx context.Context, item *entity.AgreementDocument) (err error) {
+	db := r.db
+
+	if err := db.Delete(item).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func getWhereAgreementDocument(db *gorm.DB, filter *entity.WhereAgreementDocument) *gorm.DB {
+	tableName := entity.AgreementDocument{}.TableName()
+	if filter.ID != nil {
+		db = db.Where(tableName+".id = ?", *filter.ID)
+	}
+	if filter.LoanID != nil {
+		db = db.Where(tableName+".loan_id = ?", *filter.LoanID)
+	}
+	if filter.InvestorID != nil {
+		db = db.Where(tableName+".investor_id = ?", *filter.InvestorID)
+	}
+	if filter.Variant != nil {
+		db = db.Where(tableName+".variant = ?", *filter.Variant)
+	}
+	if filter.Language != nil {
+		db = db.Where(tableName+".language = ?", *filter.Language)
+	}
+	if filter.Format != nil {
+		db = db.Where(tableName+".format = ?", *filter.Format)
+	}
+	if filter.SHA256 != nil {
+		db = db.Where(tableName+".sha256 = ?", *filter.SHA256)
+	}
+	if filter.VerificationCode != nil {
+		db = db.Where(tableName+".verification_code = ?", *filter.VerificationCode)
+	}
+	if filter.CreatedBefore != nil {
+		db = db.Where(tableName+".created_at < ?", *filter.CreatedBefore)
+	}
+	return db
+}
+
+func (r agreementDocumentRepository) AgreementDocuments(ctx context.Context, filter entity.AgreementDocumentsInput) (result []entity.AgreementDocument, err error) {
+	db := r.db
+
+	where := entity.WhereAgreementDocument{}
+	where.Scan(filter)
+	db = getWhereAgreementDocument(db, &where)
+
+	if err = db.Order("id ASC").Find(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r agreementDocumentRepository) CountAgreementDocuments(ctx context.Context, filter entity.AgreementDocumentsInput) (result int64, err error) {
+	db := r.db
+
+	where := entity.WhereAgreementDocument{}
+	where.Scan(filter)
+	db = getWhereAgreementDocument(db, &where)
+
+	if err = db.Model(&entity.AgreementDocument{}).Count(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r agreementDocumentRepository) AgreementDocument(ctx context.Context, filter entity.AgreementDocumentInput) (result entity.AgreementDocument, err error) {
+	db := r.db
+
+	where := entity.WhereAgreementDocument{}
+	where.Scan(filter)
+	db = getWhereAgreementDocument(db, &where)
+
+	if _, ok := db.Statement.Clauses["WHERE"]; !ok {
+		err = gorm.ErrMissingWhereClause
+		return
+	}
+
+	if err = db.First(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+/* -------------------------------- initiator ------------------------------- */
+type initiatorAgreementDocumentRepository func(s *agreementDocumentRepository) *agreementDocumentRepository
+
+func NewAgreementDocumentRepository() initiatorAgreementDocumentRepository {
+	return func(q *agreementDocumentRepository) *agreementDocumentRepository {
+		return q
+	}
+}
+
+func (i initiatorAgreementDocumentRepository) SetDBConnection(db *gorm.DB) initiatorAgreementDocumentRepository {
+	return func(s *agreementDocumentRepository) *agreementDocumentRepository {
+		i(s).db = db
+		return s
+	}
+}
+
+func (i initiatorAgreementDocumentRepository) Build() db.AgreementDocumentRepository {
+	return i(&agreementDocumentRepository{})
+}
@@ -0,0 +1,184 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/repository/db"
+	"gorm.io/gorm"
+)
+
+type agreementSignatureRepository struct {
+	db *gorm.DB
+}
+
+func (r agreementSignatureRepository) Create(ctx context.Context, item *entity.AgreementSignature) (err error) {
+	db := r.db
+
+	if err = db.Create(item).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r agreementSignatureRepository) Update(ctx context.Context, item *entity.AgreementSignature) (err error) {
+	db := r.db
+
+	if err = db.Save(item).Error; err != nil {
+		return
+	}
+	return
+}
+
+// RenewToken stores the new token of a pending signature unless it was signed or
+// renewed with another token in the meantime.
+func (r agreementSignatureRepository) RenewToken(ctx context.Context, item *entity.AgreementSignature, previousToken string) (renewed bool, err error) {
+	res := r.db.Model(&entity.AgreementSignature{}).
+		Where("id = ? AND token = ? AND status = ?", item.ID, previousToken, entity.SignatureStatusPending).
+		Updates(map[string]interface{}{
+			"token":            item.Token,
+			"token_expires_at": item.TokenExpiresAt,
+		})
+	err = res.Error
+	if err != nil {
+		return
+	}
+	renewed = res.RowsAffected > 0
+	return
+}
+
+// Sign stores a captured signature unless it was signed in the meantime. When it was the last
+// pending signature of the loan, signedEvent is recorded in the same transaction.
+func (r agreementSignatureRepository) Sign(ctx context.Context, item *entity.AgreementSignature, signedEvent *entity.OutboxEvent) (completed bool, err error) {
+	err = r.db.Transaction(func(tx *gorm.DB) (errTx error) {
+		res := tx.Model(&entity.AgreementSignature{}).
+			Where("id = ? AND status = ?", item.ID, entity.SignatureStatusPending).
+			Updates(map[string]interface{}{
+				"typed_name":           item.TypedName,
+				"signature_image_path": item.SignatureImagePath,
+				"signed_at":            item.SignedAt,
+				"ip_address":           item.IPAddress,
+				"user_agent":           item.UserAgent,
+				"status":               entity.SignatureStatusSigned,
+			})
+		errTx = res.Error
+		if errTx != nil {
+			return
+		}
+		if res.RowsAffected == 0 {
+			errTx = errors.New("agreement is already signed")
+			return
+		}
+
+		var pending int64
+		errTx = tx.Model(&entity.AgreementSignature{}).
+			Where("loan_id = ? AND status = ?", item.LoanID, entity.SignatureStatusPending).
+			Count(&pending).Error
+		if errTx != nil {
+			return
+		}
+		if pending > 0 {
+			return
+		}
+		completed = true
+		if signedEvent != nil {
+			errTx = createOutboxEvent(tx, signedEvent)
+			if errTx != nil {
+				return
+			}
+		}
+		return
+	})
+	if err != nil {
+		completed = false
+		return
+	}
+	item.Status = entity.SignatureStatusSigned
+	return
+}
+
+func getWhereAgreementSignature(db *gorm.DB, filter *entity.WhereAgreementSignature) *gorm.DB {
+	tableName := entity.AgreementSignature{}.TableName()
+	if filter.ID != nil {
+		db = db.Where(tableName+".id = ?", *filter.ID)
+	}
+	if filter.Token != nil {
+		db = db.Where(tableName+".token = ?", *filter.Token)
+	}
+	if filter.LoanID != nil {
+		db = db.Where(tableName+".loan_id = ?", *filter.LoanID)
+	}
+	if filter.Status != nil {
+		db = db.Where(tableName+".status = ?", *filter.Status)
+	}
+	return db
+}
+
+func (r agreementSignatureRepository) AgreementSignatures(ctx context.Context, filter entity.AgreementSignaturesInput) (result []entity.AgreementSignature, err error) {
+	db := r.db
+
+	where := entity.WhereAgreementSignature{}
+	where.Scan(filter)
+	db = getWhereAgreementSignature(db, &where)
+
+	if err = db.Find(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r agreementSignatureRepository) CountAgreementSignatures(ctx context.Context, filter entity.AgreementSignaturesInput) (result int64, err error) {
+	db := r.db
+
+	where := entity.WhereAgreementSignature{}
+	where.Scan(filter)
+	db = getWhereAgreementSignature(db, &where)
+
+	if err = db.Model(&entity.AgreementSignature{}).Count(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r agreementSignatureRepository) AgreementSignature(ctx context.Context, filter entity.AgreementSignatureInput) (result entity.AgreementSignature, err error) {
+	db := r.db
+
+	where := entity.WhereAgreementSignature{}
+	where.Scan(filter)
+	db = getWhereAgreementSignature(db, &where)
+
+	if _, ok := db.Statement.Clauses["WHERE"]; !ok {
+		err = gorm.ErrMissingWhereClause
+		return
+	}
+
+	if err = db.First(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+/* -------------------------------- initiator ------------------------------- */
+type initiatorAgreementSignatureRepository func(s *agreementSignatureRepository) *agreementSignatureRepository
+
+func NewAgreementSignatureRepository() initiatorAgreementSignatureRepository {
+	return func(q *agreementSignatureRepository) *agreementSignatureRepository {
+		return q
+	}
+}
+
+func (i initiatorAgreementSignatureRepository) SetDBConnection(db *gorm.DB) initiatorAgreementSignatureRepository {
+	return func(s *agreementSignatureRepository) *agreementSignatureRepository {
+		i(s).db = db
+		return s
+	}
+}
+
+func (i initiatorAgreementSignatureRepository) Build() db.AgreementSignatureRepository {
+	return i(&agreementSignatureRepository{})
+}
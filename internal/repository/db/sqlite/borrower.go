@@ -0,0 +1,123 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/repository/db"
+	"gorm.io/gorm"
+)
+
+type borrowerRepository struct {
+	db *gorm.DB
+}
+
+func (r borrowerRepository) Create(ctx context.Context, item *entity.Borrower) (err error) {
+	db := r.db
+
+	if err = db.Create(item).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r borrowerRepository) Update(ctx context.Context, item *entity.Borrower) (err error) {
+	db := r.db
+
+	if err = db.Save(item).Error; err != nil {
+		return
+	}
+	return
+}
+
+func (r borrowerRepository) Delete(ctx context.Context, item *entity.Borrower) (err error) {
+	db := r.db
+
+	if err := db.Delete(item).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func getWhereBorrower(db *gorm.DB, filter *entity.WhereBorrower) *gorm.DB {
+	tableName := entity.Borrower{}.TableName()
+	if filter.ID != nil {
+		db = db.Where(tableName+".id = ?", *filter.ID)
+	}
+	if filter.IDs != nil {
+		if len(*filter.IDs) > 0 {
+			db = db.Where(tableName+".id IN (?)", *filter.IDs)
+		} else {
+			db = db.Where("1 = 0")
+		}
+	}
+	return db
+}
+
+func (r borrowerRepository) Borrowers(ctx context.Context, filter entity.BorrowersInput) (result []entity.Borrower, err error) {
+	db := r.db
+
+	where := entity.WhereBorrower{}
+	where.Scan(filter)
+	db = getWhereBorrower(db, &where)
+
+	if err = db.Find(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r borrowerRepository) CountBorrowers(ctx context.Context, filter entity.BorrowersInput) (result int64, err error) {
+	db := r.db
+
+	where := entity.WhereBorrower{}
+	where.Scan(filter)
+	db = getWhereBorrower(db, &where)
+
+	if err = db.Model(&entity.Borrower{}).Count(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r borrowerRepository) Borrower(ctx context.Context, filter entity.BorrowerInput) (result entity.Borrower, err error) {
+	db := r.db
+
+	where := entity.WhereBorrower{}
+	where.Scan(filter)
+	db = getWhereBorrower(db, &where)
+
+	if _, ok := db.Statement.Clauses["WHERE"]; !ok {
+		err = gorm.ErrMissingWhereClause
+		return
+	}
+
+	if err = db.First(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+/* -------------------------------- initiator ------------------------------- */
+type initiatorBorrowerRepository func(s *borrowerRepository) *borrowerRepository
+
+func NewBorrowerRepository() initiatorBorrowerRepository {
+	return func(q *borrowerRepository) *borrowerRepository {
+		return q
+	}
+}
+
+func (i initiatorBorrowerRepository) SetDBConnection(db *gorm.DB) initiatorBorrowerRepository {
+	return func(s *borrowerRepository) *borrowerRepository {
+		i(s).db = db
+		return s
+	}
+}
+
+func (i initiatorBorrowerRepository) Build() db.BorrowerRepository {
+	return i(&borrowerRepository{})
+}
@@ -0,0 +1,177 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/repository/db"
+	"gorm.io/gorm"
+)
+
+type fileRepository struct {
+	db *gorm.DB
+}
+
+func (r fileRepository) Create(ctx context.Context, item *entity.File) (err error) {
+	db := r.db
+
+	if err = db.Create(item).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r fileRepository) Update(ctx context.Context, item *entity.File) (err error) {
+	db := r.db
+
+	if err = db.Save(item).Error; err != nil {
+		return
+	}
+	return
+}
+
+func (r fileRepository) Delete(ctx context.Context, item *entity.File) (err error) {
+	db := r.db
+
+	if err := db.Delete(item).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func getWhereFile(db *gorm.DB, filter *entity.WhereFile) *gorm.DB {
+	tableName := entity.File{}.TableName()
+	if filter.ID != nil {
+		db = db.Where(tableName+".id = ?", *filter.ID)
+	}
+	if filter.IDs != nil {
+		if len(*filter.IDs) > 0 {
+			db = db.Where(tableName+".id IN (?)", *filter.IDs)
+		} else {
+			db = db.Where("1 = 0")
+		}
+	}
+	if filter.Name != nil {
+		db = db.Where(tableName+".name = ?", *filter.Name)
+	}
+	if filter.LoanID != nil {
+		db = db.Where(tableName+".loan_id = ?", *filter.LoanID)
+	}
+	if filter.InvestorID != nil {
+		db = db.Where(tableName+".investor_id = ?", *filter.InvestorID)
+	}
+	if filter.Purpose != nil {
+		db = db.Where(tableName+".purpose = ?", *filter.Purpose)
+	}
+	if filter.ContentTypes != nil {
+		if len(*filter.ContentTypes) > 0 {
+			db = db.Where(tableName+".content_type IN (?)", *filter.ContentTypes)
+		} else {
+			db = db.Where("1 = 0")
+		}
+	}
+	if filter.HasPerceptualHash != nil {
+		if *filter.HasPerceptualHash {
+			db = db.Where(tableName + ".perceptual_hash IS NOT NULL")
+		} else {
+			db = db.Where(tableName + ".perceptual_hash IS NULL")
+		}
+	}
+	if filter.PerceptualHashPending != nil && *filter.PerceptualHashPending {
+		db = db.Where("((" + tableName + ".perceptual_hash IS NULL AND " + tableName + ".perceptual_hash_error IS NULL) OR (" +
+			tableName + ".perceptual_hash IS NOT NULL AND " + tableName + ".perceptual_hash_band0 IS NULL))")
+	}
+	if filter.PerceptualHashBands != nil {
+		conditions := []string{}
+		values := []any{}
+		for i, bandValues := range *filter.PerceptualHashBands {
+			if len(bandValues) == 0 {
+				continue
+			}
+			conditions = append(conditions, fmt.Sprintf("%s.perceptual_hash_band%d IN (?)", tableName, i))
+			values = append(values, bandValues)
+		}
+		if len(conditions) > 0 {
+			db = db.Where("("+strings.Join(conditions, " OR ")+")", values...)
+		} else {
+			db = db.Where("1 = 0")
+		}
+	}
+	if filter.CreatedBefore != nil {
+		db = db.Where(tableName+".created_at < ?", *filter.CreatedBefore)
+	}
+	if filter.ReviewStatus != nil {
+		db = db.Where(tableName+".review_status = ?", *filter.ReviewStatus)
+	}
+	return db
+}
+
+func (r fileRepository) Files(ctx context.Context, filter entity.FilesInput) (result []entity.File, err error) {
+	db := r.db
+
+	where := entity.WhereFile{}
+	where.Scan(filter)
+	db = getWhereFile(db, &where)
+
+	if err = db.Find(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r fileRepository) CountFiles(ctx context.Context, filter entity.FilesInput) (result int64, err error) {
+	db := r.db
+
+	where := entity.WhereFile{}
+	where.Scan(filter)
+	db = getWhereFile(db, &where)
+
+	if err = db.Model(&entity.File{}).Count(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r fileRepository) File(ctx context.Context, filter entity.FileInput) (result entity.File, err error) {
+	db := r.db
+
+	where := entity.WhereFile{}
+	where.Scan(filter)
+	db = getWhereFile(db, &where)
+
+	if _, ok := db.Statement.Clauses["WHERE"]; !ok {
+		err = gorm.ErrMissingWhereClause
+		return
+	}
+
+	if err = db.First(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+/* -------------------------------- initiator ------------------------------- */
+type initiatorFileRepository func(s *fileRepository) *fileRepository
+
+func NewFileRepository() initiatorFileRepository {
+	return func(q *fileRepository) *fileRepository {
+		return q
+	}
+}
+
+func (i initiatorFileRepository) SetDBConnection(db *gorm.DB) initiatorFileRepository {
+	return func(s *fileRepository) *fileRepository {
+		i(s).db = db
+		return s
+	}
+}
+
+func (i initiatorFileRepository) Build() db.FileRepository {
+	return i(&fileRepository{})
+}
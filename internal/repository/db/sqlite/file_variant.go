@@ -0,0 +1,81 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/repository/db"
+	"gorm.io/gorm"
+)
+
+type fileVariantRepository struct {
+	db *gorm.DB
+}
+
+func (r fileVariantRepository) Create(ctx context.Context, item *entity.FileVariant) (err error) {
+	db := r.db
+
+	if err = db.Create(item).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r fileVariantRepository) Delete(ctx context.Context, item *entity.FileVariant) (err error) {
+	db := r.db
+
+	if err := db.Delete(item).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func getWhereFileVariant(db *gorm.DB, filter *entity.WhereFileVariant) *gorm.DB {
+	tableName := entity.FileVariant{}.TableName()
+	if filter.FileIDs != nil {
+		if len(*filter.FileIDs) > 0 {
+			db = db.Where(tableName+".file_id IN (?)", *filter.FileIDs)
+		} else {
+			db = db.Where("1 = 0")
+		}
+	}
+	if filter.Kind != nil {
+		db = db.Where(tableName+".kind = ?", *filter.Kind)
+	}
+	return db
+}
+
+func (r fileVariantRepository) FileVariants(ctx context.Context, filter entity.FileVariantsInput) (result []entity.FileVariant, err error) {
+	db := r.db
+
+	where := entity.WhereFileVariant{}
+	where.Scan(filter)
+	db = getWhereFileVariant(db, &where)
+
+	if err = db.Order("id ASC").Find(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+/* -------------------------------- initiator ------------------------------- */
+type initiatorFileVariantRepository func(s *fileVariantRepository) *fileVariantRepository
+
+func NewFileVariantRepository() initiatorFileVariantRepository {
+	return func(q *fileVariantRepository) *fileVariantRepository {
+		return q
+	}
+}
+
+func (i initiatorFileVariantRepository) SetDBConnection(db *gorm.DB) initiatorFileVariantRepository {
+	return func(s *fileVariantRepository) *fileVariantRepository {
+		i(s).db = db
+		return s
+	}
+}
+
+func (i initiatorFileVariantRepository) Build() db.FileVariantRepository {
+	return i(&fileVariantRepository{})
+}
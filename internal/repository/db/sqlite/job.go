@@ -0,0 +1,195 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/repository/db"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type jobRepository struct {
+	db *gorm.DB
+}
+
+// Create enqueues a job unless a job with the same key exists, the item keeps a zero ID in that case.
+func (r jobRepository) Create(ctx context.Context, item *entity.Job) (err error) {
+	db := r.db
+
+	if err = db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoNothing: true,
+	}).Create(item).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r jobRepository) Update(ctx context.Context, item *entity.Job) (err error) {
+	db := r.db
+
+	if err = db.Save(item).Error; err != nil {
+		return
+	}
+	return
+}
+
+// whereJobDue matches queued jobs that are due and running jobs whose worker lost the lease,
+// a job whose lease ran out on its last attempt is not run again.
+func whereJobDue(db *gorm.DB, now time.Time) *gorm.DB {
+	tableName := entity.Job{}.TableName()
+	return db.Where("("+tableName+".status = ? AND "+tableName+".run_at <= ?) OR ("+tableName+".status = ? AND "+tableName+".lease_expires_at < ? AND "+tableName+".attempts < "+tableName+".max_attempts)",
+		entity.JobStatusQueued, now, entity.JobStatusRunning, now)
+}
+
+// Claim locks the job that is due first for one worker. The job is selected and locked in a single
+// statement, so two workers never claim the same job.
+func (r jobRepository) Claim(ctx context.Context, workerID string, now time.Time, lease time.Duration) (result entity.Job, claimed bool, err error) {
+	db := r.db
+
+	// a worker that died on the last attempt leaves the job running, it has no attempt left to claim
+	if err = db.Model(&entity.Job{}).
+		Where("status = ? AND lease_expires_at < ? AND attempts >= max_attempts", entity.JobStatusRunning, now).
+		Updates(map[string]interface{}{
+			"status":           entity.JobStatusDead,
+			"lock_token":       nil,
+			"locked_by":        nil,
+			"lease_expires_at": nil,
+			"last_error":       "lease expired on the last attempt",
+			"finished_at":      now,
+		}).Error; err != nil {
+		return
+	}
+
+	next := whereJobDue(db.Session(&gorm.Session{NewDB: true}).Model(&entity.Job{}).Select("id"), now).
+		Order("run_at ASC, id ASC").
+		Limit(1)
+	lockToken := uuid.New().String()
+	res := whereJobDue(db.Model(&entity.Job{}).Where("id = (?)", next), now).
+		Updates(map[string]interface{}{
+			"status":           entity.JobStatusRunning,
+			"attempts":         gorm.Expr("attempts + 1"),
+			"lock_token":       lockToken,
+			"locked_by":        workerID,
+			"lease_expires_at": now.Add(lease),
+		})
+	if err = res.Error; err != nil {
+		return
+	}
+	if res.RowsAffected == 0 {
+		return
+	}
+	result, err = r.Job(ctx, entity.JobInput{
+		LockToken: &lockToken,
+	})
+	if err != nil {
+		return
+	}
+	claimed = true
+	return
+}
+
+func (r jobRepository) ExtendLease(ctx context.Context, item *entity.Job, leaseExpiresAt time.Time) (err error) {
+	db := r.db
+
+	if err = db.Model(&entity.Job{}).
+		Where("id = ? AND lock_token = ?", item.ID, item.LockToken).
+		UpdateColumn("lease_expires_at", leaseExpiresAt).Error; err != nil {
+		return
+	}
+	item.LeaseExpiresAt = &leaseExpiresAt
+	return
+}
+
+// Finish stores the outcome of a run, it is not stored when the lease was lost
+// and another worker claimed the job in the meantime.
+func (r jobRepository) Finish(ctx context.Context, item *entity.Job, lockToken string) (finished bool, err error) {
+	db := r.db
+
+	res := db.Model(&entity.Job{}).
+		Where("id = ? AND lock_token = ?", item.ID, lockToken).
+		Select("status", "run_at", "lock_token", "locked_by", "lease_expires_at", "last_error", "finished_at").
+		Updates(item)
+	if err = res.Error; err != nil {
+		return
+	}
+	finished = res.RowsAffected > 0
+	return
+}
+
+func getWhereJob(db *gorm.DB, filter *entity.WhereJob) *gorm.DB {
+	tableName := entity.Job{}.TableName()
+	if filter.ID != nil {
+		db = db.Where(tableName+".id = ?", *filter.ID)
+	}
+	if filter.Key != nil {
+		db = db.Where(tableName+".key = ?", *filter.Key)
+	}
+	if filter.LockToken != nil {
+		db = db.Where(tableName+".lock_token = ?", *filter.LockToken)
+	}
+	if filter.Status != nil {
+		db = db.Where(tableName+".status = ?", *filter.Status)
+	}
+	if filter.Type != nil {
+		db = db.Where(tableName+".type = ?", *filter.Type)
+	}
+	return db
+}
+
+func (r jobRepository) Jobs(ctx context.Context, filter entity.JobsInput) (result []entity.Job, err error) {
+	db := r.db
+
+	where := entity.WhereJob{}
+	where.Scan(filter)
+	db = getWhereJob(db, &where)
+
+	if err = db.Order("id DESC").Find(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r jobRepository) Job(ctx context.Context, filter entity.JobInput) (result entity.Job, err error) {
+	db := r.db
+
+	where := entity.WhereJob{}
+	where.Scan(filter)
+	db = getWhereJob(db, &where)
+
+	if _, ok := db.Statement.Clauses["WHERE"]; !ok {
+		err = gorm.ErrMissingWhereClause
+		return
+	}
+
+	if err = db.First(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+/* -------------------------------- initiator ------------------------------- */
+type initiatorJobRepository func(s *jobRepository) *jobRepository
+
+func NewJobRepository() initiatorJobRepository {
+	return func(q *jobRepository) *jobRepository {
+		return q
+	}
+}
+
+func (i initiatorJobRepository) SetDBConnection(db *gorm.DB) initiatorJobRepository {
+	return func(s *jobRepository) *jobRepository {
+		i(s).db = db
+		return s
+	}
+}
+
+func (i initiatorJobRepository) Build() db.JobRepository {
+	return i(&jobRepository{})
+}
@@ -2,6 +2,7 @@ package sqlite
 
 import (
 	"context"
+	"time"
 
 	"github.com/adityaokke/test-amartha/internal/entity"
 	"github.com/adityaokke/test-amartha/internal/repository/db"
@@ -40,6 +41,28 @@ func (r loanRepository) Delete(ctx context.Context, item *entity.Loan) (err erro
 	return nil
 }
 
+// Expire moves an approved loan past its funding deadline to EXPIRED. A loan that got fully
+// funded or changed status in the meantime is left alone and expired is false.
+func (r loanRepository) Expire(ctx context.Context, item *entity.Loan, now time.Time) (expired bool, err error) {
+	db := r.db
+
+	res := db.Model(&entity.Loan{}).
+		Where("id = ? AND status = ? AND funding_deadline < ? AND fully_invested_at IS NULL", item.ID, entity.LoanStatusApproved, now).
+		Updates(map[string]interface{}{
+			"status":     entity.LoanStatusExpired,
+			"updated_at": now,
+		})
+	if err = res.Error; err != nil {
+		return
+	}
+	if res.RowsAffected == 0 {
+		return
+	}
+	item.Status = entity.LoanStatusExpired
+	expired = true
+	return
+}
+
 func getWhereLoan(db *gorm.DB, filter *entity.WhereLoan) *gorm.DB {
 	tableName := entity.Loan{}.TableName()
 	if filter.ID != nil {
@@ -51,6 +74,23 @@ func getWhereLoan(db *gorm.DB, filter *entity.WhereLoan) *gorm.DB {
 	if filter.Status != nil {
 		db = db.Where(tableName+".status = ?", *filter.Status)
 	}
+	if filter.HasPhotoProof != nil {
+		if *filter.HasPhotoProof {
+			db = db.Where(tableName + ".photo_proof_file_id IS NOT NULL")
+		} else {
+			db = db.Where(tableName + ".photo_proof_file_id IS NULL")
+		}
+	}
+	if filter.PhotoProofFileIDs != nil {
+		if len(*filter.PhotoProofFileIDs) > 0 {
+			db = db.Where(tableName+".photo_proof_file_id IN (?)", *filter.PhotoProofFileIDs)
+		} else {
+			db = db.Where("1 = 0")
+		}
+	}
+	if filter.FundingDeadlineBefore != nil {
+		db = db.Where(tableName+".funding_deadline < ?", *filter.FundingDeadlineBefore)
+	}
 	return db
 }
 
@@ -3,6 +3,7 @@ package sqlite
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/adityaokke/test-amartha/internal/entity"
 	"github.com/adityaokke/test-amartha/internal/repository/db"
@@ -13,23 +14,45 @@ type loanInvestmentRepository struct {
 	db *gorm.DB
 }
 
-func (r loanInvestmentRepository) InvestLoan(ctx context.Context, item *entity.LoanInvestment) (err error) {
+// InvestLoan adds the investment to the loan invested amount. The investment that completes the funding
+// also marks the loan fully invested and records fundedEvent, in the same transaction.
+func (r loanInvestmentRepository) InvestLoan(ctx context.Context, item *entity.LoanInvestment, fundedEvent *entity.OutboxEvent) (fundedAt *time.Time, err error) {
 	err = r.db.Transaction(func(tx *gorm.DB) (errTx error) {
 		if errTx = tx.Create(item).Error; errTx != nil {
 			return
 		}
 
-		res := tx.Model(&entity.Loan{}).Where("id = ? AND invested_amount + ? <= amount", item.LoanID, item.Amount).UpdateColumn("invested_amount", gorm.Expr("invested_amount + ?", item.Amount))
+		res := tx.Model(&entity.Loan{}).Where("id = ? AND status = ? AND invested_amount + ? <= amount", item.LoanID, entity.LoanStatusApproved, item.Amount).UpdateColumn("invested_amount", gorm.Expr("invested_amount + ?", item.Amount))
 		errTx = res.Error
 		if errTx != nil {
 			return
 		}
 		if res.RowsAffected == 0 {
-			errTx = errors.New("failed to update loan invested amount, possibly exceeding loan amount")
+			errTx = errors.New("failed to update loan invested amount, possibly exceeding loan amount or loan no longer approved")
 			return
 		}
+
+		now := time.Now().UTC()
+		res = tx.Model(&entity.Loan{}).Where("id = ? AND invested_amount = amount AND fully_invested_at IS NULL", item.LoanID).UpdateColumn("fully_invested_at", now)
+		errTx = res.Error
+		if errTx != nil {
+			return
+		}
+		if res.RowsAffected == 0 {
+			return
+		}
+		fundedAt = &now
+		if fundedEvent != nil {
+			errTx = createOutboxEvent(tx, fundedEvent)
+			if errTx != nil {
+				return
+			}
+		}
 		return
 	})
+	if err != nil {
+		fundedAt = nil
+	}
 	return
 }
 
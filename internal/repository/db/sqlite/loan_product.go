@@ -0,0 +1,119 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/repository/db"
+	"gorm.io/gorm"
+)
+
+type loanProductRepository struct {
+	db *gorm.DB
+}
+
+func (r loanProductRepository) Create(ctx context.Context, item *entity.LoanProduct) (err error) {
+	db := r.db
+
+	if err = db.Create(item).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r loanProductRepository) Update(ctx context.Context, item *entity.LoanProduct) (err error) {
+	db := r.db
+
+	if err = db.Save(item).Error; err != nil {
+		return
+	}
+	return
+}
+
+func (r loanProductRepository) Delete(ctx context.Context, item *entity.LoanProduct) (err error) {
+	db := r.db
+
+	if err := db.Delete(item).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func getWhereLoanProduct(db *gorm.DB, filter *entity.WhereLoanProduct) *gorm.DB {
+	tableName := entity.LoanProduct{}.TableName()
+	if filter.ID != nil {
+		db = db.Where(tableName+".id = ?", *filter.ID)
+	}
+	if filter.IsActive != nil {
+		db = db.Where(tableName+".is_active = ?", *filter.IsActive)
+	}
+	return db
+}
+
+func (r loanProductRepository) LoanProducts(ctx context.Context, filter entity.LoanProductsInput) (result []entity.LoanProduct, err error) {
+	db := r.db
+
+	where := entity.WhereLoanProduct{}
+	where.Scan(filter)
+	db = getWhereLoanProduct(db, &where)
+
+	if err = db.Find(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r loanProductRepository) CountLoanProducts(ctx context.Context, filter entity.LoanProductsInput) (result int64, err error) {
+	db := r.db
+
+	where := entity.WhereLoanProduct{}
+	where.Scan(filter)
+	db = getWhereLoanProduct(db, &where)
+
+	if err = db.Model(&entity.LoanProduct{}).Count(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r loanProductRepository) LoanProduct(ctx context.Context, filter entity.LoanProductInput) (result entity.LoanProduct, err error) {
+	db := r.db
+
+	where := entity.WhereLoanProduct{}
+	where.Scan(filter)
+	db = getWhereLoanProduct(db, &where)
+
+	if _, ok := db.Statement.Clauses["WHERE"]; !ok {
+		err = gorm.ErrMissingWhereClause
+		return
+	}
+
+	if err = db.First(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+/* -------------------------------- initiator ------------------------------- */
+type initiatorLoanProductRepository func(s *loanProductRepository) *loanProductRepository
+
+func NewLoanProductRepository() initiatorLoanProductRepository {
+	return func(q *loanProductRepository) *loanProductRepository {
+		return q
+	}
+}
+
+func (i initiatorLoanProductRepository) SetDBConnection(db *gorm.DB) initiatorLoanProductRepository {
+	return func(s *loanProductRepository) *loanProductRepository {
+		i(s).db = db
+		return s
+	}
+}
+
+func (i initiatorLoanProductRepository) Build() db.LoanProductRepository {
+	return i(&loanProductRepository{})
+}
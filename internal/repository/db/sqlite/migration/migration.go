@@ -8,4 +8,63 @@ import (
 func Migrate(db *gorm.DB) {
 	db.AutoMigrate(&entity.Loan{}, &entity.LoanInvestment{})
 	db.AutoMigrate(&entity.Investor{})
+	db.AutoMigrate(&entity.Borrower{})
+	db.AutoMigrate(&entity.LoanProduct{})
+	db.AutoMigrate(&entity.File{}, &entity.FileVariant{})
+	dedupePhotoProofDuplicates(db)
+	db.AutoMigrate(&entity.PhotoProofDuplicate{})
+	db.AutoMigrate(&entity.AgreementSignature{})
+	db.AutoMigrate(&entity.AgreementDocument{})
+	migrateAgreementDocumentVersionIndex(db)
+	db.AutoMigrate(&entity.OutboxEvent{})
+	db.AutoMigrate(&entity.Job{})
+	db.AutoMigrate(&entity.Notification{}, &entity.NotificationPreference{})
+	db.AutoMigrate(&entity.WebhookSubscription{}, &entity.WebhookEvent{}, &entity.WebhookDelivery{})
+	migrateEmailLog(db)
+}
+
+// migrateAgreementDocumentVersionIndex keeps one document per version of a series. Letters of the
+// whole loan have no investor, the index reads them as investor 0 since sqlite never matches NULLs.
+func migrateAgreementDocumentVersionIndex(db *gorm.DB) {
+	err := db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_agreement_document_version
+		ON agreement_document (loan_id, IFNULL(investor_id, 0), variant, language, format, version)`).Error
+	if err != nil {
+		panic("failed to index agreement document versions: " + err.Error())
+	}
+}
+
+// dedupePhotoProofDuplicates keeps the first record of a duplicate found again on a later approval
+// attempt, so the unique index of a loan and the file it duplicates can be created.
+func dedupePhotoProofDuplicates(db *gorm.DB) {
+	if !db.Migrator().HasTable(&entity.PhotoProofDuplicate{}) {
+		return
+	}
+	err := db.Exec(`DELETE FROM photo_proof_duplicate WHERE id NOT IN
+		(SELECT MIN(id) FROM photo_proof_duplicate GROUP BY loan_id, duplicate_of_file_id)`).Error
+	if err != nil {
+		panic("failed to dedupe photo proof duplicates: " + err.Error())
+	}
+}
+
+// migrateEmailLog moves the email delivery log into the notification log that replaced it,
+// keys get the channel suffix notifications are keyed with so queued emails are not sent twice.
+func migrateEmailLog(db *gorm.DB) {
+	if !db.Migrator().HasTable("email") {
+		return
+	}
+	// copying the rows and dropping the old table commit together, a failed run leaves the email log as it was
+	err := db.Transaction(func(tx *gorm.DB) (errTx error) {
+		errTx = tx.Exec(`INSERT INTO notification (id, key, loan_id, channel, recipient_type, recipient_id, recipient, template, subject, body, status, attempts,
+		available_at, locked_at, last_error, provider_message_id, sent_at, resend_of_id, created_at, updated_at)
+		SELECT id, key || ':EMAIL', loan_id, 'EMAIL', '', 0, recipient, template, subject, body, status, attempts,
+		available_at, locked_at, last_error, provider_message_id, sent_at, resend_of_id, created_at, updated_at FROM email`).Error
+		if errTx != nil {
+			return
+		}
+		errTx = tx.Migrator().DropTable("email")
+		return
+	})
+	if err != nil {
+		panic("failed to migrate email log: " + err.Error())
+	}
 }
@@ -0,0 +1,161 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/repository/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type notificationRepository struct {
+	db *gorm.DB
+}
+
+// Create records a notification unless a notification with the same key was already recorded,
+// the item keeps a zero ID in that case.
+func (r notificationRepository) Create(ctx context.Context, item *entity.Notification) (err error) {
+	db := r.db
+
+	if item.Status == "" {
+		item.Status = entity.NotificationStatusPending
+	}
+	if item.AvailableAt.IsZero() {
+		item.AvailableAt = time.Now().UTC()
+	}
+	if err = db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoNothing: true,
+	}).Create(item).Error; err != nil {
+		return
+	}
+	return
+}
+
+func (r notificationRepository) Update(ctx context.Context, item *entity.Notification) (err error) {
+	db := r.db
+
+	if err = db.Save(item).Error; err != nil {
+		return
+	}
+	return
+}
+
+// Claim locks a due notification for one dispatcher, a notification another dispatcher claimed first
+// or that changed since it was read is not claimed.
+func (r notificationRepository) Claim(ctx context.Context, item *entity.Notification, lockedBefore time.Time) (claimed bool, err error) {
+	db := r.db
+
+	now := time.Now().UTC()
+	res := db.Model(&entity.Notification{}).
+		Where("id = ? AND attempts = ?", item.ID, item.Attempts).
+		Where("(status = ? OR (status = ? AND locked_at < ?))", entity.NotificationStatusPending, entity.NotificationStatusSending, lockedBefore).
+		Updates(map[string]interface{}{
+			"status":    entity.NotificationStatusSending,
+			"locked_at": now,
+		})
+	if err = res.Error; err != nil {
+		return
+	}
+	if res.RowsAffected == 0 {
+		return
+	}
+	item.Status = entity.NotificationStatusSending
+	item.LockedAt = &now
+	claimed = true
+	return
+}
+
+func getWhereNotification(db *gorm.DB, filter *entity.WhereNotification) *gorm.DB {
+	tableName := entity.Notification{}.TableName()
+	if filter.ID != nil {
+		db = db.Where(tableName+".id = ?", *filter.ID)
+	}
+	if filter.Key != nil {
+		db = db.Where(tableName+".key = ?", *filter.Key)
+	}
+	if filter.LoanID != nil {
+		db = db.Where(tableName+".loan_id = ?", *filter.LoanID)
+	}
+	if filter.Channel != nil {
+		db = db.Where(tableName+".channel = ?", *filter.Channel)
+	}
+	if filter.Status != nil {
+		db = db.Where(tableName+".status = ?", *filter.Status)
+	}
+	if filter.Recipient != nil {
+		db = db.Where(tableName+".recipient = ?", *filter.Recipient)
+	}
+	return db
+}
+
+func (r notificationRepository) Notifications(ctx context.Context, filter entity.NotificationsInput) (result []entity.Notification, err error) {
+	db := r.db
+
+	where := entity.WhereNotification{}
+	where.Scan(filter)
+	db = getWhereNotification(db, &where)
+
+	if err = db.Order("id DESC").Find(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+// DueNotifications returns the pending notifications whose retry delay is over and the notifications
+// left in sending by a dispatcher that stopped before finishing them.
+func (r notificationRepository) DueNotifications(ctx context.Context, now time.Time, lockedBefore time.Time) (result []entity.Notification, err error) {
+	db := r.db
+
+	tableName := entity.Notification{}.TableName()
+	db = db.Where("("+tableName+".status = ? AND "+tableName+".available_at <= ?) OR ("+tableName+".status = ? AND "+tableName+".locked_at < ?)",
+		entity.NotificationStatusPending, now, entity.NotificationStatusSending, lockedBefore)
+
+	if err = db.Order("id ASC").Find(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r notificationRepository) Notification(ctx context.Context, filter entity.NotificationInput) (result entity.Notification, err error) {
+	db := r.db
+
+	where := entity.WhereNotification{}
+	where.Scan(filter)
+	db = getWhereNotification(db, &where)
+
+	if _, ok := db.Statement.Clauses["WHERE"]; !ok {
+		err = gorm.ErrMissingWhereClause
+		return
+	}
+
+	if err = db.First(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+/* -------------------------------- initiator ------------------------------- */
+type initiatorNotificationRepository func(s *notificationRepository) *notificationRepository
+
+func NewNotificationRepository() initiatorNotificationRepository {
+	return func(q *notificationRepository) *notificationRepository {
+		return q
+	}
+}
+
+func (i initiatorNotificationRepository) SetDBConnection(db *gorm.DB) initiatorNotificationRepository {
+	return func(s *notificationRepository) *notificationRepository {
+		i(s).db = db
+		return s
+	}
+}
+
+func (i initiatorNotificationRepository) Build() db.NotificationRepository {
+	return i(&notificationRepository{})
+}
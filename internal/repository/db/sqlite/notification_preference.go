@@ -0,0 +1,81 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/repository/db"
+	"gorm.io/gorm"
+)
+
+type notificationPreferenceRepository struct {
+	db *gorm.DB
+}
+
+func (r notificationPreferenceRepository) Create(ctx context.Context, item *entity.NotificationPreference) (err error) {
+	db := r.db
+
+	if err = db.Create(item).Error; err != nil {
+		return
+	}
+	return
+}
+
+func (r notificationPreferenceRepository) Update(ctx context.Context, item *entity.NotificationPreference) (err error) {
+	db := r.db
+
+	if err = db.Save(item).Error; err != nil {
+		return
+	}
+	return
+}
+
+func getWhereNotificationPreference(db *gorm.DB, filter *entity.WhereNotificationPreference) *gorm.DB {
+	tableName := entity.NotificationPreference{}.TableName()
+	if filter.RecipientType != nil {
+		db = db.Where(tableName+".recipient_type = ?", *filter.RecipientType)
+	}
+	if filter.RecipientID != nil {
+		db = db.Where(tableName+".recipient_id = ?", *filter.RecipientID)
+	}
+	return db
+}
+
+func (r notificationPreferenceRepository) NotificationPreference(ctx context.Context, filter entity.NotificationPreferenceInput) (result entity.NotificationPreference, err error) {
+	db := r.db
+
+	where := entity.WhereNotificationPreference{}
+	where.Scan(filter)
+	db = getWhereNotificationPreference(db, &where)
+
+	if _, ok := db.Statement.Clauses["WHERE"]; !ok {
+		err = gorm.ErrMissingWhereClause
+		return
+	}
+
+	if err = db.First(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+/* -------------------------------- initiator ------------------------------- */
+type initiatorNotificationPreferenceRepository func(s *notificationPreferenceRepository) *notificationPreferenceRepository
+
+func NewNotificationPreferenceRepository() initiatorNotificationPreferenceRepository {
+	return func(q *notificationPreferenceRepository) *notificationPreferenceRepository {
+		return q
+	}
+}
+
+func (i initiatorNotificationPreferenceRepository) SetDBConnection(db *gorm.DB) initiatorNotificationPreferenceRepository {
+	return func(s *notificationPreferenceRepository) *notificationPreferenceRepository {
+		i(s).db = db
+		return s
+	}
+}
+
+func (i initiatorNotificationPreferenceRepository) Build() db.NotificationPreferenceRepository {
+	return i(&notificationPreferenceRepository{})
+}
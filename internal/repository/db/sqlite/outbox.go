@@ -0,0 +1,157 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/repository/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type outboxEventRepository struct {
+	db *gorm.DB
+}
+
+// Create records an event unless an event with the same key was already recorded,
+// the item keeps a zero ID in that case.
+func (r outboxEventRepository) Create(ctx context.Context, item *entity.OutboxEvent) (err error) {
+	return createOutboxEvent(r.db, item)
+}
+
+func createOutboxEvent(db *gorm.DB, item *entity.OutboxEvent) (err error) {
+	if item.Status == "" {
+		item.Status = entity.OutboxEventStatusPending
+	}
+	if item.AvailableAt.IsZero() {
+		item.AvailableAt = time.Now().UTC()
+	}
+	if err = db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoNothing: true,
+	}).Create(item).Error; err != nil {
+		return
+	}
+	return
+}
+
+func (r outboxEventRepository) Update(ctx context.Context, item *entity.OutboxEvent) (err error) {
+	db := r.db
+
+	if err = db.Save(item).Error; err != nil {
+		return
+	}
+	return
+}
+
+// Claim locks a due event for one dispatcher, an event another dispatcher claimed first
+// or that changed since it was read is not claimed.
+func (r outboxEventRepository) Claim(ctx context.Context, item *entity.OutboxEvent, lockedBefore time.Time) (claimed bool, err error) {
+	db := r.db
+
+	now := time.Now().UTC()
+	res := db.Model(&entity.OutboxEvent{}).
+		Where("id = ? AND attempts = ?", item.ID, item.Attempts).
+		Where("(status = ? OR (status = ? AND locked_at < ?))", entity.OutboxEventStatusPending, entity.OutboxEventStatusProcessing, lockedBefore).
+		Updates(map[string]interface{}{
+			"status":    entity.OutboxEventStatusProcessing,
+			"locked_at": now,
+		})
+	if err = res.Error; err != nil {
+		return
+	}
+	if res.RowsAffected == 0 {
+		return
+	}
+	item.Status = entity.OutboxEventStatusProcessing
+	item.LockedAt = &now
+	claimed = true
+	return
+}
+
+func getWhereOutboxEvent(db *gorm.DB, filter *entity.WhereOutboxEvent) *gorm.DB {
+	tableName := entity.OutboxEvent{}.TableName()
+	if filter.ID != nil {
+		db = db.Where(tableName+".id = ?", *filter.ID)
+	}
+	if filter.Status != nil {
+		db = db.Where(tableName+".status = ?", *filter.Status)
+	}
+	if filter.Type != nil {
+		db = db.Where(tableName+".type = ?", *filter.Type)
+	}
+	if filter.LoanID != nil {
+		db = db.Where(tableName+".loan_id = ?", *filter.LoanID)
+	}
+	return db
+}
+
+func (r outboxEventRepository) OutboxEvents(ctx context.Context, filter entity.OutboxEventsInput) (result []entity.OutboxEvent, err error) {
+	db := r.db
+
+	where := entity.WhereOutboxEvent{}
+	where.Scan(filter)
+	db = getWhereOutboxEvent(db, &where)
+
+	if err = db.Order("id DESC").Find(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+// DueOutboxEvents returns the pending events whose retry delay is over and the events
+// left in processing by a dispatcher that stopped before finishing them.
+func (r outboxEventRepository) DueOutboxEvents(ctx context.Context, now time.Time, lockedBefore time.Time) (result []entity.OutboxEvent, err error) {
+	db := r.db
+
+	tableName := entity.OutboxEvent{}.TableName()
+	db = db.Where("("+tableName+".status = ? AND "+tableName+".available_at <= ?) OR ("+tableName+".status = ? AND "+tableName+".locked_at < ?)",
+		entity.OutboxEventStatusPending, now, entity.OutboxEventStatusProcessing, lockedBefore)
+
+	if err = db.Order("id ASC").Find(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r outboxEventRepository) OutboxEvent(ctx context.Context, filter entity.OutboxEventInput) (result entity.OutboxEvent, err error) {
+	db := r.db
+
+	where := entity.WhereOutboxEvent{}
+	where.Scan(filter)
+	db = getWhereOutboxEvent(db, &where)
+
+	if _, ok := db.Statement.Clauses["WHERE"]; !ok {
+		err = gorm.ErrMissingWhereClause
+		return
+	}
+
+	if err = db.First(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+/* -------------------------------- initiator ------------------------------- */
+type initiatorOutboxEventRepository func(s *outboxEventRepository) *outboxEventRepository
+
+func NewOutboxEventRepository() initiatorOutboxEventRepository {
+	return func(q *outboxEventRepository) *outboxEventRepository {
+		return q
+	}
+}
+
+func (i initiatorOutboxEventRepository) SetDBConnection(db *gorm.DB) initiatorOutboxEventRepository {
+	return func(s *outboxEventRepository) *outboxEventRepository {
+		i(s).db = db
+		return s
+	}
+}
+
+func (i initiatorOutboxEventRepository) Build() db.OutboxEventRepository {
+	return i(&outboxEventRepository{})
+}
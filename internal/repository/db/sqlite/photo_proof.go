@@ -0,0 +1,91 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/repository/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type photoProofDuplicateRepository struct {
+	db *gorm.DB
+}
+
+// Create records a duplicate once per loan and file it duplicates, approving the loan again
+// keeps the first record and the item keeps a zero ID.
+func (r photoProofDuplicateRepository) Create(ctx context.Context, item *entity.PhotoProofDuplicate) (err error) {
+	db := r.db
+
+	if err = db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "loan_id"}, {Name: "duplicate_of_file_id"}},
+		DoNothing: true,
+	}).Create(item).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func getWherePhotoProofDuplicate(db *gorm.DB, filter *entity.WherePhotoProofDuplicate) *gorm.DB {
+	tableName := entity.PhotoProofDuplicate{}.TableName()
+	if filter.LoanID != nil {
+		db = db.Where("("+tableName+".loan_id = ? OR "+tableName+".duplicate_of_loan_id = ?)", *filter.LoanID, *filter.LoanID)
+	}
+	if filter.EmployeeID != nil {
+		db = db.Where(tableName+".employee_id = ?", *filter.EmployeeID)
+	}
+	if filter.Action != nil {
+		db = db.Where(tableName+".action = ?", *filter.Action)
+	}
+	return db
+}
+
+func (r photoProofDuplicateRepository) PhotoProofDuplicates(ctx context.Context, filter entity.PhotoProofDuplicatesInput) (result []entity.PhotoProofDuplicate, err error) {
+	db := r.db
+
+	where := entity.WherePhotoProofDuplicate{}
+	where.Scan(filter)
+	db = getWherePhotoProofDuplicate(db, &where)
+
+	if err = db.Order("id DESC").Find(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r photoProofDuplicateRepository) CountPhotoProofDuplicates(ctx context.Context, filter entity.PhotoProofDuplicatesInput) (result int64, err error) {
+	db := r.db
+
+	where := entity.WherePhotoProofDuplicate{}
+	where.Scan(filter)
+	db = getWherePhotoProofDuplicate(db, &where)
+
+	if err = db.Model(&entity.PhotoProofDuplicate{}).Count(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+/* -------------------------------- initiator ------------------------------- */
+type initiatorPhotoProofDuplicateRepository func(s *photoProofDuplicateRepository) *photoProofDuplicateRepository
+
+func NewPhotoProofDuplicateRepository() initiatorPhotoProofDuplicateRepository {
+	return func(q *photoProofDuplicateRepository) *photoProofDuplicateRepository {
+		return q
+	}
+}
+
+func (i initiatorPhotoProofDuplicateRepository) SetDBConnection(db *gorm.DB) initiatorPhotoProofDuplicateRepository {
+	return func(s *photoProofDuplicateRepository) *photoProofDuplicateRepository {
+		i(s).db = db
+		return s
+	}
+}
+
+func (i initiatorPhotoProofDuplicateRepository) Build() db.PhotoProofDuplicateRepository {
+	return i(&photoProofDuplicateRepository{})
+}
@@ -0,0 +1,312 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/repository/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+/* ------------------------------ subscription ------------------------------ */
+type webhookSubscriptionRepository struct {
+	db *gorm.DB
+}
+
+func (r webhookSubscriptionRepository) Create(ctx context.Context, item *entity.WebhookSubscription) (err error) {
+	db := r.db
+
+	if err = db.Create(item).Error; err != nil {
+		return
+	}
+	return
+}
+
+func (r webhookSubscriptionRepository) Update(ctx context.Context, item *entity.WebhookSubscription) (err error) {
+	db := r.db
+
+	if err = db.Save(item).Error; err != nil {
+		return
+	}
+	return
+}
+
+func getWhereWebhookSubscription(db *gorm.DB, filter *entity.WhereWebhookSubscription) *gorm.DB {
+	tableName := entity.WebhookSubscription{}.TableName()
+	if filter.ID != nil {
+		db = db.Where(tableName+".id = ?", *filter.ID)
+	}
+	if filter.IsActive != nil {
+		db = db.Where(tableName+".is_active = ?", *filter.IsActive)
+	}
+	return db
+}
+
+func (r webhookSubscriptionRepository) WebhookSubscriptions(ctx context.Context, filter entity.WebhookSubscriptionsInput) (result []entity.WebhookSubscription, err error) {
+	db := r.db
+
+	where := entity.WhereWebhookSubscription{}
+	where.Scan(filter)
+	db = getWhereWebhookSubscription(db, &where)
+
+	if err = db.Order("id ASC").Find(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r webhookSubscriptionRepository) WebhookSubscription(ctx context.Context, filter entity.WebhookSubscriptionInput) (result entity.WebhookSubscription, err error) {
+	db := r.db
+
+	where := entity.WhereWebhookSubscription{}
+	where.Scan(filter)
+	db = getWhereWebhookSubscription(db, &where)
+
+	if _, ok := db.Statement.Clauses["WHERE"]; !ok {
+		err = gorm.ErrMissingWhereClause
+		return
+	}
+
+	if err = db.First(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+/* ---------------------------------- event --------------------------------- */
+type webhookEventRepository struct {
+	db *gorm.DB
+}
+
+// Create records an event together with its deliveries. An event with the same key that was
+// already recorded is left as it is, the item keeps a zero ID and no delivery is created in that case.
+func (r webhookEventRepository) Create(ctx context.Context, item *entity.WebhookEvent, deliveries []entity.WebhookDelivery) (err error) {
+	err = r.db.Transaction(func(tx *gorm.DB) (errTx error) {
+		if errTx = tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "key"}},
+			DoNothing: true,
+		}).Create(item).Error; errTx != nil {
+			return
+		}
+		if item.ID == 0 || len(deliveries) == 0 {
+			return
+		}
+		for i := range deliveries {
+			deliveries[i].EventID = item.ID
+		}
+		if errTx = tx.Create(&deliveries).Error; errTx != nil {
+			return
+		}
+		return
+	})
+	return
+}
+
+func getWhereWebhookEvent(db *gorm.DB, filter *entity.WhereWebhookEvent) *gorm.DB {
+	tableName := entity.WebhookEvent{}.TableName()
+	if filter.ID != nil {
+		db = db.Where(tableName+".id = ?", *filter.ID)
+	}
+	if filter.Key != nil {
+		db = db.Where(tableName+".key = ?", *filter.Key)
+	}
+	return db
+}
+
+func (r webhookEventRepository) WebhookEvent(ctx context.Context, filter entity.WebhookEventInput) (result entity.WebhookEvent, err error) {
+	db := r.db
+
+	where := entity.WhereWebhookEvent{}
+	where.Scan(filter)
+	db = getWhereWebhookEvent(db, &where)
+
+	if _, ok := db.Statement.Clauses["WHERE"]; !ok {
+		err = gorm.ErrMissingWhereClause
+		return
+	}
+
+	if err = db.First(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+/* -------------------------------- delivery -------------------------------- */
+type webhookDeliveryRepository struct {
+	db *gorm.DB
+}
+
+func (r webhookDeliveryRepository) Create(ctx context.Context, item *entity.WebhookDelivery) (err error) {
+	db := r.db
+
+	if err = db.Create(item).Error; err != nil {
+		return
+	}
+	return
+}
+
+func (r webhookDeliveryRepository) Update(ctx context.Context, item *entity.WebhookDelivery) (err error) {
+	db := r.db
+
+	if err = db.Save(item).Error; err != nil {
+		return
+	}
+	return
+}
+
+// Claim locks a due delivery for one dispatcher, a delivery another dispatcher claimed first
+// or that changed since it was read is not claimed.
+func (r webhookDeliveryRepository) Claim(ctx context.Context, item *entity.WebhookDelivery, lockedBefore time.Time) (claimed bool, err error) {
+	db := r.db
+
+	now := time.Now().UTC()
+	res := db.Model(&entity.WebhookDelivery{}).
+		Where("id = ? AND attempts = ?", item.ID, item.Attempts).
+		Where("(status = ? OR (status = ? AND locked_at < ?))", entity.WebhookDeliveryStatusPending, entity.WebhookDeliveryStatusSending, lockedBefore).
+		Updates(map[string]interface{}{
+			"status":    entity.WebhookDeliveryStatusSending,
+			"locked_at": now,
+		})
+	if err = res.Error; err != nil {
+		return
+	}
+	if res.RowsAffected == 0 {
+		return
+	}
+	item.Status = entity.WebhookDeliveryStatusSending
+	item.LockedAt = &now
+	claimed = true
+	return
+}
+
+func getWhereWebhookDelivery(db *gorm.DB, filter *entity.WhereWebhookDelivery) *gorm.DB {
+	tableName := entity.WebhookDelivery{}.TableName()
+	if filter.ID != nil {
+		db = db.Where(tableName+".id = ?", *filter.ID)
+	}
+	if filter.SubscriptionID != nil {
+		db = db.Where(tableName+".subscription_id = ?", *filter.SubscriptionID)
+	}
+	if filter.EventType != nil {
+		db = db.Where(tableName+".event_type = ?", *filter.EventType)
+	}
+	if filter.Status != nil {
+		db = db.Where(tableName+".status = ?", *filter.Status)
+	}
+	if filter.LoanID != nil {
+		db = db.Where(tableName+".loan_id = ?", *filter.LoanID)
+	}
+	return db
+}
+
+func (r webhookDeliveryRepository) WebhookDeliveries(ctx context.Context, filter entity.WebhookDeliveriesInput) (result []entity.WebhookDelivery, err error) {
+	db := r.db
+
+	where := entity.WhereWebhookDelivery{}
+	where.Scan(filter)
+	db = getWhereWebhookDelivery(db, &where)
+
+	if err = db.Order("id DESC").Find(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+// DueWebhookDeliveries returns the pending deliveries whose retry delay is over and the deliveries
+// left in sending by a dispatcher that stopped before finishing them.
+func (r webhookDeliveryRepository) DueWebhookDeliveries(ctx context.Context, now time.Time, lockedBefore time.Time) (result []entity.WebhookDelivery, err error) {
+	db := r.db
+
+	tableName := entity.WebhookDelivery{}.TableName()
+	db = db.Where("("+tableName+".status = ? AND "+tableName+".available_at <= ?) OR ("+tableName+".status = ? AND "+tableName+".locked_at < ?)",
+		entity.WebhookDeliveryStatusPending, now, entity.WebhookDeliveryStatusSending, lockedBefore)
+
+	if err = db.Order("id ASC").Find(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r webhookDeliveryRepository) WebhookDelivery(ctx context.Context, filter entity.WebhookDeliveryInput) (result entity.WebhookDelivery, err error) {
+	db := r.db
+
+	where := entity.WhereWebhookDelivery{}
+	where.Scan(filter)
+	db = getWhereWebhookDelivery(db, &where)
+
+	if _, ok := db.Statement.Clauses["WHERE"]; !ok {
+		err = gorm.ErrMissingWhereClause
+		return
+	}
+
+	if err = db.First(&result).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+/* -------------------------------- initiator ------------------------------- */
+type initiatorWebhookSubscriptionRepository func(s *webhookSubscriptionRepository) *webhookSubscriptionRepository
+
+func NewWebhookSubscriptionRepository() initiatorWebhookSubscriptionRepository {
+	return func(q *webhookSubscriptionRepository) *webhookSubscriptionRepository {
+		return q
+	}
+}
+
+func (i initiatorWebhookSubscriptionRepository) SetDBConnection(db *gorm.DB) initiatorWebhookSubscriptionRepository {
+	return func(s *webhookSubscriptionRepository) *webhookSubscriptionRepository {
+		i(s).db = db
+		return s
+	}
+}
+
+func (i initiatorWebhookSubscriptionRepository) Build() db.WebhookSubscriptionRepository {
+	return i(&webhookSubscriptionRepository{})
+}
+
+type initiatorWebhookEventRepository func(s *webhookEventRepository) *webhookEventRepository
+
+func NewWebhookEventRepository() initiatorWebhookEventRepository {
+	return func(q *webhookEventRepository) *webhookEventRepository {
+		return q
+	}
+}
+
+func (i initiatorWebhookEventRepository) SetDBConnection(db *gorm.DB) initiatorWebhookEventRepository {
+	return func(s *webhookEventRepository) *webhookEventRepository {
+		i(s).db = db
+		return s
+	}
+}
+
+func (i initiatorWebhookEventRepository) Build() db.WebhookEventRepository {
+	return i(&webhookEventRepository{})
+}
+
+type initiatorWebhookDeliveryRepository func(s *webhookDeliveryRepository) *webhookDeliveryRepository
+
+func NewWebhookDeliveryRepository() initiatorWebhookDeliveryRepository {
+	return func(q *webhookDeliveryRepository) *webhookDeliveryRepository {
+		return q
+	}
+}
+
+func (i initiatorWebhookDeliveryRepository) SetDBConnection(db *gorm.DB) initiatorWebhookDeliveryRepository {
+	return func(s *webhookDeliveryRepository) *webhookDeliveryRepository {
+		i(s).db = db
+		return s
+	}
+}
+
+func (i initiatorWebhookDeliveryRepository) Build() db.WebhookDeliveryRepository {
+	return i(&webhookDeliveryRepository{})
+}
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+)
+
+type WebhookSubscriptionRepository interface {
+	Create(ctx context.Context, item *entity.WebhookSubscription) (err error)
+	Update(ctx context.Context, item *entity.WebhookSubscription) (err error)
+
+	WebhookSubscriptions(ctx context.Context, filter entity.WebhookSubscriptionsInput) (result []entity.WebhookSubscription, err error)
+	WebhookSubscription(ctx context.Context, filter entity.WebhookSubscriptionInput) (result entity.WebhookSubscription, err error)
+}
+
+type WebhookEventRepository interface {
+	Create(ctx context.Context, item *entity.WebhookEvent, deliveries []entity.WebhookDelivery) (err error)
+
+	WebhookEvent(ctx context.Context, filter entity.WebhookEventInput) (result entity.WebhookEvent, err error)
+}
+
+type WebhookDeliveryRepository interface {
+	Create(ctx context.Context, item *entity.WebhookDelivery) (err error)
+	Update(ctx context.Context, item *entity.WebhookDelivery) (err error)
+	Claim(ctx context.Context, item *entity.WebhookDelivery, lockedBefore time.Time) (claimed bool, err error)
+
+	WebhookDeliveries(ctx context.Context, filter entity.WebhookDeliveriesInput) (result []entity.WebhookDelivery, err error)
+	DueWebhookDeliveries(ctx context.Context, now time.Time, lockedBefore time.Time) (result []entity.WebhookDelivery, err error)
+	WebhookDelivery(ctx context.Context, filter entity.WebhookDeliveryInput) (result entity.WebhookDelivery, err error)
+}
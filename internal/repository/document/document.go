@@ -0,0 +1,164 @@
+package document
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/repository/storage"
+	"github.com/google/uuid"
+)
+
+// renderer turns a format neutral agreement layout into file content.
+type renderer interface {
+	extension() string
+	render(layout agreementLayout) ([]byte, error)
+}
+
+type documentApi struct {
+	templates map[entity.Language]*agreementTemplate
+	renderers map[entity.DocumentFormat]renderer
+	storage   storage.Storage
+}
+
+// DocumentApi renders agreement letters, input.Format selects pdf, html or docx.
+// The generated file is put in storage and its key returned.
+type DocumentApi interface {
+	GenerateAgreement(input entity.InvestorAgreementLetterInput) (string, error)
+	GenerateSignedAgreement(input entity.InvestorAgreementLetterInput, signatures []entity.AgreementLetterSignature) (string, error)
+	GenerateInvestorAgreement(input entity.InvestorAgreementLetterInput, investor entity.InvestorAgreementLetterInvestor) (string, error)
+	TemplateVersion(language entity.Language) (int, error)
+}
+
+func (r documentApi) TemplateVersion(language entity.Language) (int, error) {
+	t, err := r.template(language)
+	if err != nil {
+		return 0, err
+	}
+	return t.Version, nil
+}
+
+func (r documentApi) template(language entity.Language) (*agreementTemplate, error) {
+	if language == "" {
+		language = entity.DefaultAgreementLanguage
+	}
+	t, ok := r.templates[language]
+	if !ok {
+		return nil, fmt.Errorf("no agreement template for language %s", language)
+	}
+	return t, nil
+}
+
+func (r documentApi) GenerateAgreement(d entity.InvestorAgreementLetterInput) (string, error) {
+	t, err := r.template(d.Language)
+	if err != nil {
+		return "", err
+	}
+	layout, err := agreementLayoutOf(t, d)
+	if err != nil {
+		return "", err
+	}
+	return r.write(d.Format, layout)
+}
+
+// GenerateSignedAgreement renders the agreement followed by a signature page
+// holding every captured electronic signature.
+func (r documentApi) GenerateSignedAgreement(d entity.InvestorAgreementLetterInput, signatures []entity.AgreementLetterSignature) (string, error) {
+	t, err := r.template(d.Language)
+	if err != nil {
+		return "", err
+	}
+	layout, err := signedAgreementLayout(t, d, signatures)
+	if err != nil {
+		return "", err
+	}
+	return r.write(d.Format, layout)
+}
+
+// GenerateInvestorAgreement renders the aggregate loan terms with only the
+// given investor's participation, other lenders are left out.
+func (r documentApi) GenerateInvestorAgreement(d entity.InvestorAgreementLetterInput, investor entity.InvestorAgreementLetterInvestor) (string, error) {
+	t, err := r.template(d.Language)
+	if err != nil {
+		return "", err
+	}
+	layout, err := investorAgreementLayout(t, d, investor)
+	if err != nil {
+		return "", err
+	}
+	return r.write(d.Format, layout)
+}
+
+func (r documentApi) write(format entity.DocumentFormat, layout agreementLayout) (string, error) {
+	if format == "" {
+		format = entity.DocumentFormatPDF
+	}
+	renderer, ok := r.renderers[format]
+	if !ok {
+		return "", fmt.Errorf("unsupported document format %s", format)
+	}
+	for i := range layout.Blocks {
+		if layout.Blocks[i].ImagePath == "" {
+			continue
+		}
+		image, err := r.read(layout.Blocks[i].ImagePath)
+		if err != nil {
+			return "", fmt.Errorf("signature image: %w", err)
+		}
+		layout.Blocks[i].Image = image
+	}
+	content, err := renderer.render(layout)
+	if err != nil {
+		return "", err
+	}
+
+	key := path.Join(entity.AgreementStoragePrefix, uuid.New().String()+renderer.extension())
+	err = r.storage.Put(context.Background(), key, bytes.NewReader(content), int64(len(content)), format.ContentType())
+	if err != nil {
+		return "", fmt.Errorf("write %s: %w", format, err)
+	}
+	return key, nil
+}
+
+func (r documentApi) read(key string) ([]byte, error) {
+	src, err := r.storage.Get(context.Background(), key)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	return io.ReadAll(src)
+}
+
+/* -------------------------------- initiator ------------------------------- */
+type initiatorDocumentApi func(s *documentApi) *documentApi
+
+func NewDocumentApi() initiatorDocumentApi {
+	return func(q *documentApi) *documentApi {
+		return q
+	}
+}
+
+func (i initiatorDocumentApi) SetStorage(storage storage.Storage) initiatorDocumentApi {
+	return func(s *documentApi) *documentApi {
+		i(s).storage = storage
+		return s
+	}
+}
+
+func (i initiatorDocumentApi) Build() DocumentApi {
+	templates, err := loadAgreementTemplates()
+	if err != nil {
+		panic(err)
+	}
+	return i(&documentApi{
+		templates: templates,
+		renderers: map[entity.DocumentFormat]renderer{
+			entity.DocumentFormatPDF:  pdfRenderer{},
+			entity.DocumentFormatHTML: htmlRenderer{},
+			entity.DocumentFormatDOCX: docxRenderer{},
+		},
+	})
+}
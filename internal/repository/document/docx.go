@@ -0,0 +1,245 @@
+package document
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// emuPerMm converts millimetres to the english metric units used by drawings.
+const emuPerMm = 36000
+
+// docxPageMarker stands in for the page number and is swapped for a PAGE field.
+const docxPageMarker = "\x00page\x00"
+
+type docxRenderer struct {
+}
+
+func (r docxRenderer) extension() string {
+	return ".docx"
+}
+
+type docxImage struct {
+	name string
+	data []byte
+}
+
+// docxWriter collects the body xml and the images it refers to.
+type docxWriter struct {
+	body   strings.Builder
+	images []docxImage
+}
+
+func docxEscape(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// runs writes the text as runs, line breaks become <w:br/>.
+func docxRuns(s string, props string) string {
+	var sb strings.Builder
+	for i, line := range strings.Split(s, "\n") {
+		sb.WriteString("<w:r>")
+		if props != "" {
+			sb.WriteString("<w:rPr>" + props + "</w:rPr>")
+		}
+		if i > 0 {
+			sb.WriteString("<w:br/>")
+		}
+		sb.WriteString(`<w:t xml:space="preserve">` + docxEscape(line) + "</w:t></w:r>")
+	}
+	return sb.String()
+}
+
+func (w *docxWriter) paragraph(s string, runProps string, paraProps string) {
+	w.body.WriteString("<w:p>")
+	if paraProps != "" {
+		w.body.WriteString("<w:pPr>" + paraProps + "</w:pPr>")
+	}
+	w.body.WriteString(docxRuns(s, runProps))
+	w.body.WriteString("</w:p>")
+}
+
+// image adds a png and returns the inline drawing run that shows it.
+func (w *docxWriter) image(data []byte, widthMm float64, heightMm float64) string {
+	w.images = append(w.images, docxImage{
+		name: fmt.Sprintf("image%d.png", len(w.images)+1),
+		data: data,
+	})
+	id := len(w.images)
+	cx := int(widthMm * emuPerMm)
+	cy := int(heightMm * emuPerMm)
+	return fmt.Sprintf(`<w:r><w:drawing><wp:inline distT="0" distB="0" distL="0" distR="0">`+
+		`<wp:extent cx="%[2]d" cy="%[3]d"/><wp:docPr id="%[1]d" name="Picture %[1]d"/>`+
+		`<a:graphic xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main">`+
+		`<a:graphicData uri="http://schemas.openxmlformats.org/drawingml/2006/picture">`+
+		`<pic:pic xmlns:pic="http://schemas.openxmlformats.org/drawingml/2006/picture">`+
+		`<pic:nvPicPr><pic:cNvPr id="%[1]d" name="image%[1]d.png"/><pic:cNvPicPr/></pic:nvPicPr>`+
+		`<pic:blipFill><a:blip r:embed="rIdImage%[1]d"/><a:stretch><a:fillRect/></a:stretch></pic:blipFill>`+
+		`<pic:spPr><a:xfrm><a:off x="0" y="0"/><a:ext cx="%[2]d" cy="%[3]d"/></a:xfrm><a:prstGeom prst="rect"><a:avLst/></a:prstGeom></pic:spPr>`+
+		`</pic:pic></a:graphicData></a:graphic></wp:inline></w:drawing></w:r>`, id, cx, cy)
+}
+
+func (r docxRenderer) render(l agreementLayout) ([]byte, error) {
+	w := &docxWriter{}
+	for _, b := range l.Blocks {
+		switch b.Kind {
+		case blockTitle:
+			w.paragraph(b.Text, `<w:b/><w:sz w:val="32"/>`, `<w:jc w:val="center"/><w:spacing w:after="480"/>`)
+		case blockField:
+			w.paragraph(b.Text, `<w:sz w:val="22"/>`, "")
+		case blockHeading:
+			w.paragraph(b.Text, `<w:b/><w:sz w:val="24"/>`, "")
+		case blockParagraph:
+			w.paragraph(b.Text, `<w:sz w:val="20"/>`, "")
+		case blockTable:
+			w.body.WriteString(`<w:tbl><w:tblPr><w:tblW w:w="0" w:type="auto"/><w:tblBorders>` +
+				`<w:top w:val="single" w:sz="4" w:space="0" w:color="9CA3AF"/><w:left w:val="single" w:sz="4" w:space="0" w:color="9CA3AF"/>` +
+				`<w:bottom w:val="single" w:sz="4" w:space="0" w:color="9CA3AF"/><w:right w:val="single" w:sz="4" w:space="0" w:color="9CA3AF"/>` +
+				`<w:insideH w:val="single" w:sz="4" w:space="0" w:color="9CA3AF"/><w:insideV w:val="single" w:sz="4" w:space="0" w:color="9CA3AF"/>` +
+				`</w:tblBorders></w:tblPr><w:tblGrid>`)
+			for _, col := range b.Columns {
+				fmt.Fprintf(&w.body, `<w:gridCol w:w="%d"/>`, mmToTwip(col.Width))
+			}
+			w.body.WriteString("</w:tblGrid>")
+			cell := func(col column, s string, header bool) {
+				fmt.Fprintf(&w.body, `<w:tc><w:tcPr><w:tcW w:w="%d" w:type="dxa"/>`, mmToTwip(col.Width))
+				if header {
+					w.body.WriteString(`<w:shd w:val="clear" w:color="auto" w:fill="F5F6F8"/>`)
+				}
+				w.body.WriteString("</w:tcPr>")
+				jc := ""
+				if col.Align == alignRight {
+					jc = `<w:jc w:val="right"/>`
+				}
+				w.paragraph(s, `<w:sz w:val="22"/>`, jc)
+				w.body.WriteString("</w:tc>")
+			}
+			w.body.WriteString("<w:tr>")
+			for _, col := range b.Columns {
+				cell(col, col.Title, true)
+			}
+			w.body.WriteString("</w:tr>")
+			for _, row := range b.Rows {
+				w.body.WriteString("<w:tr>")
+				for c, col := range b.Columns {
+					cell(col, row[c], false)
+				}
+				w.body.WriteString("</w:tr>")
+			}
+			w.body.WriteString("</w:tbl>")
+		case blockSignatureLines:
+			w.paragraph(b.Text, `<w:sz w:val="22"/>`, `<w:spacing w:after="480"/>`)
+			w.body.WriteString(`<w:p><w:pPr><w:tabs><w:tab w:val="left" w:pos="5103"/></w:tabs></w:pPr>` +
+				docxRuns(b.Left, `<w:sz w:val="22"/>`) + `<w:r><w:tab/></w:r>` + docxRuns(b.Right, `<w:sz w:val="22"/>`) + "</w:p>")
+		case blockPageBreak:
+			w.body.WriteString(`<w:p><w:r><w:br w:type="page"/></w:r></w:p>`)
+		case blockPageTitle:
+			w.paragraph(b.Text, `<w:b/><w:sz w:val="28"/>`, `<w:jc w:val="center"/><w:spacing w:after="240"/>`)
+		case blockSignature:
+			w.paragraph(b.Text, `<w:b/><w:sz w:val="22"/>`, "")
+			w.body.WriteString("<w:p>" + w.image(b.Image, 60, 20) + "</w:p>")
+			w.paragraph(b.Details, `<w:sz w:val="20"/>`, `<w:pBdr><w:bottom w:val="single" w:sz="4" w:space="4" w:color="D1D5DB"/></w:pBdr>`)
+		case blockVerification:
+			png, err := qrcode.Encode(b.QRContent, qrcode.Medium, 256)
+			if err != nil {
+				return nil, fmt.Errorf("verification qr: %w", err)
+			}
+			w.body.WriteString("<w:p>" + w.image(png, 30, 30) + "</w:p>")
+			w.paragraph(b.Text, `<w:sz w:val="18"/>`, "")
+		case blockSpace:
+			fmt.Fprintf(&w.body, `<w:p><w:pPr><w:spacing w:before="0" w:after="0" w:line="%d" w:lineRule="exact"/></w:pPr></w:p>`, mmToTwip(b.Height))
+		}
+	}
+	return w.pack(l)
+}
+
+// pack zips the document parts into a .docx package.
+func (w *docxWriter) pack(l agreementLayout) ([]byte, error) {
+	const ns = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships" ` +
+		`xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing"`
+	const header = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+	rels := strings.Builder{}
+	rels.WriteString(header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i, img := range w.images {
+		fmt.Fprintf(&rels, `<Relationship Id="rIdImage%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/image" Target="media/%s"/>`, i+1, img.name)
+	}
+	sectPr := `<w:sectPr>`
+	contentTypes := header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Default Extension="png" ContentType="image/png"/>` +
+		`<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>` +
+		`<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>`
+	parts := map[string]string{}
+	if l.Footer != nil {
+		rels.WriteString(`<Relationship Id="rIdFooter" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/footer" Target="footer1.xml"/>`)
+		sectPr += `<w:footerReference w:type="default" r:id="rIdFooter"/>`
+		contentTypes += `<Override PartName="/word/footer1.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.footer+xml"/>`
+
+		footer := strings.Builder{}
+		footer.WriteString(header + `<w:ftr ` + ns + `><w:p><w:pPr><w:jc w:val="center"/></w:pPr>`)
+		before, after, found := strings.Cut(l.Footer(docxPageMarker), docxPageMarker)
+		footer.WriteString(docxRuns(before, `<w:sz w:val="16"/>`))
+		if found {
+			footer.WriteString(`<w:fldSimple w:instr="PAGE"><w:r><w:rPr><w:sz w:val="16"/></w:rPr><w:t>1</w:t></w:r></w:fldSimple>`)
+			footer.WriteString(docxRuns(after, `<w:sz w:val="16"/>`))
+		}
+		footer.WriteString(`</w:p></w:ftr>`)
+		parts["word/footer1.xml"] = footer.String()
+	}
+	rels.WriteString(`</Relationships>`)
+	contentTypes += `</Types>`
+	sectPr += `<w:pgSz w:w="11906" w:h="16838"/><w:pgMar w:top="1134" w:right="1134" w:bottom="1134" w:left="1134" w:header="709" w:footer="709" w:gutter="0"/></w:sectPr>`
+
+	parts["[Content_Types].xml"] = contentTypes
+	parts["_rels/.rels"] = header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>` +
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/>` +
+		`</Relationships>`
+	parts["docProps/core.xml"] = header + `<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/">` +
+		`<dc:title>` + docxEscape(l.Title) + `</dc:title></cp:coreProperties>`
+	parts["word/_rels/document.xml.rels"] = rels.String()
+	parts["word/document.xml"] = header + `<w:document ` + ns + `><w:body>` + w.body.String() + sectPr + `</w:body></w:document>`
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	// [Content_Types].xml goes first, some readers expect it there
+	order := []string{"[Content_Types].xml", "_rels/.rels", "docProps/core.xml", "word/document.xml", "word/_rels/document.xml.rels"}
+	if _, ok := parts["word/footer1.xml"]; ok {
+		order = append(order, "word/footer1.xml")
+	}
+	for _, name := range order {
+		f, err := zw.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write([]byte(parts[name])); err != nil {
+			return nil, err
+		}
+	}
+	for _, img := range w.images {
+		f, err := zw.Create("word/media/" + img.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write(img.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("write docx: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func mmToTwip(mm float64) int {
+	return int(mm * 1440 / 25.4)
+}
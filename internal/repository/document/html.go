@@ -0,0 +1,103 @@
+package document
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+type htmlRenderer struct {
+}
+
+func (r htmlRenderer) extension() string {
+	return ".html"
+}
+
+// render writes a standalone page, images are inlined as data urls so the file
+// can be shown by the borrower app without further requests.
+func (r htmlRenderer) render(l agreementLayout) ([]byte, error) {
+	var sb strings.Builder
+	text := func(s string) string {
+		return strings.ReplaceAll(html.EscapeString(s), "\n", "<br>")
+	}
+
+	sb.WriteString("<!doctype html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(&sb, "<title>%s</title>\n", text(l.Title))
+	sb.WriteString(`<style>
+body{font-family:Arial,Helvetica,sans-serif;color:#111827;max-width:760px;margin:0 auto;padding:24px;font-size:14px;line-height:1.5;}
+h1{font-size:22px;text-align:center;margin:0 0 32px 0;}
+h2{font-size:16px;margin:16px 0 8px 0;}
+h3{font-size:18px;text-align:center;margin:32px 0 16px 0;}
+p{margin:0 0 6px 0;}
+.note{font-size:13px;}
+table{border-collapse:collapse;width:100%;margin:0 0 16px 0;}
+th,td{border:1px solid #9ca3af;padding:6px 8px;}
+th{background:#f5f6f8;}
+.right{text-align:right;}
+.signature-lines{display:flex;gap:48px;margin-top:36px;}
+.signature{display:flex;gap:16px;border-bottom:1px solid #d1d5db;padding:8px 0;}
+.signature img{width:240px;height:80px;object-fit:contain;}
+.verification{display:flex;gap:16px;align-items:center;margin-top:24px;font-size:12px;}
+.verification img{width:120px;height:120px;}
+footer{margin-top:24px;font-size:11px;text-align:center;color:#6b7280;}
+</style>
+</head>
+<body>
+`)
+	for _, b := range l.Blocks {
+		switch b.Kind {
+		case blockTitle:
+			fmt.Fprintf(&sb, "<h1>%s</h1>\n", text(b.Text))
+		case blockField:
+			fmt.Fprintf(&sb, "<p>%s</p>\n", text(b.Text))
+		case blockHeading:
+			fmt.Fprintf(&sb, "<h2>%s</h2>\n", text(b.Text))
+		case blockParagraph:
+			fmt.Fprintf(&sb, "<p class=\"note\">%s</p>\n", text(b.Text))
+		case blockTable:
+			sb.WriteString("<table>\n<tr>")
+			for _, col := range b.Columns {
+				fmt.Fprintf(&sb, "<th%s>%s</th>", alignClass(col.Align), text(col.Title))
+			}
+			sb.WriteString("</tr>\n")
+			for _, row := range b.Rows {
+				sb.WriteString("<tr>")
+				for c, col := range b.Columns {
+					fmt.Fprintf(&sb, "<td%s>%s</td>", alignClass(col.Align), text(row[c]))
+				}
+				sb.WriteString("</tr>\n")
+			}
+			sb.WriteString("</table>\n")
+		case blockSignatureLines:
+			fmt.Fprintf(&sb, "<p>%s</p>\n<div class=\"signature-lines\"><span>%s</span><span>%s</span></div>\n", text(b.Text), text(b.Left), text(b.Right))
+		case blockPageTitle:
+			fmt.Fprintf(&sb, "<h3>%s</h3>\n", text(b.Text))
+		case blockSignature:
+			fmt.Fprintf(&sb, "<h2>%s</h2>\n<div class=\"signature\"><img alt=\"signature\" src=\"data:image/png;base64,%s\"><p>%s</p></div>\n",
+				text(b.Text), base64.StdEncoding.EncodeToString(b.Image), text(b.Details))
+		case blockVerification:
+			png, err := qrcode.Encode(b.QRContent, qrcode.Medium, 256)
+			if err != nil {
+				return nil, fmt.Errorf("verification qr: %w", err)
+			}
+			fmt.Fprintf(&sb, "<div class=\"verification\"><img alt=\"verification qr code\" src=\"data:image/png;base64,%s\"><p>%s</p></div>\n",
+				base64.StdEncoding.EncodeToString(png), text(b.Text))
+		}
+	}
+	// pages only exist in print, the footer is shown once
+	if l.Footer != nil {
+		fmt.Fprintf(&sb, "<footer>%s</footer>\n", text(l.Footer("")))
+	}
+	sb.WriteString("</body>\n</html>\n")
+	return []byte(sb.String()), nil
+}
+
+func alignClass(a align) string {
+	if a == alignRight {
+		return ` class="right"`
+	}
+	return ""
+}
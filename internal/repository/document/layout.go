@@ -0,0 +1,209 @@
+package document
+
+import (
+	"fmt"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+)
+
+type blockKind int
+
+const (
+	blockTitle blockKind = iota
+	blockField
+	blockHeading
+	blockParagraph
+	blockTable
+	blockSignatureLines
+	blockPageTitle
+	blockSignature
+	blockVerification
+	blockSpace
+	blockPageBreak
+)
+
+type align string
+
+const (
+	alignLeft  align = "L"
+	alignRight align = "R"
+)
+
+type column struct {
+	Title string
+	Width float64
+	Align align
+}
+
+// block is one format neutral piece of an agreement, every renderer walks the
+// same list of blocks.
+type block struct {
+	Kind blockKind
+	Text string
+	// table
+	Columns []column
+	Rows    [][]string
+	// signature lines
+	Left  string
+	Right string
+	// captured signature, Image is read from storage before rendering
+	ImagePath string
+	Image     []byte
+	Details   string
+	// verification
+	QRContent string
+	// space, in mm
+	Height float64
+}
+
+type agreementLayout struct {
+	Title string
+	// Footer renders the footer text for a page, nil when there is none.
+	Footer func(page string) string
+	Blocks []block
+	// Password is required to open the document, only the PDF renderer applies it
+	Password string
+}
+
+// layoutBuilder keeps the first template error so the layout code can stay linear.
+type layoutBuilder struct {
+	t      *agreementTemplate
+	layout agreementLayout
+	err    error
+}
+
+func (b *layoutBuilder) text(key string, data any) string {
+	if b.err != nil {
+		return ""
+	}
+	s, err := b.t.render(key, data)
+	if err != nil {
+		b.err = fmt.Errorf("render %s: %w", key, err)
+	}
+	return s
+}
+
+func (b *layoutBuilder) add(kind blockKind, key string, data any) {
+	b.layout.Blocks = append(b.layout.Blocks, block{Kind: kind, Text: b.text(key, data)})
+}
+
+func (b *layoutBuilder) space(height float64) {
+	b.layout.Blocks = append(b.layout.Blocks, block{Kind: blockSpace, Height: height})
+}
+
+func (b *layoutBuilder) lenderTable(d entity.InvestorAgreementLetterInput, investors []entity.InvestorAgreementLetterInvestor) {
+	rows := [][]string{}
+	for _, ls := range investors {
+		rows = append(rows, []string{ls.Name, b.t.money(ls.Amount), b.t.percent(ls.Percent)})
+	}
+	b.layout.Blocks = append(b.layout.Blocks, block{
+		Kind: blockTable,
+		Columns: []column{
+			{Title: b.text("lenderColumn", d), Width: 100, Align: alignLeft},
+			{Title: b.text("amountColumn", d), Width: 50, Align: alignRight},
+			{Title: b.text("shareColumn", d), Width: 30, Align: alignRight},
+		},
+		Rows: rows,
+	})
+	b.space(4)
+}
+
+func (b *layoutBuilder) signatureLines(d entity.InvestorAgreementLetterInput, rightKey string) {
+	b.space(8)
+	b.layout.Blocks = append(b.layout.Blocks, block{
+		Kind:  blockSignatureLines,
+		Text:  b.text("signaturesTitle", d),
+		Left:  b.text("borrowerSignature", d),
+		Right: b.text(rightKey, d),
+	})
+}
+
+func (b *layoutBuilder) build(d entity.InvestorAgreementLetterInput) (agreementLayout, error) {
+	if d.VerificationCode != "" {
+		b.space(6)
+		b.layout.Blocks = append(b.layout.Blocks, block{
+			Kind:      blockVerification,
+			Text:      b.text("verificationNote", d),
+			QRContent: d.VerificationURL,
+		})
+		footerData := func(page string) any {
+			return struct {
+				entity.InvestorAgreementLetterInput
+				Page string
+			}{d, page}
+		}
+		// render once up front so a broken footer template fails the document
+		b.text("footer", footerData(""))
+		b.layout.Footer = func(page string) string {
+			s, _ := b.t.render("footer", footerData(page))
+			return s
+		}
+	}
+	if b.err != nil {
+		return agreementLayout{}, b.err
+	}
+	return b.layout, nil
+}
+
+// newTermsLayout starts a layout with the title and the aggregate loan terms
+// shared by every agreement variant.
+func newTermsLayout(t *agreementTemplate, d entity.InvestorAgreementLetterInput) *layoutBuilder {
+	b := &layoutBuilder{t: t}
+	b.layout.Title = b.text("title", d)
+	b.add(blockTitle, "title", d)
+	b.add(blockField, "agreementNo", d)
+	b.add(blockField, "effectiveDate", d)
+	b.add(blockField, "borrower", d)
+	b.add(blockField, "loanAmount", d)
+	b.add(blockField, "interest", d)
+	b.add(blockField, "term", d)
+	b.add(blockField, "interestMethod", d)
+	b.space(2)
+	return b
+}
+
+func aggregateLayout(t *agreementTemplate, d entity.InvestorAgreementLetterInput) *layoutBuilder {
+	b := newTermsLayout(t, d)
+	b.add(blockHeading, "scheduleLenders", d)
+	b.lenderTable(d, d.Investors)
+	// Pari passu / pro-rata note
+	b.add(blockParagraph, "proRataNote", d)
+	return b
+}
+
+func agreementLayoutOf(t *agreementTemplate, d entity.InvestorAgreementLetterInput) (agreementLayout, error) {
+	b := aggregateLayout(t, d)
+	b.signatureLines(d, "lenderRepSignature")
+	return b.build(d)
+}
+
+// signedAgreementLayout adds a signature page holding every captured electronic signature.
+func signedAgreementLayout(t *agreementTemplate, d entity.InvestorAgreementLetterInput, signatures []entity.AgreementLetterSignature) (agreementLayout, error) {
+	b := aggregateLayout(t, d)
+	b.space(8)
+	b.add(blockField, "electronicSignatureNote", d)
+	b.layout.Blocks = append(b.layout.Blocks, block{Kind: blockPageBreak})
+	b.add(blockPageTitle, "signaturePageTitle", d)
+	for _, sig := range signatures {
+		b.layout.Blocks = append(b.layout.Blocks, block{
+			Kind:      blockSignature,
+			Text:      b.text("signerHeading", sig),
+			ImagePath: sig.ImagePath,
+			Details:   b.text("signerDetails", sig),
+		})
+	}
+	return b.build(d)
+}
+
+// investorAgreementLayout shows the aggregate loan terms with only the given
+// investor's participation, other lenders are left out.
+func investorAgreementLayout(t *agreementTemplate, d entity.InvestorAgreementLetterInput, investor entity.InvestorAgreementLetterInvestor) (agreementLayout, error) {
+	b := newTermsLayout(t, d)
+	b.add(blockHeading, "scheduleParticipation", d)
+	b.lenderTable(d, []entity.InvestorAgreementLetterInvestor{investor})
+	b.add(blockParagraph, "participationNote", d)
+	b.add(blockParagraph, "proRataNote", d)
+	b.signatureLines(d, "lenderSignature")
+	b.layout.Password = investor.Password
+	return b.build(d)
+}
@@ -0,0 +1,132 @@
+package document
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/skip2/go-qrcode"
+)
+
+type pdfRenderer struct {
+}
+
+func (r pdfRenderer) extension() string {
+	return ".pdf"
+}
+
+func (r pdfRenderer) render(l agreementLayout) ([]byte, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle(l.Title, false)
+	if l.Password != "" {
+		// an empty owner password is replaced by a random one, so nobody can lift the restriction.
+		// gofpdf only offers 40-bit RC4, and a date of birth or 6 digits of the NIK is a small key
+		// space, the password keeps a forwarded attachment from being opened casually, nothing more
+		pdf.SetProtection(gofpdf.CnProtectPrint, l.Password, "")
+	}
+	if l.Footer != nil {
+		pdf.SetFooterFunc(func() {
+			pdf.SetY(-15)
+			pdf.SetFont("Arial", "", 8)
+			pdf.CellFormat(0, 10, l.Footer(strconv.Itoa(pdf.PageNo())), "", 0, "C", false, 0, "")
+		})
+	}
+	pdf.AddPage()
+	para := func(s string) { pdf.MultiCell(0, 6, s, "", "L", false); pdf.Ln(1) }
+
+	for i, b := range l.Blocks {
+		switch b.Kind {
+		case blockTitle:
+			pdf.SetFont("Arial", "B", 16)
+			pdf.CellFormat(0, 10, b.Text, "", 1, "C", false, 0, "")
+			pdf.Ln(12)
+		case blockField:
+			pdf.SetFont("Arial", "", 11)
+			para(b.Text)
+		case blockHeading:
+			pdf.SetFont("Arial", "B", 12)
+			para(b.Text)
+		case blockParagraph:
+			pdf.SetFont("Arial", "", 10)
+			para(b.Text)
+		case blockTable:
+			pdf.SetFont("Arial", "", 11)
+			pdf.SetFillColor(245, 246, 248)
+			for c, col := range b.Columns {
+				pdf.CellFormat(col.Width, 8, col.Title, "1", lineBreak(c, len(b.Columns)), string(col.Align), true, 0, "")
+			}
+			for _, row := range b.Rows {
+				for c, col := range b.Columns {
+					pdf.CellFormat(col.Width, 8, row[c], "1", lineBreak(c, len(b.Columns)), string(col.Align), false, 0, "")
+				}
+			}
+		case blockSignatureLines:
+			pdf.SetFont("Arial", "", 11)
+			pdf.MultiCell(0, 6, b.Text, "", "L", false)
+			pdf.Ln(9)
+			pdf.CellFormat(90, 8, b.Left, "", 0, "L", false, 0, "")
+			pdf.CellFormat(0, 8, b.Right, "", 1, "L", false, 0, "")
+		case blockPageBreak:
+			pdf.AddPage()
+		case blockPageTitle:
+			pdf.SetFont("Arial", "B", 14)
+			pdf.CellFormat(0, 10, b.Text, "", 1, "C", false, 0, "")
+			pdf.Ln(6)
+		case blockSignature:
+			if pdf.GetY() > 230 {
+				pdf.AddPage()
+			}
+			pdf.SetFont("Arial", "B", 11)
+			pdf.CellFormat(0, 7, b.Text, "", 1, "L", false, 0, "")
+			y := pdf.GetY()
+			opt := gofpdf.ImageOptions{ImageType: "PNG", ReadDpi: false}
+			pdf.RegisterImageOptionsReader(b.ImagePath, opt, bytes.NewReader(b.Image))
+			if err := pdf.Error(); err != nil {
+				return nil, fmt.Errorf("signature image: %w", err)
+			}
+			pdf.ImageOptions(b.ImagePath, 10, y, 60, 20, false, opt, 0, "")
+			pdf.SetXY(75, y)
+			pdf.SetFont("Arial", "", 10)
+			pdf.MultiCell(0, 5, b.Details, "", "L", false)
+			pdf.SetY(y + 24)
+			pdf.Line(10, pdf.GetY(), 200, pdf.GetY())
+			pdf.Ln(4)
+		case blockVerification:
+			png, err := qrcode.Encode(b.QRContent, qrcode.Medium, 256)
+			if err != nil {
+				return nil, fmt.Errorf("verification qr: %w", err)
+			}
+			if pdf.GetY() > 240 {
+				pdf.AddPage()
+			}
+			y := pdf.GetY()
+			opt := gofpdf.ImageOptions{ImageType: "PNG", ReadDpi: false}
+			name := fmt.Sprintf("verification-%d", i)
+			pdf.RegisterImageOptionsReader(name, opt, bytes.NewReader(png))
+			pdf.ImageOptions(name, 10, y, 30, 30, false, opt, 0, "")
+			pdf.SetXY(45, y+5)
+			pdf.SetFont("Arial", "", 9)
+			pdf.MultiCell(0, 5, b.Text, "", "L", false)
+		case blockSpace:
+			pdf.Ln(b.Height)
+		}
+	}
+	if err := pdf.Error(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, fmt.Errorf("write pdf: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// lineBreak moves to the next line after the last cell of a table row.
+func lineBreak(c int, columns int) int {
+	if c == columns-1 {
+		return 1
+	}
+	return 0
+}
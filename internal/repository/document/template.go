@@ -0,0 +1,138 @@
+package document
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"text/template"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+//go:embed template/*.json
+var FS embed.FS
+
+// agreementTemplateKeys lists every text an agreement template has to provide.
+var agreementTemplateKeys = []string{
+	"title", "agreementNo", "effectiveDate", "borrower", "loanAmount", "interest", "term", "interestMethod",
+	"scheduleLenders", "scheduleParticipation", "lenderColumn", "amountColumn", "shareColumn",
+	"proRataNote", "participationNote",
+	"signaturesTitle", "borrowerSignature", "lenderRepSignature", "lenderSignature",
+	"electronicSignatureNote", "signaturePageTitle", "signerHeading", "signerDetails",
+	"verificationNote", "footer",
+}
+
+type agreementTemplate struct {
+	Version         int                              `json:"version"`
+	Language        entity.Language                  `json:"language"`
+	Months          []string                         `json:"months"`
+	TermUnits       map[entity.TermUnit]string       `json:"termUnits"`
+	InterestMethods map[entity.InterestMethod]string `json:"interestMethods"`
+	SignerRoles     map[string]string                `json:"signerRoles"`
+	Text            map[string]string                `json:"text"`
+
+	printer *message.Printer
+	texts   *template.Template
+}
+
+func (t *agreementTemplate) money(v int) string {
+	return "Rp " + t.printer.Sprint(v)
+}
+
+func (t *agreementTemplate) percent(v float64) string {
+	return t.printer.Sprintf("%.2f", v) + "%"
+}
+
+func (t *agreementTemplate) date(v time.Time) string {
+	return fmt.Sprintf("%02d %s %d", v.Day(), t.Months[v.Month()-1], v.Year())
+}
+
+func (t *agreementTemplate) parse() error {
+	if len(t.Months) != 12 {
+		return fmt.Errorf("agreement template %s v%d: months must have 12 entries", t.Language, t.Version)
+	}
+	switch t.Language {
+	case entity.LanguageIndonesian:
+		t.printer = message.NewPrinter(language.Indonesian)
+	default:
+		t.printer = message.NewPrinter(language.English)
+	}
+	funcs := template.FuncMap{
+		"money":   t.money,
+		"percent": t.percent,
+		"date":    t.date,
+		"datetime": func(v time.Time) string {
+			v = v.UTC()
+			return fmt.Sprintf("%s %02d:%02d UTC", t.date(v), v.Hour(), v.Minute())
+		},
+		"termUnit":       func(v entity.TermUnit) string { return lookup(t.TermUnits, v) },
+		"interestMethod": func(v entity.InterestMethod) string { return lookup(t.InterestMethods, v) },
+		"signerRole":     func(v string) string { return lookup(t.SignerRoles, v) },
+	}
+	t.texts = template.New("agreement").Funcs(funcs)
+	for _, key := range agreementTemplateKeys {
+		text, ok := t.Text[key]
+		if !ok {
+			return fmt.Errorf("agreement template %s v%d: missing text %s", t.Language, t.Version, key)
+		}
+		if _, err := t.texts.New(key).Parse(text); err != nil {
+			return fmt.Errorf("agreement template %s v%d: %w", t.Language, t.Version, err)
+		}
+	}
+	return nil
+}
+
+func (t *agreementTemplate) render(key string, data any) (string, error) {
+	var buf bytes.Buffer
+	if err := t.texts.ExecuteTemplate(&buf, key, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// lookup falls back to the raw value so an unknown enum still prints.
+func lookup[K ~string](m map[K]string, k K) string {
+	if v, ok := m[k]; ok {
+		return v
+	}
+	return string(k)
+}
+
+// loadAgreementTemplates reads every embedded template and keeps the latest
+// version of each language.
+func loadAgreementTemplates() (map[entity.Language]*agreementTemplate, error) {
+	paths, err := fs.Glob(FS, "template/*.json")
+	if err != nil {
+		return nil, err
+	}
+	result := map[entity.Language]*agreementTemplate{}
+	for _, path := range paths {
+		raw, err := FS.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		t := &agreementTemplate{}
+		if err := json.Unmarshal(raw, t); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if !t.Language.IsValid() {
+			return nil, fmt.Errorf("%s: unknown language %s", path, t.Language)
+		}
+		if err := t.parse(); err != nil {
+			return nil, err
+		}
+		if current, ok := result[t.Language]; ok && current.Version >= t.Version {
+			continue
+		}
+		result[t.Language] = t
+	}
+	if _, ok := result[entity.DefaultAgreementLanguage]; !ok {
+		return nil, fmt.Errorf("no agreement template for default language %s", entity.DefaultAgreementLanguage)
+	}
+	return result, nil
+}
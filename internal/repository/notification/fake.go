@@ -0,0 +1,100 @@
+package notification
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+)
+
+// fakeProvider stands in for a real provider on a local machine, every message is appended
+// to a file as one JSON line instead of being delivered.
+type fakeProvider struct {
+	mu   *sync.Mutex
+	path string
+}
+
+type fakeMessage struct {
+	ID       string                      `json:"id"`
+	SentAt   time.Time                   `json:"sentAt"`
+	Channel  entity.NotificationChannel  `json:"channel"`
+	To       string                      `json:"to"`
+	Template entity.NotificationTemplate `json:"template"`
+	Language entity.Language             `json:"language,omitempty"`
+	Subject  string                      `json:"subject,omitempty"`
+	Body     string                      `json:"body"`
+	TextBody string                      `json:"textBody,omitempty"`
+	// the content of an attachment is left out, only what was attached is logged
+	Attachments []entity.NotificationAttachment `json:"attachments,omitempty"`
+}
+
+func (p fakeProvider) Send(ctx context.Context, message entity.NotificationMessage) (messageID string, err error) {
+	b := make([]byte, 8)
+	_, err = rand.Read(b)
+	if err != nil {
+		return
+	}
+	id := "fake-" + hex.EncodeToString(b)
+	line, err := json.Marshal(fakeMessage{
+		ID:          id,
+		SentAt:      time.Now().UTC(),
+		Channel:     message.Channel,
+		To:          message.To,
+		Template:    message.Template,
+		Language:    message.Language,
+		Subject:     message.Subject,
+		Body:        message.Body,
+		TextBody:    message.TextBody,
+		Attachments: message.Attachments,
+	})
+	if err != nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	err = os.MkdirAll(filepath.Dir(p.path), 0o755)
+	if err != nil {
+		return
+	}
+	f, err := os.OpenFile(p.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	if err != nil {
+		return
+	}
+	messageID = id
+	return
+}
+
+/* -------------------------------- initiator ------------------------------- */
+type initiatorFakeProvider func(s *fakeProvider) *fakeProvider
+
+func NewFakeProvider() initiatorFakeProvider {
+	return func(q *fakeProvider) *fakeProvider {
+		return q
+	}
+}
+
+func (i initiatorFakeProvider) SetPath(path string) initiatorFakeProvider {
+	return func(s *fakeProvider) *fakeProvider {
+		i(s).path = path
+		return s
+	}
+}
+
+func (i initiatorFakeProvider) Build() Provider {
+	return i(&fakeProvider{
+		mu:   &sync.Mutex{},
+		path: "notifications.log",
+	})
+}
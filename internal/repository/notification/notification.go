@@ -0,0 +1,228 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+//go:embed template
+var FS embed.FS
+
+// templateExt is the extension of the templates of every channel under template/<channel>/<language>.
+// An email has a plain-text alternative next to its html, under the same name with .txt.
+var templateExt = map[entity.NotificationChannel]string{
+	entity.NotificationChannelEmail:    ".html",
+	entity.NotificationChannelSMS:      ".txt",
+	entity.NotificationChannelWhatsApp: ".txt",
+	entity.NotificationChannelPush:     ".txt",
+}
+
+var emailSubjects = map[entity.Language]map[entity.NotificationTemplate]string{
+	entity.LanguageIndonesian: {
+		entity.NotificationTemplateInvestmentAgreement: "Perjanjian investasi Anda (PDF terlampir)",
+		entity.NotificationTemplateSignatureRequest:    "Mohon tanda tangani perjanjian pinjaman Anda",
+		entity.NotificationTemplateLoanApproved:        "Pinjaman Anda telah disetujui",
+		entity.NotificationTemplateInvestmentReceived:  "Investasi Anda telah kami terima",
+		entity.NotificationTemplateLoanFunded:          "Pinjaman Anda telah terdanai penuh",
+		entity.NotificationTemplateLoanDisbursed:       "Dana pinjaman Anda telah dicairkan",
+		entity.NotificationTemplateRepaymentReceived:   "Pembayaran cicilan Anda telah kami terima",
+		entity.NotificationTemplateLoanExpired:         "Masa pendanaan pinjaman Anda telah berakhir",
+	},
+	entity.LanguageEnglish: {
+		entity.NotificationTemplateInvestmentAgreement: "Your investment agreement (PDF attached)",
+		entity.NotificationTemplateSignatureRequest:    "Please sign your loan agreement",
+		entity.NotificationTemplateLoanApproved:        "Your loan has been approved",
+		entity.NotificationTemplateInvestmentReceived:  "We have received your investment",
+		entity.NotificationTemplateLoanFunded:          "Your loan is fully funded",
+		entity.NotificationTemplateLoanDisbursed:       "Your loan has been disbursed",
+		entity.NotificationTemplateRepaymentReceived:   "We have received your repayment",
+		entity.NotificationTemplateLoanExpired:         "The funding window of your loan has closed",
+	},
+}
+
+var pushTitles = map[entity.Language]map[entity.NotificationTemplate]string{
+	entity.LanguageEnglish: {
+		entity.NotificationTemplateInvestmentAgreement: "Your investment agreement",
+		entity.NotificationTemplateSignatureRequest:    "Please sign your loan agreement",
+	},
+}
+
+var months = map[entity.Language][12]string{
+	entity.LanguageIndonesian: {"Januari", "Februari", "Maret", "April", "Mei", "Juni", "Juli", "Agustus", "September", "Oktober", "November", "Desember"},
+	entity.LanguageEnglish:    {"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+}
+
+// periods is the name of one and of several terms of a term unit.
+var periods = map[entity.Language]map[entity.TermUnit][2]string{
+	entity.LanguageIndonesian: {
+		entity.TermUnitWeek:  {"minggu", "minggu"},
+		entity.TermUnitMonth: {"bulan", "bulan"},
+	},
+	entity.LanguageEnglish: {
+		entity.TermUnitWeek:  {"week", "weeks"},
+		entity.TermUnitMonth: {"month", "months"},
+	},
+}
+
+// funcs are the template functions, dates, terms and rates are written the way the language does.
+func funcs(lang entity.Language) template.FuncMap {
+	return template.FuncMap{
+		// thousands formats an amount with Indonesian digit grouping, 1000000 becomes 1.000.000
+		"thousands": func(amount int) string {
+			return message.NewPrinter(language.Indonesian).Sprint(amount)
+		},
+		// date writes a day like 2 Januari 2026
+		"date": func(t time.Time) string {
+			return fmt.Sprintf("%d %s %d", t.Day(), months[lang][t.Month()-1], t.Year())
+		},
+		// period writes a term like 50 weeks
+		"period": func(term int, unit entity.TermUnit) string {
+			names := periods[lang][unit]
+			if term == 1 {
+				return fmt.Sprintf("%d %s", term, names[0])
+			}
+			return fmt.Sprintf("%d %s", term, names[1])
+		},
+		// percent writes a rate like 12.5%, with a decimal comma in Indonesian
+		"percent": func(rate float64) string {
+			formatted := strconv.FormatFloat(rate, 'f', -1, 64)
+			if lang == entity.LanguageIndonesian {
+				formatted = strings.Replace(formatted, ".", ",", 1)
+			}
+			return formatted + "%"
+		},
+	}
+}
+
+type notificationApi struct {
+	templates map[entity.NotificationChannel]map[entity.Language]*template.Template
+	providers map[entity.NotificationChannel]Provider
+}
+
+type NotificationApi interface {
+	Render(ctx context.Context, channel entity.NotificationChannel, name entity.NotificationTemplate, lang entity.Language, data any) (result entity.NotificationMessage, err error)
+	// HasTemplate tells whether a template exists for the channel in any language.
+	HasTemplate(channel entity.NotificationChannel, name entity.NotificationTemplate) bool
+	Send(ctx context.Context, message entity.NotificationMessage) (messageID string, err error)
+}
+
+// Render fills the template of a channel, the recipient address is left for the caller to set.
+// A template missing in the language asked for is rendered in the default language, then in English.
+func (r notificationApi) Render(ctx context.Context, channel entity.NotificationChannel, name entity.NotificationTemplate, lang entity.Language, data any) (result entity.NotificationMessage, err error) {
+	tt, lang, ok := r.lookup(channel, name, lang)
+	if !ok {
+		err = fmt.Errorf("template %s not found for channel %s", name, channel)
+		return
+	}
+	var bodyBuf bytes.Buffer
+	err = tt.ExecuteTemplate(&bodyBuf, string(name)+templateExt[channel], data)
+	if err != nil {
+		return
+	}
+
+	result = entity.NotificationMessage{
+		Channel:  channel,
+		Template: name,
+		Language: lang,
+		Body:     bodyBuf.String(),
+	}
+	switch channel {
+	case entity.NotificationChannelEmail:
+		result.Subject = emailSubjects[lang][name]
+		var textBuf bytes.Buffer
+		err = tt.ExecuteTemplate(&textBuf, string(name)+".txt", data)
+		if err != nil {
+			return
+		}
+		result.TextBody = strings.TrimSpace(textBuf.String())
+	case entity.NotificationChannelPush:
+		result.Subject = pushTitles[lang][name]
+	}
+	if channel != entity.NotificationChannelEmail {
+		result.Body = strings.TrimSpace(result.Body)
+	}
+	return
+}
+
+func (r notificationApi) HasTemplate(channel entity.NotificationChannel, name entity.NotificationTemplate) bool {
+	for _, tt := range r.templates[channel] {
+		if tt.Lookup(string(name)+templateExt[channel]) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (r notificationApi) lookup(channel entity.NotificationChannel, name entity.NotificationTemplate, lang entity.Language) (result *template.Template, found entity.Language, ok bool) {
+	for _, found = range []entity.Language{lang, entity.DefaultNotificationLanguage, entity.LanguageEnglish} {
+		result = r.templates[channel][found]
+		if result != nil && result.Lookup(string(name)+templateExt[channel]) != nil {
+			ok = true
+			return
+		}
+	}
+	return
+}
+
+func (r notificationApi) Send(ctx context.Context, message entity.NotificationMessage) (messageID string, err error) {
+	provider, ok := r.providers[message.Channel]
+	if !ok {
+		err = fmt.Errorf("no provider for channel %s", message.Channel)
+		return
+	}
+	messageID, err = provider.Send(ctx, message)
+	if err != nil {
+		return
+	}
+	return
+}
+
+/* -------------------------------- initiator ------------------------------- */
+type initiatorNotificationApi func(s *notificationApi) *notificationApi
+
+func NewNotificationApi() initiatorNotificationApi {
+	return func(q *notificationApi) *notificationApi {
+		return q
+	}
+}
+
+func (i initiatorNotificationApi) SetProvider(channel entity.NotificationChannel, provider Provider) initiatorNotificationApi {
+	return func(s *notificationApi) *notificationApi {
+		i(s).providers[channel] = provider
+		return s
+	}
+}
+
+func (i initiatorNotificationApi) Build() NotificationApi {
+	templates := map[entity.NotificationChannel]map[entity.Language]*template.Template{}
+	for channel := range templateExt {
+		dir := "template/" + strings.ToLower(string(channel))
+		entries, err := fs.ReadDir(FS, dir)
+		if err != nil {
+			panic(err)
+		}
+		templates[channel] = map[entity.Language]*template.Template{}
+		for _, entry := range entries {
+			lang := entity.Language(entry.Name())
+			if !entry.IsDir() || !lang.IsValid() {
+				panic(fmt.Errorf("%s/%s: not a language directory", dir, entry.Name()))
+			}
+			templates[channel][lang] = template.Must(template.New("").Funcs(funcs(lang)).ParseFS(FS, dir+"/"+entry.Name()+"/*"))
+		}
+	}
+	return i(&notificationApi{
+		templates: templates,
+		providers: map[entity.NotificationChannel]Provider{},
+	})
+}
@@ -0,0 +1,109 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+)
+
+// oneSignalProvider sends push notifications to a OneSignal subscription through the OneSignal REST API.
+type oneSignalProvider struct {
+	client *http.Client
+	config OneSignalConfig
+}
+
+type OneSignalConfig struct {
+	AppID  string
+	APIKey string
+	// empty uses https://api.onesignal.com
+	BaseURL string
+}
+
+type oneSignalRequest struct {
+	AppID                  string            `json:"app_id"`
+	TargetChannel          string            `json:"target_channel"`
+	IncludeSubscriptionIDs []string          `json:"include_subscription_ids"`
+	Headings               map[string]string `json:"headings"`
+	Contents               map[string]string `json:"contents"`
+}
+
+type oneSignalResponse struct {
+	ID     string          `json:"id"`
+	Errors json.RawMessage `json:"errors"`
+}
+
+func (p oneSignalProvider) Send(ctx context.Context, message entity.NotificationMessage) (messageID string, err error) {
+	payload, err := json.Marshal(oneSignalRequest{
+		AppID:                  p.config.AppID,
+		TargetChannel:          "push",
+		IncludeSubscriptionIDs: []string{message.To},
+		Headings:               map[string]string{"en": message.Subject},
+		Contents:               map[string]string{"en": message.Body},
+	})
+	if err != nil {
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.BaseURL+"/notifications", bytes.NewReader(payload))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Authorization", "Key "+p.config.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	var body oneSignalResponse
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		err = fmt.Errorf("onesignal: %s: %w", resp.Status, err)
+		return
+	}
+	// an unknown subscription is reported in errors with a 200 and no id
+	if resp.StatusCode >= 300 || body.ID == "" {
+		err = fmt.Errorf("onesignal: %s: %s", resp.Status, body.Errors)
+		return
+	}
+	messageID = body.ID
+	return
+}
+
+/* -------------------------------- initiator ------------------------------- */
+type initiatorOneSignalProvider func(s *oneSignalProvider) *oneSignalProvider
+
+func NewOneSignalProvider() initiatorOneSignalProvider {
+	return func(q *oneSignalProvider) *oneSignalProvider {
+		return q
+	}
+}
+
+func (i initiatorOneSignalProvider) SetConfig(config OneSignalConfig) initiatorOneSignalProvider {
+	return func(s *oneSignalProvider) *oneSignalProvider {
+		i(s).config = config
+		return s
+	}
+}
+
+func (i initiatorOneSignalProvider) Build() Provider {
+	s := i(&oneSignalProvider{
+		client: &http.Client{Timeout: 30 * time.Second},
+	})
+	if s.config.AppID == "" || s.config.APIKey == "" {
+		panic(errors.New("onesignal provider needs an app id and an api key"))
+	}
+	if s.config.BaseURL == "" {
+		s.config.BaseURL = "https://api.onesignal.com"
+	}
+	s.config.BaseURL = strings.TrimRight(s.config.BaseURL, "/")
+	return s
+}
@@ -0,0 +1,12 @@
+package notification
+
+import (
+	"context"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+)
+
+// Provider delivers a rendered notification on one channel and returns the id the provider gave the message.
+type Provider interface {
+	Send(ctx context.Context, message entity.NotificationMessage) (messageID string, err error)
+}
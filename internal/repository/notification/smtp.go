@@ -0,0 +1,61 @@
+package notification
+
+import (
+	"context"
+	"errors"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	pkgMail "github.com/adityaokke/test-amartha/internal/pkg/mail"
+)
+
+// smtpProvider sends email notifications through the SMTP mailer.
+type smtpProvider struct {
+	mailer pkgMail.Mailer
+}
+
+func (p smtpProvider) Send(ctx context.Context, message entity.NotificationMessage) (messageID string, err error) {
+	attachments := make([]pkgMail.Attachment, 0, len(message.Attachments))
+	for _, attachment := range message.Attachments {
+		attachments = append(attachments, pkgMail.Attachment{
+			Name:        attachment.Name,
+			ContentType: attachment.ContentType,
+			Content:     attachment.Content,
+			Inline:      attachment.Inline,
+		})
+	}
+	messageID, err = p.mailer.SendMail(pkgMail.Message{
+		To:          message.To,
+		Subject:     message.Subject,
+		HTML:        message.Body,
+		Text:        message.TextBody,
+		Attachments: attachments,
+	})
+	if err != nil {
+		return
+	}
+	return
+}
+
+/* -------------------------------- initiator ------------------------------- */
+type initiatorSMTPProvider func(s *smtpProvider) *smtpProvider
+
+func NewSMTPProvider() initiatorSMTPProvider {
+	return func(q *smtpProvider) *smtpProvider {
+		return q
+	}
+}
+
+func (i initiatorSMTPProvider) SetMailer(mailer pkgMail.Mailer) initiatorSMTPProvider {
+	return func(s *smtpProvider) *smtpProvider {
+		i(s).mailer = mailer
+		return s
+	}
+}
+
+func (i initiatorSMTPProvider) Build() Provider {
+	s := i(&smtpProvider{})
+	if s.mailer == nil {
+		panic(errors.New("smtp provider needs a mailer"))
+	}
+	return s
+}
@@ -0,0 +1,112 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+)
+
+// twilioProvider sends SMS, or WhatsApp messages, through the Twilio Messages API.
+type twilioProvider struct {
+	client   *http.Client
+	config   TwilioConfig
+	whatsApp bool
+}
+
+type TwilioConfig struct {
+	AccountSID string
+	AuthToken  string
+	// the sender phone number in E.164 format
+	From string
+	// empty uses https://api.twilio.com
+	BaseURL string
+}
+
+type twilioResponse struct {
+	SID     string `json:"sid"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (p twilioProvider) Send(ctx context.Context, message entity.NotificationMessage) (messageID string, err error) {
+	from, to := p.config.From, message.To
+	if p.whatsApp {
+		from, to = "whatsapp:"+from, "whatsapp:"+to
+	}
+	form := url.Values{}
+	form.Set("From", from)
+	form.Set("To", to)
+	form.Set("Body", message.Body)
+
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", p.config.BaseURL, url.PathEscape(p.config.AccountSID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return
+	}
+	req.SetBasicAuth(p.config.AccountSID, p.config.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	var body twilioResponse
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		err = fmt.Errorf("twilio: %s: %w", resp.Status, err)
+		return
+	}
+	if resp.StatusCode >= 300 {
+		err = fmt.Errorf("twilio: %s: %d %s", resp.Status, body.Code, body.Message)
+		return
+	}
+	messageID = body.SID
+	return
+}
+
+/* -------------------------------- initiator ------------------------------- */
+type initiatorTwilioProvider func(s *twilioProvider) *twilioProvider
+
+func NewTwilioProvider() initiatorTwilioProvider {
+	return func(q *twilioProvider) *twilioProvider {
+		return q
+	}
+}
+
+func (i initiatorTwilioProvider) SetConfig(config TwilioConfig) initiatorTwilioProvider {
+	return func(s *twilioProvider) *twilioProvider {
+		i(s).config = config
+		return s
+	}
+}
+
+// SetWhatsApp sends through the Twilio WhatsApp sender instead of SMS.
+func (i initiatorTwilioProvider) SetWhatsApp(whatsApp bool) initiatorTwilioProvider {
+	return func(s *twilioProvider) *twilioProvider {
+		i(s).whatsApp = whatsApp
+		return s
+	}
+}
+
+func (i initiatorTwilioProvider) Build() Provider {
+	s := i(&twilioProvider{
+		client: &http.Client{Timeout: 30 * time.Second},
+	})
+	if s.config.AccountSID == "" || s.config.AuthToken == "" || s.config.From == "" {
+		panic(errors.New("twilio provider needs an account sid, an auth token and a sender"))
+	}
+	if s.config.BaseURL == "" {
+		s.config.BaseURL = "https://api.twilio.com"
+	}
+	s.config.BaseURL = strings.TrimRight(s.config.BaseURL, "/")
+	return s
+}
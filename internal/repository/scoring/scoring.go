@@ -0,0 +1,155 @@
+package scoring
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+)
+
+type CreditScorer interface {
+	Score(ctx context.Context, input entity.CreditScoreInput) (result entity.CreditScore, err error)
+	RateBand(grade entity.RiskGrade) (result entity.CreditScoringGrade, err error)
+}
+
+type ruleBasedCreditScorer struct {
+	config entity.CreditScoringConfig
+}
+
+func (r ruleBasedCreditScorer) Score(ctx context.Context, input entity.CreditScoreInput) (result entity.CreditScore, err error) {
+	// loans that are not disbursed yet make the borrower ineligible before scoring,
+	// disbursed loans count as active within their term and as repaid once it has elapsed
+	now := time.Now().UTC()
+	activeLoans := 0
+	completedLoans := 0
+	for _, loan := range input.PreviousLoans {
+		if loan.Status != entity.LoanStatusDisbursed {
+			continue
+		}
+		if loan.IsOutstanding(now) {
+			activeLoans++
+		} else {
+			completedLoans++
+		}
+	}
+	factors := map[entity.CreditFactor]int{
+		entity.CreditFactorMonthlyIncome:     input.Borrower.MonthlyIncome,
+		entity.CreditFactorBusinessAgeMonths: input.Borrower.BusinessAgeMonths,
+		entity.CreditFactorCompletedLoans:    completedLoans,
+		entity.CreditFactorActiveLoans:       activeLoans,
+	}
+
+	score := r.config.BaseScore
+	for _, rule := range r.config.Rules {
+		value := factors[rule.Factor]
+		if value < rule.Min {
+			continue
+		}
+		if rule.Max != nil && value >= *rule.Max {
+			continue
+		}
+		score += rule.Points
+	}
+	if score < r.config.MinScore {
+		score = r.config.MinScore
+	}
+	if score > r.config.MaxScore {
+		score = r.config.MaxScore
+	}
+
+	// grades are sorted by min score descending on build
+	for _, grade := range r.config.Grades {
+		if score >= grade.MinScore {
+			result = entity.CreditScore{
+				Score: score,
+				Grade: grade.Grade,
+			}
+			return
+		}
+	}
+	err = fmt.Errorf("no risk grade configured for score %d", score)
+	return
+}
+
+func (r ruleBasedCreditScorer) RateBand(grade entity.RiskGrade) (result entity.CreditScoringGrade, err error) {
+	for _, g := range r.config.Grades {
+		if g.Grade == grade {
+			result = g
+			return
+		}
+	}
+	err = fmt.Errorf("no rate band configured for risk grade %s", grade)
+	return
+}
+
+// LoadConfig reads and validates a rule-based scoring config from a json file.
+func LoadConfig(path string) (result entity.CreditScoringConfig, err error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(raw, &result); err != nil {
+		return
+	}
+	if result.MaxScore <= result.MinScore {
+		err = errors.New("maxScore must be greater than minScore")
+		return
+	}
+	for _, rule := range result.Rules {
+		if !rule.Factor.IsValid() {
+			err = fmt.Errorf("unknown credit factor %s", rule.Factor)
+			return
+		}
+		if rule.Max != nil && *rule.Max <= rule.Min {
+			err = fmt.Errorf("rule %s max must be greater than min", rule.Factor)
+			return
+		}
+	}
+	if len(result.Grades) == 0 {
+		err = errors.New("at least one grade is required")
+		return
+	}
+	for _, grade := range result.Grades {
+		if !grade.Grade.IsValid() {
+			err = fmt.Errorf("unknown risk grade %s", grade.Grade)
+			return
+		}
+		if grade.MinRate > grade.MaxRate {
+			err = fmt.Errorf("grade %s minRate must not be greater than maxRate", grade.Grade)
+			return
+		}
+	}
+	return
+}
+
+/* -------------------------------- initiator ------------------------------- */
+type initiatorRuleBasedCreditScorer func(s *ruleBasedCreditScorer) *ruleBasedCreditScorer
+
+func NewRuleBasedCreditScorer() initiatorRuleBasedCreditScorer {
+	return func(q *ruleBasedCreditScorer) *ruleBasedCreditScorer {
+		return q
+	}
+}
+
+func (i initiatorRuleBasedCreditScorer) SetConfig(config entity.CreditScoringConfig) initiatorRuleBasedCreditScorer {
+	return func(s *ruleBasedCreditScorer) *ruleBasedCreditScorer {
+		i(s).config = config
+		return s
+	}
+}
+
+func (i initiatorRuleBasedCreditScorer) Build() CreditScorer {
+	s := i(&ruleBasedCreditScorer{})
+	grades := make([]entity.CreditScoringGrade, len(s.config.Grades))
+	copy(grades, s.config.Grades)
+	sort.SliceStable(grades, func(a, b int) bool {
+		return grades[a].MinScore > grades[b].MinScore
+	})
+	s.config.Grades = grades
+	return s
+}
@@ -0,0 +1,61 @@
+package scoring
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+)
+
+func TestScore(t *testing.T) {
+	three := 3
+	scorer := NewRuleBasedCreditScorer().SetConfig(entity.CreditScoringConfig{
+		BaseScore: 500,
+		MinScore:  0,
+		MaxScore:  1000,
+		Rules: []entity.CreditScoringRule{
+			{Factor: entity.CreditFactorCompletedLoans, Min: 1, Max: &three, Points: 75},
+			{Factor: entity.CreditFactorCompletedLoans, Min: 3, Points: 150},
+			{Factor: entity.CreditFactorActiveLoans, Min: 1, Points: -150},
+		},
+		Grades: []entity.CreditScoringGrade{
+			{Grade: entity.RiskGradeE, MinScore: 0},
+			{Grade: entity.RiskGradeC, MinScore: 500},
+			{Grade: entity.RiskGradeA, MinScore: 600},
+		},
+	}).Build()
+
+	now := time.Now().UTC()
+	// a weekly loan disbursed a year ago has repaid its 10 weeks, one disbursed yesterday is running
+	repaidAt := now.AddDate(-1, 0, 0)
+	runningAt := now.AddDate(0, 0, -1)
+	repaid := entity.Loan{Status: entity.LoanStatusDisbursed, Term: 10, TermUnit: entity.TermUnitWeek, DisbursedAt: &repaidAt}
+	running := entity.Loan{Status: entity.LoanStatusDisbursed, Term: 10, TermUnit: entity.TermUnitWeek, DisbursedAt: &runningAt}
+	approved := entity.Loan{Status: entity.LoanStatusApproved, Term: 10, TermUnit: entity.TermUnitWeek}
+
+	tests := []struct {
+		name      string
+		loans     []entity.Loan
+		wantScore int
+		wantGrade entity.RiskGrade
+	}{
+		{name: "no history", wantScore: 500, wantGrade: entity.RiskGradeC},
+		{name: "one repaid loan", loans: []entity.Loan{repaid}, wantScore: 575, wantGrade: entity.RiskGradeC},
+		{name: "three repaid loans", loans: []entity.Loan{repaid, repaid, repaid}, wantScore: 650, wantGrade: entity.RiskGradeA},
+		{name: "running loan", loans: []entity.Loan{running}, wantScore: 350, wantGrade: entity.RiskGradeE},
+		{name: "repaid and running loans", loans: []entity.Loan{repaid, running}, wantScore: 425, wantGrade: entity.RiskGradeE},
+		{name: "loan not disbursed yet", loans: []entity.Loan{approved}, wantScore: 500, wantGrade: entity.RiskGradeC},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := scorer.Score(context.Background(), entity.CreditScoreInput{PreviousLoans: tt.loans})
+			if err != nil {
+				t.Fatalf("Score() error = %v", err)
+			}
+			if got.Score != tt.wantScore || got.Grade != tt.wantGrade {
+				t.Errorf("Score() = %d %s, want %d %s", got.Score, got.Grade, tt.wantScore, tt.wantGrade)
+			}
+		})
+	}
+}
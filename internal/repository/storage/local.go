@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+)
+
+type localStorage struct {
+	root string
+}
+
+func (r localStorage) path(key string) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(r.root, filepath.FromSlash(key)), nil
+}
+
+func (r localStorage) Put(ctx context.Context, key string, src io.Reader, size int64, contentType string) (err error) {
+	fullpath, err := r.path(key)
+	if err != nil {
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(fullpath), 0o755); err != nil {
+		return fmt.Errorf("mkdir: %w", err)
+	}
+	dst, err := os.Create(fullpath)
+	if err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+	if _, err = io.Copy(dst, src); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(fullpath)
+		return fmt.Errorf("write: %w", err)
+	}
+	if err = dst.Close(); err != nil {
+		return fmt.Errorf("close: %w", err)
+	}
+	return
+}
+
+func (r localStorage) Get(ctx context.Context, key string) (result io.ReadCloser, err error) {
+	fullpath, err := r.path(key)
+	if err != nil {
+		return
+	}
+	result, err = os.Open(fullpath)
+	if errors.Is(err, fs.ErrNotExist) {
+		err = entity.ErrStorageObjectNotFound
+	}
+	return
+}
+
+func (r localStorage) Delete(ctx context.Context, key string) (err error) {
+	fullpath, err := r.path(key)
+	if err != nil {
+		return
+	}
+	err = os.Remove(fullpath)
+	if errors.Is(err, fs.ErrNotExist) {
+		err = entity.ErrStorageObjectNotFound
+	}
+	return
+}
+
+func (r localStorage) Stat(ctx context.Context, key string) (result entity.StorageObject, err error) {
+	fullpath, err := r.path(key)
+	if err != nil {
+		return
+	}
+	info, err := os.Stat(fullpath)
+	if errors.Is(err, fs.ErrNotExist) {
+		err = entity.ErrStorageObjectNotFound
+		return
+	}
+	if err != nil {
+		return
+	}
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	result = entity.StorageObject{
+		Key:         key,
+		Size:        info.Size(),
+		ContentType: contentType,
+		ModifiedAt:  info.ModTime().UTC(),
+	}
+	return
+}
+
+// Presign is not available on disk, files are streamed through Get instead.
+func (r localStorage) Presign(ctx context.Context, key string, expiry time.Duration) (result string, err error) {
+	err = entity.ErrPresignNotSupported
+	return
+}
+
+/* -------------------------------- initiator ------------------------------- */
+type initiatorLocalStorage func(s *localStorage) *localStorage
+
+func NewLocalStorage() initiatorLocalStorage {
+	return func(q *localStorage) *localStorage {
+		return q
+	}
+}
+
+func (i initiatorLocalStorage) SetRoot(root string) initiatorLocalStorage {
+	return func(s *localStorage) *localStorage {
+		i(s).root = root
+		return s
+	}
+}
+
+func (i initiatorLocalStorage) Build() Storage {
+	return i(&localStorage{
+		root: "storage",
+	})
+}
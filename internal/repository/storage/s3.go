@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// s3Storage works with AWS S3 and any S3 compatible server such as MinIO.
+type s3Storage struct {
+	client *minio.Client
+	bucket string
+}
+
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	AccessKey string
+	SecretKey string
+	Bucket    string
+	UseSSL    bool
+}
+
+func (r s3Storage) Put(ctx context.Context, key string, src io.Reader, size int64, contentType string) (err error) {
+	key, err = cleanKey(key)
+	if err != nil {
+		return
+	}
+	_, err = r.client.PutObject(ctx, r.bucket, key, src, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	return
+}
+
+func (r s3Storage) Get(ctx context.Context, key string) (result io.ReadCloser, err error) {
+	key, err = cleanKey(key)
+	if err != nil {
+		return
+	}
+	object, err := r.client.GetObject(ctx, r.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		err = notFound(err)
+		return
+	}
+	// GetObject is lazy, stat it so a missing key fails here instead of on the first read
+	if _, err = object.Stat(); err != nil {
+		_ = object.Close()
+		err = notFound(err)
+		return
+	}
+	result = object
+	return
+}
+
+func (r s3Storage) Delete(ctx context.Context, key string) (err error) {
+	key, err = cleanKey(key)
+	if err != nil {
+		return
+	}
+	if _, err = r.Stat(ctx, key); err != nil {
+		return
+	}
+	err = r.client.RemoveObject(ctx, r.bucket, key, minio.RemoveObjectOptions{})
+	return
+}
+
+func (r s3Storage) Stat(ctx context.Context, key string) (result entity.StorageObject, err error) {
+	key, err = cleanKey(key)
+	if err != nil {
+		return
+	}
+	info, err := r.client.StatObject(ctx, r.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		err = notFound(err)
+		return
+	}
+	result = entity.StorageObject{
+		Key:         key,
+		Size:        info.Size,
+		ContentType: info.ContentType,
+		ModifiedAt:  info.LastModified.UTC(),
+	}
+	return
+}
+
+func (r s3Storage) Presign(ctx context.Context, key string, expiry time.Duration) (result string, err error) {
+	key, err = cleanKey(key)
+	if err != nil {
+		return
+	}
+	u, err := r.client.PresignedGetObject(ctx, r.bucket, key, expiry, url.Values{})
+	if err != nil {
+		return
+	}
+	result = u.String()
+	return
+}
+
+func notFound(err error) error {
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return entity.ErrStorageObjectNotFound
+	}
+	return err
+}
+
+/* -------------------------------- initiator ------------------------------- */
+type initiatorS3Storage func(s *s3Storage) *s3Storage
+
+func NewS3Storage() initiatorS3Storage {
+	return func(q *s3Storage) *s3Storage {
+		return q
+	}
+}
+
+func (i initiatorS3Storage) SetConfig(config S3Config) initiatorS3Storage {
+	return func(s *s3Storage) *s3Storage {
+		client, err := minio.New(config.Endpoint, &minio.Options{
+			Creds:  credentials.NewStaticV4(config.AccessKey, config.SecretKey, ""),
+			Secure: config.UseSSL,
+			Region: config.Region,
+		})
+		if err != nil {
+			panic(err)
+		}
+		i(s).client = client
+		s.bucket = config.Bucket
+		return s
+	}
+}
+
+func (i initiatorS3Storage) Build() Storage {
+	s := i(&s3Storage{})
+	if s.client == nil || s.bucket == "" {
+		panic(errors.New("s3 storage needs an endpoint and a bucket"))
+	}
+	return s
+}
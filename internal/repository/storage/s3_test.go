@@ -0,0 +1,231 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+)
+
+// fakeS3 is a path style, in-memory stand-in for an S3 compatible server such as MinIO.
+type fakeS3 struct {
+	mu           sync.Mutex
+	bucket       string
+	objects      map[string]fakeS3Object
+	unsignedReqs int
+}
+
+type fakeS3Object struct {
+	body        []byte
+	contentType string
+	modifiedAt  time.Time
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/") {
+		f.unsignedReqs++
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		body, err := readS3Body(r)
+		if err != nil {
+			writeS3Error(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		f.objects[key] = fakeS3Object{body: body, contentType: r.Header.Get("Content-Type"), modifiedAt: time.Now().UTC()}
+		w.Header().Set("ETag", `"etag"`)
+	case http.MethodGet, http.MethodHead:
+		object, ok := f.objects[key]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Type", object.contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(object.body)))
+		w.Header().Set("Last-Modified", object.modifiedAt.Format(http.TimeFormat))
+		w.Header().Set("ETag", `"etag"`)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(object.body)
+		}
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
+
+// readS3Body reads a plain body, or the aws-chunked body clients stream over plain http.
+func readS3Body(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+	var body bytes.Buffer
+	reader := bufio.NewReader(r.Body)
+	for {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(header), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return body.Bytes(), nil
+		}
+		if _, err = io.CopyN(&body, reader, size); err != nil {
+			return nil, err
+		}
+		if _, err = reader.Discard(2); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func newTestS3Storage(t *testing.T) (Storage, *fakeS3) {
+	t.Helper()
+	fake := &fakeS3{bucket: "amartha", objects: map[string]fakeS3Object{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewS3Storage().
+		SetConfig(S3Config{
+			Endpoint:  u.Host,
+			Region:    "us-east-1",
+			AccessKey: "access",
+			SecretKey: "secret",
+			Bucket:    fake.bucket,
+		}).
+		Build()
+	return store, fake
+}
+
+func TestS3Storage(t *testing.T) {
+	store, fake := newTestS3Storage(t)
+	ctx := context.Background()
+	content := []byte("%PDF-1.4 agreement")
+
+	err := store.Put(ctx, "agreements/a.pdf", bytes.NewReader(content), int64(len(content)), "application/pdf")
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	object, err := store.Stat(ctx, "agreements/a.pdf")
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if object.Key != "agreements/a.pdf" || object.Size != int64(len(content)) || object.ContentType != "application/pdf" || object.ModifiedAt.IsZero() {
+		t.Errorf("Stat() = %+v", object)
+	}
+
+	src, err := store.Get(ctx, "agreements/a.pdf")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	got, err := io.ReadAll(src)
+	_ = src.Close()
+	if err != nil {
+		t.Fatalf("reading the object: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("Get() = %q, want %q", got, content)
+	}
+
+	presigned, err := store.Presign(ctx, "agreements/a.pdf", 15*time.Minute)
+	if err != nil {
+		t.Fatalf("Presign() error = %v", err)
+	}
+	presignedURL, err := url.Parse(presigned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if presignedURL.Path != "/amartha/agreements/a.pdf" || presignedURL.Query().Get("X-Amz-Expires") != "900" || presignedURL.Query().Get("X-Amz-Signature") == "" {
+		t.Errorf("Presign() = %s", presigned)
+	}
+
+	if err = store.Delete(ctx, "agreements/a.pdf"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err = store.Stat(ctx, "agreements/a.pdf"); !errors.Is(err, entity.ErrStorageObjectNotFound) {
+		t.Errorf("Stat() after Delete() error = %v, want %v", err, entity.ErrStorageObjectNotFound)
+	}
+	if fake.unsignedReqs > 0 {
+		t.Errorf("%d requests were not signed", fake.unsignedReqs)
+	}
+}
+
+func TestS3StorageMissingObject(t *testing.T) {
+	store, _ := newTestS3Storage(t)
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{name: "get", call: func() error {
+			_, err := store.Get(ctx, "uploads/missing.png")
+			return err
+		}},
+		{name: "stat", call: func() error {
+			_, err := store.Stat(ctx, "uploads/missing.png")
+			return err
+		}},
+		{name: "delete", call: func() error {
+			return store.Delete(ctx, "uploads/missing.png")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, entity.ErrStorageObjectNotFound) {
+				t.Errorf("error = %v, want %v", err, entity.ErrStorageObjectNotFound)
+			}
+		})
+	}
+}
+
+func TestS3StorageInvalidKey(t *testing.T) {
+	store, fake := newTestS3Storage(t)
+	ctx := context.Background()
+	for _, key := range []string{"../secrets", "uploads/../../x", "", "uploads/"} {
+		t.Run(key, func(t *testing.T) {
+			err := store.Put(ctx, key, strings.NewReader("x"), 1, "text/plain")
+			if err == nil {
+				t.Errorf("Put(%q) succeeded", key)
+			}
+		})
+	}
+	if len(fake.objects) != 0 {
+		t.Errorf("invalid keys reached the server: %v", fake.objects)
+	}
+}
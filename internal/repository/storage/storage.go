@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+)
+
+// Storage keeps uploads and generated documents under slash separated keys
+// such as uploads/<name>, the backend is picked by configuration.
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (err error)
+	Get(ctx context.Context, key string) (result io.ReadCloser, err error)
+	Delete(ctx context.Context, key string) (err error)
+	Stat(ctx context.Context, key string) (result entity.StorageObject, err error)
+	Presign(ctx context.Context, key string, expiry time.Duration) (result string, err error)
+}
+
+// cleanKey rejects keys that would escape the storage root.
+func cleanKey(key string) (string, error) {
+	cleaned := path.Clean("/" + key)[1:]
+	if cleaned == "" || cleaned != strings.TrimPrefix(key, "/") {
+		return "", errors.New("invalid storage key " + key)
+	}
+	return cleaned, nil
+}
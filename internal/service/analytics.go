@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+)
+
+// AnalyticsService follows loans through the funnel from proposal to disbursement. It only logs
+// one line per domain event for now, so it can be fed to a log based pipeline.
+type AnalyticsService interface {
+	HandleDomainEvent(ctx context.Context, event entity.DomainEvent) (err error)
+}
+
+func (s *analyticsService) HandleDomainEvent(ctx context.Context, event entity.DomainEvent) (err error) {
+	investmentID, investorID, investedAmount := 0, 0, 0
+	if event.Investment != nil {
+		investmentID = event.Investment.ID
+		investorID = event.Investment.InvestorID
+		investedAmount = event.Investment.Amount
+	}
+	log.Printf("analytics: event=%s loan=%d product=%d grade=%s amount=%d funded=%d investment=%d investor=%d invested=%d at=%s",
+		event.Type, event.Loan.ID, event.Loan.ProductID, event.Loan.RiskGrade, event.Loan.Amount, event.Loan.InvestedAmount,
+		investmentID, investorID, investedAmount, event.OccurredAt.Format(time.RFC3339))
+	return
+}
+
+type analyticsService struct {
+}
+
+type InitiatorAnalytics func(s *analyticsService) *analyticsService
+
+func NewAnalyticsService() InitiatorAnalytics {
+	return func(s *analyticsService) *analyticsService {
+		return s
+	}
+}
+
+func (i InitiatorAnalytics) Build() AnalyticsService {
+	return i(&analyticsService{})
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+)
+
+type AuthService interface {
+	IssueAccessToken(ctx context.Context, input entity.IssueAccessTokenInput) (result entity.AccessToken, err error)
+	Authenticate(ctx context.Context, token string) (result entity.Caller, err error)
+}
+
+// IssueAccessToken signs a token for a user the gateway logged in, the gateway
+// proves itself with the api key.
+func (s *authService) IssueAccessToken(ctx context.Context, input entity.IssueAccessTokenInput) (result entity.AccessToken, err error) {
+	if subtle.ConstantTimeCompare([]byte(input.APIKey), []byte(s.policy.APIKey)) != 1 {
+		err = entity.ErrAccessDenied
+		return
+	}
+	if !input.Audience.IsValid() {
+		err = errors.New("audience is invalid")
+		return
+	}
+	if input.SubjectID <= 0 {
+		err = errors.New("subjectId is required")
+		return
+	}
+	result.ExpiresAt = time.Now().UTC().Add(s.policy.TTL).Truncate(time.Second)
+	payload := fmt.Sprintf("%s.%d.%d", input.Audience, input.SubjectID, result.ExpiresAt.Unix())
+	result.Token = payload + "." + s.signature(payload)
+	return
+}
+
+// Authenticate returns the caller a token was issued to.
+func (s *authService) Authenticate(ctx context.Context, token string) (result entity.Caller, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 4 {
+		err = errors.New("access token is invalid")
+		return
+	}
+	payload := strings.Join(parts[:3], ".")
+	if !hmac.Equal([]byte(s.signature(payload)), []byte(parts[3])) {
+		err = errors.New("access token is invalid")
+		return
+	}
+	expiresAt, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		err = errors.New("access token is invalid")
+		return
+	}
+	if time.Now().Unix() > expiresAt {
+		err = errors.New("access token has expired")
+		return
+	}
+	result.Audience = entity.DownloadAudience(parts[0])
+	result.SubjectID, err = strconv.Atoi(parts[1])
+	if err != nil || !result.Audience.IsValid() {
+		err = errors.New("access token is invalid")
+		return
+	}
+	return
+}
+
+func (s *authService) signature(payload string) string {
+	mac := hmac.New(sha256.New, []byte(s.policy.Secret))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+type authService struct {
+	policy entity.AuthPolicy
+}
+
+type InitiatorAuth func(s *authService) *authService
+
+func NewAuthService() InitiatorAuth {
+	return func(s *authService) *authService {
+		return s
+	}
+}
+
+func (i InitiatorAuth) SetPolicy(policy entity.AuthPolicy) InitiatorAuth {
+	return func(s *authService) *authService {
+		i(s).policy = policy
+		return s
+	}
+}
+
+func (i InitiatorAuth) Build() AuthService {
+	return i(&authService{})
+}
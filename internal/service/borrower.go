@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/repository/db"
+)
+
+type BorrowerService interface {
+	AddBorrower(ctx context.Context, input entity.AddBorrowerInput) (result entity.Borrower, err error)
+
+	Borrowers(ctx context.Context, filter entity.BorrowersInput) (result []entity.Borrower, err error)
+	CountBorrowers(ctx context.Context, filter entity.BorrowersInput) (result int64, err error)
+	Borrower(ctx context.Context, filter entity.BorrowerInput) (result entity.Borrower, err error)
+}
+
+func (s *borrowerService) AddBorrower(ctx context.Context, input entity.AddBorrowerInput) (result entity.Borrower, err error) {
+	if input.Name == "" {
+		err = errors.New("name is required")
+		return
+	}
+	if input.MonthlyIncome < 0 {
+		err = errors.New("monthlyIncome is invalid")
+		return
+	}
+	if input.BusinessAgeMonths < 0 {
+		err = errors.New("businessAgeMonths is invalid")
+		return
+	}
+	if (input.Latitude == nil) != (input.Longitude == nil) {
+		err = errors.New("latitude and longitude must be given together")
+		return
+	}
+	if input.Latitude != nil && (*input.Latitude < -90 || *input.Latitude > 90 || *input.Longitude < -180 || *input.Longitude > 180) {
+		err = errors.New("latitude/longitude is out of range")
+		return
+	}
+	item := entity.Borrower{
+		Name:              input.Name,
+		Email:             input.Email,
+		Phone:             input.Phone,
+		MonthlyIncome:     input.MonthlyIncome,
+		BusinessAgeMonths: input.BusinessAgeMonths,
+		Latitude:          input.Latitude,
+		Longitude:         input.Longitude,
+	}
+	err = s.borrowerRepo.Create(ctx, &item)
+	if err != nil {
+		return
+	}
+	result = item
+	return
+}
+
+func (s *borrowerService) Borrowers(ctx context.Context, filter entity.BorrowersInput) (result []entity.Borrower, err error) {
+	result, err = s.borrowerRepo.Borrowers(ctx, filter)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (s *borrowerService) CountBorrowers(ctx context.Context, filter entity.BorrowersInput) (result int64, err error) {
+	result, err = s.borrowerRepo.CountBorrowers(ctx, filter)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (s *borrowerService) Borrower(ctx context.Context, filter entity.BorrowerInput) (result entity.Borrower, err error) {
+	result, err = s.borrowerRepo.Borrower(ctx, filter)
+	if err != nil {
+		return
+	}
+	return
+}
+
+type borrowerService struct {
+	borrowerRepo db.BorrowerRepository
+}
+
+type InitiatorBorrower func(s *borrowerService) *borrowerService
+
+func NewBorrowerService() InitiatorBorrower {
+	return func(s *borrowerService) *borrowerService {
+		return s
+	}
+}
+
+func (i InitiatorBorrower) SetRepository(borrowerRepository db.BorrowerRepository) InitiatorBorrower {
+	return func(s *borrowerService) *borrowerService {
+		i(s).borrowerRepo = borrowerRepository
+		return s
+	}
+}
+
+func (i InitiatorBorrower) Build() BorrowerService {
+	return i(&borrowerService{})
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// dispatcher works off a queue kept in a table, the outbox, notifications and webhook deliveries
+// each store their own rows but claim, run and reschedule them the same way.
+type dispatcher[T any] struct {
+	name string
+	// due lists the items whose time has come, including claimed items whose lock is older than lockedBefore
+	due   func(ctx context.Context, now time.Time, lockedBefore time.Time) ([]T, error)
+	claim func(ctx context.Context, item *T, lockedBefore time.Time) (bool, error)
+	// run attempts one claimed item and records the outcome on it, finish stores it afterwards
+	run    func(ctx context.Context, item *T)
+	finish func(ctx context.Context, item *T) error
+
+	pollInterval time.Duration
+	lockTimeout  time.Duration
+	// wake dispatches right away instead of at the next poll, nil only polls
+	wake chan struct{}
+}
+
+// dispatch runs every item that is due once.
+func (d dispatcher[T]) dispatch(ctx context.Context) (processed int, err error) {
+	now := time.Now().UTC()
+	lockedBefore := now.Add(-d.lockTimeout)
+	items, err := d.due(ctx, now, lockedBefore)
+	if err != nil {
+		return
+	}
+	for i := range items {
+		item := &items[i]
+		var claimed bool
+		claimed, err = d.claim(ctx, item, lockedBefore)
+		if err != nil {
+			return
+		}
+		if !claimed {
+			continue
+		}
+		d.run(ctx, item)
+		err = d.finish(ctx, item)
+		if err != nil {
+			return
+		}
+		processed++
+	}
+	return
+}
+
+// start dispatches due items every poll interval, and right away when woken up, until ctx is done.
+func (d dispatcher[T]) start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(d.pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-d.wake:
+			}
+			_, err := d.dispatch(ctx)
+			if err != nil {
+				log.Printf("%s: %v", d.name, err)
+			}
+		}
+	}()
+}
+
+// wakeUp lets an idle loop pick new work up without waiting for the next poll.
+func wakeUp(wake chan struct{}) {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// retryBackoff is the delay before the next attempt, it doubles after every failed attempt up to maxDelay.
+func retryBackoff(attempts int, delay time.Duration, maxDelay time.Duration) time.Duration {
+	for i := 1; i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxDelay)
+}
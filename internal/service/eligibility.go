@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+)
+
+type EligibilityEngine interface {
+	Check(ctx context.Context, input entity.EligibilityInput) (result entity.EligibilityResult, err error)
+}
+
+func (s *eligibilityEngine) Check(ctx context.Context, input entity.EligibilityInput) (result entity.EligibilityResult, err error) {
+	reasons := []entity.EligibilityReason{}
+	now := time.Now().UTC()
+
+	outstanding := 0
+	for _, loan := range input.PreviousLoans {
+		if !loan.Status.IsTerminal() {
+			reasons = append(reasons, entity.EligibilityReason{
+				Code:    entity.EligibilityReasonActiveLoan,
+				Message: fmt.Sprintf("borrower already has an active loan #%d with status %s", loan.ID, loan.Status),
+			})
+		}
+		if loan.IsOutstanding(now) {
+			outstanding += loan.Amount
+		}
+	}
+
+	limits := s.limits
+	if limits.MaxBorrowerExposure > 0 && outstanding+input.Amount > limits.MaxBorrowerExposure {
+		reasons = append(reasons, entity.EligibilityReason{
+			Code:    entity.EligibilityReasonExposureLimit,
+			Message: fmt.Sprintf("outstanding exposure %d plus requested amount %d exceeds limit %d", outstanding, input.Amount, limits.MaxBorrowerExposure),
+		})
+	}
+
+	product := input.Product
+	if input.Amount < product.MinAmount {
+		reasons = append(reasons, entity.EligibilityReason{
+			Code:    entity.EligibilityReasonAmountTooLow,
+			Message: fmt.Sprintf("amount must be at least %d for product %s", product.MinAmount, product.Name),
+		})
+	}
+	if input.Amount > product.MaxAmount {
+		reasons = append(reasons, entity.EligibilityReason{
+			Code:    entity.EligibilityReasonAmountTooHigh,
+			Message: fmt.Sprintf("amount must be at most %d for product %s", product.MaxAmount, product.Name),
+		})
+	}
+	if !product.AllowsTerm(input.Term) {
+		reasons = append(reasons, entity.EligibilityReason{
+			Code:    entity.EligibilityReasonTermNotAllowed,
+			Message: fmt.Sprintf("term must be one of %v for product %s", product.AllowedTerms, product.Name),
+		})
+	}
+	if !product.AllowsTermUnit(input.TermUnit) {
+		reasons = append(reasons, entity.EligibilityReason{
+			Code:    entity.EligibilityReasonTermUnitInvalid,
+			Message: fmt.Sprintf("termUnit must be one of %v for product %s", product.TermUnits, product.Name),
+		})
+	}
+
+	result = entity.EligibilityResult{
+		Eligible:            len(reasons) == 0,
+		OutstandingExposure: outstanding,
+		Reasons:             reasons,
+	}
+	return
+}
+
+type eligibilityEngine struct {
+	limits entity.EligibilityLimits
+}
+
+type InitiatorEligibility func(s *eligibilityEngine) *eligibilityEngine
+
+func NewEligibilityEngine() InitiatorEligibility {
+	return func(s *eligibilityEngine) *eligibilityEngine {
+		return s
+	}
+}
+
+func (i InitiatorEligibility) SetLimits(limits entity.EligibilityLimits) InitiatorEligibility {
+	return func(s *eligibilityEngine) *eligibilityEngine {
+		i(s).limits = limits
+		return s
+	}
+}
+
+func (i InitiatorEligibility) Build() EligibilityEngine {
+	return i(&eligibilityEngine{})
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+)
+
+// EventHandler reacts to a domain event. A handler may see the same event again, for example
+// when the outbox retries a funded loan, so it has to check what it already did.
+type EventHandler func(ctx context.Context, event entity.DomainEvent) (err error)
+
+type EventBus interface {
+	// Subscribe runs the handler on the publisher goroutine, its error is returned by Publish.
+	Subscribe(name string, handler EventHandler, eventTypes ...entity.DomainEventType)
+	// SubscribeAsync runs the handler on its own goroutine, its error is only logged.
+	SubscribeAsync(name string, handler EventHandler, eventTypes ...entity.DomainEventType)
+	Publish(ctx context.Context, event entity.DomainEvent) (err error)
+}
+
+// Publish hands the event to every subscriber of its type. A subscriber that fails or panics
+// does not stop the others, the failures of the synchronous ones are joined into the error.
+func (b *eventBus) Publish(ctx context.Context, event entity.DomainEvent) (err error) {
+	if !event.Type.IsValid() {
+		err = fmt.Errorf("invalid domain event type %s", event.Type)
+		return
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now().UTC()
+	}
+
+	b.mu.RLock()
+	subscriptions := b.subscriptions[event.Type]
+	b.mu.RUnlock()
+
+	errs := []error{}
+	for _, subscription := range subscriptions {
+		if subscription.async {
+			// the request that published the event may be over before the handler runs
+			go func(subscription eventSubscription) {
+				handleErr := b.handle(context.WithoutCancel(ctx), subscription, event)
+				if handleErr != nil {
+					log.Printf("events: %s %s: %v", event.Key, subscription.name, handleErr)
+				}
+			}(subscription)
+			continue
+		}
+		handleErr := b.handle(ctx, subscription, event)
+		if handleErr != nil {
+			log.Printf("events: %s %s: %v", event.Key, subscription.name, handleErr)
+			errs = append(errs, fmt.Errorf("%s: %w", subscription.name, handleErr))
+		}
+	}
+	err = errors.Join(errs...)
+	return
+}
+
+// handle turns a panic of the handler into an error so it is isolated like any other failure.
+func (b *eventBus) handle(ctx context.Context, subscription eventSubscription, event entity.DomainEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return subscription.handler(ctx, event)
+}
+
+func (b *eventBus) Subscribe(name string, handler EventHandler, eventTypes ...entity.DomainEventType) {
+	b.subscribe(eventSubscription{name: name, handler: handler}, eventTypes)
+}
+
+func (b *eventBus) SubscribeAsync(name string, handler EventHandler, eventTypes ...entity.DomainEventType) {
+	b.subscribe(eventSubscription{name: name, handler: handler, async: true}, eventTypes)
+}
+
+func (b *eventBus) subscribe(subscription eventSubscription, eventTypes []entity.DomainEventType) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, eventType := range eventTypes {
+		if !eventType.IsValid() {
+			panic(fmt.Sprintf("invalid domain event type %s", eventType))
+		}
+		// copy on write, Publish ranges over the slice it read without holding the lock
+		subscriptions := append([]eventSubscription{}, b.subscriptions[eventType]...)
+		b.subscriptions[eventType] = append(subscriptions, subscription)
+	}
+}
+
+type eventSubscription struct {
+	name    string
+	handler EventHandler
+	async   bool
+}
+
+type eventBus struct {
+	mu            sync.RWMutex
+	subscriptions map[entity.DomainEventType][]eventSubscription
+}
+
+type InitiatorEventBus func(b *eventBus) *eventBus
+
+func NewEventBus() InitiatorEventBus {
+	return func(b *eventBus) *eventBus {
+		return b
+	}
+}
+
+func (i InitiatorEventBus) Build() EventBus {
+	return i(&eventBus{
+		subscriptions: map[entity.DomainEventType][]eventSubscription{},
+	})
+}
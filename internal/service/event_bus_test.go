@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+)
+
+func TestEventBusPublish(t *testing.T) {
+	tests := []struct {
+		name string
+		// failing handler run between two handlers that record the event
+		failing EventHandler
+		wantErr string
+	}{
+		{
+			name:    "handler succeeds",
+			failing: func(ctx context.Context, event entity.DomainEvent) error { return nil },
+		},
+		{
+			name:    "handler fails",
+			failing: func(ctx context.Context, event entity.DomainEvent) error { return errors.New("boom") },
+			wantErr: "failing: boom",
+		},
+		{
+			name:    "handler panics",
+			failing: func(ctx context.Context, event entity.DomainEvent) error { panic("boom") },
+			wantErr: "failing: panic: boom",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := NewEventBus().Build()
+			handled := []string{}
+			record := func(name string) EventHandler {
+				return func(ctx context.Context, event entity.DomainEvent) error {
+					handled = append(handled, name)
+					return nil
+				}
+			}
+			bus.Subscribe("first", record("first"), entity.DomainEventTypeLoanApproved)
+			bus.Subscribe("failing", tt.failing, entity.DomainEventTypeLoanApproved)
+			bus.Subscribe("last", record("last"), entity.DomainEventTypeLoanApproved)
+			bus.Subscribe("other", record("other"), entity.DomainEventTypeLoanDisbursed)
+
+			err := bus.Publish(context.Background(), entity.DomainEvent{
+				Type: entity.DomainEventTypeLoanApproved,
+				Key:  "LOAN_APPROVED:1",
+			})
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("Publish() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("Publish() error = %v, want %q", err, tt.wantErr)
+			}
+			if strings.Join(handled, ",") != "first,last" {
+				t.Errorf("handled by %v, want [first last]", handled)
+			}
+		})
+	}
+}
+
+func TestEventBusPublishAsync(t *testing.T) {
+	bus := NewEventBus().Build()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	bus.SubscribeAsync("panicking", func(ctx context.Context, event entity.DomainEvent) error {
+		defer wg.Done()
+		panic("boom")
+	}, entity.DomainEventTypeLoanDisbursed)
+
+	var mu sync.Mutex
+	var got entity.DomainEvent
+	bus.SubscribeAsync("recording", func(ctx context.Context, event entity.DomainEvent) error {
+		defer wg.Done()
+		if ctx.Err() != nil {
+			t.Errorf("async handler got a cancelled context: %v", ctx.Err())
+		}
+		mu.Lock()
+		got = event
+		mu.Unlock()
+		return nil
+	}, entity.DomainEventTypeLoanDisbursed)
+
+	// the publishing request is over before the async handlers run
+	ctx, cancel := context.WithCancel(context.Background())
+	err := bus.Publish(ctx, entity.DomainEvent{
+		Type: entity.DomainEventTypeLoanDisbursed,
+		Key:  "LOAN_DISBURSED:1",
+	})
+	cancel()
+	if err != nil {
+		t.Fatalf("Publish() error = %v, async failures are only logged", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("async handlers did not run")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if got.Key != "LOAN_DISBURSED:1" || got.OccurredAt.IsZero() {
+		t.Errorf("async handler got %+v, want the event with its time set", got)
+	}
+}
+
+func TestEventBusPublishInvalidType(t *testing.T) {
+	bus := NewEventBus().Build()
+	err := bus.Publish(context.Background(), entity.DomainEvent{Type: "UNKNOWN"})
+	if err == nil {
+		t.Fatal("Publish() of an unknown event type succeeded")
+	}
+}
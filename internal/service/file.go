@@ -1,70 +1,639 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
 	"io"
+	"log"
+	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/pkg/exif"
+	"github.com/adityaokke/test-amartha/internal/pkg/imaging"
+	"github.com/adityaokke/test-amartha/internal/pkg/pdfinfo"
+	"github.com/adityaokke/test-amartha/internal/pkg/phash"
+	"github.com/adityaokke/test-amartha/internal/repository/db"
+	"github.com/adityaokke/test-amartha/internal/repository/storage"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type FileService interface {
-	UploadFile(ctx context.Context, input entity.UploadFileInput) (fileURL string, err error)
+	UploadFile(ctx context.Context, input entity.UploadFileInput) (result entity.File, err error)
+	ReviewFile(ctx context.Context, input entity.ReviewFileInput) (result entity.File, err error)
+	ResolveFile(ctx context.Context, fileURL string) (result entity.File, err error)
+	ImageMetadata(ctx context.Context, file entity.File) (result entity.ImageMetadata, err error)
+	PerceptualHash(ctx context.Context, file entity.File) (result uint64, err error)
+	HashPendingImages(ctx context.Context) (hashed int, failed int, err error)
+	RunHashBackfillJob(ctx context.Context, job entity.Job) (err error)
+	PDFPageCount(ctx context.Context, file entity.File) (result int, err error)
+	DownloadURL(ctx context.Context, fileURL string, grant entity.DownloadGrant) (result string, err error)
+	VerifyDownload(ctx context.Context, input entity.DownloadInput) (err error)
+	OpenObject(ctx context.Context, key string) (result io.ReadCloser, object entity.StorageObject, err error)
+	PresignObject(ctx context.Context, key string, expiry time.Duration) (result string, err error)
+
+	DeleteFile(ctx context.Context, file entity.File) (err error)
+
+	Files(ctx context.Context, filter entity.FilesInput) (result []entity.File, err error)
+	File(ctx context.Context, filter entity.FileInput) (result entity.File, err error)
+}
+
+// fileExtensions names stored files after their sniffed content type.
+var fileExtensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"application/pdf": ".pdf",
 }
 
-func (s *fileService) UploadFile(ctx context.Context, input entity.UploadFileInput) (result string, err error) {
+func (s *fileService) UploadFile(ctx context.Context, input entity.UploadFileInput) (result entity.File, err error) {
 	if input.File == nil {
 		err = errors.New("file is required")
 		return
 	}
 
-	ext := strings.ToLower(filepath.Ext(input.File.Filename))
-	if ext != ".pdf" && ext != ".png" && ext != ".jpg" && ext != ".jpeg" {
-		err = errors.New("only pdf/png/jpg/jpeg allowed")
+	if input.Purpose == "" {
+		err = errors.New("purpose is required")
+		return
+	}
+	if !input.Purpose.IsValid() {
+		err = errors.New("purpose is invalid")
+		return
+	}
+	if input.Purpose == entity.FilePurposeSignedAgreement && input.LoanID == 0 {
+		err = errors.New("loanId is required for a signed agreement")
+		return
+	}
+	if input.Purpose == entity.FilePurposePhotoProof && input.EmployeeID == 0 {
+		err = errors.New("employeeId is required for a photo proof")
+		return
+	}
+	maxSize := input.Purpose.MaxSize()
+	if input.File.Size > maxSize {
+		err = fmt.Errorf("file is larger than %d MB", maxSize>>20)
 		return
 	}
 
 	// open upload
 	src, err := input.File.Open()
 	if err != nil {
-		return "", fmt.Errorf("open: %w", err)
+		return result, fmt.Errorf("open: %w", err)
 	}
 	defer src.Close()
+	content, err := io.ReadAll(io.LimitReader(src, maxSize+1))
+	if err != nil {
+		return result, fmt.Errorf("read: %w", err)
+	}
+	if int64(len(content)) > maxSize {
+		err = fmt.Errorf("file is larger than %d MB", maxSize>>20)
+		return
+	}
 
-	dir := entity.LocalUploadPath
+	// the file name and extension are chosen by the client, only the content is trusted
+	contentType := http.DetectContentType(content)
+	if !slices.Contains(input.Purpose.ContentTypes(), contentType) {
+		err = fmt.Errorf("%s upload must be one of %s, got %s", input.Purpose, strings.Join(input.Purpose.ContentTypes(), ", "), contentType)
+		return
+	}
+	ext := fileExtensions[contentType]
+	checksum := sha256.Sum256(content)
 
 	// generate a server-side name (or keep original if you prefer)
 	filename := uuid.New().String() + ext
-	dstPath := filepath.Join(dir, filename)
+	item := entity.File{
+		Name:         filename,
+		OriginalName: filepath.Base(input.File.Filename),
+		ContentType:  contentType,
+		Size:         int64(len(content)),
+		SHA256:       hex.EncodeToString(checksum[:]),
+		Purpose:      input.Purpose,
+	}
+	var img image.Image
+	if contentType != "application/pdf" {
+		img, err = decodeImage(content)
+		if err != nil {
+			return
+		}
+		setPerceptualHash(&item, phash.Compute(img))
+	}
+
+	err = s.storage.Put(ctx, item.StorageKey(), bytes.NewReader(content), item.Size, contentType)
+	if err != nil {
+		return result, fmt.Errorf("write: %w", err)
+	}
 
-	// write directly to final path (NO atomic temp)
-	dst, err := os.Create(dstPath)
+	u, _ := url.Parse(os.Getenv("APP_HOST"))
+	fileURL, err := url.JoinPath(u.String(), entity.PublicUploadPath, filename)
 	if err != nil {
-		return "", fmt.Errorf("create: %w", err)
+		return
 	}
-	if _, err := io.Copy(dst, src); err != nil {
-		_ = dst.Close()
-		return "", fmt.Errorf("write: %w", err)
+
+	item.URL = fileURL
+	if input.EmployeeID != 0 {
+		item.UploadedByEmployeeID = &input.EmployeeID
+	}
+	if input.LoanID != 0 {
+		item.LoanID = &input.LoanID
 	}
-	if err := dst.Close(); err != nil {
-		return "", fmt.Errorf("close: %w", err)
+	if input.InvestorID != 0 {
+		item.InvestorID = &input.InvestorID
 	}
+	err = s.fileRepo.Create(ctx, &item)
+	if err != nil {
+		return
+	}
+	if img != nil {
+		// the original keeps its EXIF for ImageMetadata, only the variants are meant to be shown
+		orientation := exif.Extract(bytes.NewReader(content)).Orientation
+		item.Variants, err = s.createVariants(ctx, item, img, orientation)
+		if err != nil {
+			return
+		}
+	}
+	result = item
+	return
+}
 
+// createVariants stores the resized copies of an image upload. The copies are re-encoded
+// from pixels, so none of the EXIF of the original (gps position, device) is carried over.
+func (s *fileService) createVariants(ctx context.Context, file entity.File, img image.Image, orientation int) (result []entity.FileVariant, err error) {
 	u, _ := url.Parse(os.Getenv("APP_HOST"))
-	fileURL, err := url.JoinPath(u.String(), entity.PublicUploadPath, filename)
+	base := strings.TrimSuffix(file.Name, path.Ext(file.Name))
+	for _, kind := range entity.FileVariantKinds {
+		resized := imaging.Orient(imaging.Fit(img, kind.MaxSide()), orientation)
+		var buf bytes.Buffer
+		if file.ContentType == "image/png" {
+			err = png.Encode(&buf, resized)
+		} else {
+			err = jpeg.Encode(&buf, resized, &jpeg.Options{Quality: 82})
+		}
+		if err != nil {
+			return result, fmt.Errorf("encode %s: %w", kind, err)
+		}
+
+		variant := entity.FileVariant{
+			FileID:      file.ID,
+			Kind:        kind,
+			Name:        base + "-" + strings.ToLower(string(kind)) + fileExtensions[file.ContentType],
+			ContentType: file.ContentType,
+			Width:       resized.Bounds().Dx(),
+			Height:      resized.Bounds().Dy(),
+			Size:        int64(buf.Len()),
+		}
+		err = s.storage.Put(ctx, variant.StorageKey(), &buf, variant.Size, variant.ContentType)
+		if err != nil {
+			return result, fmt.Errorf("write %s: %w", kind, err)
+		}
+		variant.URL, err = url.JoinPath(u.String(), entity.PublicVariantPath, variant.Name)
+		if err != nil {
+			return
+		}
+		err = s.fileVariantRepo.Create(ctx, &variant)
+		if err != nil {
+			return
+		}
+		result = append(result, variant)
+	}
+	return
+}
+
+// ResolveFile finds the stored file a public upload url points to,
+// urls that were not issued by UploadFile are rejected.
+func (s *fileService) ResolveFile(ctx context.Context, fileURL string) (result entity.File, err error) {
+	key, err := objectKey(fileURL)
+	if err != nil {
+		return
+	}
+	dir, filename := path.Split(key)
+	if strings.Trim(dir, "/") != entity.UploadStoragePrefix {
+		err = errors.New("file url is not an uploaded file")
+		return
+	}
+	result, err = s.fileRepo.File(ctx, entity.FileInput{
+		Name: &filename,
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = errors.New("file url is not an uploaded file")
+		return
+	}
+	if err != nil {
+		return
+	}
+	if _, err = s.storage.Stat(ctx, result.StorageKey()); err != nil {
+		err = fmt.Errorf("stat: %w", err)
+		return
+	}
+	return
+}
+
+// ImageMetadata checks by content that the file is an image and extracts its EXIF capture time and position.
+func (s *fileService) ImageMetadata(ctx context.Context, file entity.File) (result entity.ImageMetadata, err error) {
+	content, err := readObject(ctx, s.storage, file.StorageKey())
+	if err != nil {
+		return
+	}
+	result.ContentType = http.DetectContentType(content)
+	if result.ContentType != "image/jpeg" && result.ContentType != "image/png" {
+		err = errors.New("file is not a jpeg/png image")
+		return
+	}
+
+	metadata := exif.Extract(bytes.NewReader(content))
+	result.TakenAt = metadata.TakenAt
+	result.Latitude = metadata.Latitude
+	result.Longitude = metadata.Longitude
+	return
+}
+
+// PerceptualHash returns the stored hash of an image, files uploaded before hashing
+// was introduced are hashed on demand and the hash is stored.
+func (s *fileService) PerceptualHash(ctx context.Context, file entity.File) (result uint64, err error) {
+	if file.PerceptualHash != nil {
+		return phash.Parse(*file.PerceptualHash)
+	}
+	content, err := readObject(ctx, s.storage, file.StorageKey())
+	if err != nil {
+		return
+	}
+	result, err = hashImage(content)
+	if err != nil {
+		return
+	}
+	setPerceptualHash(&file, result)
+	err = s.fileRepo.Update(ctx, &file)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// HashPendingImages stores the hash of the images uploaded before hashing and the bands of hashes
+// stored before the bands. An image that can not be read or decoded keeps the error and is left
+// out of the duplicate check instead of being tried on every run.
+func (s *fileService) HashPendingImages(ctx context.Context) (hashed int, failed int, err error) {
+	pending := true
+	imageContentTypes := entity.FilePurposePhotoProof.ContentTypes()
+	files, err := s.fileRepo.Files(ctx, entity.FilesInput{
+		ContentTypes:          &imageContentTypes,
+		PerceptualHashPending: &pending,
+	})
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		var hash uint64
+		var hashErr error
+		if file.PerceptualHash != nil {
+			hash, hashErr = phash.Parse(*file.PerceptualHash)
+		} else {
+			var content []byte
+			content, hashErr = readObject(ctx, s.storage, file.StorageKey())
+			if hashErr == nil {
+				hash, hashErr = hashImage(content)
+			}
+		}
+		if hashErr != nil {
+			message := hashErr.Error()
+			file.PerceptualHash = nil
+			file.PerceptualHashError = &message
+			failed++
+		} else {
+			setPerceptualHash(&file, hash)
+			hashed++
+		}
+		err = s.fileRepo.Update(ctx, &file)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// RunHashBackfillJob is the handler of the PERCEPTUAL_HASH_BACKFILL job.
+func (s *fileService) RunHashBackfillJob(ctx context.Context, job entity.Job) (err error) {
+	hashed, failed, err := s.HashPendingImages(ctx)
+	if err != nil {
+		return
+	}
+	log.Printf("perceptual hash backfill: hashed=%d failed=%d", hashed, failed)
+	return
+}
+
+// setPerceptualHash stores the hash of an image together with the bands it is looked up by.
+func setPerceptualHash(file *entity.File, hash uint64) {
+	formatted := phash.Format(hash)
+	file.PerceptualHash = &formatted
+	bands := phash.Bands(hash)
+	values := make([]int, len(bands))
+	for i, band := range bands {
+		values[i] = int(band)
+	}
+	file.PerceptualHashBand0 = &values[0]
+	file.PerceptualHashBand1 = &values[1]
+	file.PerceptualHashBand2 = &values[2]
+	file.PerceptualHashBand3 = &values[3]
+	file.PerceptualHashError = nil
+}
+
+// PDFPageCount checks by content that the file is a complete pdf and counts its pages.
+func (s *fileService) PDFPageCount(ctx context.Context, file entity.File) (result int, err error) {
+	return pdfPageCount(ctx, s.storage, file.StorageKey())
+}
+
+// DownloadURL turns the url a file was stored under into a short-lived link
+// signed for one audience, the stored url itself is no longer served.
+func (s *fileService) DownloadURL(ctx context.Context, fileURL string, grant entity.DownloadGrant) (result string, err error) {
+	if !grant.Audience.IsValid() {
+		err = errors.New("download audience is invalid")
+		return
+	}
+	key, err := objectKey(fileURL)
+	if err != nil {
+		return
+	}
+	ttl := grant.TTL
+	if ttl == 0 {
+		ttl = s.downloadPolicy.TTL
+	}
+	input := entity.DownloadInput{
+		Key:       key,
+		Audience:  grant.Audience,
+		SubjectID: grant.SubjectID,
+		ExpiresAt: time.Now().Add(ttl).Unix(),
+	}
+	u, _ := url.Parse(os.Getenv("APP_HOST"))
+	downloadURL, err := url.JoinPath(u.String(), "downloads", key)
+	if err != nil {
+		return
+	}
+	query := url.Values{}
+	query.Set("aud", string(input.Audience))
+	query.Set("sub", strconv.Itoa(input.SubjectID))
+	query.Set("exp", strconv.FormatInt(input.ExpiresAt, 10))
+	query.Set("sig", s.downloadSignature(input))
+	result = downloadURL + "?" + query.Encode()
+	return
+}
+
+func (s *fileService) VerifyDownload(ctx context.Context, input entity.DownloadInput) (err error) {
+	expected := s.downloadSignature(input)
+	if !hmac.Equal([]byte(expected), []byte(input.Signature)) {
+		err = errors.New("download link is invalid")
+		return
+	}
+	if time.Now().Unix() > input.ExpiresAt {
+		err = errors.New("download link has expired")
+		return
+	}
+	if input.Caller.Audience != input.Audience || input.Caller.SubjectID != input.SubjectID {
+		err = entity.ErrAccessDenied
+		return
+	}
+	return
+}
+
+func (s *fileService) downloadSignature(input entity.DownloadInput) string {
+	mac := hmac.New(sha256.New, []byte(s.downloadPolicy.Secret))
+	fmt.Fprintf(mac, "%s\n%s\n%d\n%d", input.Key, input.Audience, input.SubjectID, input.ExpiresAt)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// objectKey maps a stored file url back to its storage key.
+func objectKey(fileURL string) (result string, err error) {
+	u, err := url.Parse(strings.TrimSpace(fileURL))
+	if err != nil {
+		err = errors.New("file url is invalid")
+		return
+	}
+	appHost, _ := url.Parse(os.Getenv("APP_HOST"))
+	if u.Host != appHost.Host {
+		err = errors.New("file url is not hosted by this service")
+		return
+	}
+	dir, filename := path.Split(u.Path)
+	if filename == "" {
+		err = errors.New("file url is invalid")
+		return
+	}
+	switch strings.Trim(dir, "/") {
+	case entity.PublicUploadPath:
+		result = path.Join(entity.UploadStoragePrefix, filename)
+	case entity.PublicAggrementLetterPath:
+		result = path.Join(entity.AgreementStoragePrefix, filename)
+	case entity.PublicVariantPath:
+		result = path.Join(entity.VariantStoragePrefix, filename)
+	default:
+		err = errors.New("file url is not a stored file")
+	}
+	return
+}
+
+// OpenObject streams a stored object, the caller closes the reader.
+func (s *fileService) OpenObject(ctx context.Context, key string) (result io.ReadCloser, object entity.StorageObject, err error) {
+	object, err = s.storage.Stat(ctx, key)
 	if err != nil {
 		return
 	}
-	return fileURL, nil
+	result, err = s.storage.Get(ctx, key)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (s *fileService) PresignObject(ctx context.Context, key string, expiry time.Duration) (result string, err error) {
+	result, err = s.storage.Presign(ctx, key, expiry)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// DeleteFile removes an upload with its variants from storage and the registry.
+// Objects that are already gone from storage are not an error.
+func (s *fileService) DeleteFile(ctx context.Context, file entity.File) (err error) {
+	variants, err := s.fileVariantRepo.FileVariants(ctx, entity.FileVariantsInput{
+		FileIDs: &[]int{file.ID},
+	})
+	if err != nil {
+		return
+	}
+	for _, variant := range variants {
+		err = s.storage.Delete(ctx, variant.StorageKey())
+		if err != nil && !errors.Is(err, entity.ErrStorageObjectNotFound) {
+			return fmt.Errorf("delete %s: %w", variant.StorageKey(), err)
+		}
+		err = s.fileVariantRepo.Delete(ctx, &variant)
+		if err != nil {
+			return
+		}
+	}
+	err = s.storage.Delete(ctx, file.StorageKey())
+	if err != nil && !errors.Is(err, entity.ErrStorageObjectNotFound) {
+		return fmt.Errorf("delete %s: %w", file.StorageKey(), err)
+	}
+	err = s.fileRepo.Delete(ctx, &file)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func readObject(ctx context.Context, store storage.Storage, key string) (result []byte, err error) {
+	src, err := store.Get(ctx, key)
+	if err != nil {
+		return result, fmt.Errorf("open: %w", err)
+	}
+	defer src.Close()
+	result, err = io.ReadAll(src)
+	if err != nil {
+		return result, fmt.Errorf("read: %w", err)
+	}
+	return
+}
+
+func pdfPageCount(ctx context.Context, store storage.Storage, key string) (result int, err error) {
+	content, err := readObject(ctx, store, key)
+	if err != nil {
+		return
+	}
+	result, err = pdfinfo.PageCount(bytes.NewReader(content))
+	if err != nil {
+		return
+	}
+	return
+}
+
+func hashImage(content []byte) (result uint64, err error) {
+	img, err := decodeImage(content)
+	if err != nil {
+		return
+	}
+	result = phash.Compute(img)
+	return
+}
+
+// maxImagePixels bounds the decoded size of an image, a small file can declare
+// dimensions that take gigabytes to decode.
+const maxImagePixels = 50_000_000
+
+// decodeImage reads the dimensions from the header first and only decodes images within maxImagePixels.
+func decodeImage(content []byte) (result image.Image, err error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return result, fmt.Errorf("decode image: %w", err)
+	}
+	if int64(config.Width)*int64(config.Height) > maxImagePixels {
+		err = fmt.Errorf("image is %dx%d pixels, larger than the allowed %d megapixels", config.Width, config.Height, maxImagePixels/1_000_000)
+		return
+	}
+	result, _, err = image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return result, fmt.Errorf("decode image: %w", err)
+	}
+	return
+}
+
+func (s *fileService) Files(ctx context.Context, filter entity.FilesInput) (result []entity.File, err error) {
+	result, err = s.fileRepo.Files(ctx, filter)
+	if err != nil {
+		return
+	}
+	err = s.loadVariants(ctx, result)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// ReviewFile records the outcome of the review of a KYC document, a rejected document
+// is deleted once the rejected period of its retention rule is over.
+func (s *fileService) ReviewFile(ctx context.Context, input entity.ReviewFileInput) (result entity.File, err error) {
+	if !input.Status.IsValid() {
+		err = errors.New("status is invalid")
+		return
+	}
+	if input.EmployeeID == 0 {
+		err = errors.New("employeeId is required")
+		return
+	}
+	result, err = s.File(ctx, entity.FileInput{
+		ID: &input.ID,
+	})
+	if err != nil {
+		return
+	}
+	if result.Purpose != entity.FilePurposeKYC {
+		err = errors.New("only KYC documents are reviewed")
+		return
+	}
+	reviewedAt := time.Now().UTC()
+	result.ReviewStatus = input.Status
+	result.ReviewedAt = &reviewedAt
+	result.ReviewedByEmployeeID = &input.EmployeeID
+	err = s.fileRepo.Update(ctx, &result)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (s *fileService) File(ctx context.Context, filter entity.FileInput) (result entity.File, err error) {
+	result, err = s.fileRepo.File(ctx, filter)
+	if err != nil {
+		return
+	}
+	files := []entity.File{result}
+	err = s.loadVariants(ctx, files)
+	if err != nil {
+		return
+	}
+	result = files[0]
+	return
+}
+
+func (s *fileService) loadVariants(ctx context.Context, files []entity.File) (err error) {
+	fileIDs := make([]int, 0, len(files))
+	for _, file := range files {
+		fileIDs = append(fileIDs, file.ID)
+	}
+	variants, err := s.fileVariantRepo.FileVariants(ctx, entity.FileVariantsInput{
+		FileIDs: &fileIDs,
+	})
+	if err != nil {
+		return
+	}
+	variantsByFile := map[int][]entity.FileVariant{}
+	for _, variant := range variants {
+		variantsByFile[variant.FileID] = append(variantsByFile[variant.FileID], variant)
+	}
+	for i := range files {
+		files[i].Variants = variantsByFile[files[i].ID]
+	}
+	return
 }
 
 type fileService struct {
+	fileRepo        db.FileRepository
+	fileVariantRepo db.FileVariantRepository
+	storage         storage.Storage
+	downloadPolicy  entity.DownloadPolicy
 }
 
 type InitiatorFile func(s *fileService) *fileService
@@ -75,6 +644,34 @@ func NewFileService() InitiatorFile {
 	}
 }
 
+func (i InitiatorFile) SetRepository(fileRepository db.FileRepository) InitiatorFile {
+	return func(s *fileService) *fileService {
+		i(s).fileRepo = fileRepository
+		return s
+	}
+}
+
+func (i InitiatorFile) SetFileVariantRepository(fileVariantRepository db.FileVariantRepository) InitiatorFile {
+	return func(s *fileService) *fileService {
+		i(s).fileVariantRepo = fileVariantRepository
+		return s
+	}
+}
+
+func (i InitiatorFile) SetStorage(storage storage.Storage) InitiatorFile {
+	return func(s *fileService) *fileService {
+		i(s).storage = storage
+		return s
+	}
+}
+
+func (i InitiatorFile) SetDownloadPolicy(downloadPolicy entity.DownloadPolicy) InitiatorFile {
+	return func(s *fileService) *fileService {
+		i(s).downloadPolicy = downloadPolicy
+		return s
+	}
+}
+
 func (i InitiatorFile) Build() FileService {
 	return i(&fileService{})
 }
@@ -0,0 +1,321 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/pkg/phash"
+	"github.com/adityaokke/test-amartha/internal/repository/db/sqlite"
+	"github.com/adityaokke/test-amartha/internal/repository/storage"
+)
+
+// downloadInput reads a signed download url back the way the download route does.
+func downloadInput(t *testing.T, downloadURL string, caller entity.Caller) entity.DownloadInput {
+	t.Helper()
+	u, err := url.Parse(downloadURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	subjectID, err := strconv.Atoi(query.Get("sub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiresAt, err := strconv.ParseInt(query.Get("exp"), 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entity.DownloadInput{
+		Key:       strings.TrimPrefix(u.Path, "/downloads/"),
+		Audience:  entity.DownloadAudience(query.Get("aud")),
+		SubjectID: subjectID,
+		ExpiresAt: expiresAt,
+		Signature: query.Get("sig"),
+		Caller:    caller,
+	}
+}
+
+func TestDownloadURL(t *testing.T) {
+	t.Setenv("APP_HOST", "http://localhost:3000")
+	s := &fileService{downloadPolicy: entity.DownloadPolicy{Secret: "secret", TTL: time.Hour}}
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		fileURL string
+		grant   entity.DownloadGrant
+		wantKey string
+		wantErr bool
+	}{
+		{
+			name:    "upload",
+			fileURL: "http://localhost:3000/public/uploads/a.png",
+			grant:   entity.DownloadGrant{Audience: entity.DownloadAudienceEmployee, SubjectID: 3},
+			wantKey: "uploads/a.png",
+		},
+		{
+			name:    "agreement",
+			fileURL: "http://localhost:3000/storage/agreements/b.pdf",
+			grant:   entity.DownloadGrant{Audience: entity.DownloadAudienceInvestor, SubjectID: 1},
+			wantKey: "agreements/b.pdf",
+		},
+		{
+			name:    "url of another host",
+			fileURL: "http://example.com/public/uploads/a.png",
+			grant:   entity.DownloadGrant{Audience: entity.DownloadAudienceEmployee, SubjectID: 3},
+			wantErr: true,
+		},
+		{
+			name:    "url outside the stored files",
+			fileURL: "http://localhost:3000/public/other/a.png",
+			grant:   entity.DownloadGrant{Audience: entity.DownloadAudienceEmployee, SubjectID: 3},
+			wantErr: true,
+		},
+		{
+			name:    "invalid audience",
+			fileURL: "http://localhost:3000/public/uploads/a.png",
+			grant:   entity.DownloadGrant{Audience: "ADMIN", SubjectID: 3},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.DownloadURL(ctx, tt.fileURL, tt.grant)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("DownloadURL() = %s, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DownloadURL() error = %v", err)
+			}
+			input := downloadInput(t, got, entity.Caller{Audience: tt.grant.Audience, SubjectID: tt.grant.SubjectID})
+			if input.Key != tt.wantKey {
+				t.Errorf("DownloadURL() key = %s, want %s", input.Key, tt.wantKey)
+			}
+			if err := s.VerifyDownload(ctx, input); err != nil {
+				t.Errorf("VerifyDownload() of a fresh link error = %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyDownload(t *testing.T) {
+	t.Setenv("APP_HOST", "http://localhost:3000")
+	s := &fileService{downloadPolicy: entity.DownloadPolicy{Secret: "secret", TTL: time.Hour}}
+	ctx := context.Background()
+	investor := entity.Caller{Audience: entity.DownloadAudienceInvestor, SubjectID: 1}
+	downloadURL, err := s.DownloadURL(ctx, "http://localhost:3000/storage/agreements/b.pdf", investor.Grant(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := downloadInput(t, downloadURL, investor)
+
+	// expired links are signed like any other, only the time has passed
+	expired := valid
+	expired.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+	expired.Signature = s.downloadSignature(expired)
+
+	tests := []struct {
+		name    string
+		input   func() entity.DownloadInput
+		wantErr string
+	}{
+		{
+			name:  "valid",
+			input: func() entity.DownloadInput { return valid },
+		},
+		{
+			name:    "expired",
+			input:   func() entity.DownloadInput { return expired },
+			wantErr: "download link has expired",
+		},
+		{
+			name: "expiry pushed back",
+			input: func() entity.DownloadInput {
+				input := valid
+				input.ExpiresAt += 3600
+				return input
+			},
+			wantErr: "download link is invalid",
+		},
+		{
+			name: "other object",
+			input: func() entity.DownloadInput {
+				input := valid
+				input.Key = "agreements/c.pdf"
+				return input
+			},
+			wantErr: "download link is invalid",
+		},
+		{
+			name: "other subject",
+			input: func() entity.DownloadInput {
+				input := valid
+				input.SubjectID = 2
+				input.Caller.SubjectID = 2
+				return input
+			},
+			wantErr: "download link is invalid",
+		},
+		{
+			name: "other audience",
+			input: func() entity.DownloadInput {
+				input := valid
+				input.Audience = entity.DownloadAudienceEmployee
+				input.Caller.Audience = entity.DownloadAudienceEmployee
+				return input
+			},
+			wantErr: "download link is invalid",
+		},
+		{
+			name: "tampered signature",
+			input: func() entity.DownloadInput {
+				input := valid
+				input.Signature = strings.ToUpper(input.Signature)
+				return input
+			},
+			wantErr: "download link is invalid",
+		},
+		{
+			name: "link of another investor",
+			input: func() entity.DownloadInput {
+				input := valid
+				input.Caller = entity.Caller{Audience: entity.DownloadAudienceInvestor, SubjectID: 2}
+				return input
+			},
+			wantErr: entity.ErrAccessDenied.Error(),
+		},
+		{
+			name: "link of an investor opened by an employee",
+			input: func() entity.DownloadInput {
+				input := valid
+				input.Caller = entity.Caller{Audience: entity.DownloadAudienceEmployee, SubjectID: 1}
+				return input
+			},
+			wantErr: entity.ErrAccessDenied.Error(),
+		},
+		{
+			name: "anonymous caller",
+			input: func() entity.DownloadInput {
+				input := valid
+				input.Caller = entity.Caller{}
+				return input
+			},
+			wantErr: entity.ErrAccessDenied.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.VerifyDownload(ctx, tt.input())
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("VerifyDownload() error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("VerifyDownload() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("link signed with another secret", func(t *testing.T) {
+		other := &fileService{downloadPolicy: entity.DownloadPolicy{Secret: "other", TTL: time.Hour}}
+		err := other.VerifyDownload(ctx, valid)
+		if err == nil || errors.Is(err, entity.ErrAccessDenied) {
+			t.Fatalf("VerifyDownload() error = %v, want an invalid link", err)
+		}
+	})
+}
+
+func TestHashPendingImages(t *testing.T) {
+	conn := newTestDB(t)
+	fileRepo := sqlite.NewFileRepository().SetDBConnection(conn).Build()
+	store := storage.NewLocalStorage().SetRoot(t.TempDir()).Build()
+	s := NewFileService().
+		SetRepository(fileRepo).
+		SetStorage(store).
+		Build()
+	ctx := context.Background()
+
+	img := image.NewGray(image.Rect(0, 0, 32, 32))
+	for x := 0; x < 32; x++ {
+		for y := 0; y < 32; y++ {
+			img.SetGray(x, y, color.Gray{Y: uint8(x * y % 256)})
+		}
+	}
+	var content bytes.Buffer
+	if err := png.Encode(&content, img); err != nil {
+		t.Fatal(err)
+	}
+	stored := entity.File{Name: "stored.png", ContentType: "image/png"}
+	if err := store.Put(ctx, stored.StorageKey(), bytes.NewReader(content.Bytes()), int64(content.Len()), "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	// hashed before the hash bands were stored
+	oldHash := phash.Format(0x0123456789abcdef)
+	files := map[string]*entity.File{
+		"stored":       &stored,
+		"missing":      {Name: "missing.png", ContentType: "image/png"},
+		"hashed":       {Name: "hashed.jpg", ContentType: "image/jpeg", PerceptualHash: &oldHash},
+		"not an image": {Name: "letter.pdf", ContentType: "application/pdf"},
+	}
+	for _, file := range files {
+		if err := fileRepo.Create(ctx, file); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hashed, failed, err := s.HashPendingImages(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hashed != 2 || failed != 1 {
+		t.Errorf("HashPendingImages() hashed %d and failed %d, want 2 and 1", hashed, failed)
+	}
+	for name, file := range files {
+		got, err := fileRepo.File(ctx, entity.FileInput{ID: &file.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch name {
+		case "stored":
+			if got.PerceptualHash == nil || *got.PerceptualHash != phash.Format(phash.Compute(img)) || got.PerceptualHashBand0 == nil {
+				t.Errorf("%s: hash %v band %v, want the hash of the image and its bands", name, got.PerceptualHash, got.PerceptualHashBand0)
+			}
+		case "missing":
+			if got.PerceptualHash != nil || got.PerceptualHashError == nil {
+				t.Errorf("%s: hash %v error %v, want the error kept", name, got.PerceptualHash, got.PerceptualHashError)
+			}
+		case "hashed":
+			if got.PerceptualHashBand3 == nil || *got.PerceptualHashBand3 != 0x0123 {
+				t.Errorf("%s: band 3 = %v, want 0x0123", name, got.PerceptualHashBand3)
+			}
+		case "not an image":
+			if got.PerceptualHash != nil || got.PerceptualHashError != nil {
+				t.Errorf("%s: hashed a pdf", name)
+			}
+		}
+	}
+
+	// a failed image is not tried again on every run
+	hashed, failed, err = s.HashPendingImages(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hashed != 0 || failed != 0 {
+		t.Errorf("second HashPendingImages() hashed %d and failed %d, want nothing left", hashed, failed)
+	}
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/adityaokke/test-amartha/internal/entity"
 	"github.com/adityaokke/test-amartha/internal/repository/db"
@@ -21,8 +22,21 @@ func (s *investorService) AddInvestor(ctx context.Context, input entity.AddInves
 		err = errors.New("email is required")
 		return
 	}
+	if input.DateOfBirth != nil {
+		dateOfBirth, parseErr := time.Parse(time.DateOnly, *input.DateOfBirth)
+		if parseErr != nil || dateOfBirth.After(time.Now()) {
+			err = errors.New("dateOfBirth must be a past date formatted as YYYY-MM-DD")
+			return
+		}
+	}
+	if input.NIK != nil && !isNIK(*input.NIK) {
+		err = errors.New("nik must be 16 digits")
+		return
+	}
 	item := entity.Investor{
-		Email: input.Email,
+		Email:       input.Email,
+		DateOfBirth: input.DateOfBirth,
+		NIK:         input.NIK,
 	}
 	err = s.investorRepo.Create(ctx, &item)
 	if err != nil {
@@ -78,3 +92,16 @@ func (i InitiatorInvestor) SetRepository(investorRepository db.InvestorRepositor
 func (i InitiatorInvestor) Build() InvestorService {
 	return i(&investorService{})
 }
+
+// isNIK reports whether value looks like an Indonesian national identity number.
+func isNIK(value string) bool {
+	if len(value) != 16 {
+		return false
+	}
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
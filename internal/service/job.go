@@ -0,0 +1,337 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/repository/db"
+)
+
+// JobHandler runs one job, a returned error (or panic) schedules a retry.
+type JobHandler func(ctx context.Context, job entity.Job) (err error)
+
+type JobService interface {
+	Enqueue(ctx context.Context, input entity.EnqueueJobInput) (result entity.Job, err error)
+	Start(ctx context.Context)
+
+	Jobs(ctx context.Context, filter entity.JobsInput) (result []entity.Job, err error)
+	Job(ctx context.Context, id int) (result entity.Job, err error)
+	RetryJob(ctx context.Context, id int) (result entity.Job, err error)
+	CancelJob(ctx context.Context, id int) (result entity.Job, err error)
+}
+
+// Enqueue stores a job for the worker pool. A job enqueued again under an existing key returns
+// the job already stored. Recurring jobs are keyed by the slot they run in, so each slot runs once
+// however often the schedule is enqueued.
+func (s *jobService) Enqueue(ctx context.Context, input entity.EnqueueJobInput) (result entity.Job, err error) {
+	if input.Type == "" {
+		err = errors.New("type is required")
+		return
+	}
+	if _, ok := s.handlers[input.Type]; !ok {
+		err = fmt.Errorf("no handler for job type %s", input.Type)
+		return
+	}
+	payload, err := json.Marshal(input.Payload)
+	if err != nil {
+		return
+	}
+
+	runAt := input.RunAt.UTC()
+	if input.RepeatEvery > 0 {
+		if runAt.IsZero() {
+			runAt = time.Now().UTC().Truncate(input.RepeatEvery).Add(input.RepeatEvery)
+		}
+		if input.Key == "" {
+			input.Key = fmt.Sprintf("%s@%d", input.Type, runAt.Unix())
+		}
+		err = s.replaceSchedule(ctx, input.Type, input.RepeatEvery)
+		if err != nil {
+			return
+		}
+	}
+	if runAt.IsZero() {
+		runAt = time.Now().UTC()
+	}
+	maxAttempts := input.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = s.policy.MaxAttempts
+	}
+	item := entity.Job{
+		Type:        input.Type,
+		Payload:     payload,
+		Status:      entity.JobStatusQueued,
+		MaxAttempts: maxAttempts,
+		ScheduledAt: runAt,
+		RunAt:       runAt,
+		RepeatEvery: int(input.RepeatEvery / time.Second),
+	}
+	if input.Key != "" {
+		item.Key = &input.Key
+	}
+	err = s.jobRepo.Create(ctx, &item)
+	if err != nil {
+		return
+	}
+	if item.ID == 0 {
+		result, err = s.jobRepo.Job(ctx, entity.JobInput{
+			Key: item.Key,
+		})
+		return
+	}
+	result = item
+
+	// let an idle worker pick the job up without waiting for the next poll
+	wakeUp(s.wake)
+	return
+}
+
+// replaceSchedule cancels the queued runs of a job type that recur at another interval,
+// so changing the interval of a schedule does not leave the old schedule running.
+func (s *jobService) replaceSchedule(ctx context.Context, jobType entity.JobType, every time.Duration) (err error) {
+	status := entity.JobStatusQueued
+	jobs, err := s.jobRepo.Jobs(ctx, entity.JobsInput{
+		Status: &status,
+		Type:   &jobType,
+	})
+	if err != nil {
+		return
+	}
+	now := time.Now().UTC()
+	for _, job := range jobs {
+		if job.RepeatEvery == 0 || job.RepeatEvery == int(every/time.Second) {
+			continue
+		}
+		job.Status = entity.JobStatusCancelled
+		job.FinishedAt = &now
+		err = s.jobRepo.Update(ctx, &job)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Start runs the worker pool until ctx is done.
+func (s *jobService) Start(ctx context.Context) {
+	hostname, _ := os.Hostname()
+	for i := 1; i <= s.policy.Workers; i++ {
+		go s.work(ctx, fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), i))
+	}
+}
+
+func (s *jobService) work(ctx context.Context, workerID string) {
+	for {
+		job, claimed, err := s.jobRepo.Claim(ctx, workerID, time.Now().UTC(), s.policy.Lease)
+		if err != nil {
+			log.Printf("jobs: %s: claim: %v", workerID, err)
+		}
+		if claimed {
+			s.run(ctx, job)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-time.After(s.policy.PollInterval):
+		}
+	}
+}
+
+func (s *jobService) run(ctx context.Context, job entity.Job) {
+	lockToken := *job.LockToken
+	runCtx, stop := context.WithCancel(ctx)
+	go s.keepLease(runCtx, job)
+	runErr := s.process(runCtx, job)
+	stop()
+
+	now := time.Now().UTC()
+	job.LockToken = nil
+	job.LockedBy = nil
+	job.LeaseExpiresAt = nil
+	if runErr == nil {
+		job.Status = entity.JobStatusSucceeded
+		job.FinishedAt = &now
+	} else {
+		lastError := runErr.Error()
+		job.LastError = &lastError
+		if job.Attempts >= job.MaxAttempts {
+			job.Status = entity.JobStatusDead
+			job.FinishedAt = &now
+		} else {
+			job.Status = entity.JobStatusQueued
+			job.RunAt = now.Add(retryBackoff(job.Attempts, s.policy.Backoff, s.policy.MaxBackoff))
+		}
+	}
+	finished, err := s.jobRepo.Finish(ctx, &job, lockToken)
+	if err != nil {
+		log.Printf("jobs: %s %d: %v", job.Type, job.ID, err)
+		return
+	}
+	if !finished {
+		log.Printf("jobs: %s %d: lease was lost before the job finished", job.Type, job.ID)
+		return
+	}
+	if job.RepeatEvery > 0 && job.Status != entity.JobStatusQueued {
+		every := time.Duration(job.RepeatEvery) * time.Second
+		next := job.ScheduledAt.Add(every)
+		for !next.After(now) {
+			next = next.Add(every)
+		}
+		_, err = s.Enqueue(ctx, entity.EnqueueJobInput{
+			Type:        job.Type,
+			Payload:     job.Payload,
+			RunAt:       next,
+			MaxAttempts: job.MaxAttempts,
+			RepeatEvery: every,
+		})
+		if err != nil {
+			log.Printf("jobs: %s %d: schedule next run: %v", job.Type, job.ID, err)
+		}
+	}
+}
+
+// keepLease extends the lease of a running job until its handler returns.
+func (s *jobService) keepLease(ctx context.Context, job entity.Job) {
+	ticker := time.NewTicker(s.policy.Lease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		err := s.jobRepo.ExtendLease(ctx, &job, time.Now().UTC().Add(s.policy.Lease))
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("jobs: %s %d: extend lease: %v", job.Type, job.ID, err)
+		}
+	}
+}
+
+func (s *jobService) process(ctx context.Context, job entity.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	handler, ok := s.handlers[job.Type]
+	if !ok {
+		return fmt.Errorf("no handler for job type %s", job.Type)
+	}
+	return handler(ctx, job)
+}
+
+func (s *jobService) Jobs(ctx context.Context, filter entity.JobsInput) (result []entity.Job, err error) {
+	result, err = s.jobRepo.Jobs(ctx, filter)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (s *jobService) Job(ctx context.Context, id int) (result entity.Job, err error) {
+	result, err = s.jobRepo.Job(ctx, entity.JobInput{
+		ID: &id,
+	})
+	if err != nil {
+		return
+	}
+	return
+}
+
+// RetryJob runs a dead or cancelled job again with a fresh set of attempts,
+// a queued job waiting for its retry delay is run right away.
+func (s *jobService) RetryJob(ctx context.Context, id int) (result entity.Job, err error) {
+	result, err = s.Job(ctx, id)
+	if err != nil {
+		return
+	}
+	switch result.Status {
+	case entity.JobStatusDead, entity.JobStatusCancelled, entity.JobStatusQueued:
+	default:
+		err = fmt.Errorf("%s job can not be retried", result.Status)
+		return
+	}
+	if result.Status != entity.JobStatusQueued {
+		result.Attempts = 0
+	}
+	result.Status = entity.JobStatusQueued
+	result.RunAt = time.Now().UTC()
+	result.FinishedAt = nil
+	err = s.jobRepo.Update(ctx, &result)
+	if err != nil {
+		return
+	}
+	wakeUp(s.wake)
+	return
+}
+
+// CancelJob stops a queued job from running, a cancelled recurring job ends its schedule.
+func (s *jobService) CancelJob(ctx context.Context, id int) (result entity.Job, err error) {
+	result, err = s.Job(ctx, id)
+	if err != nil {
+		return
+	}
+	if result.Status != entity.JobStatusQueued {
+		err = fmt.Errorf("%s job can not be cancelled", result.Status)
+		return
+	}
+	now := time.Now().UTC()
+	result.Status = entity.JobStatusCancelled
+	result.FinishedAt = &now
+	err = s.jobRepo.Update(ctx, &result)
+	if err != nil {
+		return
+	}
+	return
+}
+
+type jobService struct {
+	jobRepo  db.JobRepository
+	handlers map[entity.JobType]JobHandler
+	policy   entity.JobQueuePolicy
+	wake     chan struct{}
+}
+
+type InitiatorJob func(s *jobService) *jobService
+
+func NewJobService() InitiatorJob {
+	return func(s *jobService) *jobService {
+		return s
+	}
+}
+
+func (i InitiatorJob) SetRepository(jobRepository db.JobRepository) InitiatorJob {
+	return func(s *jobService) *jobService {
+		i(s).jobRepo = jobRepository
+		return s
+	}
+}
+
+func (i InitiatorJob) SetHandler(jobType entity.JobType, handler JobHandler) InitiatorJob {
+	return func(s *jobService) *jobService {
+		i(s).handlers[jobType] = handler
+		return s
+	}
+}
+
+func (i InitiatorJob) SetPolicy(policy entity.JobQueuePolicy) InitiatorJob {
+	return func(s *jobService) *jobService {
+		i(s).policy = policy
+		return s
+	}
+}
+
+func (i InitiatorJob) Build() JobService {
+	return i(&jobService{
+		handlers: map[entity.JobType]JobHandler{},
+		wake:     make(chan struct{}, 1),
+	})
+}
@@ -3,17 +3,20 @@ package service
 import (
 	"context"
 	"errors"
-	"net/url"
-	"os"
-	"path/filepath"
+	"fmt"
+	"log"
+	"math"
+	"path"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/pkg/phash"
 	"github.com/adityaokke/test-amartha/internal/repository/db"
-	"github.com/adityaokke/test-amartha/internal/repository/mail"
-	"github.com/adityaokke/test-amartha/internal/repository/pdf"
+	"github.com/adityaokke/test-amartha/internal/repository/document"
+	"github.com/adityaokke/test-amartha/internal/repository/scoring"
+	"github.com/adityaokke/test-amartha/internal/repository/storage"
 	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
@@ -23,13 +26,29 @@ type LoanService interface {
 	ApproveLoan(ctx context.Context, input entity.ApproveLoanInput) (result entity.Loan, err error)
 	InvestLoan(ctx context.Context, input entity.InvestLoanInput) (result entity.LoanInvestment, err error)
 	DisburseLoan(ctx context.Context, input entity.DisburseLoanInput) (result entity.Loan, err error)
+	ExpireLoans(ctx context.Context) (result []entity.Loan, err error)
 
 	Loans(ctx context.Context, filter entity.LoansInput) (result []entity.Loan, err error)
 	CountLoans(ctx context.Context, filter entity.LoansInput) (result int64, err error)
 	Loan(ctx context.Context, filter entity.LoanInput) (result entity.Loan, err error)
-	GetDraftLoanAgreementLetter(ctx context.Context, loanID int) (result string, err error)
-	GetSignedLoanAgreementLetter(ctx context.Context, loanID int) (result string, err error)
-	GetLoanQuote(ctx context.Context, loanID int) (result entity.LoanQuote, err error)
+	GetDraftLoanAgreementLetter(ctx context.Context, caller entity.Caller, loanID int, options entity.AgreementLetterOptions) (result string, err error)
+	GetSignedLoanAgreementLetter(ctx context.Context, caller entity.Caller, loanID int, options entity.AgreementLetterOptions) (result string, err error)
+	GetInvestorLoanAgreementLetter(ctx context.Context, caller entity.Caller, loanID int, investorID int, options entity.AgreementLetterOptions) (result string, err error)
+	GetLoanQuote(ctx context.Context, caller entity.Caller, loanID int) (result entity.LoanQuote, err error)
+	PhotoProofDuplicates(ctx context.Context, filter entity.PhotoProofDuplicatesInput) (result []entity.PhotoProofDuplicate, err error)
+
+	AgreementSigningRequest(ctx context.Context, token string) (result entity.AgreementSigningRequest, err error)
+	SignAgreement(ctx context.Context, input entity.SignAgreementInput) (result entity.AgreementSignature, err error)
+	RenewSigningLink(ctx context.Context, token string) (err error)
+	AgreementSignatures(ctx context.Context, loanID int) (result []entity.AgreementSignature, err error)
+
+	RegenerateLoanAgreement(ctx context.Context, input entity.RegenerateAgreementInput) (result entity.Loan, err error)
+	AgreementVersions(ctx context.Context, filter entity.AgreementDocumentsInput) (result []entity.AgreementDocument, err error)
+	VerifyAgreement(ctx context.Context, input entity.VerifyAgreementInput) (result entity.AgreementVerification, err error)
+
+	ProcessOutboxEvent(ctx context.Context, event entity.OutboxEvent) (err error)
+	HandleLoanFullyFunded(ctx context.Context, event entity.DomainEvent) (err error)
+	RunExpiryJob(ctx context.Context, job entity.Job) (err error)
 }
 
 func (s *loanService) ProposeLoan(ctx context.Context, input entity.ProposeLoanInput) (result entity.Loan, err error) {
@@ -37,14 +56,32 @@ func (s *loanService) ProposeLoan(ctx context.Context, input entity.ProposeLoanI
 		err = errors.New("userId is required")
 		return
 	}
+	if input.ProductID == 0 {
+		err = errors.New("productId is required")
+		return
+	}
 	if input.Amount == 0 {
 		err = errors.New("amount is required")
 		return
 	}
-	if input.Rate == 0 {
-		err = errors.New("rate is required")
+
+	product, err := s.loanProductRepo.LoanProduct(ctx, entity.LoanProductInput{
+		ID: &input.ProductID,
+	})
+	if err != nil {
 		return
 	}
+	if !product.IsActive {
+		err = errors.New("loan product is not active")
+		return
+	}
+	// default term and term unit when the product only offers one
+	if input.TermUnit == "" && len(product.TermUnits) == 1 {
+		input.TermUnit = product.TermUnits[0]
+	}
+	if input.Term == 0 && len(product.AllowedTerms) == 1 {
+		input.Term = product.AllowedTerms[0]
+	}
 	if input.Term == 0 {
 		err = errors.New("term is required")
 		return
@@ -53,17 +90,84 @@ func (s *loanService) ProposeLoan(ctx context.Context, input entity.ProposeLoanI
 		err = errors.New("termUnit is invalid")
 		return
 	}
+
+	borrower, err := s.borrowerRepo.Borrower(ctx, entity.BorrowerInput{
+		ID: &input.UserID,
+	})
+	if err != nil {
+		return
+	}
+	previousLoans, err := s.loanRepo.Loans(ctx, entity.LoansInput{
+		UserID: &input.UserID,
+	})
+	if err != nil {
+		return
+	}
+	eligibility, err := s.eligibilityEngine.Check(ctx, entity.EligibilityInput{
+		UserID:        input.UserID,
+		Product:       product,
+		Amount:        input.Amount,
+		Term:          input.Term,
+		TermUnit:      input.TermUnit,
+		PreviousLoans: previousLoans,
+	})
+	if err != nil {
+		return
+	}
+	if !eligibility.Eligible {
+		err = entity.EligibilityError{
+			Reasons: eligibility.Reasons,
+		}
+		return
+	}
+	creditScore, err := s.creditScorer.Score(ctx, entity.CreditScoreInput{
+		Borrower:      borrower,
+		PreviousLoans: previousLoans,
+	})
+	if err != nil {
+		return
+	}
+	rateBand, err := s.creditScorer.RateBand(creditScore.Grade)
+	if err != nil {
+		return
+	}
+	// the rate has to fit both the product range and the risk grade band,
+	// when not given the lowest rate allowed is used
+	minRate := max(product.MinRate, rateBand.MinRate)
+	maxRate := min(product.MaxRate, rateBand.MaxRate)
+	if minRate > maxRate {
+		err = fmt.Errorf("loan product %s is not available for risk grade %s", product.Name, creditScore.Grade)
+		return
+	}
+	if input.Rate == 0 {
+		input.Rate = minRate
+	}
+	if input.Rate < minRate || input.Rate > maxRate {
+		err = fmt.Errorf("rate must be between %.2f and %.2f for product %s and risk grade %s", minRate, maxRate, product.Name, creditScore.Grade)
+		return
+	}
+
 	item := entity.Loan{
-		UserID: input.UserID,
-		Amount: input.Amount,
-		Status: entity.LoanStatusProposed,
-		Rate:   input.Rate,
-		Term:   input.Term,
+		UserID:         input.UserID,
+		Amount:         input.Amount,
+		Status:         entity.LoanStatusProposed,
+		Rate:           input.Rate,
+		Term:           input.Term,
+		ProductID:      product.ID,
+		TermUnit:       input.TermUnit,
+		InterestMethod: product.InterestMethod,
+		CreditScore:    creditScore.Score,
+		RiskGrade:      creditScore.Grade,
 	}
 	err = s.loanRepo.Create(ctx, &item)
 	if err != nil {
 		return
 	}
+	s.publish(ctx, entity.DomainEvent{
+		Type: entity.DomainEventTypeLoanProposed,
+		Key:  fmt.Sprintf("%s:%d", entity.DomainEventTypeLoanProposed, item.ID),
+		Loan: item,
+	})
 	result = item
 	return
 }
@@ -93,16 +197,94 @@ func (s *loanService) ApproveLoan(ctx context.Context, input entity.ApproveLoanI
 		return
 	}
 
+	photoProof, err := s.fileService.ResolveFile(ctx, input.PhotoProofURL)
+	if err != nil {
+		err = fmt.Errorf("photoProofUrl is invalid: %w", err)
+		return
+	}
+	if photoProof.Purpose != "" && photoProof.Purpose != entity.FilePurposePhotoProof {
+		err = errors.New("photoProofUrl is not a photo proof upload")
+		return
+	}
+	// a proof without an uploader can not be tied to the field validator, it has to be uploaded again
+	if photoProof.UploadedByEmployeeID == nil || *photoProof.UploadedByEmployeeID != input.EmployeeID {
+		err = errors.New("photo proof was uploaded by another employee")
+		return
+	}
+	photoMetadata, err := s.fileService.ImageMetadata(ctx, photoProof)
+	if err != nil {
+		err = fmt.Errorf("photoProofUrl is invalid: %w", err)
+		return
+	}
+	approvedAt := time.Now().UTC()
+	borrower, err := s.borrowerRepo.Borrower(ctx, entity.BorrowerInput{
+		ID: &currentItem.UserID,
+	})
+	if err != nil {
+		return
+	}
+	distanceMeters, err := s.checkPhotoProof(photoMetadata, borrower, approvedAt)
+	if err != nil {
+		return
+	}
+	if s.photoProofPolicy.DuplicateAction != "" {
+		var duplicates []entity.PhotoProofDuplicate
+		duplicates, err = s.findPhotoProofDuplicates(ctx, currentItem.ID, photoProof)
+		if err != nil {
+			return
+		}
+		duplicateOfLoanIDs := []int{}
+		for _, duplicate := range duplicates {
+			duplicate.EmployeeID = input.EmployeeID
+			duplicate.Action = s.photoProofPolicy.DuplicateAction
+			err = s.photoProofDuplicateRepo.Create(ctx, &duplicate)
+			if err != nil {
+				return
+			}
+			duplicateOfLoanIDs = append(duplicateOfLoanIDs, duplicate.DuplicateOfLoanID)
+		}
+		if len(duplicates) > 0 && s.photoProofPolicy.DuplicateAction == entity.PhotoProofDuplicateActionReject {
+			err = fmt.Errorf("photo proof is a near-duplicate of the photo proof of loan %v", duplicateOfLoanIDs)
+			return
+		}
+		currentItem.PhotoProofDuplicateSuspected = len(duplicates) > 0
+	}
+
 	currentItem.ApprovedByEmployeeID = &input.EmployeeID
 	currentItem.Status = entity.LoanStatusApproved
 	trimmedURL := strings.TrimSpace(input.PhotoProofURL)
 	currentItem.PhotoProofURL = &trimmedURL
-	approvedAt := time.Now().UTC()
+	currentItem.PhotoProofFileID = &photoProof.ID
+	currentItem.PhotoProofTakenAt = photoMetadata.TakenAt
+	currentItem.PhotoProofLatitude = photoMetadata.Latitude
+	currentItem.PhotoProofLongitude = photoMetadata.Longitude
+	currentItem.PhotoProofDistanceMeters = distanceMeters
 	currentItem.ApprovedAt = &approvedAt
+
+	// loans proposed before the product catalog have no product to price them
+	if currentItem.ProductID != 0 {
+		var product entity.LoanProduct
+		product, err = s.loanProductRepo.LoanProduct(ctx, entity.LoanProductInput{
+			ID: &currentItem.ProductID,
+		})
+		if err != nil {
+			return
+		}
+		currentItem.Fees, currentItem.FeeAmount = calculateLoanFees(product.Fees, currentItem.Amount)
+		if product.FundingWindowDays > 0 {
+			fundingDeadline := approvedAt.AddDate(0, 0, product.FundingWindowDays)
+			currentItem.FundingDeadline = &fundingDeadline
+		}
+	}
 	err = s.loanRepo.Update(ctx, &currentItem)
 	if err != nil {
 		return
 	}
+	s.publish(ctx, entity.DomainEvent{
+		Type: entity.DomainEventTypeLoanApproved,
+		Key:  fmt.Sprintf("%s:%d", entity.DomainEventTypeLoanApproved, currentItem.ID),
+		Loan: currentItem,
+	})
 	result = currentItem
 	return
 }
@@ -139,6 +321,10 @@ func (s *loanService) InvestLoan(ctx context.Context, input entity.InvestLoanInp
 		err = errors.New("only approved loan can be invested")
 		return
 	}
+	if loan.FundingDeadline != nil && time.Now().UTC().After(*loan.FundingDeadline) {
+		err = errors.New("funding window of this loan has closed")
+		return
+	}
 	if loan.InvestedAmount >= loan.Amount {
 		err = errors.New("loan is already fully funded")
 		return
@@ -165,54 +351,241 @@ func (s *loanService) InvestLoan(ctx context.Context, input entity.InvestLoanInp
 		InvestorID: input.InvestorID,
 		Amount:     input.Amount,
 	}
-	err = s.loanInvestmentRepo.InvestLoan(ctx, &item)
+	// the agreement and investor mails of a fully funded loan are left to the outbox dispatcher
+	_, err = s.loanInvestmentRepo.InvestLoan(ctx, &item, &entity.OutboxEvent{
+		Type:   entity.OutboxEventTypeLoanFunded,
+		LoanID: loan.ID,
+		Key:    fmt.Sprintf("%s:%d", entity.OutboxEventTypeLoanFunded, loan.ID),
+		Payload: entity.OutboxPayload{
+			LoanID: loan.ID,
+		},
+	})
 	if err != nil {
 		return
 	}
+	result = item
 
-	loan, err = s.loanRepo.Loan(ctx, entity.LoanInput{
-		ID: &input.LoanID,
+	// reload so the event carries the invested amount including this investment
+	investedLoan, loanErr := s.loanRepo.Loan(ctx, entity.LoanInput{
+		ID: &loan.ID,
+	})
+	if loanErr != nil {
+		log.Printf("events: %s %d: %v", entity.DomainEventTypeInvestmentPlaced, item.ID, loanErr)
+		return
+	}
+	s.publish(ctx, entity.DomainEvent{
+		Type:       entity.DomainEventTypeInvestmentPlaced,
+		Key:        fmt.Sprintf("%s:%d", entity.DomainEventTypeInvestmentPlaced, item.ID),
+		Loan:       investedLoan,
+		Investment: &item,
 	})
+	return
+}
+
+// publish tells the subscribers about a change that is already committed, a failing subscriber
+// is logged by the bus instead of failing the request that made the change.
+func (s *loanService) publish(ctx context.Context, event entity.DomainEvent) {
+	_ = s.eventBus.Publish(ctx, event)
+}
+
+// checkPhotoProof enforces the photo proof policy, it returns the distance between
+// the photo and the borrower registered location when both are known.
+func (s *loanService) checkPhotoProof(metadata entity.ImageMetadata, borrower entity.Borrower, now time.Time) (distanceMeters *float64, err error) {
+	policy := s.photoProofPolicy
+	if policy.MaxAge > 0 {
+		if metadata.TakenAt == nil {
+			err = errors.New("photo proof has no capture time in its EXIF data")
+			return
+		}
+		if now.Sub(*metadata.TakenAt) > policy.MaxAge {
+			err = fmt.Errorf("photo proof was taken at %s, older than the allowed %s", metadata.TakenAt.Format(time.RFC3339), policy.MaxAge)
+			return
+		}
+	}
+
+	if borrower.Latitude == nil || borrower.Longitude == nil {
+		return
+	}
+	if metadata.Latitude == nil || metadata.Longitude == nil {
+		if policy.MaxDistanceMeters > 0 {
+			err = errors.New("photo proof has no gps position in its EXIF data")
+		}
+		return
+	}
+	distance := haversineMeters(*metadata.Latitude, *metadata.Longitude, *borrower.Latitude, *borrower.Longitude)
+	if policy.MaxDistanceMeters > 0 && distance > policy.MaxDistanceMeters {
+		err = fmt.Errorf("photo proof was taken %.0fm from the borrower location, more than the allowed %.0fm", distance, policy.MaxDistanceMeters)
+		return
+	}
+	distanceMeters = &distance
+	return
+}
+
+// findPhotoProofDuplicates compares the photo proof against the stored perceptual hashes
+// of earlier images and returns the loans whose photo proof is within the configured
+// hamming distance. Only the images sharing a close enough hash band are read from the index,
+// the distance is checked on those.
+func (s *loanService) findPhotoProofDuplicates(ctx context.Context, loanID int, photoProof entity.File) (result []entity.PhotoProofDuplicate, err error) {
+	hash, err := s.fileService.PerceptualHash(ctx, photoProof)
+	if err != nil {
+		return
+	}
+
+	radius := s.photoProofPolicy.DuplicateMaxDistance / phash.BandCount
+	bands := [][]int{}
+	for _, band := range phash.Bands(hash) {
+		values := []int{}
+		for _, value := range phash.BandNeighbours(band, radius) {
+			values = append(values, int(value))
+		}
+		bands = append(bands, values)
+	}
+	files, err := s.fileService.Files(ctx, entity.FilesInput{
+		PerceptualHashBands: &bands,
+	})
+	if err != nil {
+		return
+	}
+	fileIDs := []int{}
+	distances := make(map[int]int)
+	for _, file := range files {
+		if file.ID == photoProof.ID {
+			continue
+		}
+		otherHash, parseErr := phash.Parse(*file.PerceptualHash)
+		if parseErr != nil {
+			continue
+		}
+		distance := phash.Distance(hash, otherHash)
+		if distance > s.photoProofPolicy.DuplicateMaxDistance {
+			continue
+		}
+		fileIDs = append(fileIDs, file.ID)
+		distances[file.ID] = distance
+	}
+	loans, err := s.loanRepo.Loans(ctx, entity.LoansInput{
+		PhotoProofFileIDs: &fileIDs,
+	})
+	if err != nil {
+		return
+	}
+
+	result = []entity.PhotoProofDuplicate{}
+	for _, loan := range loans {
+		if loan.ID == loanID {
+			continue
+		}
+		result = append(result, entity.PhotoProofDuplicate{
+			LoanID:            loanID,
+			FileID:            photoProof.ID,
+			DuplicateOfLoanID: loan.ID,
+			DuplicateOfFileID: *loan.PhotoProofFileID,
+			Distance:          distances[*loan.PhotoProofFileID],
+		})
+	}
+	return
+}
+
+func haversineMeters(lat1, long1, lat2, long2 float64) float64 {
+	const earthRadiusMeters = 6371000
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRad(lat2 - lat1)
+	dLong := toRad(long2 - long1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLong/2)*math.Sin(dLong/2)
+	return earthRadiusMeters * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+func calculateLoanFees(productFees []entity.LoanProductFee, amount int) (fees []entity.LoanFee, total int) {
+	fees = []entity.LoanFee{}
+	for _, fee := range productFees {
+		feeAmount := decimal.NewFromFloat(fee.Value)
+		if fee.Type == entity.FeeTypePercent {
+			feeAmount = decimal.NewFromInt(int64(amount)).Mul(feeAmount).Div(decimal.NewFromInt(100))
+		}
+		loanFee := entity.LoanFee{
+			Name:   fee.Name,
+			Type:   fee.Type,
+			Value:  fee.Value,
+			Amount: int(feeAmount.Round(0).IntPart()),
+		}
+		fees = append(fees, loanFee)
+		total += loanFee.Amount
+	}
+	return
+}
+
+// prepareLoanAgreement generates the draft letters of a fully invested loan,
+// points the loan at the new draft and opens the e-signature flow.
+func (s *loanService) prepareLoanAgreement(ctx context.Context, loan entity.Loan, notifyInvestors bool) (result entity.Loan, err error) {
+	// generate loan agreement pdf
+	draftLoanAgreementLetterURL, err := s.generateLoanAgreementPDF(ctx, loan)
 	if err != nil {
 		return
 	}
-	if loan.InvestedAmount == loan.Amount {
-		// update loan fully invested at
-		fullInvestedAt := time.Now().UTC()
-		loan.FullyInvestedAt = &fullInvestedAt
-		err = s.loanRepo.Update(ctx, &loan)
+	loan.DraftLoanAgreementLetterURL = &draftLoanAgreementLetterURL
+	err = s.loanRepo.Update(ctx, &loan)
+	if err != nil {
+		return
+	}
+	err = s.eventBus.Publish(ctx, entity.DomainEvent{
+		Type: entity.DomainEventTypeLoanAgreementGenerated,
+		Key:  fmt.Sprintf("%s:%d:%s", entity.DomainEventTypeLoanAgreementGenerated, loan.ID, path.Base(draftLoanAgreementLetterURL)),
+		Loan: loan,
+	})
+	if err != nil {
+		return
+	}
+	// open the e-signature flow, the borrower is notified here
+	_, err = s.requestAgreementSignatures(ctx, loan)
+	if err != nil {
+		return
+	}
+	if notifyInvestors {
+		err = s.enqueueLoanAgreementEmails(ctx, loan)
 		if err != nil {
 			return
 		}
-		go func() {
-			// generate loan agreement pdf
-			var pdfRelativePath string
-			pdfRelativePath, err = s.generateLoanAgreementPDF(ctx, loan)
-			if err != nil {
-				return
-			}
-			u, _ := url.Parse(os.Getenv("APP_HOST"))
-			draftLoanAgreementLetterURL, err := url.JoinPath(u.String(), filepath.ToSlash(pdfRelativePath))
-			if err != nil {
-				return
-			}
-			loan.DraftLoanAgreementLetterURL = &draftLoanAgreementLetterURL
-			err = s.loanRepo.Update(ctx, &loan)
-			if err != nil {
-				return
-			}
-			// send email to investors
-			err = s.sendLoanAgreementEmail(ctx, loan)
-			if err != nil {
-				return
-			}
-		}()
 	}
-	result = item
+	result = loan
 	return
 }
 
-func (s *loanService) generateLoanAgreementPDF(ctx context.Context, loan entity.Loan) (pdfRelativePath string, err error) {
+func (s *loanService) generateLoanAgreementPDF(ctx context.Context, loan entity.Loan) (draftURL string, err error) {
+	input, err := s.buildLoanAgreementInput(ctx, loan, entity.DefaultAgreementLanguage)
+	if err != nil {
+		return
+	}
+	input.Format = entity.DocumentFormatPDF
+	document, err := s.issueAgreementDocument(ctx, entity.AgreementDocument{
+		LoanID:   loan.ID,
+		Variant:  entity.AggrementLetterVariantDraft,
+		Language: input.Language,
+		Format:   input.Format,
+	}, input, s.documentApi.GenerateAgreement)
+	if err != nil {
+		return
+	}
+	draftURL = document.URL
+
+	// every investor also gets a letter that only shows their own participation
+	for _, investor := range input.Investors {
+		_, err = s.issueAgreementDocument(ctx, entity.AgreementDocument{
+			LoanID:     loan.ID,
+			InvestorID: &investor.InvestorID,
+			Variant:    entity.AggrementLetterVariantDraft,
+			Language:   input.Language,
+			Format:     input.Format,
+		}, input, func(input entity.InvestorAgreementLetterInput) (string, error) {
+			return s.documentApi.GenerateInvestorAgreement(input, investor)
+		})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (s *loanService) buildLoanAgreementInput(ctx context.Context, loan entity.Loan, language entity.Language) (result entity.InvestorAgreementLetterInput, err error) {
 	var loanInvestments []entity.LoanInvestment
 	loanInvestments, err = s.loanInvestmentRepo.LoanInvestments(ctx, entity.LoanInvestmentsInput{
 		LoanID: &loan.ID,
@@ -221,10 +594,12 @@ func (s *loanService) generateLoanAgreementPDF(ctx context.Context, loan entity.
 		return
 	}
 	investorIDs := make([]int, 0)
-	investmentsMap := make(map[int]entity.LoanInvestment)
+	investedAmounts := make(map[int]int)
 	for _, investment := range loanInvestments {
-		investorIDs = append(investorIDs, investment.InvestorID)
-		investmentsMap[investment.InvestorID] = investment
+		if _, ok := investedAmounts[investment.InvestorID]; !ok {
+			investorIDs = append(investorIDs, investment.InvestorID)
+		}
+		investedAmounts[investment.InvestorID] += investment.Amount
 	}
 	var investors []entity.Investor
 	investors, err = s.investorRepo.Investors(ctx, entity.InvestorsInput{
@@ -236,62 +611,192 @@ func (s *loanService) generateLoanAgreementPDF(ctx context.Context, loan entity.
 
 	investorsPdf := []entity.InvestorAgreementLetterInvestor{}
 	for _, investor := range investors {
-		investment := investmentsMap[investor.ID]
+		amount := investedAmounts[investor.ID]
 		investorsPdf = append(investorsPdf, entity.InvestorAgreementLetterInvestor{
-			Name:    investor.Email,
-			Amount:  strconv.Itoa(investment.Amount),
-			Percent: (float64(investment.Amount) / float64(loan.Amount)) * 100,
+			InvestorID: investor.ID,
+			Name:       investor.Email,
+			Amount:     amount,
+			Percent:    (float64(amount) / float64(loan.Amount)) * 100,
+			Password:   s.agreementPassword.For(investor),
 		})
 	}
-	pdfRelativePath, err = s.pdfApi.GenerateAgreementPDF(entity.InvestorAgreementLetterInput{
-		AgreementNo:  strconv.Itoa(loan.ID),
-		EffectiveOn:  loan.FullyInvestedAt.Format("02 Jan 2006"),
-		BorrowerName: strconv.Itoa(loan.UserID),
-		Amount:       strconv.Itoa(loan.Amount),
-		Rate:         strconv.FormatFloat(loan.Rate, 'f', 2, 64),
-		Term:         strconv.Itoa(loan.Term),
-		Investors:    investorsPdf,
+	borrowerName := strconv.Itoa(loan.UserID)
+	borrower, err := s.borrowerRepo.Borrower(ctx, entity.BorrowerInput{
+		ID: &loan.UserID,
 	})
-	if err != nil {
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		return
 	}
+	err = nil
+	if borrower.Name != "" {
+		borrowerName = borrower.Name
+	}
+	result = entity.InvestorAgreementLetterInput{
+		Language:       language,
+		AgreementNo:    strconv.Itoa(loan.ID),
+		EffectiveOn:    *loan.FullyInvestedAt,
+		BorrowerName:   borrowerName,
+		Amount:         loan.Amount,
+		Rate:           loan.Rate,
+		Term:           loan.Term,
+		TermUnit:       loan.TermUnit,
+		InterestMethod: loan.InterestMethod,
+		Investors:      investorsPdf,
+	}
 	return
 }
 
-func (s *loanService) sendLoanAgreementEmail(ctx context.Context, loan entity.Loan) (err error) {
-	var loanInvestments []entity.LoanInvestment
-	loanInvestments, err = s.loanInvestmentRepo.LoanInvestments(ctx, entity.LoanInvestmentsInput{
+// enqueueLoanAgreementEmails records one mail per investor in the outbox. The key includes the draft,
+// so a regenerated agreement is mailed again while a retried one is not.
+func (s *loanService) enqueueLoanAgreementEmails(ctx context.Context, loan entity.Loan) (err error) {
+	loanInvestments, err := s.loanInvestmentRepo.LoanInvestments(ctx, entity.LoanInvestmentsInput{
 		LoanID: &loan.ID,
 	})
 	if err != nil {
 		return
 	}
-	investorIDs := make([]int, 0)
-	investmentsMap := make(map[int]entity.LoanInvestment)
 	for _, investment := range loanInvestments {
-		investorIDs = append(investorIDs, investment.InvestorID)
-		investmentsMap[investment.InvestorID] = investment
+		err = s.outboxEventRepo.Create(ctx, &entity.OutboxEvent{
+			Type:   entity.OutboxEventTypeInvestorAgreementMail,
+			LoanID: loan.ID,
+			Key:    fmt.Sprintf("%s:%d:%d:%s", entity.OutboxEventTypeInvestorAgreementMail, loan.ID, investment.InvestorID, path.Base(*loan.DraftLoanAgreementLetterURL)),
+			Payload: entity.OutboxPayload{
+				LoanID:     loan.ID,
+				InvestorID: investment.InvestorID,
+			},
+		})
+		if err != nil {
+			return
+		}
 	}
-	var investors []entity.Investor
-	investors, err = s.investorRepo.Investors(ctx, entity.InvestorsInput{
-		IDs: &investorIDs,
+	return
+}
+
+// notifyInvestorAgreement queues the agreement notification of one investor, key keeps a retried event from queueing it twice.
+func (s *loanService) notifyInvestorAgreement(ctx context.Context, loan entity.Loan, investorID int, key string) (err error) {
+	investment, err := s.loanInvestmentRepo.LoanInvestment(ctx, entity.LoanInvestmentInput{
+		LoanID:     &loan.ID,
+		InvestorID: &investorID,
 	})
 	if err != nil {
 		return
 	}
-	for _, investor := range investors {
-		investment := investmentsMap[investor.ID]
-		err = s.mailApi.SendInvestorAgreementMail(ctx, entity.SendInvestorAgreementMailInput{
-			To:           investor.Email,
-			InvestorName: investor.Email,
-			InvestDate:   investment.CreatedAt.Format("02 Jan 2006"),
-			Amount:       strconv.Itoa(investment.Amount),
-			AgreementURL: *loan.DraftLoanAgreementLetterURL,
+	investor, err := s.investorRepo.Investor(ctx, entity.InvestorInput{
+		ID: &investorID,
+	})
+	if err != nil {
+		return
+	}
+	signingURLs, err := s.investorSigningURLs(ctx, loan.ID)
+	if err != nil {
+		return
+	}
+	agreementDocuments, err := s.investorAgreementDocuments(ctx, loan.ID)
+	if err != nil {
+		return
+	}
+	// the letter of the investor travels with the email, so it can be read even when the link
+	// stops working. Loans funded before investors got their own letter get it rendered now.
+	document, ok := agreementDocuments[investor.ID]
+	if !ok {
+		document, err = s.issueLoanAgreement(ctx, loan, entity.AgreementDocument{
+			InvestorID: &investor.ID,
+			Variant:    entity.AggrementLetterVariantDraft,
+			Language:   entity.DefaultAgreementLanguage,
+			Format:     entity.DocumentFormatPDF,
 		})
 		if err != nil {
 			return
 		}
 	}
+	attachments := []entity.NotificationAttachment{{
+		Name:        fmt.Sprintf("loan-agreement-%d.pdf", loan.ID),
+		ContentType: document.Format.ContentType(),
+		StorageKey:  document.Path,
+	}}
+	password := entity.AgreementPasswordNone
+	if s.agreementPassword.For(investor) != "" {
+		password = s.agreementPassword
+	}
+	agreementURL, err := s.fileService.DownloadURL(ctx, document.URL, entity.DownloadGrant{
+		Audience:  entity.DownloadAudienceInvestor,
+		SubjectID: investor.ID,
+		TTL:       agreementMailLinkTTL,
+	})
+	if err != nil {
+		return
+	}
+	_, err = s.notificationService.Notify(ctx, entity.NotifyInput{
+		Key:    key,
+		LoanID: &loan.ID,
+		Recipient: entity.NotificationRecipient{
+			Type:  entity.NotificationRecipientTypeInvestor,
+			ID:    investor.ID,
+			Email: investor.Email,
+		},
+		Template: entity.NotificationTemplateInvestmentAgreement,
+		Data: entity.InvestorAgreementTemplateData{
+			InvestorName: investor.Email,
+			InvestedAt:   investment.CreatedAt,
+			Amount:       investment.Amount,
+			AgreementURL: agreementURL,
+			SigningURL:   signingURLs[investor.ID],
+			Attached:     true,
+			Password:     password,
+		},
+		Attachments: attachments,
+	})
+	if err != nil {
+		return
+	}
+	return
+}
+
+// HandleLoanFullyFunded generates the draft agreement of a fully funded loan, opens its signing
+// and mails the investors. What an earlier attempt finished is not done again.
+func (s *loanService) HandleLoanFullyFunded(ctx context.Context, event entity.DomainEvent) (err error) {
+	loan := event.Loan
+	if loan.DraftLoanAgreementLetterURL == nil {
+		_, err = s.prepareLoanAgreement(ctx, loan, true)
+		return
+	}
+	_, err = s.requestAgreementSignatures(ctx, loan)
+	if err != nil {
+		return
+	}
+	err = s.enqueueLoanAgreementEmails(ctx, loan)
+	return
+}
+
+// ProcessOutboxEvent runs a side effect recorded in the outbox. Every step checks what an earlier,
+// failed attempt already did, so the dispatcher can retry an event as often as needed.
+func (s *loanService) ProcessOutboxEvent(ctx context.Context, event entity.OutboxEvent) (err error) {
+	loan, err := s.loanRepo.Loan(ctx, entity.LoanInput{
+		ID: &event.Payload.LoanID,
+	})
+	if err != nil {
+		return
+	}
+
+	switch event.Type {
+	case entity.OutboxEventTypeLoanFunded:
+		// a failed subscriber fails the event, the outbox retries it and every subscriber again
+		err = s.eventBus.Publish(ctx, entity.DomainEvent{
+			Type: entity.DomainEventTypeLoanFullyFunded,
+			Key:  fmt.Sprintf("%s:%d", entity.DomainEventTypeLoanFullyFunded, loan.ID),
+			Loan: loan,
+		})
+	case entity.OutboxEventTypeInvestorAgreementMail:
+		if loan.DraftLoanAgreementLetterURL == nil {
+			err = errors.New("loan has no agreement to mail yet")
+			return
+		}
+		err = s.notifyInvestorAgreement(ctx, loan, event.Payload.InvestorID, event.Key)
+	case entity.OutboxEventTypeAgreementSigned:
+		err = s.finalizeSignedAgreement(ctx, loan)
+	default:
+		err = fmt.Errorf("unknown outbox event type %s", event.Type)
+	}
 	return
 }
 
@@ -304,10 +809,6 @@ func (s *loanService) DisburseLoan(ctx context.Context, input entity.DisburseLoa
 		err = errors.New("disbursedByEmployeeId is required")
 		return
 	}
-	if input.LoanAgreementLetterURL == "" {
-		err = errors.New("loanAgreementLetterUrl is required")
-		return
-	}
 	if input.AgreementCollectedByEmployeeID == 0 {
 		err = errors.New("agreementCollectedByEmployeeId is required")
 		return
@@ -341,10 +842,16 @@ func (s *loanService) DisburseLoan(ctx context.Context, input entity.DisburseLoa
 		err = errors.New("total investment does not match loan amount, please contact admin/cs")
 		return
 	}
+	if input.LoanAgreementLetterURL != "" {
+		err = s.attachWetSignedAgreement(ctx, &currentItem, input.LoanAgreementLetterURL)
+	} else {
+		err = s.checkAgreementSigned(ctx, currentItem)
+	}
+	if err != nil {
+		return
+	}
 
 	currentItem.DisbursedByEmployeeID = &input.DisbursedByEmployeeID
-	trimmedURL := strings.TrimSpace(input.LoanAgreementLetterURL)
-	currentItem.LoanAgreementLetterURL = &trimmedURL
 	currentItem.AgreementCollectedByEmployeeID = &input.AgreementCollectedByEmployeeID
 	disbursedAt := time.Now().UTC()
 	currentItem.DisbursedAt = &disbursedAt
@@ -353,10 +860,59 @@ func (s *loanService) DisburseLoan(ctx context.Context, input entity.DisburseLoa
 	if err != nil {
 		return
 	}
+	s.publish(ctx, entity.DomainEvent{
+		Type: entity.DomainEventTypeLoanDisbursed,
+		Key:  fmt.Sprintf("%s:%d", entity.DomainEventTypeLoanDisbursed, currentItem.ID),
+		Loan: currentItem,
+	})
 	result = currentItem
 	return
 }
 
+// ExpireLoans closes the approved loans whose funding deadline has passed before they were fully
+// funded, so the borrower can propose again. The borrower is told by the LOAN_EXPIRED event.
+func (s *loanService) ExpireLoans(ctx context.Context) (result []entity.Loan, err error) {
+	now := time.Now().UTC()
+	status := entity.LoanStatusApproved
+	loans, err := s.loanRepo.Loans(ctx, entity.LoansInput{
+		Status:                &status,
+		FundingDeadlineBefore: &now,
+	})
+	if err != nil {
+		return
+	}
+	result = []entity.Loan{}
+	for _, loan := range loans {
+		var expired bool
+		expired, err = s.loanRepo.Expire(ctx, &loan, now)
+		if err != nil {
+			return
+		}
+		if !expired {
+			continue
+		}
+		s.publish(ctx, entity.DomainEvent{
+			Type: entity.DomainEventTypeLoanExpired,
+			Key:  fmt.Sprintf("%s:%d", entity.DomainEventTypeLoanExpired, loan.ID),
+			Loan: loan,
+		})
+		result = append(result, loan)
+	}
+	return
+}
+
+// RunExpiryJob is the handler of the scheduled LOAN_EXPIRY job.
+func (s *loanService) RunExpiryJob(ctx context.Context, job entity.Job) (err error) {
+	expired, err := s.ExpireLoans(ctx)
+	if err != nil {
+		return
+	}
+	for _, loan := range expired {
+		log.Printf("loan expiry: loan %d expired, funding deadline %s, invested %d of %d", loan.ID, loan.FundingDeadline.Format(time.RFC3339), loan.InvestedAmount, loan.Amount)
+	}
+	return
+}
+
 func (s *loanService) Loans(ctx context.Context, filter entity.LoansInput) (result []entity.Loan, err error) {
 	result, err = s.loanRepo.Loans(ctx, filter)
 	if err != nil {
@@ -381,13 +937,17 @@ func (s *loanService) Loan(ctx context.Context, filter entity.LoanInput) (result
 	return
 }
 
-func (s *loanService) GetDraftLoanAgreementLetter(ctx context.Context, loanID int) (result string, err error) {
+func (s *loanService) GetDraftLoanAgreementLetter(ctx context.Context, caller entity.Caller, loanID int, options entity.AgreementLetterOptions) (result string, err error) {
 	loan, err := s.loanRepo.Loan(ctx, entity.LoanInput{
 		ID: &loanID,
 	})
 	if err != nil {
 		return
 	}
+	if !canReadLoanAgreement(caller, loan) {
+		err = entity.ErrAccessDenied
+		return
+	}
 	if loan.FullyInvestedAt == nil {
 		err = errors.New("loan is not fully funded yet")
 		return
@@ -396,17 +956,35 @@ func (s *loanService) GetDraftLoanAgreementLetter(ctx context.Context, loanID in
 		err = errors.New("loan agreement letter is not available")
 		return
 	}
-	result = *loan.DraftLoanAgreementLetterURL
+	agreementURL := *loan.DraftLoanAgreementLetterURL
+	if !options.IsDefault() {
+		agreementURL, err = s.agreementLetterURL(ctx, loan, entity.AgreementDocument{
+			Variant:  entity.AggrementLetterVariantDraft,
+			Language: options.Language,
+			Format:   options.Format,
+		})
+		if err != nil {
+			return
+		}
+	}
+	result, err = s.fileService.DownloadURL(ctx, agreementURL, caller.Grant(0))
+	if err != nil {
+		return
+	}
 	return
 }
 
-func (s *loanService) GetSignedLoanAgreementLetter(ctx context.Context, loanID int) (result string, err error) {
+func (s *loanService) GetSignedLoanAgreementLetter(ctx context.Context, caller entity.Caller, loanID int, options entity.AgreementLetterOptions) (result string, err error) {
 	loan, err := s.loanRepo.Loan(ctx, entity.LoanInput{
 		ID: &loanID,
 	})
 	if err != nil {
 		return
 	}
+	if !canReadLoanAgreement(caller, loan) {
+		err = entity.ErrAccessDenied
+		return
+	}
 	if loan.Status != entity.LoanStatusDisbursed {
 		err = errors.New("loan is not disbursed yet")
 		return
@@ -415,11 +993,34 @@ func (s *loanService) GetSignedLoanAgreementLetter(ctx context.Context, loanID i
 		err = errors.New("loan agreement letter is not available")
 		return
 	}
-	result = *loan.LoanAgreementLetterURL
+	agreementURL := *loan.LoanAgreementLetterURL
+	if !options.IsDefault() {
+		if loan.LoanAgreementFileID != nil {
+			err = errors.New("loan agreement was signed on paper, only the uploaded scan is available")
+			return
+		}
+		agreementURL, err = s.agreementLetterURL(ctx, loan, entity.AgreementDocument{
+			Variant:  entity.AggrementLetterVariantSign,
+			Language: options.Language,
+			Format:   options.Format,
+		})
+		if err != nil {
+			return
+		}
+	}
+	result, err = s.fileService.DownloadURL(ctx, agreementURL, caller.Grant(0))
+	if err != nil {
+		return
+	}
 	return
 }
 
-func (s *loanService) GetLoanQuote(ctx context.Context, loanID int) (result entity.LoanQuote, err error) {
+func (s *loanService) GetLoanQuote(ctx context.Context, caller entity.Caller, loanID int) (result entity.LoanQuote, err error) {
+	// the quote lists every investor of the loan
+	if !caller.IsEmployee() {
+		err = entity.ErrAccessDenied
+		return
+	}
 	loan, err := s.loanRepo.Loan(ctx, entity.LoanInput{
 		ID: &loanID,
 	})
@@ -453,14 +1054,12 @@ func (s *loanService) GetLoanQuote(ctx context.Context, loanID int) (result enti
 	for _, investor := range investors {
 		investorsMap[investor.ID] = investor
 	}
+	agreementURL, err := s.fileService.DownloadURL(ctx, *loan.LoanAgreementLetterURL, caller.Grant(0))
+	if err != nil {
+		return
+	}
 	principal := decimal.NewFromInt(int64(loan.Amount))
-	rateAnnual := decimal.NewFromFloat(loan.Rate).Div(decimal.NewFromInt(100))
-	termWeeks := decimal.NewFromInt(int64(loan.Term))
-
-	// 1. Weekly Interest = (Principal Amount x Annual Interest Rate) / 52
-	WeeklyInterest := principal.Mul(rateAnnual).Div(decimal.NewFromInt(52))
-	// 2. Total Interest = Weekly Interest x Term (in weeks)
-	TotalInterest := WeeklyInterest.Mul(termWeeks)
+	TotalInterest := calculateTotalInterest(loan)
 
 	investorsQuote := []entity.LoanQuoteInvestor{}
 	for _, investment := range loanInvestments {
@@ -477,20 +1076,76 @@ func (s *loanService) GetLoanQuote(ctx context.Context, loanID int) (result enti
 		BorrowerID:      loan.UserID,
 		PrincipalAmount: loan.Amount,
 		Rate:            loan.Rate,
+		Term:            loan.Term,
+		TermUnit:        loan.TermUnit,
+		InterestMethod:  loan.InterestMethod,
+		FeeAmount:       loan.FeeAmount,
 		TotalROI:        TotalInterest.StringFixed(0),
-		AgreementURL:    *loan.LoanAgreementLetterURL,
+		AgreementURL:    agreementURL,
 		Investors:       investorsQuote,
 	}
 
 	return
 }
 
+func (s *loanService) PhotoProofDuplicates(ctx context.Context, filter entity.PhotoProofDuplicatesInput) (result []entity.PhotoProofDuplicate, err error) {
+	result, err = s.photoProofDuplicateRepo.PhotoProofDuplicates(ctx, filter)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// canReadLoanAgreement allows employees and the borrower of the loan to read the letter
+// of the whole loan, investors only read the letter of their own participation.
+func canReadLoanAgreement(caller entity.Caller, loan entity.Loan) bool {
+	switch caller.Audience {
+	case entity.DownloadAudienceEmployee:
+		return true
+	case entity.DownloadAudienceBorrower:
+		return caller.SubjectID == loan.UserID
+	}
+	return false
+}
+
+func calculateTotalInterest(loan entity.Loan) decimal.Decimal {
+	principal := decimal.NewFromInt(int64(loan.Amount))
+	rateAnnual := decimal.NewFromFloat(loan.Rate).Div(decimal.NewFromInt(100))
+	periods := decimal.NewFromInt(int64(loan.Term))
+	periodicRate := rateAnnual.Div(decimal.NewFromInt(int64(loan.TermUnit.PeriodsPerYear())))
+
+	if loan.InterestMethod == entity.InterestMethodEffective && !periodicRate.IsZero() {
+		// Installment = Principal x r / (1 - (1 + r)^-n), Total Interest = Installment x n - Principal
+		growth := decimal.NewFromInt(1).Add(periodicRate).Pow(periods)
+		installment := principal.Mul(periodicRate).Mul(growth).Div(growth.Sub(decimal.NewFromInt(1)))
+		return installment.Mul(periods).Sub(principal)
+	}
+
+	// 1. Periodic Interest = (Principal Amount x Annual Interest Rate) / periods per year
+	periodicInterest := principal.Mul(periodicRate)
+	// 2. Total Interest = Periodic Interest x Term (in periods)
+	return periodicInterest.Mul(periods)
+}
+
 type loanService struct {
-	loanRepo           db.LoanRepository
-	loanInvestmentRepo db.LoanInvestmentRepository
-	investorRepo       db.InvestorRepository
-	mailApi            mail.MailApi
-	pdfApi             pdf.PdfApi
+	loanRepo                db.LoanRepository
+	loanInvestmentRepo      db.LoanInvestmentRepository
+	investorRepo            db.InvestorRepository
+	borrowerRepo            db.BorrowerRepository
+	loanProductRepo         db.LoanProductRepository
+	photoProofDuplicateRepo db.PhotoProofDuplicateRepository
+	agreementSignatureRepo  db.AgreementSignatureRepository
+	agreementDocumentRepo   db.AgreementDocumentRepository
+	outboxEventRepo         db.OutboxEventRepository
+	notificationService     NotificationService
+	eventBus                EventBus
+	documentApi             document.DocumentApi
+	creditScorer            scoring.CreditScorer
+	eligibilityEngine       EligibilityEngine
+	fileService             FileService
+	storage                 storage.Storage
+	photoProofPolicy        entity.PhotoProofPolicy
+	agreementPassword       entity.AgreementPassword
 }
 
 type InitiatorLoan func(s *loanService) *loanService
@@ -522,16 +1177,107 @@ func (i InitiatorLoan) SetInvestorRepository(investorRepository db.InvestorRepos
 	}
 }
 
-func (i InitiatorLoan) SetMailApi(mailApi mail.MailApi) InitiatorLoan {
+func (i InitiatorLoan) SetBorrowerRepository(borrowerRepository db.BorrowerRepository) InitiatorLoan {
+	return func(s *loanService) *loanService {
+		i(s).borrowerRepo = borrowerRepository
+		return s
+	}
+}
+
+func (i InitiatorLoan) SetLoanProductRepository(loanProductRepository db.LoanProductRepository) InitiatorLoan {
+	return func(s *loanService) *loanService {
+		i(s).loanProductRepo = loanProductRepository
+		return s
+	}
+}
+
+func (i InitiatorLoan) SetPhotoProofDuplicateRepository(photoProofDuplicateRepository db.PhotoProofDuplicateRepository) InitiatorLoan {
+	return func(s *loanService) *loanService {
+		i(s).photoProofDuplicateRepo = photoProofDuplicateRepository
+		return s
+	}
+}
+
+func (i InitiatorLoan) SetAgreementSignatureRepository(agreementSignatureRepository db.AgreementSignatureRepository) InitiatorLoan {
+	return func(s *loanService) *loanService {
+		i(s).agreementSignatureRepo = agreementSignatureRepository
+		return s
+	}
+}
+
+func (i InitiatorLoan) SetAgreementDocumentRepository(agreementDocumentRepository db.AgreementDocumentRepository) InitiatorLoan {
+	return func(s *loanService) *loanService {
+		i(s).agreementDocumentRepo = agreementDocumentRepository
+		return s
+	}
+}
+
+func (i InitiatorLoan) SetOutboxEventRepository(outboxEventRepository db.OutboxEventRepository) InitiatorLoan {
+	return func(s *loanService) *loanService {
+		i(s).outboxEventRepo = outboxEventRepository
+		return s
+	}
+}
+
+func (i InitiatorLoan) SetNotificationService(notificationService NotificationService) InitiatorLoan {
+	return func(s *loanService) *loanService {
+		i(s).notificationService = notificationService
+		return s
+	}
+}
+
+func (i InitiatorLoan) SetEventBus(eventBus EventBus) InitiatorLoan {
+	return func(s *loanService) *loanService {
+		i(s).eventBus = eventBus
+		return s
+	}
+}
+
+func (i InitiatorLoan) SetDocumentApi(documentApi document.DocumentApi) InitiatorLoan {
+	return func(s *loanService) *loanService {
+		i(s).documentApi = documentApi
+		return s
+	}
+}
+
+func (i InitiatorLoan) SetCreditScorer(creditScorer scoring.CreditScorer) InitiatorLoan {
+	return func(s *loanService) *loanService {
+		i(s).creditScorer = creditScorer
+		return s
+	}
+}
+
+func (i InitiatorLoan) SetEligibilityEngine(eligibilityEngine EligibilityEngine) InitiatorLoan {
+	return func(s *loanService) *loanService {
+		i(s).eligibilityEngine = eligibilityEngine
+		return s
+	}
+}
+
+func (i InitiatorLoan) SetFileService(fileService FileService) InitiatorLoan {
+	return func(s *loanService) *loanService {
+		i(s).fileService = fileService
+		return s
+	}
+}
+
+func (i InitiatorLoan) SetStorage(storage storage.Storage) InitiatorLoan {
+	return func(s *loanService) *loanService {
+		i(s).storage = storage
+		return s
+	}
+}
+
+func (i InitiatorLoan) SetPhotoProofPolicy(photoProofPolicy entity.PhotoProofPolicy) InitiatorLoan {
 	return func(s *loanService) *loanService {
-		i(s).mailApi = mailApi
+		i(s).photoProofPolicy = photoProofPolicy
 		return s
 	}
 }
 
-func (i InitiatorLoan) SetPdfApi(pdfApi pdf.PdfApi) InitiatorLoan {
+func (i InitiatorLoan) SetAgreementPassword(agreementPassword entity.AgreementPassword) InitiatorLoan {
 	return func(s *loanService) *loanService {
-		i(s).pdfApi = pdfApi
+		i(s).agreementPassword = agreementPassword
 		return s
 	}
 }
@@ -0,0 +1,403 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/repository/storage"
+	"gorm.io/gorm"
+)
+
+// verificationCodeAlphabet leaves out characters that are easy to misread.
+const verificationCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// issueAgreementDocument renders an agreement with a fresh verification code and
+// records the hash of the written file, so the document can be verified later.
+// The document carries the loan, variant and optional investor of the record.
+func (s *loanService) issueAgreementDocument(ctx context.Context, document entity.AgreementDocument, input entity.InvestorAgreementLetterInput, render func(input entity.InvestorAgreementLetterInput) (string, error)) (result entity.AgreementDocument, err error) {
+	code, err := newVerificationCode()
+	if err != nil {
+		return
+	}
+	u, _ := url.Parse(os.Getenv("APP_HOST"))
+	verificationURL, err := url.JoinPath(u.String(), "agreements", "verify")
+	if err != nil {
+		return
+	}
+	input.VerificationCode = code
+	input.VerificationURL = verificationURL + "?code=" + code
+
+	documentKey, err := render(input)
+	if err != nil {
+		return
+	}
+	templateVersion, err := s.documentApi.TemplateVersion(document.Language)
+	if err != nil {
+		return
+	}
+	checksum, err := objectSHA256(ctx, s.storage, documentKey)
+	if err != nil {
+		return
+	}
+	documentURL, err := url.JoinPath(u.String(), entity.PublicAggrementLetterPath, path.Base(documentKey))
+	if err != nil {
+		return
+	}
+	result = document
+	result.TemplateVersion = templateVersion
+	result.Path = documentKey
+	result.URL = documentURL
+	result.SHA256 = checksum
+	result.VerificationCode = code
+	result.GeneratedAt = time.Now().UTC()
+	err = s.agreementDocumentRepo.Create(ctx, &result)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// issueLoanAgreement renders and records one agreement letter of the loan. The
+// document carries the variant, language and format to render, an investor id
+// selects the letter covering only that investor.
+func (s *loanService) issueLoanAgreement(ctx context.Context, loan entity.Loan, document entity.AgreementDocument) (result entity.AgreementDocument, err error) {
+	input, err := s.buildLoanAgreementInput(ctx, loan, document.Language)
+	if err != nil {
+		return
+	}
+	input.Format = document.Format
+	render := s.documentApi.GenerateAgreement
+	switch {
+	case document.Variant == entity.AggrementLetterVariantSign && document.InvestorID != nil:
+		err = errors.New("signed agreement is only available for the whole loan")
+		return
+	case document.Variant == entity.AggrementLetterVariantSign:
+		var signatures []entity.AgreementLetterSignature
+		signatures, err = s.agreementLetterSignatures(ctx, loan.ID)
+		if err != nil {
+			return
+		}
+		render = func(input entity.InvestorAgreementLetterInput) (string, error) {
+			return s.documentApi.GenerateSignedAgreement(input, signatures)
+		}
+	case document.InvestorID != nil:
+		var investor *entity.InvestorAgreementLetterInvestor
+		for i := range input.Investors {
+			if input.Investors[i].InvestorID == *document.InvestorID {
+				investor = &input.Investors[i]
+			}
+		}
+		if investor == nil {
+			err = errors.New("investor has no participation in this loan")
+			return
+		}
+		render = func(input entity.InvestorAgreementLetterInput) (string, error) {
+			return s.documentApi.GenerateInvestorAgreement(input, *investor)
+		}
+	}
+	document.LoanID = loan.ID
+	result, err = s.issueAgreementDocument(ctx, document, input, render)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// agreementLetterURL returns the latest letter matching the variant, investor,
+// language and format of the document, rendering it the first time it is asked for.
+// A letter rendered before the current draft or signed agreement of the loan is
+// stale and rendered again.
+func (s *loanService) agreementLetterURL(ctx context.Context, loan entity.Loan, document entity.AgreementDocument) (result string, err error) {
+	if document.Language == "" {
+		document.Language = entity.DefaultAgreementLanguage
+	}
+	if document.Format == "" {
+		document.Format = entity.DocumentFormatPDF
+	}
+	current, err := s.currentAgreementDocumentID(ctx, loan, document.Variant)
+	if err != nil {
+		return
+	}
+	documents, err := s.agreementDocumentRepo.AgreementDocuments(ctx, entity.AgreementDocumentsInput{
+		LoanID:     &loan.ID,
+		InvestorID: document.InvestorID,
+		Variant:    &document.Variant,
+		Language:   &document.Language,
+		Format:     &document.Format,
+	})
+	if err != nil {
+		return
+	}
+	for _, issued := range documents {
+		if (document.InvestorID == nil) != (issued.InvestorID == nil) || issued.ID < current {
+			continue
+		}
+		result = issued.URL
+	}
+	if result != "" {
+		return
+	}
+	issued, err := s.issueLoanAgreement(ctx, loan, document)
+	if err != nil {
+		return
+	}
+	result = issued.URL
+	return
+}
+
+// currentAgreementDocumentID is the id of the default letter the loan points at for the variant,
+// every other letter of that variant is rendered after it. It is zero when the loan points at
+// no rendered letter, for instance a scan of an agreement signed on paper.
+func (s *loanService) currentAgreementDocumentID(ctx context.Context, loan entity.Loan, variant string) (result int, err error) {
+	currentURL := loan.DraftLoanAgreementLetterURL
+	if variant == entity.AggrementLetterVariantSign {
+		currentURL = loan.LoanAgreementLetterURL
+	}
+	if currentURL == nil {
+		return
+	}
+	language := entity.DefaultAgreementLanguage
+	format := entity.DocumentFormatPDF
+	documents, err := s.agreementDocumentRepo.AgreementDocuments(ctx, entity.AgreementDocumentsInput{
+		LoanID:   &loan.ID,
+		Variant:  &variant,
+		Language: &language,
+		Format:   &format,
+	})
+	if err != nil {
+		return
+	}
+	for _, document := range documents {
+		if document.InvestorID == nil && document.URL == *currentURL {
+			result = document.ID
+		}
+	}
+	return
+}
+
+// investorAgreementDocuments maps investor id to the latest PDF draft of its own letter.
+func (s *loanService) investorAgreementDocuments(ctx context.Context, loanID int) (result map[int]entity.AgreementDocument, err error) {
+	variant := entity.AggrementLetterVariantDraft
+	language := entity.DefaultAgreementLanguage
+	format := entity.DocumentFormatPDF
+	documents, err := s.agreementDocumentRepo.AgreementDocuments(ctx, entity.AgreementDocumentsInput{
+		LoanID:   &loanID,
+		Variant:  &variant,
+		Language: &language,
+		Format:   &format,
+	})
+	if err != nil {
+		return
+	}
+	result = map[int]entity.AgreementDocument{}
+	// documents are ordered by version, later drafts win
+	for _, document := range documents {
+		if document.InvestorID == nil {
+			continue
+		}
+		result[*document.InvestorID] = document
+	}
+	return
+}
+
+func (s *loanService) GetInvestorLoanAgreementLetter(ctx context.Context, caller entity.Caller, loanID int, investorID int, options entity.AgreementLetterOptions) (result string, err error) {
+	if !caller.IsEmployee() && (caller.Audience != entity.DownloadAudienceInvestor || caller.SubjectID != investorID) {
+		err = entity.ErrAccessDenied
+		return
+	}
+	loan, err := s.loanRepo.Loan(ctx, entity.LoanInput{
+		ID: &loanID,
+	})
+	if err != nil {
+		return
+	}
+	if loan.FullyInvestedAt == nil {
+		err = errors.New("loan is not fully funded yet")
+		return
+	}
+	if loan.DraftLoanAgreementLetterURL == nil {
+		err = errors.New("investor agreement letter is not available")
+		return
+	}
+	// only the PDF renderer applies the password, a protected letter is not handed out in another format
+	if options.Format != "" && options.Format != entity.DocumentFormatPDF {
+		var investor entity.Investor
+		investor, err = s.investorRepo.Investor(ctx, entity.InvestorInput{
+			ID: &investorID,
+		})
+		if err != nil {
+			return
+		}
+		if s.agreementPassword.For(investor) != "" {
+			err = errors.New("investor agreement letter is password protected, it is only available as PDF")
+			return
+		}
+	}
+	agreementURL, err := s.agreementLetterURL(ctx, loan, entity.AgreementDocument{
+		InvestorID: &investorID,
+		Variant:    entity.AggrementLetterVariantDraft,
+		Language:   options.Language,
+		Format:     options.Format,
+	})
+	if err != nil {
+		return
+	}
+	result, err = s.fileService.DownloadURL(ctx, agreementURL, caller.Grant(0))
+	if err != nil {
+		return
+	}
+	return
+}
+
+// RegenerateLoanAgreement renders the draft letters again, for instance after
+// the background generation failed or the template changed. Earlier versions
+// stay recorded, only the loan is pointed at the new draft.
+func (s *loanService) RegenerateLoanAgreement(ctx context.Context, input entity.RegenerateAgreementInput) (result entity.Loan, err error) {
+	loan, err := s.loanRepo.Loan(ctx, entity.LoanInput{
+		ID: &input.LoanID,
+	})
+	if err != nil {
+		return
+	}
+	if loan.FullyInvestedAt == nil {
+		err = errors.New("loan is not fully funded yet")
+		return
+	}
+	if loan.Status == entity.LoanStatusDisbursed {
+		err = errors.New("loan is already disbursed")
+		return
+	}
+	// signers agreed to the current draft, a new one would not match their signature
+	signatures, err := s.agreementSignatureRepo.AgreementSignatures(ctx, entity.AgreementSignaturesInput{
+		LoanID: &loan.ID,
+	})
+	if err != nil {
+		return
+	}
+	for _, signature := range signatures {
+		if signature.Status == entity.SignatureStatusSigned {
+			err = errors.New("agreement already has signatures, it can no longer be regenerated")
+			return
+		}
+	}
+	result, err = s.prepareLoanAgreement(ctx, loan, input.ResendEmails)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (s *loanService) AgreementVersions(ctx context.Context, filter entity.AgreementDocumentsInput) (result []entity.AgreementDocument, err error) {
+	if filter.LoanID == nil {
+		err = errors.New("loanId is required")
+		return
+	}
+	result, err = s.agreementDocumentRepo.AgreementDocuments(ctx, filter)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (s *loanService) VerifyAgreement(ctx context.Context, input entity.VerifyAgreementInput) (result entity.AgreementVerification, err error) {
+	code := strings.ToUpper(strings.TrimSpace(input.Code))
+	if input.File == nil && code == "" {
+		err = errors.New("file or code is required")
+		return
+	}
+
+	var checksum string
+	if input.File != nil {
+		checksum, err = multipartSHA256(input.File)
+		if err != nil {
+			return
+		}
+	}
+
+	filter := entity.AgreementDocumentInput{}
+	if code != "" {
+		filter.VerificationCode = &code
+	} else {
+		filter.SHA256 = &checksum
+	}
+	document, err := s.agreementDocumentRepo.AgreementDocument(ctx, filter)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+		if code != "" {
+			result.Reason = "no agreement was issued with this verification code"
+		} else {
+			result.Reason = "document does not match any issued agreement"
+		}
+		return
+	}
+	if err != nil {
+		return
+	}
+	result.Document = &document
+
+	// a code printed on a forged copy is still found, only the content proves the document
+	if input.File == nil {
+		result.Reason = "verification code exists, upload the document to verify it"
+		return
+	}
+	if document.SHA256 != checksum {
+		result.Reason = "document has been modified since it was issued"
+		return
+	}
+	result.Verified = true
+	result.Reason = "document matches an issued agreement"
+	return
+}
+
+func newVerificationCode() (result string, err error) {
+	b := make([]byte, 10)
+	_, err = rand.Read(b)
+	if err != nil {
+		return
+	}
+	code := make([]byte, len(b))
+	for i, v := range b {
+		code[i] = verificationCodeAlphabet[int(v)%len(verificationCodeAlphabet)]
+	}
+	result = "AGR-" + string(code[:5]) + "-" + string(code[5:])
+	return
+}
+
+func objectSHA256(ctx context.Context, store storage.Storage, key string) (result string, err error) {
+	f, err := store.Get(ctx, key)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return
+	}
+	result = hex.EncodeToString(h.Sum(nil))
+	return
+}
+
+func multipartSHA256(file *multipart.FileHeader) (result string, err error) {
+	src, err := file.Open()
+	if err != nil {
+		return
+	}
+	defer src.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, src); err != nil {
+		return
+	}
+	result = hex.EncodeToString(h.Sum(nil))
+	return
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/repository/db"
+	"gorm.io/gorm"
+)
+
+// LoanNotificationService tells borrowers and investors how a loan is doing, it is a subscriber
+// of the domain events. The event key makes a repeated event a no-op.
+type LoanNotificationService interface {
+	HandleDomainEvent(ctx context.Context, event entity.DomainEvent) (err error)
+}
+
+func (s *loanNotificationService) HandleDomainEvent(ctx context.Context, event entity.DomainEvent) (err error) {
+	switch event.Type {
+	case entity.DomainEventTypeInvestmentPlaced:
+		err = s.notifyInvestor(ctx, event)
+	case entity.DomainEventTypeLoanApproved, entity.DomainEventTypeLoanFullyFunded, entity.DomainEventTypeLoanDisbursed,
+		entity.DomainEventTypeLoanExpired:
+		err = s.notifyBorrower(ctx, event)
+	}
+	return
+}
+
+func (s *loanNotificationService) notifyInvestor(ctx context.Context, event entity.DomainEvent) (err error) {
+	loan := event.Loan
+	if event.Investment == nil {
+		err = errors.New("investment placed event has no investment")
+		return
+	}
+	investor, err := s.investorRepo.Investor(ctx, entity.InvestorInput{
+		ID: &event.Investment.InvestorID,
+	})
+	if err != nil {
+		return
+	}
+	_, err = s.notificationService.Notify(ctx, entity.NotifyInput{
+		Key:    event.Key,
+		LoanID: &loan.ID,
+		Recipient: entity.NotificationRecipient{
+			Type:  entity.NotificationRecipientTypeInvestor,
+			ID:    investor.ID,
+			Email: investor.Email,
+		},
+		Template: entity.NotificationTemplateInvestmentReceived,
+		Data: entity.InvestmentReceivedTemplateData{
+			InvestorName:   investor.Email,
+			LoanID:         loan.ID,
+			Amount:         event.Investment.Amount,
+			InvestedAt:     event.Investment.CreatedAt,
+			LoanAmount:     loan.Amount,
+			InvestedAmount: loan.InvestedAmount,
+		},
+	})
+	return
+}
+
+func (s *loanNotificationService) notifyBorrower(ctx context.Context, event entity.DomainEvent) (err error) {
+	loan := event.Loan
+	// loans proposed before borrower profiles have no borrower to look up
+	borrower, err := s.borrowerRepo.Borrower(ctx, entity.BorrowerInput{
+		ID: &loan.UserID,
+	})
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return
+	}
+	err = nil
+	if borrower.Name == "" {
+		borrower.Name = strconv.Itoa(loan.UserID)
+	}
+
+	var template entity.NotificationTemplate
+	var data any
+	switch event.Type {
+	case entity.DomainEventTypeLoanApproved:
+		approved := entity.LoanApprovedTemplateData{
+			BorrowerName: borrower.Name,
+			LoanID:       loan.ID,
+			Amount:       loan.Amount,
+			Rate:         loan.Rate,
+			Term:         loan.Term,
+			TermUnit:     loan.TermUnit,
+		}
+		if loan.FundingDeadline != nil {
+			approved.FundingDeadline = *loan.FundingDeadline
+		}
+		template, data = entity.NotificationTemplateLoanApproved, approved
+	case entity.DomainEventTypeLoanFullyFunded:
+		var investorCount int64
+		investorCount, err = s.loanInvestmentRepo.CountLoanInvestments(ctx, entity.LoanInvestmentsInput{
+			LoanID: &loan.ID,
+		})
+		if err != nil {
+			return
+		}
+		template, data = entity.NotificationTemplateLoanFunded, entity.LoanFundedTemplateData{
+			BorrowerName:  borrower.Name,
+			LoanID:        loan.ID,
+			Amount:        loan.Amount,
+			InvestorCount: int(investorCount),
+		}
+	case entity.DomainEventTypeLoanDisbursed:
+		disbursed := entity.LoanDisbursedTemplateData{
+			BorrowerName: borrower.Name,
+			LoanID:       loan.ID,
+			Amount:       loan.Amount,
+			Term:         loan.Term,
+			TermUnit:     loan.TermUnit,
+		}
+		if loan.DisbursedAt != nil {
+			disbursed.DisbursedAt = *loan.DisbursedAt
+		}
+		template, data = entity.NotificationTemplateLoanDisbursed, disbursed
+	case entity.DomainEventTypeLoanExpired:
+		expired := entity.LoanExpiredTemplateData{
+			BorrowerName:   borrower.Name,
+			LoanID:         loan.ID,
+			Amount:         loan.Amount,
+			InvestedAmount: loan.InvestedAmount,
+		}
+		if loan.FundingDeadline != nil {
+			expired.FundingDeadline = *loan.FundingDeadline
+		}
+		template, data = entity.NotificationTemplateLoanExpired, expired
+	}
+
+	_, err = s.notificationService.Notify(ctx, entity.NotifyInput{
+		Key:    event.Key,
+		LoanID: &loan.ID,
+		Recipient: entity.NotificationRecipient{
+			Type:  entity.NotificationRecipientTypeBorrower,
+			ID:    loan.UserID,
+			Email: borrower.Email,
+			Phone: borrower.Phone,
+		},
+		Template: template,
+		Data:     data,
+	})
+	return
+}
+
+type loanNotificationService struct {
+	loanInvestmentRepo  db.LoanInvestmentRepository
+	investorRepo        db.InvestorRepository
+	borrowerRepo        db.BorrowerRepository
+	notificationService NotificationService
+}
+
+type InitiatorLoanNotification func(s *loanNotificationService) *loanNotificationService
+
+func NewLoanNotificationService() InitiatorLoanNotification {
+	return func(s *loanNotificationService) *loanNotificationService {
+		return s
+	}
+}
+
+func (i InitiatorLoanNotification) SetLoanInvestmentRepository(loanInvestmentRepository db.LoanInvestmentRepository) InitiatorLoanNotification {
+	return func(s *loanNotificationService) *loanNotificationService {
+		i(s).loanInvestmentRepo = loanInvestmentRepository
+		return s
+	}
+}
+
+func (i InitiatorLoanNotification) SetInvestorRepository(investorRepository db.InvestorRepository) InitiatorLoanNotification {
+	return func(s *loanNotificationService) *loanNotificationService {
+		i(s).investorRepo = investorRepository
+		return s
+	}
+}
+
+func (i InitiatorLoanNotification) SetBorrowerRepository(borrowerRepository db.BorrowerRepository) InitiatorLoanNotification {
+	return func(s *loanNotificationService) *loanNotificationService {
+		i(s).borrowerRepo = borrowerRepository
+		return s
+	}
+}
+
+func (i InitiatorLoanNotification) SetNotificationService(notificationService NotificationService) InitiatorLoanNotification {
+	return func(s *loanNotificationService) *loanNotificationService {
+		i(s).notificationService = notificationService
+		return s
+	}
+}
+
+func (i InitiatorLoanNotification) Build() LoanNotificationService {
+	return i(&loanNotificationService{})
+}
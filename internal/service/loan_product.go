@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/repository/db"
+)
+
+type LoanProductService interface {
+	AddLoanProduct(ctx context.Context, input entity.AddLoanProductInput) (result entity.LoanProduct, err error)
+	UpdateLoanProduct(ctx context.Context, input entity.UpdateLoanProductInput) (result entity.LoanProduct, err error)
+
+	LoanProducts(ctx context.Context, filter entity.LoanProductsInput) (result []entity.LoanProduct, err error)
+	CountLoanProducts(ctx context.Context, filter entity.LoanProductsInput) (result int64, err error)
+	LoanProduct(ctx context.Context, filter entity.LoanProductInput) (result entity.LoanProduct, err error)
+}
+
+func (s *loanProductService) AddLoanProduct(ctx context.Context, input entity.AddLoanProductInput) (result entity.LoanProduct, err error) {
+	item := entity.LoanProduct{
+		Name:              input.Name,
+		MinAmount:         input.MinAmount,
+		MaxAmount:         input.MaxAmount,
+		AllowedTerms:      input.AllowedTerms,
+		TermUnits:         input.TermUnits,
+		MinRate:           input.MinRate,
+		MaxRate:           input.MaxRate,
+		Fees:              input.Fees,
+		InterestMethod:    input.InterestMethod,
+		FundingWindowDays: input.FundingWindowDays,
+		IsActive:          true,
+	}
+	err = validateLoanProduct(item)
+	if err != nil {
+		return
+	}
+	err = s.loanProductRepo.Create(ctx, &item)
+	if err != nil {
+		return
+	}
+	result = item
+	return
+}
+
+func (s *loanProductService) UpdateLoanProduct(ctx context.Context, input entity.UpdateLoanProductInput) (result entity.LoanProduct, err error) {
+	if input.ID == 0 {
+		err = errors.New("id is required")
+		return
+	}
+	currentItem, err := s.loanProductRepo.LoanProduct(ctx, entity.LoanProductInput{
+		ID: &input.ID,
+	})
+	if err != nil {
+		return
+	}
+	currentItem.Name = input.Name
+	currentItem.MinAmount = input.MinAmount
+	currentItem.MaxAmount = input.MaxAmount
+	currentItem.AllowedTerms = input.AllowedTerms
+	currentItem.TermUnits = input.TermUnits
+	currentItem.MinRate = input.MinRate
+	currentItem.MaxRate = input.MaxRate
+	currentItem.Fees = input.Fees
+	currentItem.InterestMethod = input.InterestMethod
+	currentItem.FundingWindowDays = input.FundingWindowDays
+	if input.IsActive != nil {
+		currentItem.IsActive = *input.IsActive
+	}
+	err = validateLoanProduct(currentItem)
+	if err != nil {
+		return
+	}
+	err = s.loanProductRepo.Update(ctx, &currentItem)
+	if err != nil {
+		return
+	}
+	result = currentItem
+	return
+}
+
+func validateLoanProduct(item entity.LoanProduct) (err error) {
+	if item.Name == "" {
+		return errors.New("name is required")
+	}
+	if item.MinAmount <= 0 {
+		return errors.New("minAmount is required")
+	}
+	if item.MaxAmount < item.MinAmount {
+		return errors.New("maxAmount must not be less than minAmount")
+	}
+	if len(item.AllowedTerms) == 0 {
+		return errors.New("allowedTerms is required")
+	}
+	for _, term := range item.AllowedTerms {
+		if term <= 0 {
+			return fmt.Errorf("allowedTerms contains invalid term %d", term)
+		}
+	}
+	if len(item.TermUnits) == 0 {
+		return errors.New("termUnits is required")
+	}
+	for _, termUnit := range item.TermUnits {
+		if !termUnit.IsValid() {
+			return fmt.Errorf("termUnits contains invalid term unit %s", termUnit)
+		}
+	}
+	if item.MinRate <= 0 {
+		return errors.New("minRate is required")
+	}
+	if item.MaxRate < item.MinRate {
+		return errors.New("maxRate must not be less than minRate")
+	}
+	for _, fee := range item.Fees {
+		if fee.Name == "" {
+			return errors.New("fee name is required")
+		}
+		if !fee.Type.IsValid() {
+			return fmt.Errorf("fee %s has invalid type", fee.Name)
+		}
+		if fee.Value < 0 {
+			return fmt.Errorf("fee %s value must not be negative", fee.Name)
+		}
+	}
+	if !item.InterestMethod.IsValid() {
+		return errors.New("interestMethod is invalid")
+	}
+	if item.FundingWindowDays < 0 {
+		return errors.New("fundingWindowDays must not be negative")
+	}
+	return
+}
+
+func (s *loanProductService) LoanProducts(ctx context.Context, filter entity.LoanProductsInput) (result []entity.LoanProduct, err error) {
+	result, err = s.loanProductRepo.LoanProducts(ctx, filter)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (s *loanProductService) CountLoanProducts(ctx context.Context, filter entity.LoanProductsInput) (result int64, err error) {
+	result, err = s.loanProductRepo.CountLoanProducts(ctx, filter)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (s *loanProductService) LoanProduct(ctx context.Context, filter entity.LoanProductInput) (result entity.LoanProduct, err error) {
+	result, err = s.loanProductRepo.LoanProduct(ctx, filter)
+	if err != nil {
+		return
+	}
+	return
+}
+
+type loanProductService struct {
+	loanProductRepo db.LoanProductRepository
+}
+
+type InitiatorLoanProduct func(s *loanProductService) *loanProductService
+
+func NewLoanProductService() InitiatorLoanProduct {
+	return func(s *loanProductService) *loanProductService {
+		return s
+	}
+}
+
+func (i InitiatorLoanProduct) SetRepository(loanProductRepository db.LoanProductRepository) InitiatorLoanProduct {
+	return func(s *loanProductService) *loanProductService {
+		i(s).loanProductRepo = loanProductRepository
+		return s
+	}
+}
+
+func (i InitiatorLoanProduct) Build() LoanProductService {
+	return i(&loanProductService{})
+}
@@ -0,0 +1,642 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image/png"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"gorm.io/gorm"
+)
+
+// maxSignatureImageSize bounds the decoded signature png.
+const maxSignatureImageSize = 1 << 20
+
+// agreementMailLinkTTL keeps the agreement links sent in notifications usable for a few days.
+const agreementMailLinkTTL = 72 * time.Hour
+
+// agreementSigningTTL is how long a signing link works, an expired link is renewed
+// when the agreement is sent for signing again or the signer asks for a new one.
+const agreementSigningTTL = 14 * 24 * time.Hour
+
+// requestAgreementSignatures opens one signature slot per investor and one for
+// the borrower. It is safe to call more than once for the same loan, the slots are
+// only opened once, an expired pending token is replaced and the borrower is asked
+// again until they signed.
+func (s *loanService) requestAgreementSignatures(ctx context.Context, loan entity.Loan) (result []entity.AgreementSignature, err error) {
+	result, err = s.agreementSignatureRepo.AgreementSignatures(ctx, entity.AgreementSignaturesInput{
+		LoanID: &loan.ID,
+	})
+	if err != nil {
+		return
+	}
+	if len(result) == 0 {
+		result, err = s.openAgreementSignatures(ctx, loan)
+		if err != nil {
+			return
+		}
+	}
+	now := time.Now().UTC()
+	for i := range result {
+		if result[i].Status != entity.SignatureStatusPending || !result[i].IsExpired(now) {
+			continue
+		}
+		err = renewSignatureToken(&result[i], now)
+		if err != nil {
+			return
+		}
+		err = s.agreementSignatureRepo.Update(ctx, &result[i])
+		if err != nil {
+			return
+		}
+	}
+
+	// investors receive their signing link with the agreement notification,
+	// the borrower is asked on their own
+	for _, signature := range result {
+		if signature.SignerType != entity.SignerTypeBorrower || signature.Status != entity.SignatureStatusPending {
+			continue
+		}
+		err = s.notifySigner(ctx, loan, signature)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// notifySigner sends the signing link of a pending signature on the channels the
+// signer prefers and can be reached on.
+func (s *loanService) notifySigner(ctx context.Context, loan entity.Loan, signature entity.AgreementSignature) (err error) {
+	recipient := entity.NotificationRecipient{
+		Type:  entity.NotificationRecipientTypeInvestor,
+		ID:    signature.SignerID,
+		Email: signature.SignerEmail,
+	}
+	agreementURL := ""
+	audience := entity.DownloadAudienceInvestor
+	if signature.SignerType == entity.SignerTypeBorrower {
+		borrower, errBorrower := s.borrowerRepo.Borrower(ctx, entity.BorrowerInput{
+			ID: &loan.UserID,
+		})
+		if errBorrower != nil && !errors.Is(errBorrower, gorm.ErrRecordNotFound) {
+			err = errBorrower
+			return
+		}
+		recipient = entity.NotificationRecipient{
+			Type:  entity.NotificationRecipientTypeBorrower,
+			ID:    loan.UserID,
+			Email: borrower.Email,
+			Phone: borrower.Phone,
+		}
+		agreementURL = *loan.DraftLoanAgreementLetterURL
+		audience = entity.DownloadAudienceBorrower
+	} else {
+		agreementURL, err = s.agreementLetterURL(ctx, loan, entity.AgreementDocument{
+			InvestorID: &signature.SignerID,
+			Variant:    entity.AggrementLetterVariantDraft,
+		})
+		if err != nil {
+			return
+		}
+	}
+	signerSigningURL, err := signingURL(signature.Token)
+	if err != nil {
+		return
+	}
+	agreementURL, err = s.fileService.DownloadURL(ctx, agreementURL, entity.DownloadGrant{
+		Audience:  audience,
+		SubjectID: signature.SignerID,
+		TTL:       agreementMailLinkTTL,
+	})
+	if err != nil {
+		return
+	}
+	// a renewed token changes the key, so the new link is sent while a retry does not send it twice
+	key := fmt.Sprintf("%s:%d", entity.NotificationTemplateSignatureRequest, signature.ID)
+	if signature.TokenExpiresAt != nil {
+		key = fmt.Sprintf("%s:%d", key, signature.TokenExpiresAt.Unix())
+	}
+	_, err = s.notificationService.Notify(ctx, entity.NotifyInput{
+		Key:       key,
+		LoanID:    &loan.ID,
+		Recipient: recipient,
+		Template:  entity.NotificationTemplateSignatureRequest,
+		Data: entity.SignatureRequestTemplateData{
+			SignerName:   signature.SignerName,
+			LoanID:       loan.ID,
+			AgreementURL: agreementURL,
+			SigningURL:   signerSigningURL,
+		},
+	})
+	if err != nil {
+		return
+	}
+	return
+}
+
+// openAgreementSignatures creates the pending signature of every investor and the borrower.
+func (s *loanService) openAgreementSignatures(ctx context.Context, loan entity.Loan) (result []entity.AgreementSignature, err error) {
+	loanInvestments, err := s.loanInvestmentRepo.LoanInvestments(ctx, entity.LoanInvestmentsInput{
+		LoanID: &loan.ID,
+	})
+	if err != nil {
+		return
+	}
+	investorIDs := []int{}
+	seen := map[int]bool{}
+	for _, investment := range loanInvestments {
+		if seen[investment.InvestorID] {
+			continue
+		}
+		seen[investment.InvestorID] = true
+		investorIDs = append(investorIDs, investment.InvestorID)
+	}
+	investors, err := s.investorRepo.Investors(ctx, entity.InvestorsInput{
+		IDs: &investorIDs,
+	})
+	if err != nil {
+		return
+	}
+	signatures := []entity.AgreementSignature{}
+	for _, investor := range investors {
+		signatures = append(signatures, entity.AgreementSignature{
+			LoanID:      loan.ID,
+			SignerType:  entity.SignerTypeInvestor,
+			SignerID:    investor.ID,
+			SignerName:  investor.Email,
+			SignerEmail: investor.Email,
+		})
+	}
+
+	borrower, err := s.borrowerRepo.Borrower(ctx, entity.BorrowerInput{
+		ID: &loan.UserID,
+	})
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return
+	}
+	err = nil
+	if borrower.Name == "" {
+		borrower.Name = strconv.Itoa(loan.UserID)
+	}
+	signatures = append(signatures, entity.AgreementSignature{
+		LoanID:      loan.ID,
+		SignerType:  entity.SignerTypeBorrower,
+		SignerID:    loan.UserID,
+		SignerName:  borrower.Name,
+		SignerEmail: borrower.Email,
+	})
+
+	now := time.Now().UTC()
+	for i := range signatures {
+		err = renewSignatureToken(&signatures[i], now)
+		if err != nil {
+			return
+		}
+		signatures[i].Status = entity.SignatureStatusPending
+		err = s.agreementSignatureRepo.Create(ctx, &signatures[i])
+		if err != nil {
+			return
+		}
+	}
+	result = signatures
+	return
+}
+
+// investorSigningURLs maps investor id to its pending signing link.
+func (s *loanService) investorSigningURLs(ctx context.Context, loanID int) (result map[int]string, err error) {
+	pendingStatus := entity.SignatureStatusPending
+	signatures, err := s.agreementSignatureRepo.AgreementSignatures(ctx, entity.AgreementSignaturesInput{
+		LoanID: &loanID,
+		Status: &pendingStatus,
+	})
+	if err != nil {
+		return
+	}
+	result = map[int]string{}
+	for _, signature := range signatures {
+		if signature.SignerType != entity.SignerTypeInvestor {
+			continue
+		}
+		result[signature.SignerID], err = signingURL(signature.Token)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (s *loanService) AgreementSigningRequest(ctx context.Context, token string) (result entity.AgreementSigningRequest, err error) {
+	signature, err := s.agreementSignatureRepo.AgreementSignature(ctx, entity.AgreementSignatureInput{
+		Token: &token,
+	})
+	if err != nil {
+		return
+	}
+	if signature.Status == entity.SignatureStatusPending && signature.IsExpired(time.Now().UTC()) {
+		err = errors.New("signing link has expired, ask for a new one")
+		return
+	}
+	loan, err := s.loanRepo.Loan(ctx, entity.LoanInput{
+		ID: &signature.LoanID,
+	})
+	if err != nil {
+		return
+	}
+	result.Signature = signature
+	if loan.DraftLoanAgreementLetterURL == nil {
+		return
+	}
+	// the borrower reads the letter of the whole loan, an investor only the letter of their own participation
+	agreementURL := *loan.DraftLoanAgreementLetterURL
+	audience := entity.DownloadAudienceBorrower
+	if signature.SignerType == entity.SignerTypeInvestor {
+		audience = entity.DownloadAudienceInvestor
+		agreementURL, err = s.agreementLetterURL(ctx, loan, entity.AgreementDocument{
+			InvestorID: &signature.SignerID,
+			Variant:    entity.AggrementLetterVariantDraft,
+		})
+		if err != nil {
+			return
+		}
+	}
+	result.AgreementURL, err = s.fileService.DownloadURL(ctx, agreementURL, entity.DownloadGrant{
+		Audience:  audience,
+		SubjectID: signature.SignerID,
+	})
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (s *loanService) SignAgreement(ctx context.Context, input entity.SignAgreementInput) (result entity.AgreementSignature, err error) {
+	if input.Token == "" {
+		err = errors.New("token is required")
+		return
+	}
+	typedName := strings.TrimSpace(input.TypedName)
+	if typedName == "" {
+		err = errors.New("typedName is required")
+		return
+	}
+	if input.SignatureImage == "" {
+		err = errors.New("signatureImage is required")
+		return
+	}
+	image, err := decodeSignatureImage(input.SignatureImage)
+	if err != nil {
+		return
+	}
+
+	signature, err := s.agreementSignatureRepo.AgreementSignature(ctx, entity.AgreementSignatureInput{
+		Token: &input.Token,
+	})
+	if err != nil {
+		return
+	}
+	if signature.Status == entity.SignatureStatusSigned {
+		err = errors.New("agreement is already signed")
+		return
+	}
+	if signature.IsExpired(time.Now().UTC()) {
+		err = errors.New("signing link has expired, ask for a new one")
+		return
+	}
+	loan, err := s.loanRepo.Loan(ctx, entity.LoanInput{
+		ID: &signature.LoanID,
+	})
+	if err != nil {
+		return
+	}
+	if loan.Status != entity.LoanStatusApproved {
+		err = errors.New("only approved loan agreement can be signed")
+		return
+	}
+
+	// every attempt writes its own image, so a request that loses the race below
+	// never overwrites the image of the one that signed
+	imageID, err := newSignatureToken()
+	if err != nil {
+		return
+	}
+	imagePath := path.Join(entity.SignatureStoragePrefix, signature.Token+"-"+imageID[:16]+".png")
+	err = s.storage.Put(ctx, imagePath, bytes.NewReader(image), int64(len(image)), "image/png")
+	if err != nil {
+		return
+	}
+	signedAt := time.Now().UTC()
+	signature.TypedName = typedName
+	signature.SignatureImagePath = imagePath
+	signature.SignedAt = &signedAt
+	signature.IPAddress = input.IPAddress
+	signature.UserAgent = input.UserAgent
+	// the last signature records the signed agreement in the outbox, so a failed render is retried
+	// and two parties signing last at the same time do not both render it
+	_, err = s.agreementSignatureRepo.Sign(ctx, &signature, agreementSignedEvent(loan.ID))
+	if err != nil {
+		if errDelete := s.storage.Delete(ctx, imagePath); errDelete != nil {
+			log.Printf("failed to delete signature image %s: %v", imagePath, errDelete)
+		}
+		return
+	}
+	result = signature
+	return
+}
+
+// RenewSigningLink replaces an expired signing link and sends the new one to the
+// signer only, the link is never returned to whoever holds the old token.
+func (s *loanService) RenewSigningLink(ctx context.Context, token string) (err error) {
+	if token == "" {
+		err = errors.New("token is required")
+		return
+	}
+	signature, err := s.agreementSignatureRepo.AgreementSignature(ctx, entity.AgreementSignatureInput{
+		Token: &token,
+	})
+	if err != nil {
+		return
+	}
+	if signature.Status == entity.SignatureStatusSigned {
+		err = errors.New("agreement is already signed")
+		return
+	}
+	now := time.Now().UTC()
+	if !signature.IsExpired(now) {
+		err = errors.New("signing link has not expired yet")
+		return
+	}
+	loan, err := s.loanRepo.Loan(ctx, entity.LoanInput{
+		ID: &signature.LoanID,
+	})
+	if err != nil {
+		return
+	}
+	if loan.Status != entity.LoanStatusApproved || loan.DraftLoanAgreementLetterURL == nil {
+		err = errors.New("only approved loan agreement can be signed")
+		return
+	}
+	err = renewSignatureToken(&signature, now)
+	if err != nil {
+		return
+	}
+	// only one of two requests renewing the same link at once sends a new one
+	renewed, err := s.agreementSignatureRepo.RenewToken(ctx, &signature, token)
+	if err != nil {
+		return
+	}
+	if !renewed {
+		err = errors.New("signing link was already renewed")
+		return
+	}
+	err = s.notifySigner(ctx, loan, signature)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (s *loanService) AgreementSignatures(ctx context.Context, loanID int) (result []entity.AgreementSignature, err error) {
+	result, err = s.agreementSignatureRepo.AgreementSignatures(ctx, entity.AgreementSignaturesInput{
+		LoanID: &loanID,
+	})
+	if err != nil {
+		return
+	}
+	return
+}
+
+// agreementSignedEvent is recorded once per loan when its last party signed.
+func agreementSignedEvent(loanID int) *entity.OutboxEvent {
+	return &entity.OutboxEvent{
+		Type:   entity.OutboxEventTypeAgreementSigned,
+		LoanID: loanID,
+		Key:    fmt.Sprintf("%s:%d", entity.OutboxEventTypeAgreementSigned, loanID),
+		Payload: entity.OutboxPayload{
+			LoanID: loanID,
+		},
+	}
+}
+
+// finalizeSignedAgreement renders the agreement with every captured signature
+// and stores it as the loan agreement letter. An agreement finalized by an
+// earlier attempt is kept.
+func (s *loanService) finalizeSignedAgreement(ctx context.Context, loan entity.Loan) (err error) {
+	if loan.LoanAgreementLetterURL != nil && *loan.LoanAgreementLetterURL != "" {
+		return
+	}
+	document, err := s.issueLoanAgreement(ctx, loan, entity.AgreementDocument{
+		Variant:  entity.AggrementLetterVariantSign,
+		Language: entity.DefaultAgreementLanguage,
+		Format:   entity.DocumentFormatPDF,
+	})
+	if err != nil {
+		return
+	}
+	loan.LoanAgreementLetterURL = &document.URL
+	err = s.loanRepo.Update(ctx, &loan)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// checkAgreementSigned makes sure every party signed and the final agreement exists.
+func (s *loanService) checkAgreementSigned(ctx context.Context, loan entity.Loan) (err error) {
+	total, err := s.agreementSignatureRepo.CountAgreementSignatures(ctx, entity.AgreementSignaturesInput{
+		LoanID: &loan.ID,
+	})
+	if err != nil {
+		return
+	}
+	if total == 0 {
+		err = errors.New("loan agreement has not been sent for signing")
+		return
+	}
+	pendingStatus := entity.SignatureStatusPending
+	pending, err := s.agreementSignatureRepo.CountAgreementSignatures(ctx, entity.AgreementSignaturesInput{
+		LoanID: &loan.ID,
+		Status: &pendingStatus,
+	})
+	if err != nil {
+		return
+	}
+	if pending > 0 {
+		err = fmt.Errorf("loan agreement is waiting for %d signature(s)", pending)
+		return
+	}
+	if loan.LoanAgreementLetterURL == nil || *loan.LoanAgreementLetterURL == "" {
+		// signatures completed before the outbox recorded them get their event here
+		err = s.outboxEventRepo.Create(ctx, agreementSignedEvent(loan.ID))
+		if err != nil {
+			return
+		}
+		err = errors.New("signed loan agreement is not available yet, it is being generated")
+		return
+	}
+	return
+}
+
+// attachWetSignedAgreement accepts a scan of the printed and hand signed draft
+// instead of the electronic signatures. The scan has to be a pdf uploaded for
+// this loan, with the same number of pages as the generated draft.
+func (s *loanService) attachWetSignedAgreement(ctx context.Context, loan *entity.Loan, letterURL string) (err error) {
+	file, err := s.fileService.ResolveFile(ctx, letterURL)
+	if err != nil {
+		err = fmt.Errorf("loanAgreementLetterUrl is invalid: %w", err)
+		return
+	}
+	if file.Purpose != "" && file.Purpose != entity.FilePurposeSignedAgreement {
+		err = errors.New("loanAgreementLetterUrl is not a signed agreement upload")
+		return
+	}
+	if file.LoanID == nil || *file.LoanID != loan.ID {
+		err = errors.New("loan agreement letter was not uploaded for this loan")
+		return
+	}
+	if file.UploadedByEmployeeID == nil {
+		err = errors.New("loan agreement letter has no uploading employee")
+		return
+	}
+	pages, err := s.fileService.PDFPageCount(ctx, file)
+	if err != nil {
+		err = fmt.Errorf("loanAgreementLetterUrl is invalid: %w", err)
+		return
+	}
+	draftPages, err := s.draftAgreementPageCount(ctx, loan.ID)
+	if err != nil {
+		return
+	}
+	if draftPages != 0 && pages != draftPages {
+		err = fmt.Errorf("loan agreement letter has %d page(s), the generated draft has %d", pages, draftPages)
+		return
+	}
+
+	trimmedURL := strings.TrimSpace(letterURL)
+	loan.LoanAgreementLetterURL = &trimmedURL
+	loan.LoanAgreementFileID = &file.ID
+	loan.LoanAgreementUploadedByEmployeeID = file.UploadedByEmployeeID
+	return
+}
+
+// draftAgreementPageCount counts the pages of the latest default pdf draft,
+// zero when no draft was generated for the loan.
+func (s *loanService) draftAgreementPageCount(ctx context.Context, loanID int) (result int, err error) {
+	variant := entity.AggrementLetterVariantDraft
+	language := entity.DefaultAgreementLanguage
+	format := entity.DocumentFormatPDF
+	documents, err := s.agreementDocumentRepo.AgreementDocuments(ctx, entity.AgreementDocumentsInput{
+		LoanID:   &loanID,
+		Variant:  &variant,
+		Language: &language,
+		Format:   &format,
+	})
+	if err != nil {
+		return
+	}
+	var draft *entity.AgreementDocument
+	for i := range documents {
+		if documents[i].InvestorID == nil {
+			draft = &documents[i]
+		}
+	}
+	if draft == nil {
+		return
+	}
+	result, err = pdfPageCount(ctx, s.storage, draft.Path)
+	if err != nil {
+		err = fmt.Errorf("draft agreement: %w", err)
+		return
+	}
+	return
+}
+
+// agreementLetterSignatures lists the captured signatures in the shape the pdf expects.
+func (s *loanService) agreementLetterSignatures(ctx context.Context, loanID int) (result []entity.AgreementLetterSignature, err error) {
+	signatures, err := s.agreementSignatureRepo.AgreementSignatures(ctx, entity.AgreementSignaturesInput{
+		LoanID: &loanID,
+	})
+	if err != nil {
+		return
+	}
+	result = []entity.AgreementLetterSignature{}
+	for _, signature := range signatures {
+		if signature.SignedAt == nil {
+			err = errors.New("loan agreement is not fully signed yet")
+			return
+		}
+		result = append(result, entity.AgreementLetterSignature{
+			SignerRole: string(signature.SignerType),
+			SignerName: signature.SignerName,
+			TypedName:  signature.TypedName,
+			ImagePath:  signature.SignatureImagePath,
+			SignedAt:   *signature.SignedAt,
+			IPAddress:  signature.IPAddress,
+		})
+	}
+	return
+}
+
+func decodeSignatureImage(raw string) (result []byte, err error) {
+	if i := strings.Index(raw, ","); strings.HasPrefix(raw, "data:") && i >= 0 {
+		raw = raw[i+1:]
+	}
+	result, err = base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		err = errors.New("signatureImage must be base64 encoded")
+		return
+	}
+	if len(result) > maxSignatureImageSize {
+		err = errors.New("signatureImage is too large")
+		return
+	}
+	config, err := png.DecodeConfig(bytes.NewReader(result))
+	if err != nil {
+		err = errors.New("signatureImage must be a png image")
+		return
+	}
+	if int64(config.Width)*int64(config.Height) > maxImagePixels {
+		err = errors.New("signatureImage is too large")
+		return
+	}
+	return
+}
+
+// renewSignatureToken gives the signature a new token valid for agreementSigningTTL.
+func renewSignatureToken(signature *entity.AgreementSignature, now time.Time) (err error) {
+	signature.Token, err = newSignatureToken()
+	if err != nil {
+		return
+	}
+	expiresAt := now.Add(agreementSigningTTL)
+	signature.TokenExpiresAt = &expiresAt
+	return
+}
+
+func newSignatureToken() (result string, err error) {
+	b := make([]byte, 32)
+	_, err = rand.Read(b)
+	if err != nil {
+		return
+	}
+	result = hex.EncodeToString(b)
+	return
+}
+
+func signingURL(token string) (result string, err error) {
+	u, _ := url.Parse(os.Getenv("APP_HOST"))
+	result, err = url.JoinPath(u.String(), "signatures", token)
+	return
+}
@@ -0,0 +1,360 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/repository/db/sqlite"
+	"github.com/adityaokke/test-amartha/internal/repository/db/sqlite/migration"
+	driver "github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestCalculateTotalInterest(t *testing.T) {
+	tests := []struct {
+		name string
+		loan entity.Loan
+		want string
+	}{
+		{
+			name: "flat weekly",
+			loan: entity.Loan{Amount: 1000000, Rate: 12, Term: 50, TermUnit: entity.TermUnitWeek, InterestMethod: entity.InterestMethodFlat},
+			want: "115384.62",
+		},
+		{
+			name: "flat monthly",
+			loan: entity.Loan{Amount: 12000000, Rate: 12, Term: 12, TermUnit: entity.TermUnitMonth, InterestMethod: entity.InterestMethodFlat},
+			want: "1440000",
+		},
+		{
+			name: "loan without term unit is weekly",
+			loan: entity.Loan{Amount: 1000000, Rate: 12, Term: 50},
+			want: "115384.62",
+		},
+		{
+			name: "effective monthly",
+			loan: entity.Loan{Amount: 12000000, Rate: 12, Term: 12, TermUnit: entity.TermUnitMonth, InterestMethod: entity.InterestMethodEffective},
+			want: "794225.57",
+		},
+		{
+			name: "effective weekly",
+			loan: entity.Loan{Amount: 1000000, Rate: 18, Term: 50, TermUnit: entity.TermUnitWeek, InterestMethod: entity.InterestMethodEffective},
+			want: "90758.99",
+		},
+		{
+			name: "effective without interest",
+			loan: entity.Loan{Amount: 1000000, Rate: 0, Term: 10, TermUnit: entity.TermUnitMonth, InterestMethod: entity.InterestMethodEffective},
+			want: "0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := calculateTotalInterest(tt.loan).Round(2).String()
+			if got != tt.want {
+				t.Errorf("calculateTotalInterest() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalculateLoanFees(t *testing.T) {
+	tests := []struct {
+		name      string
+		fees      []entity.LoanProductFee
+		amount    int
+		wantFees  []entity.LoanFee
+		wantTotal int
+	}{
+		{
+			name:      "no fees",
+			amount:    1000000,
+			wantFees:  []entity.LoanFee{},
+			wantTotal: 0,
+		},
+		{
+			name:      "flat fee",
+			fees:      []entity.LoanProductFee{{Name: "admin", Type: entity.FeeTypeFlat, Value: 25000}},
+			amount:    1000000,
+			wantFees:  []entity.LoanFee{{Name: "admin", Type: entity.FeeTypeFlat, Value: 25000, Amount: 25000}},
+			wantTotal: 25000,
+		},
+		{
+			name:      "percent fee is rounded to whole rupiah",
+			fees:      []entity.LoanProductFee{{Name: "platform", Type: entity.FeeTypePercent, Value: 1.5}},
+			amount:    1234567,
+			wantFees:  []entity.LoanFee{{Name: "platform", Type: entity.FeeTypePercent, Value: 1.5, Amount: 18519}},
+			wantTotal: 18519,
+		},
+		{
+			name: "flat and percent fees add up",
+			fees: []entity.LoanProductFee{
+				{Name: "admin", Type: entity.FeeTypeFlat, Value: 10000},
+				{Name: "platform", Type: entity.FeeTypePercent, Value: 2},
+			},
+			amount: 5000000,
+			wantFees: []entity.LoanFee{
+				{Name: "admin", Type: entity.FeeTypeFlat, Value: 10000, Amount: 10000},
+				{Name: "platform", Type: entity.FeeTypePercent, Value: 2, Amount: 100000},
+			},
+			wantTotal: 110000,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotFees, gotTotal := calculateLoanFees(tt.fees, tt.amount)
+			if !reflect.DeepEqual(gotFees, tt.wantFees) {
+				t.Errorf("calculateLoanFees() fees = %+v, want %+v", gotFees, tt.wantFees)
+			}
+			if gotTotal != tt.wantTotal {
+				t.Errorf("calculateLoanFees() total = %d, want %d", gotTotal, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestEligibilityActiveLoan(t *testing.T) {
+	engine := NewEligibilityEngine().Build()
+	product := entity.LoanProduct{Name: "weekly", MinAmount: 1000000, MaxAmount: 5000000, AllowedTerms: []int{10}, TermUnits: []entity.TermUnit{entity.TermUnitWeek}}
+	disbursedAt := time.Now().UTC().AddDate(-1, 0, 0)
+
+	tests := []struct {
+		name         string
+		loan         entity.Loan
+		wantEligible bool
+	}{
+		{name: "approved loan", loan: entity.Loan{ID: 1, Amount: 1000000, Status: entity.LoanStatusApproved}, wantEligible: false},
+		{name: "expired loan", loan: entity.Loan{ID: 1, Amount: 1000000, Status: entity.LoanStatusExpired}, wantEligible: true},
+		{name: "repaid loan", loan: entity.Loan{ID: 1, Amount: 1000000, Status: entity.LoanStatusDisbursed, Term: 10, TermUnit: entity.TermUnitWeek, DisbursedAt: &disbursedAt}, wantEligible: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := engine.Check(context.Background(), entity.EligibilityInput{
+				Product:       product,
+				Amount:        1000000,
+				Term:          10,
+				TermUnit:      entity.TermUnitWeek,
+				PreviousLoans: []entity.Loan{tt.loan},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Eligible != tt.wantEligible {
+				t.Errorf("Check() eligible = %v, want %v, reasons %+v", got.Eligible, tt.wantEligible, got.Reasons)
+			}
+			if tt.loan.Status == entity.LoanStatusExpired && got.OutstandingExposure != 0 {
+				t.Errorf("Check() counts %d of an expired loan as outstanding", got.OutstandingExposure)
+			}
+		})
+	}
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	conn, err := gorm.Open(driver.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	migration.Migrate(conn)
+	return conn
+}
+
+func TestExpireLoans(t *testing.T) {
+	conn := newTestDB(t)
+	loanRepo := sqlite.NewLoanRepository().SetDBConnection(conn).Build()
+	bus := NewEventBus().Build()
+	expiredEvents := []entity.DomainEvent{}
+	bus.Subscribe("test", func(ctx context.Context, event entity.DomainEvent) error {
+		expiredEvents = append(expiredEvents, event)
+		return nil
+	}, entity.DomainEventTypeLoanExpired)
+	s := NewLoanService().SetRepository(loanRepo).SetEventBus(bus).Build()
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	passed := now.Add(-time.Hour)
+	open := now.Add(time.Hour)
+	loans := map[string]*entity.Loan{
+		"past deadline":          {UserID: 1, Amount: 1000000, InvestedAmount: 400000, Status: entity.LoanStatusApproved, FundingDeadline: &passed},
+		"funding window open":    {UserID: 2, Amount: 1000000, Status: entity.LoanStatusApproved, FundingDeadline: &open},
+		"fully funded in time":   {UserID: 3, Amount: 1000000, InvestedAmount: 1000000, Status: entity.LoanStatusApproved, FundingDeadline: &passed, FullyInvestedAt: &passed},
+		"without funding window": {UserID: 4, Amount: 1000000, Status: entity.LoanStatusApproved},
+		"proposed, not approved": {UserID: 5, Amount: 1000000, Status: entity.LoanStatusProposed},
+	}
+	for _, loan := range loans {
+		if err := loanRepo.Create(ctx, loan); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expired, err := s.ExpireLoans(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 1 || expired[0].ID != loans["past deadline"].ID {
+		t.Fatalf("ExpireLoans() = %+v, want loan %d", expired, loans["past deadline"].ID)
+	}
+	if len(expiredEvents) != 1 || expiredEvents[0].Loan.Status != entity.LoanStatusExpired {
+		t.Errorf("published %+v, want one LOAN_EXPIRED event of the expired loan", expiredEvents)
+	}
+	for name, loan := range loans {
+		got, err := loanRepo.Loan(ctx, entity.LoanInput{ID: &loan.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := loan.Status
+		if name == "past deadline" {
+			want = entity.LoanStatusExpired
+		}
+		if got.Status != want {
+			t.Errorf("%s: status = %s, want %s", name, got.Status, want)
+		}
+	}
+
+	// a second run finds nothing left to expire
+	expired, err = s.ExpireLoans(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 0 {
+		t.Errorf("second ExpireLoans() = %+v, want none", expired)
+	}
+}
+
+func TestFindPhotoProofDuplicates(t *testing.T) {
+	conn := newTestDB(t)
+	fileRepo := sqlite.NewFileRepository().SetDBConnection(conn).Build()
+	loanRepo := sqlite.NewLoanRepository().SetDBConnection(conn).Build()
+	fileService := NewFileService().
+		SetRepository(fileRepo).
+		SetFileVariantRepository(sqlite.NewFileVariantRepository().SetDBConnection(conn).Build()).
+		Build()
+	s := NewLoanService().
+		SetRepository(loanRepo).
+		SetFileService(fileService).
+		SetPhotoProofPolicy(entity.PhotoProofPolicy{DuplicateMaxDistance: 6}).
+		Build().(*loanService)
+	ctx := context.Background()
+
+	const hash = uint64(0x0123456789abcdef)
+	// flips bits in every band, so no band matches exactly
+	spread := func(distance int) uint64 {
+		flipped := hash
+		for i := 0; i < distance; i++ {
+			flipped ^= 1 << ((i%4)*16 + i/4)
+		}
+		return flipped
+	}
+	photoProofs := map[string]uint64{
+		"near":      spread(6),
+		"far":       spread(8),
+		"identical": hash,
+	}
+	loanIDs := map[string]int{}
+	for name, photoHash := range photoProofs {
+		file := entity.File{Name: name + ".jpg", ContentType: "image/jpeg"}
+		setPerceptualHash(&file, photoHash)
+		if err := fileRepo.Create(ctx, &file); err != nil {
+			t.Fatal(err)
+		}
+		loan := entity.Loan{UserID: 1, Status: entity.LoanStatusDisbursed, PhotoProofFileID: &file.ID}
+		if err := loanRepo.Create(ctx, &loan); err != nil {
+			t.Fatal(err)
+		}
+		loanIDs[name] = loan.ID
+	}
+
+	photoProof := entity.File{Name: "new.jpg", ContentType: "image/jpeg"}
+	setPerceptualHash(&photoProof, hash)
+	if err := fileRepo.Create(ctx, &photoProof); err != nil {
+		t.Fatal(err)
+	}
+	duplicates, err := s.findPhotoProofDuplicates(ctx, 100, photoProof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[int]int{}
+	for _, duplicate := range duplicates {
+		got[duplicate.DuplicateOfLoanID] = duplicate.Distance
+	}
+	want := map[int]int{loanIDs["identical"]: 0, loanIDs["near"]: 6}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findPhotoProofDuplicates() found loans with distances %v, want %v", got, want)
+	}
+}
+
+func TestRenewSigningLinkRejected(t *testing.T) {
+	conn := newTestDB(t)
+	loanRepo := sqlite.NewLoanRepository().SetDBConnection(conn).Build()
+	signatureRepo := sqlite.NewAgreementSignatureRepository().SetDBConnection(conn).Build()
+	s := NewLoanService().SetRepository(loanRepo).SetAgreementSignatureRepository(signatureRepo).Build()
+	ctx := context.Background()
+
+	draftURL := "agreements/draft.pdf"
+	approved := entity.Loan{UserID: 1, Amount: 1000000, Status: entity.LoanStatusApproved, DraftLoanAgreementLetterURL: &draftURL}
+	expired := entity.Loan{UserID: 2, Amount: 1000000, Status: entity.LoanStatusExpired, DraftLoanAgreementLetterURL: &draftURL}
+	for _, loan := range []*entity.Loan{&approved, &expired} {
+		if err := loanRepo.Create(ctx, loan); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now().UTC()
+	passed := now.Add(-time.Hour)
+	open := now.Add(time.Hour)
+	tests := []struct {
+		name      string
+		signature entity.AgreementSignature
+		wantErr   string
+	}{
+		{
+			name:      "link still valid",
+			signature: entity.AgreementSignature{LoanID: approved.ID, Token: "valid", TokenExpiresAt: &open, Status: entity.SignatureStatusPending},
+			wantErr:   "signing link has not expired yet",
+		},
+		{
+			name:      "already signed",
+			signature: entity.AgreementSignature{LoanID: approved.ID, Token: "signed", TokenExpiresAt: &passed, Status: entity.SignatureStatusSigned},
+			wantErr:   "agreement is already signed",
+		},
+		{
+			name:      "loan no longer approved",
+			signature: entity.AgreementSignature{LoanID: expired.ID, Token: "expired-loan", TokenExpiresAt: &passed, Status: entity.SignatureStatusPending},
+			wantErr:   "only approved loan agreement can be signed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := signatureRepo.Create(ctx, &tt.signature); err != nil {
+				t.Fatal(err)
+			}
+			err := s.RenewSigningLink(ctx, tt.signature.Token)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("RenewSigningLink() error = %v, want %q", err, tt.wantErr)
+			}
+			got, err := signatureRepo.AgreementSignature(ctx, entity.AgreementSignatureInput{ID: &tt.signature.ID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Token != tt.signature.Token {
+				t.Errorf("token = %q, want it kept as %q", got.Token, tt.signature.Token)
+			}
+		})
+	}
+
+	// a link renewed in the meantime is not renewed again from its old token
+	stale := entity.AgreementSignature{LoanID: approved.ID, Token: "renewed-elsewhere", TokenExpiresAt: &open, Status: entity.SignatureStatusPending}
+	if err := signatureRepo.Create(ctx, &stale); err != nil {
+		t.Fatal(err)
+	}
+	renewed, err := signatureRepo.RenewToken(ctx, &entity.AgreementSignature{ID: stale.ID, Token: "new", TokenExpiresAt: &open}, "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renewed {
+		t.Error("RenewToken() from a stale token = true, want false")
+	}
+}
@@ -0,0 +1,457 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/repository/db"
+	"github.com/adityaokke/test-amartha/internal/repository/notification"
+	"github.com/adityaokke/test-amartha/internal/repository/storage"
+	"gorm.io/gorm"
+)
+
+type NotificationService interface {
+	Notify(ctx context.Context, input entity.NotifyInput) (result []entity.Notification, err error)
+	Dispatch(ctx context.Context) (processed int, err error)
+	Start(ctx context.Context)
+
+	Notifications(ctx context.Context, filter entity.NotificationsInput) (result []entity.Notification, err error)
+	ResendNotification(ctx context.Context, id int) (result entity.Notification, err error)
+
+	NotificationPreference(ctx context.Context, recipientType entity.NotificationRecipientType, recipientID int) (result entity.NotificationPreference, err error)
+	SetNotificationPreference(ctx context.Context, input entity.SetNotificationPreferenceInput) (result entity.NotificationPreference, err error)
+
+	PreviewNotification(ctx context.Context, input entity.PreviewNotificationInput) (result entity.NotificationMessage, err error)
+}
+
+// Notify queues a notification on every channel the recipient prefers, can be reached on and has
+// the template. A recipient without preferences, or out of reach on every preferred channel, is notified by email.
+// Each channel is queued once per key, so a retried caller does not notify the recipient twice.
+func (s *notificationService) Notify(ctx context.Context, input entity.NotifyInput) (result []entity.Notification, err error) {
+	preference, err := s.NotificationPreference(ctx, input.Recipient.Type, input.Recipient.ID)
+	if err != nil {
+		return
+	}
+	phone := input.Recipient.Phone
+	if preference.Phone != nil && *preference.Phone != "" {
+		phone = *preference.Phone
+	}
+	addresses := map[entity.NotificationChannel]string{
+		entity.NotificationChannelEmail:    input.Recipient.Email,
+		entity.NotificationChannelSMS:      phone,
+		entity.NotificationChannelWhatsApp: phone,
+	}
+	if preference.PushToken != nil {
+		addresses[entity.NotificationChannelPush] = *preference.PushToken
+	}
+
+	channels := []entity.NotificationChannel{}
+	for _, channel := range preference.Channels {
+		if addresses[channel] != "" && s.notificationApi.HasTemplate(channel, input.Template) {
+			channels = append(channels, channel)
+		}
+	}
+	if len(channels) == 0 && input.Recipient.Email != "" {
+		channels = append(channels, entity.NotificationChannelEmail)
+	}
+	if len(channels) == 0 {
+		log.Printf("notification: %s %s %d can not be reached on any channel", input.Template, input.Recipient.Type, input.Recipient.ID)
+		return
+	}
+
+	language := preference.Language
+	if language == "" {
+		language = entity.DefaultNotificationLanguage
+	}
+	for _, channel := range channels {
+		var message entity.NotificationMessage
+		message, err = s.notificationApi.Render(ctx, channel, input.Template, language, input.Data)
+		if err != nil {
+			return
+		}
+		item := entity.Notification{
+			LoanID:        input.LoanID,
+			Channel:       channel,
+			RecipientType: input.Recipient.Type,
+			RecipientID:   input.Recipient.ID,
+			Recipient:     addresses[channel],
+			Template:      input.Template,
+			Language:      message.Language,
+			Subject:       message.Subject,
+			Body:          message.Body,
+			TextBody:      message.TextBody,
+		}
+		if channel == entity.NotificationChannelEmail {
+			item.Attachments = input.Attachments
+		}
+		if input.Key != "" {
+			key := fmt.Sprintf("%s:%s", input.Key, channel)
+			item.Key = &key
+		}
+		err = s.notificationRepo.Create(ctx, &item)
+		if err != nil {
+			return
+		}
+		if item.ID == 0 {
+			item, err = s.notificationRepo.Notification(ctx, entity.NotificationInput{
+				Key: item.Key,
+			})
+			if err != nil {
+				return
+			}
+		}
+		result = append(result, item)
+	}
+	wakeUp(s.wake)
+	return
+}
+
+// Dispatch sends every notification that is due once. A failed send is retried after a backoff
+// that doubles with every attempt, the notification is marked DEAD when no attempt is left.
+func (s *notificationService) Dispatch(ctx context.Context) (processed int, err error) {
+	return s.dispatcher().dispatch(ctx)
+}
+
+func (s *notificationService) dispatcher() dispatcher[entity.Notification] {
+	return dispatcher[entity.Notification]{
+		name:         "notification",
+		due:          s.notificationRepo.DueNotifications,
+		claim:        s.notificationRepo.Claim,
+		run:          s.runNotification,
+		finish:       s.notificationRepo.Update,
+		pollInterval: s.policy.PollInterval,
+		lockTimeout:  s.policy.LockTimeout,
+		wake:         s.wake,
+	}
+}
+
+func (s *notificationService) runNotification(ctx context.Context, item *entity.Notification) {
+	item.Attempts++
+	messageID, sendErr := s.send(ctx, *item)
+	finishedAt := time.Now().UTC()
+	item.LockedAt = nil
+	if sendErr == nil {
+		item.Status = entity.NotificationStatusSent
+		item.SentAt = &finishedAt
+		item.ProviderMessageID = &messageID
+		return
+	}
+	log.Printf("notification: %d %s to %s: attempt %d: %v", item.ID, item.Channel, item.Recipient, item.Attempts, sendErr)
+	lastError := sendErr.Error()
+	item.LastError = &lastError
+	item.Status = entity.NotificationStatusPending
+	item.AvailableAt = finishedAt.Add(retryBackoff(item.Attempts, s.policy.Backoff, s.policy.MaxBackoff))
+	if item.Attempts >= s.policy.MaxAttempts {
+		item.Status = entity.NotificationStatusDead
+	}
+}
+
+// send turns a panic of a provider into an error so the notification is retried like any other failure.
+// Attachments are read from storage first, one that can not be read fails the attempt.
+func (s *notificationService) send(ctx context.Context, item entity.Notification) (messageID string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	attachments := make([]entity.NotificationAttachment, len(item.Attachments))
+	for i, attachment := range item.Attachments {
+		attachment.Content, err = readObject(ctx, s.storage, attachment.StorageKey)
+		if err != nil {
+			err = fmt.Errorf("attachment %s: %w", attachment.Name, err)
+			return
+		}
+		attachments[i] = attachment
+	}
+	return s.notificationApi.Send(ctx, entity.NotificationMessage{
+		Channel:     item.Channel,
+		To:          item.Recipient,
+		Template:    item.Template,
+		Language:    item.Language,
+		Subject:     item.Subject,
+		Body:        item.Body,
+		TextBody:    item.TextBody,
+		Attachments: attachments,
+	})
+}
+
+// Start dispatches due notifications every poll interval, and right away when one is queued, until ctx is done.
+func (s *notificationService) Start(ctx context.Context) {
+	s.dispatcher().start(ctx)
+}
+
+func (s *notificationService) Notifications(ctx context.Context, filter entity.NotificationsInput) (result []entity.Notification, err error) {
+	result, err = s.notificationRepo.Notifications(ctx, filter)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// ResendNotification gives a DEAD notification a fresh set of attempts. A SENT notification is copied
+// into a new one, so the log keeps the delivery that was already made.
+func (s *notificationService) ResendNotification(ctx context.Context, id int) (result entity.Notification, err error) {
+	result, err = s.notificationRepo.Notification(ctx, entity.NotificationInput{
+		ID: &id,
+	})
+	if err != nil {
+		return
+	}
+
+	switch result.Status {
+	case entity.NotificationStatusDead:
+		result.Status = entity.NotificationStatusPending
+		result.Attempts = 0
+		result.AvailableAt = time.Now().UTC()
+		err = s.notificationRepo.Update(ctx, &result)
+		if err != nil {
+			return
+		}
+	case entity.NotificationStatusSent:
+		originalID := result.ID
+		result = entity.Notification{
+			LoanID:        result.LoanID,
+			Channel:       result.Channel,
+			RecipientType: result.RecipientType,
+			RecipientID:   result.RecipientID,
+			Recipient:     result.Recipient,
+			Template:      result.Template,
+			Language:      result.Language,
+			Subject:       result.Subject,
+			Body:          result.Body,
+			TextBody:      result.TextBody,
+			Attachments:   result.Attachments,
+			ResendOfID:    &originalID,
+		}
+		err = s.notificationRepo.Create(ctx, &result)
+		if err != nil {
+			return
+		}
+	default:
+		err = fmt.Errorf("%s notification is already queued", result.Status)
+		return
+	}
+	wakeUp(s.wake)
+	return
+}
+
+// NotificationPreference returns the stored preference of a recipient, or email only when none was set.
+func (s *notificationService) NotificationPreference(ctx context.Context, recipientType entity.NotificationRecipientType, recipientID int) (result entity.NotificationPreference, err error) {
+	result, err = s.notificationPreferenceRepo.NotificationPreference(ctx, entity.NotificationPreferenceInput{
+		RecipientType: &recipientType,
+		RecipientID:   &recipientID,
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+		result = entity.NotificationPreference{
+			RecipientType: recipientType,
+			RecipientID:   recipientID,
+			Channels:      []entity.NotificationChannel{entity.NotificationChannelEmail},
+		}
+	}
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (s *notificationService) SetNotificationPreference(ctx context.Context, input entity.SetNotificationPreferenceInput) (result entity.NotificationPreference, err error) {
+	if !input.RecipientType.IsValid() {
+		err = errors.New("invalid recipient type")
+		return
+	}
+	if input.RecipientID <= 0 {
+		err = errors.New("recipient id is required")
+		return
+	}
+	if len(input.Channels) == 0 {
+		err = errors.New("at least one channel is required")
+		return
+	}
+	channels := []entity.NotificationChannel{}
+	seen := map[entity.NotificationChannel]bool{}
+	for _, channel := range input.Channels {
+		if !channel.IsValid() {
+			err = fmt.Errorf("invalid channel %s", channel)
+			return
+		}
+		if seen[channel] {
+			continue
+		}
+		seen[channel] = true
+		channels = append(channels, channel)
+	}
+
+	if input.Language != "" && !input.Language.IsValid() {
+		err = fmt.Errorf("invalid language %s", input.Language)
+		return
+	}
+
+	result, err = s.NotificationPreference(ctx, input.RecipientType, input.RecipientID)
+	if err != nil {
+		return
+	}
+	result.Channels = channels
+	result.Language = input.Language
+	result.Phone = input.Phone
+	result.PushToken = input.PushToken
+	if result.ID == 0 {
+		err = s.notificationPreferenceRepo.Create(ctx, &result)
+	} else {
+		err = s.notificationPreferenceRepo.Update(ctx, &result)
+	}
+	if err != nil {
+		return
+	}
+	return
+}
+
+// notificationSamples is the data templates are previewed with.
+var notificationSamples = map[entity.NotificationTemplate]any{
+	entity.NotificationTemplateInvestmentAgreement: entity.InvestorAgreementTemplateData{
+		InvestorName: "investor@example.com",
+		InvestedAt:   time.Date(2026, time.March, 2, 9, 30, 0, 0, time.UTC),
+		Amount:       2500000,
+		AgreementURL: "https://example.com/files/agreement.pdf",
+		SigningURL:   "https://example.com/signatures/sample-token",
+		Attached:     true,
+		Password:     entity.AgreementPasswordDateOfBirth,
+	},
+	entity.NotificationTemplateSignatureRequest: entity.SignatureRequestTemplateData{
+		SignerName:   "Siti Aminah",
+		LoanID:       42,
+		AgreementURL: "https://example.com/files/agreement.pdf",
+		SigningURL:   "https://example.com/signatures/sample-token",
+	},
+	entity.NotificationTemplateLoanApproved: entity.LoanApprovedTemplateData{
+		BorrowerName:    "Siti Aminah",
+		LoanID:          42,
+		Amount:          5000000,
+		Rate:            18.5,
+		Term:            50,
+		TermUnit:        entity.TermUnitWeek,
+		FundingDeadline: time.Date(2026, time.March, 16, 0, 0, 0, 0, time.UTC),
+	},
+	entity.NotificationTemplateInvestmentReceived: entity.InvestmentReceivedTemplateData{
+		InvestorName:   "investor@example.com",
+		LoanID:         42,
+		Amount:         2500000,
+		InvestedAt:     time.Date(2026, time.March, 2, 9, 30, 0, 0, time.UTC),
+		LoanAmount:     5000000,
+		InvestedAmount: 3500000,
+	},
+	entity.NotificationTemplateLoanFunded: entity.LoanFundedTemplateData{
+		BorrowerName:  "Siti Aminah",
+		LoanID:        42,
+		Amount:        5000000,
+		InvestorCount: 3,
+	},
+	entity.NotificationTemplateLoanDisbursed: entity.LoanDisbursedTemplateData{
+		BorrowerName: "Siti Aminah",
+		LoanID:       42,
+		Amount:       5000000,
+		DisbursedAt:  time.Date(2026, time.March, 9, 10, 0, 0, 0, time.UTC),
+		Term:         50,
+		TermUnit:     entity.TermUnitWeek,
+	},
+	entity.NotificationTemplateRepaymentReceived: entity.RepaymentReceivedTemplateData{
+		BorrowerName: "Siti Aminah",
+		LoanID:       42,
+		Amount:       118500,
+		PaidAt:       time.Date(2026, time.March, 16, 8, 0, 0, 0, time.UTC),
+		Outstanding:  5806500,
+	},
+	entity.NotificationTemplateLoanExpired: entity.LoanExpiredTemplateData{
+		BorrowerName:    "Siti Aminah",
+		LoanID:          42,
+		Amount:          5000000,
+		InvestedAmount:  3500000,
+		FundingDeadline: time.Date(2026, time.March, 16, 0, 0, 0, 0, time.UTC),
+	},
+}
+
+// PreviewNotification renders a template with sample data, so it can be checked without sending it.
+func (s *notificationService) PreviewNotification(ctx context.Context, input entity.PreviewNotificationInput) (result entity.NotificationMessage, err error) {
+	if !input.Channel.IsValid() {
+		err = fmt.Errorf("invalid channel %s", input.Channel)
+		return
+	}
+	if !input.Template.IsValid() {
+		err = fmt.Errorf("invalid template %s", input.Template)
+		return
+	}
+	if input.Language == "" {
+		input.Language = entity.DefaultNotificationLanguage
+	}
+	if !input.Language.IsValid() {
+		err = fmt.Errorf("invalid language %s", input.Language)
+		return
+	}
+	result, err = s.notificationApi.Render(ctx, input.Channel, input.Template, input.Language, notificationSamples[input.Template])
+	if err != nil {
+		return
+	}
+	return
+}
+
+type notificationService struct {
+	notificationRepo           db.NotificationRepository
+	notificationPreferenceRepo db.NotificationPreferenceRepository
+	notificationApi            notification.NotificationApi
+	storage                    storage.Storage
+	policy                     entity.NotificationPolicy
+	wake                       chan struct{}
+}
+
+type InitiatorNotification func(s *notificationService) *notificationService
+
+func NewNotificationService() InitiatorNotification {
+	return func(s *notificationService) *notificationService {
+		return s
+	}
+}
+
+func (i InitiatorNotification) SetRepository(notificationRepository db.NotificationRepository) InitiatorNotification {
+	return func(s *notificationService) *notificationService {
+		i(s).notificationRepo = notificationRepository
+		return s
+	}
+}
+
+func (i InitiatorNotification) SetNotificationPreferenceRepository(notificationPreferenceRepository db.NotificationPreferenceRepository) InitiatorNotification {
+	return func(s *notificationService) *notificationService {
+		i(s).notificationPreferenceRepo = notificationPreferenceRepository
+		return s
+	}
+}
+
+func (i InitiatorNotification) SetNotificationApi(notificationApi notification.NotificationApi) InitiatorNotification {
+	return func(s *notificationService) *notificationService {
+		i(s).notificationApi = notificationApi
+		return s
+	}
+}
+
+func (i InitiatorNotification) SetStorage(storage storage.Storage) InitiatorNotification {
+	return func(s *notificationService) *notificationService {
+		i(s).storage = storage
+		return s
+	}
+}
+
+func (i InitiatorNotification) SetPolicy(policy entity.NotificationPolicy) InitiatorNotification {
+	return func(s *notificationService) *notificationService {
+		i(s).policy = policy
+		return s
+	}
+}
+
+func (i InitiatorNotification) Build() NotificationService {
+	return i(&notificationService{
+		wake: make(chan struct{}, 1),
+	})
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/repository/db"
+)
+
+// OutboxProcessor runs the side effect an outbox event stands for.
+type OutboxProcessor interface {
+	ProcessOutboxEvent(ctx context.Context, event entity.OutboxEvent) (err error)
+}
+
+type OutboxService interface {
+	Dispatch(ctx context.Context) (processed int, err error)
+	Start(ctx context.Context)
+	OutboxEvents(ctx context.Context, filter entity.OutboxEventsInput) (result []entity.OutboxEvent, err error)
+	RetryOutboxEvent(ctx context.Context, id int) (result entity.OutboxEvent, err error)
+}
+
+// Dispatch processes every event that is due once. A failed event is retried after a backoff
+// that doubles with every attempt, it is marked FAILED when no attempt is left.
+func (s *outboxService) Dispatch(ctx context.Context) (processed int, err error) {
+	return s.dispatcher().dispatch(ctx)
+}
+
+func (s *outboxService) dispatcher() dispatcher[entity.OutboxEvent] {
+	return dispatcher[entity.OutboxEvent]{
+		name:         "outbox",
+		due:          s.outboxEventRepo.DueOutboxEvents,
+		claim:        s.outboxEventRepo.Claim,
+		run:          s.runEvent,
+		finish:       s.outboxEventRepo.Update,
+		pollInterval: s.policy.PollInterval,
+		lockTimeout:  s.policy.LockTimeout,
+	}
+}
+
+func (s *outboxService) runEvent(ctx context.Context, event *entity.OutboxEvent) {
+	event.Attempts++
+	processErr := s.process(ctx, *event)
+	finishedAt := time.Now().UTC()
+	event.LockedAt = nil
+	if processErr == nil {
+		event.Status = entity.OutboxEventStatusDone
+		event.ProcessedAt = &finishedAt
+		return
+	}
+	lastError := processErr.Error()
+	event.LastError = &lastError
+	event.Status = entity.OutboxEventStatusPending
+	event.AvailableAt = finishedAt.Add(retryBackoff(event.Attempts, s.policy.Backoff, s.policy.MaxBackoff))
+	if event.Attempts >= s.policy.MaxAttempts {
+		event.Status = entity.OutboxEventStatusFailed
+	}
+}
+
+// process turns a panic of the processor into an error so the event is retried like any other failure.
+func (s *outboxService) process(ctx context.Context, event entity.OutboxEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return s.processor.ProcessOutboxEvent(ctx, event)
+}
+
+// Start dispatches due events every poll interval until ctx is done.
+func (s *outboxService) Start(ctx context.Context) {
+	s.dispatcher().start(ctx)
+}
+
+func (s *outboxService) OutboxEvents(ctx context.Context, filter entity.OutboxEventsInput) (result []entity.OutboxEvent, err error) {
+	result, err = s.outboxEventRepo.OutboxEvents(ctx, filter)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// RetryOutboxEvent gives a FAILED event a fresh set of attempts.
+func (s *outboxService) RetryOutboxEvent(ctx context.Context, id int) (result entity.OutboxEvent, err error) {
+	result, err = s.outboxEventRepo.OutboxEvent(ctx, entity.OutboxEventInput{
+		ID: &id,
+	})
+	if err != nil {
+		return
+	}
+	if result.Status != entity.OutboxEventStatusFailed {
+		err = errors.New("only failed outbox event can be retried")
+		return
+	}
+	result.Status = entity.OutboxEventStatusPending
+	result.Attempts = 0
+	result.AvailableAt = time.Now().UTC()
+	err = s.outboxEventRepo.Update(ctx, &result)
+	if err != nil {
+		return
+	}
+	return
+}
+
+type outboxService struct {
+	outboxEventRepo db.OutboxEventRepository
+	processor       OutboxProcessor
+	policy          entity.OutboxPolicy
+}
+
+type InitiatorOutbox func(s *outboxService) *outboxService
+
+func NewOutboxService() InitiatorOutbox {
+	return func(s *outboxService) *outboxService {
+		return s
+	}
+}
+
+func (i InitiatorOutbox) SetRepository(outboxEventRepository db.OutboxEventRepository) InitiatorOutbox {
+	return func(s *outboxService) *outboxService {
+		i(s).outboxEventRepo = outboxEventRepository
+		return s
+	}
+}
+
+func (i InitiatorOutbox) SetProcessor(processor OutboxProcessor) InitiatorOutbox {
+	return func(s *outboxService) *outboxService {
+		i(s).processor = processor
+		return s
+	}
+}
+
+func (i InitiatorOutbox) SetPolicy(policy entity.OutboxPolicy) InitiatorOutbox {
+	return func(s *outboxService) *outboxService {
+		i(s).policy = policy
+		return s
+	}
+}
+
+func (i InitiatorOutbox) Build() OutboxService {
+	return i(&outboxService{})
+}
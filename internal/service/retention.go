@@ -0,0 +1,386 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/repository/db"
+	"github.com/adityaokke/test-amartha/internal/repository/storage"
+	"gorm.io/gorm"
+)
+
+type RetentionService interface {
+	Run(ctx context.Context, input entity.RetentionRunInput) (result entity.RetentionReport, err error)
+	RunJob(ctx context.Context, job entity.Job) (err error)
+}
+
+// Run finds the uploads and agreement documents whose retention period is over and deletes them,
+// a dry run only reports them. A failed deletion is reported and does not stop the run.
+func (s *retentionService) Run(ctx context.Context, input entity.RetentionRunInput) (result entity.RetentionReport, err error) {
+	now := time.Now()
+	result = entity.RetentionReport{
+		DryRun:     input.DryRun,
+		RanAt:      now,
+		Candidates: []entity.RetentionCandidate{},
+	}
+
+	candidates, err := s.expiredFiles(ctx, now)
+	if err != nil {
+		return
+	}
+	documentCandidates, err := s.expiredAgreementDocuments(ctx, now)
+	if err != nil {
+		return
+	}
+	candidates = append(candidates, documentCandidates...)
+
+	for _, candidate := range candidates {
+		if !input.DryRun {
+			deleteErr := s.deleteCandidate(ctx, candidate)
+			if deleteErr != nil {
+				candidate.Error = deleteErr.Error()
+				result.Failed++
+			} else {
+				candidate.Deleted = true
+				result.Deleted++
+			}
+		}
+		result.Candidates = append(result.Candidates, candidate)
+	}
+	return
+}
+
+func (s *retentionService) expiredFiles(ctx context.Context, now time.Time) (result []entity.RetentionCandidate, err error) {
+	// nothing younger than the shortest retention period can be due
+	shortestDays := s.policy.OrphanUploadDays
+	rejectedDays := false
+	for _, rule := range s.policy.Rules {
+		for _, days := range []int{rule.UnreferencedDays, rule.ReferencedDays} {
+			if days > 0 && (shortestDays == 0 || days < shortestDays) {
+				shortestDays = days
+			}
+		}
+		rejectedDays = rejectedDays || rule.RejectedDays > 0
+	}
+	var files []entity.File
+	if shortestDays > 0 {
+		createdBefore := now.AddDate(0, 0, -shortestDays)
+		files, err = s.fileRepo.Files(ctx, entity.FilesInput{
+			CreatedBefore: &createdBefore,
+		})
+		if err != nil {
+			return
+		}
+	}
+	// a rejected document is due counting from its review, however recent the upload is
+	if rejectedDays {
+		var rejectedFiles []entity.File
+		rejectedStatus := entity.FileReviewStatusRejected
+		rejectedFiles, err = s.fileRepo.Files(ctx, entity.FilesInput{
+			ReviewStatus: &rejectedStatus,
+		})
+		if err != nil {
+			return
+		}
+		loaded := map[int]bool{}
+		for _, file := range files {
+			loaded[file.ID] = true
+		}
+		for _, file := range rejectedFiles {
+			if !loaded[file.ID] {
+				files = append(files, file)
+			}
+		}
+	}
+	if len(files) == 0 {
+		return
+	}
+
+	referencedIDs, referencedURLs, err := s.referencedFiles(ctx)
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		referenced := referencedIDs[file.ID] || referencedURLs[file.URL]
+		if file.Purpose == entity.FilePurposeKYC && (file.LoanID != nil || file.InvestorID != nil) {
+			referenced = true
+		}
+		rule := s.policy.Rule(file.Purpose)
+		days := rule.UnreferencedDays
+		if referenced {
+			days = rule.ReferencedDays
+		}
+		var expiredAt time.Time
+		if days > 0 {
+			expiredAt = file.CreatedAt.AddDate(0, 0, days)
+		}
+		// a rejected document is not needed anymore, whether it is attached or not
+		rejected := file.ReviewStatus == entity.FileReviewStatusRejected && file.ReviewedAt != nil
+		if rejected && rule.RejectedDays > 0 {
+			rejectedExpiredAt := file.ReviewedAt.AddDate(0, 0, rule.RejectedDays)
+			if expiredAt.IsZero() || rejectedExpiredAt.Before(expiredAt) {
+				expiredAt = rejectedExpiredAt
+			}
+		}
+		if expiredAt.IsZero() || now.Before(expiredAt) {
+			continue
+		}
+		result = append(result, entity.RetentionCandidate{
+			Kind:       entity.RetentionCandidateKindFile,
+			ID:         file.ID,
+			Key:        file.StorageKey(),
+			Purpose:    file.Purpose,
+			Referenced: referenced,
+			Rejected:   rejected,
+			LoanID:     file.LoanID,
+			URL:        file.URL,
+			CreatedAt:  file.CreatedAt,
+			ExpiredAt:  expiredAt,
+		})
+	}
+	return
+}
+
+// referencedFiles collects the uploads a loan is using as its photo proof or wet-signed agreement.
+func (s *retentionService) referencedFiles(ctx context.Context) (ids map[int]bool, urls map[string]bool, err error) {
+	loans, err := s.loanRepo.Loans(ctx, entity.LoansInput{})
+	if err != nil {
+		return
+	}
+	ids = map[int]bool{}
+	urls = map[string]bool{}
+	for _, loan := range loans {
+		if loan.PhotoProofFileID != nil {
+			ids[*loan.PhotoProofFileID] = true
+		}
+		if loan.LoanAgreementFileID != nil {
+			ids[*loan.LoanAgreementFileID] = true
+		}
+		// loans approved before files were tracked by id only kept the url
+		if loan.PhotoProofURL != nil {
+			urls[*loan.PhotoProofURL] = true
+		}
+		if loan.LoanAgreementLetterURL != nil {
+			urls[*loan.LoanAgreementLetterURL] = true
+		}
+	}
+	return
+}
+
+// expiredAgreementDocuments finds the agreements of loans whose term ended more than the
+// agreement retention period ago, the agreements of a loan that was never disbursed are kept.
+func (s *retentionService) expiredAgreementDocuments(ctx context.Context, now time.Time) (result []entity.RetentionCandidate, err error) {
+	if s.policy.AgreementDays == 0 {
+		return
+	}
+	status := entity.LoanStatusDisbursed
+	loans, err := s.loanRepo.Loans(ctx, entity.LoansInput{
+		Status: &status,
+	})
+	if err != nil {
+		return
+	}
+	for _, loan := range loans {
+		endsAt := loan.EndsAt()
+		if endsAt == nil {
+			continue
+		}
+		expiredAt := endsAt.AddDate(0, 0, s.policy.AgreementDays)
+		if now.Before(expiredAt) {
+			continue
+		}
+		var documents []entity.AgreementDocument
+		documents, err = s.agreementDocumentRepo.AgreementDocuments(ctx, entity.AgreementDocumentsInput{
+			LoanID: &loan.ID,
+		})
+		if err != nil {
+			return
+		}
+		for _, document := range documents {
+			result = append(result, entity.RetentionCandidate{
+				Kind:       entity.RetentionCandidateKindAgreementDocument,
+				ID:         document.ID,
+				Key:        document.Path,
+				Referenced: true,
+				LoanID:     &document.LoanID,
+				URL:        document.URL,
+				CreatedAt:  document.CreatedAt,
+				ExpiredAt:  expiredAt,
+			})
+		}
+	}
+	return
+}
+
+func (s *retentionService) deleteCandidate(ctx context.Context, candidate entity.RetentionCandidate) (err error) {
+	switch candidate.Kind {
+	case entity.RetentionCandidateKindFile:
+		var file entity.File
+		file, err = s.fileRepo.File(ctx, entity.FileInput{
+			ID: &candidate.ID,
+		})
+		if err != nil {
+			return
+		}
+		err = s.fileService.DeleteFile(ctx, file)
+	case entity.RetentionCandidateKindAgreementDocument:
+		err = s.storage.Delete(ctx, candidate.Key)
+		if err != nil && !errors.Is(err, entity.ErrStorageObjectNotFound) {
+			return fmt.Errorf("delete %s: %w", candidate.Key, err)
+		}
+		err = s.agreementDocumentRepo.Delete(ctx, &entity.AgreementDocument{
+			ID: candidate.ID,
+		})
+	}
+	if err != nil {
+		return
+	}
+	err = s.detachLoanLetter(ctx, candidate)
+	return
+}
+
+// detachLoanLetter clears the agreement letter urls of the loan that point at a deleted object,
+// so the loan does not hand out links to a letter that is gone.
+func (s *retentionService) detachLoanLetter(ctx context.Context, candidate entity.RetentionCandidate) (err error) {
+	if candidate.LoanID == nil || candidate.URL == "" {
+		return
+	}
+	loan, err := s.loanRepo.Loan(ctx, entity.LoanInput{
+		ID: candidate.LoanID,
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return
+	}
+	detached := false
+	if loan.DraftLoanAgreementLetterURL != nil && *loan.DraftLoanAgreementLetterURL == candidate.URL {
+		loan.DraftLoanAgreementLetterURL = nil
+		detached = true
+	}
+	if loan.LoanAgreementLetterURL != nil && *loan.LoanAgreementLetterURL == candidate.URL {
+		loan.LoanAgreementLetterURL = nil
+		detached = true
+	}
+	if !detached {
+		return
+	}
+	err = s.loanRepo.Update(ctx, &loan)
+	return
+}
+
+// RunJob is the handler of the scheduled RETENTION_RUN job, the report is logged
+// and failed deletions fail the job so it is retried.
+func (s *retentionService) RunJob(ctx context.Context, job entity.Job) (err error) {
+	report, err := s.Run(ctx, entity.RetentionRunInput{
+		DryRun: s.policy.DryRun,
+	})
+	if err != nil {
+		return
+	}
+	for _, candidate := range report.Candidates {
+		log.Printf("retention: %s %d %s expired at %s deleted=%t %s", candidate.Kind, candidate.ID, candidate.Key, candidate.ExpiredAt.Format(time.RFC3339), candidate.Deleted, candidate.Error)
+	}
+	log.Printf("retention: dryRun=%t candidates=%d deleted=%d failed=%d", report.DryRun, len(report.Candidates), report.Deleted, report.Failed)
+	if report.Failed > 0 {
+		err = fmt.Errorf("%d of %d deletions failed", report.Failed, len(report.Candidates))
+		return
+	}
+	return
+}
+
+// LoadRetentionPolicy reads the retention periods from a json file.
+func LoadRetentionPolicy(path string) (result entity.RetentionPolicy, err error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(raw, &result); err != nil {
+		return
+	}
+	if result.OrphanUploadDays < 0 || result.AgreementDays < 0 {
+		err = errors.New("retention days must not be negative")
+		return
+	}
+	for _, rule := range result.Rules {
+		if !rule.Purpose.IsValid() {
+			err = fmt.Errorf("unknown file purpose %s", rule.Purpose)
+			return
+		}
+		if rule.UnreferencedDays < 0 || rule.ReferencedDays < 0 {
+			err = fmt.Errorf("rule %s days must not be negative", rule.Purpose)
+			return
+		}
+	}
+	return
+}
+
+type retentionService struct {
+	fileRepo              db.FileRepository
+	loanRepo              db.LoanRepository
+	agreementDocumentRepo db.AgreementDocumentRepository
+	fileService           FileService
+	storage               storage.Storage
+	policy                entity.RetentionPolicy
+}
+
+type InitiatorRetention func(s *retentionService) *retentionService
+
+func NewRetentionService() InitiatorRetention {
+	return func(s *retentionService) *retentionService {
+		return s
+	}
+}
+
+func (i InitiatorRetention) SetFileRepository(fileRepository db.FileRepository) InitiatorRetention {
+	return func(s *retentionService) *retentionService {
+		i(s).fileRepo = fileRepository
+		return s
+	}
+}
+
+func (i InitiatorRetention) SetLoanRepository(loanRepository db.LoanRepository) InitiatorRetention {
+	return func(s *retentionService) *retentionService {
+		i(s).loanRepo = loanRepository
+		return s
+	}
+}
+
+func (i InitiatorRetention) SetAgreementDocumentRepository(agreementDocumentRepository db.AgreementDocumentRepository) InitiatorRetention {
+	return func(s *retentionService) *retentionService {
+		i(s).agreementDocumentRepo = agreementDocumentRepository
+		return s
+	}
+}
+
+func (i InitiatorRetention) SetFileService(fileService FileService) InitiatorRetention {
+	return func(s *retentionService) *retentionService {
+		i(s).fileService = fileService
+		return s
+	}
+}
+
+func (i InitiatorRetention) SetStorage(storage storage.Storage) InitiatorRetention {
+	return func(s *retentionService) *retentionService {
+		i(s).storage = storage
+		return s
+	}
+}
+
+func (i InitiatorRetention) SetPolicy(policy entity.RetentionPolicy) InitiatorRetention {
+	return func(s *retentionService) *retentionService {
+		i(s).policy = policy
+		return s
+	}
+}
+
+func (i InitiatorRetention) Build() RetentionService {
+	return i(&retentionService{})
+}
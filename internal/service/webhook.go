@@ -0,0 +1,546 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/repository/db"
+)
+
+// headers of every webhook delivery, the signature is the hex HMAC-SHA256 of "<timestamp>.<body>"
+// with the subscription secret, signing the timestamp lets a partner reject replayed requests.
+const (
+	WebhookHeaderEventID   = "X-Webhook-Id"
+	WebhookHeaderEventType = "X-Webhook-Event"
+	WebhookHeaderDelivery  = "X-Webhook-Delivery"
+	WebhookHeaderTimestamp = "X-Webhook-Timestamp"
+	WebhookHeaderSignature = "X-Webhook-Signature"
+)
+
+type WebhookService interface {
+	Emit(ctx context.Context, input entity.EmitWebhookEventInput) (result entity.WebhookEvent, err error)
+	HandleDomainEvent(ctx context.Context, event entity.DomainEvent) (err error)
+	Dispatch(ctx context.Context) (processed int, err error)
+	Start(ctx context.Context)
+
+	AddWebhookSubscription(ctx context.Context, input entity.AddWebhookSubscriptionInput) (result entity.WebhookSubscription, err error)
+	UpdateWebhookSubscription(ctx context.Context, input entity.UpdateWebhookSubscriptionInput) (result entity.WebhookSubscription, err error)
+	WebhookSubscriptions(ctx context.Context, filter entity.WebhookSubscriptionsInput) (result []entity.WebhookSubscription, err error)
+	WebhookSubscription(ctx context.Context, id int) (result entity.WebhookSubscription, err error)
+
+	WebhookDeliveries(ctx context.Context, filter entity.WebhookDeliveriesInput) (result []entity.WebhookDelivery, err error)
+	ReplayWebhookDelivery(ctx context.Context, id int) (result entity.WebhookDelivery, err error)
+}
+
+// Emit records an event and queues a delivery to every active subscription of its type.
+// An event emitted again under an existing key returns the event already recorded and queues nothing.
+func (s *webhookService) Emit(ctx context.Context, input entity.EmitWebhookEventInput) (result entity.WebhookEvent, err error) {
+	if !input.Type.IsValid() {
+		err = fmt.Errorf("invalid webhook event type %s", input.Type)
+		return
+	}
+	data, err := json.Marshal(input.Data)
+	if err != nil {
+		return
+	}
+	isActive := true
+	subscriptions, err := s.webhookSubscriptionRepo.WebhookSubscriptions(ctx, entity.WebhookSubscriptionsInput{
+		IsActive: &isActive,
+	})
+	if err != nil {
+		return
+	}
+
+	now := time.Now().UTC()
+	deliveries := []entity.WebhookDelivery{}
+	for _, subscription := range subscriptions {
+		if !subscription.Subscribes(input.Type) {
+			continue
+		}
+		deliveries = append(deliveries, entity.WebhookDelivery{
+			SubscriptionID: subscription.ID,
+			EventType:      input.Type,
+			LoanID:         input.LoanID,
+			Status:         entity.WebhookDeliveryStatusPending,
+			AvailableAt:    now,
+		})
+	}
+	item := entity.WebhookEvent{
+		Type:       input.Type,
+		Key:        input.Key,
+		LoanID:     input.LoanID,
+		Data:       data,
+		OccurredAt: input.OccurredAt,
+	}
+	if item.OccurredAt.IsZero() {
+		item.OccurredAt = now
+	}
+	if item.Key == "" {
+		item.Key = fmt.Sprintf("%s:%d:%d", input.Type, input.LoanID, now.UnixNano())
+	}
+	err = s.webhookEventRepo.Create(ctx, &item, deliveries)
+	if err != nil {
+		return
+	}
+	if item.ID == 0 {
+		result, err = s.webhookEventRepo.WebhookEvent(ctx, entity.WebhookEventInput{
+			Key: &item.Key,
+		})
+		return
+	}
+	result = item
+	if len(deliveries) > 0 {
+		wakeUp(s.wake)
+	}
+	return
+}
+
+// webhookEventTypes is the webhook event partners receive for a domain event.
+var webhookEventTypes = map[entity.DomainEventType]entity.WebhookEventType{
+	entity.DomainEventTypeLoanProposed:           entity.WebhookEventTypeLoanProposed,
+	entity.DomainEventTypeLoanApproved:           entity.WebhookEventTypeLoanApproved,
+	entity.DomainEventTypeInvestmentPlaced:       entity.WebhookEventTypeLoanInvested,
+	entity.DomainEventTypeLoanFullyFunded:        entity.WebhookEventTypeLoanFunded,
+	entity.DomainEventTypeLoanAgreementGenerated: entity.WebhookEventTypeLoanAgreementGenerated,
+	entity.DomainEventTypeLoanDisbursed:          entity.WebhookEventTypeLoanDisbursed,
+}
+
+// HandleDomainEvent emits the webhook event of a domain event, the event key makes a repeat a no-op.
+func (s *webhookService) HandleDomainEvent(ctx context.Context, event entity.DomainEvent) (err error) {
+	eventType, ok := webhookEventTypes[event.Type]
+	if !ok {
+		return
+	}
+	_, err = s.Emit(ctx, entity.EmitWebhookEventInput{
+		Key:        event.Key,
+		Type:       eventType,
+		LoanID:     event.Loan.ID,
+		Data:       webhookEventData(event),
+		OccurredAt: event.OccurredAt,
+	})
+	return
+}
+
+// webhookEventData copies what partners may see of the loan and investment of the event.
+func webhookEventData(event entity.DomainEvent) (result entity.WebhookEventData) {
+	loan := event.Loan
+	result.Loan = entity.WebhookLoan{
+		ID:              loan.ID,
+		Status:          loan.Status,
+		ProductID:       loan.ProductID,
+		Amount:          loan.Amount,
+		InvestedAmount:  loan.InvestedAmount,
+		Rate:            loan.Rate,
+		Term:            loan.Term,
+		TermUnit:        loan.TermUnit,
+		InterestMethod:  loan.InterestMethod,
+		RiskGrade:       loan.RiskGrade,
+		ApprovedAt:      loan.ApprovedAt,
+		FundingDeadline: loan.FundingDeadline,
+		FullyInvestedAt: loan.FullyInvestedAt,
+		DisbursedAt:     loan.DisbursedAt,
+		CreatedAt:       loan.CreatedAt,
+	}
+	if event.Investment != nil {
+		result.Investment = &entity.WebhookInvestment{
+			ID:         event.Investment.ID,
+			Amount:     event.Investment.Amount,
+			InvestedAt: event.Investment.CreatedAt,
+		}
+	}
+	return
+}
+
+// Dispatch attempts every delivery that is due once. A failed delivery is retried after a backoff
+// that doubles with every attempt, the delivery is marked DEAD when no attempt is left.
+func (s *webhookService) Dispatch(ctx context.Context) (processed int, err error) {
+	return s.dispatcher().dispatch(ctx)
+}
+
+func (s *webhookService) dispatcher() dispatcher[entity.WebhookDelivery] {
+	return dispatcher[entity.WebhookDelivery]{
+		name:         "webhook",
+		due:          s.webhookDeliveryRepo.DueWebhookDeliveries,
+		claim:        s.webhookDeliveryRepo.Claim,
+		run:          s.runDelivery,
+		finish:       s.webhookDeliveryRepo.Update,
+		pollInterval: s.policy.PollInterval,
+		lockTimeout:  s.policy.LockTimeout,
+		wake:         s.wake,
+	}
+}
+
+func (s *webhookService) runDelivery(ctx context.Context, delivery *entity.WebhookDelivery) {
+	delivery.Attempts++
+	responseStatus, deliverErr := s.deliver(ctx, *delivery)
+	finishedAt := time.Now().UTC()
+	delivery.LockedAt = nil
+	delivery.ResponseStatus = responseStatus
+	if deliverErr == nil {
+		delivery.Status = entity.WebhookDeliveryStatusDelivered
+		delivery.DeliveredAt = &finishedAt
+		return
+	}
+	log.Printf("webhook: delivery %d of event %d to subscription %d: attempt %d: %v", delivery.ID, delivery.EventID, delivery.SubscriptionID, delivery.Attempts, deliverErr)
+	lastError := deliverErr.Error()
+	delivery.LastError = &lastError
+	delivery.Status = entity.WebhookDeliveryStatusPending
+	delivery.AvailableAt = finishedAt.Add(retryBackoff(delivery.Attempts, s.policy.Backoff, s.policy.MaxBackoff))
+	if delivery.Attempts >= s.policy.MaxAttempts {
+		delivery.Status = entity.WebhookDeliveryStatusDead
+	}
+}
+
+// deliver posts the event to the subscription endpoint, any answer but a 2xx is a failure.
+func (s *webhookService) deliver(ctx context.Context, delivery entity.WebhookDelivery) (responseStatus *int, err error) {
+	subscription, err := s.WebhookSubscription(ctx, delivery.SubscriptionID)
+	if err != nil {
+		return
+	}
+	event, err := s.webhookEventRepo.WebhookEvent(ctx, entity.WebhookEventInput{
+		ID: &delivery.EventID,
+	})
+	if err != nil {
+		return
+	}
+	body, err := json.Marshal(entity.WebhookPayload{
+		ID:         event.ID,
+		Type:       event.Type,
+		LoanID:     event.LoanID,
+		OccurredAt: event.OccurredAt,
+		Data:       event.Data,
+	})
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.policy.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookHeaderEventID, strconv.Itoa(event.ID))
+	req.Header.Set(WebhookHeaderEventType, string(event.Type))
+	req.Header.Set(WebhookHeaderDelivery, strconv.Itoa(delivery.ID))
+	req.Header.Set(WebhookHeaderTimestamp, timestamp)
+	req.Header.Set(WebhookHeaderSignature, "sha256="+signWebhook(subscription.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	responseStatus = &resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		err = fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(snippet))
+		return
+	}
+	return
+}
+
+func signWebhook(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Start dispatches due deliveries every poll interval, and right away when an event is emitted, until ctx is done.
+func (s *webhookService) Start(ctx context.Context) {
+	s.dispatcher().start(ctx)
+}
+
+func (s *webhookService) AddWebhookSubscription(ctx context.Context, input entity.AddWebhookSubscriptionInput) (result entity.WebhookSubscription, err error) {
+	err = s.validateWebhookSubscription(ctx, input.URL, input.EventTypes)
+	if err != nil {
+		return
+	}
+	if input.Secret == "" {
+		input.Secret, err = newWebhookSecret()
+		if err != nil {
+			return
+		}
+	}
+	item := entity.WebhookSubscription{
+		URL:        input.URL,
+		Secret:     input.Secret,
+		EventTypes: input.EventTypes,
+		IsActive:   true,
+	}
+	err = s.webhookSubscriptionRepo.Create(ctx, &item)
+	if err != nil {
+		return
+	}
+	result = item
+	return
+}
+
+func (s *webhookService) UpdateWebhookSubscription(ctx context.Context, input entity.UpdateWebhookSubscriptionInput) (result entity.WebhookSubscription, err error) {
+	err = s.validateWebhookSubscription(ctx, input.URL, input.EventTypes)
+	if err != nil {
+		return
+	}
+	result, err = s.WebhookSubscription(ctx, input.ID)
+	if err != nil {
+		return
+	}
+	result.URL = input.URL
+	result.EventTypes = input.EventTypes
+	if input.IsActive != nil {
+		result.IsActive = *input.IsActive
+	}
+	if input.Secret != "" {
+		result.Secret = input.Secret
+	}
+	err = s.webhookSubscriptionRepo.Update(ctx, &result)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (s *webhookService) validateWebhookSubscription(ctx context.Context, rawURL string, eventTypes []entity.WebhookEventType) (err error) {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+		err = errors.New("url must be an absolute http or https url")
+		return
+	}
+	err = s.checkWebhookHost(ctx, parsedURL.Hostname())
+	if err != nil {
+		return
+	}
+	if len(eventTypes) == 0 {
+		err = errors.New("at least one event type is required")
+		return
+	}
+	for _, eventType := range eventTypes {
+		if !eventType.IsValid() {
+			err = fmt.Errorf("invalid event type %s", eventType)
+			return
+		}
+	}
+	return
+}
+
+// errWebhookAddress is returned for an endpoint on loopback, private, link-local or otherwise
+// non-public addresses, a subscription must not make this service call its own network.
+var errWebhookAddress = errors.New("webhook url must resolve to a public address")
+
+// checkWebhookHost resolves the host of an endpoint and refuses it when any of its addresses is not public.
+func (s *webhookService) checkWebhookHost(ctx context.Context, host string) (err error) {
+	if s.policy.AllowPrivateNetworks {
+		return
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		err = fmt.Errorf("webhook url host %s can not be resolved: %w", host, err)
+		return
+	}
+	for _, addr := range addrs {
+		if !isPublicAddr(addr) {
+			err = errWebhookAddress
+			return
+		}
+	}
+	return
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, netip does not count it as private.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
+
+// newWebhookClient posts deliveries without a proxy and refuses to connect to a non-public address,
+// checked on the address actually dialed so a host that resolves differently after subscribing,
+// or redirects elsewhere, still can not reach the internal network.
+func (s *webhookService) newClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: s.policy.Timeout,
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			if s.policy.AllowPrivateNetworks {
+				return nil
+			}
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !isPublicAddr(addrPort.Addr()) {
+				return errWebhookAddress
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: s.policy.Timeout,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("webhook endpoint redirected too many times")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return errors.New("webhook endpoint redirected to a non http url")
+			}
+			return s.checkWebhookHost(req.Context(), req.URL.Hostname())
+		},
+	}
+}
+
+func newWebhookSecret() (secret string, err error) {
+	b := make([]byte, 32)
+	_, err = rand.Read(b)
+	if err != nil {
+		return
+	}
+	secret = "whsec_" + hex.EncodeToString(b)
+	return
+}
+
+func (s *webhookService) WebhookSubscriptions(ctx context.Context, filter entity.WebhookSubscriptionsInput) (result []entity.WebhookSubscription, err error) {
+	result, err = s.webhookSubscriptionRepo.WebhookSubscriptions(ctx, filter)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (s *webhookService) WebhookSubscription(ctx context.Context, id int) (result entity.WebhookSubscription, err error) {
+	result, err = s.webhookSubscriptionRepo.WebhookSubscription(ctx, entity.WebhookSubscriptionInput{
+		ID: &id,
+	})
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (s *webhookService) WebhookDeliveries(ctx context.Context, filter entity.WebhookDeliveriesInput) (result []entity.WebhookDelivery, err error) {
+	result, err = s.webhookDeliveryRepo.WebhookDeliveries(ctx, filter)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// ReplayWebhookDelivery gives a DEAD delivery a fresh set of attempts. A DELIVERED one is copied
+// into a new delivery of the same event, so the log keeps the delivery that was already made.
+func (s *webhookService) ReplayWebhookDelivery(ctx context.Context, id int) (result entity.WebhookDelivery, err error) {
+	result, err = s.webhookDeliveryRepo.WebhookDelivery(ctx, entity.WebhookDeliveryInput{
+		ID: &id,
+	})
+	if err != nil {
+		return
+	}
+
+	switch result.Status {
+	case entity.WebhookDeliveryStatusDead:
+		result.Status = entity.WebhookDeliveryStatusPending
+		result.Attempts = 0
+		result.AvailableAt = time.Now().UTC()
+		err = s.webhookDeliveryRepo.Update(ctx, &result)
+		if err != nil {
+			return
+		}
+	case entity.WebhookDeliveryStatusDelivered:
+		originalID := result.ID
+		result = entity.WebhookDelivery{
+			EventID:        result.EventID,
+			SubscriptionID: result.SubscriptionID,
+			EventType:      result.EventType,
+			LoanID:         result.LoanID,
+			Status:         entity.WebhookDeliveryStatusPending,
+			AvailableAt:    time.Now().UTC(),
+			ReplayOfID:     &originalID,
+		}
+		err = s.webhookDeliveryRepo.Create(ctx, &result)
+		if err != nil {
+			return
+		}
+	default:
+		err = fmt.Errorf("%s delivery is already queued", result.Status)
+		return
+	}
+	wakeUp(s.wake)
+	return
+}
+
+type webhookService struct {
+	webhookSubscriptionRepo db.WebhookSubscriptionRepository
+	webhookEventRepo        db.WebhookEventRepository
+	webhookDeliveryRepo     db.WebhookDeliveryRepository
+	policy                  entity.WebhookPolicy
+	client                  *http.Client
+	wake                    chan struct{}
+}
+
+type InitiatorWebhook func(s *webhookService) *webhookService
+
+func NewWebhookService() InitiatorWebhook {
+	return func(s *webhookService) *webhookService {
+		return s
+	}
+}
+
+func (i InitiatorWebhook) SetWebhookSubscriptionRepository(webhookSubscriptionRepository db.WebhookSubscriptionRepository) InitiatorWebhook {
+	return func(s *webhookService) *webhookService {
+		i(s).webhookSubscriptionRepo = webhookSubscriptionRepository
+		return s
+	}
+}
+
+func (i InitiatorWebhook) SetWebhookEventRepository(webhookEventRepository db.WebhookEventRepository) InitiatorWebhook {
+	return func(s *webhookService) *webhookService {
+		i(s).webhookEventRepo = webhookEventRepository
+		return s
+	}
+}
+
+func (i InitiatorWebhook) SetWebhookDeliveryRepository(webhookDeliveryRepository db.WebhookDeliveryRepository) InitiatorWebhook {
+	return func(s *webhookService) *webhookService {
+		i(s).webhookDeliveryRepo = webhookDeliveryRepository
+		return s
+	}
+}
+
+func (i InitiatorWebhook) SetPolicy(policy entity.WebhookPolicy) InitiatorWebhook {
+	return func(s *webhookService) *webhookService {
+		i(s).policy = policy
+		return s
+	}
+}
+
+func (i InitiatorWebhook) Build() WebhookService {
+	s := i(&webhookService{
+		wake: make(chan struct{}, 1),
+	})
+	s.client = s.newClient()
+	return s
+}
@@ -0,0 +1,299 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adityaokke/test-amartha/internal/entity"
+	"github.com/adityaokke/test-amartha/internal/repository/db/sqlite"
+	"github.com/adityaokke/test-amartha/internal/repository/db/sqlite/migration"
+	driver "github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestSignWebhook(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      string
+		want      string
+	}{
+		{
+			name:      "event",
+			secret:    "whsec_test",
+			timestamp: "1700000000",
+			body:      `{"id":1}`,
+			want:      "2f441ba4b3b2d50d28a9ab9d9fd8880376ecd1eb5d0435401553f5d8d0a5dcf8",
+		},
+		{
+			name:      "empty body",
+			secret:    "secret",
+			timestamp: "0",
+			body:      "",
+			want:      "3445798a051818ef95def46c2eb62b43d377ce6e3c29b4d0aec3da0e59577f79",
+		},
+		{
+			name:      "loan event",
+			secret:    "s3cr3t",
+			timestamp: "1792418322",
+			body:      `{"type":"LOAN_FUNDED","loanId":7}`,
+			want:      "484c916d5e799001b2569b4e0f201c2028ef8bcb6fd804bff7d312ed04821c2a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := signWebhook(tt.secret, tt.timestamp, []byte(tt.body))
+			if got != tt.want {
+				t.Errorf("signWebhook() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// the timestamp is signed, a replayed body under a new timestamp does not verify
+	if signWebhook("secret", "1", []byte("{}")) == signWebhook("secret", "2", []byte("{}")) {
+		t.Error("signWebhook() does not depend on the timestamp")
+	}
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "8.8.8.8", want: true},
+		{addr: "2606:4700:4700::1111", want: true},
+		{addr: "127.0.0.1", want: false},
+		{addr: "::1", want: false},
+		{addr: "10.1.2.3", want: false},
+		{addr: "172.16.0.1", want: false},
+		{addr: "192.168.1.1", want: false},
+		{addr: "169.254.169.254", want: false},
+		{addr: "100.64.0.1", want: false},
+		{addr: "0.0.0.0", want: false},
+		{addr: "fd00::1", want: false},
+		{addr: "fe80::1", want: false},
+		{addr: "::ffff:10.0.0.1", want: false},
+		{addr: "224.0.0.1", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := isPublicAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+				t.Errorf("isPublicAddr(%s) = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookEventData(t *testing.T) {
+	photoProofURL := "http://localhost:3000/public/uploads/a.jpg"
+	employeeID := 3
+	data := webhookEventData(entity.DomainEvent{
+		Type: entity.DomainEventTypeInvestmentPlaced,
+		Loan: entity.Loan{
+			ID:                   7,
+			UserID:               1,
+			Amount:               1000000,
+			InvestedAmount:       400000,
+			Status:               entity.LoanStatusApproved,
+			CreditScore:          575,
+			RiskGrade:            entity.RiskGradeC,
+			PhotoProofURL:        &photoProofURL,
+			ApprovedByEmployeeID: &employeeID,
+		},
+		Investment: &entity.LoanInvestment{ID: 2, LoanID: 7, InvestorID: 5, Amount: 400000},
+	})
+	raw, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]map[string]any
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["loan"]["id"] != float64(7) || got["loan"]["investedAmount"] != float64(400000) || got["loan"]["riskGrade"] != "C" {
+		t.Errorf("loan = %v, want the loan id, invested amount and risk grade", got["loan"])
+	}
+	for _, key := range []string{"userId", "creditScore", "photoProofUrl", "employeeId"} {
+		if _, ok := got["loan"][key]; ok {
+			t.Errorf("loan has %s, partners must not see it", key)
+		}
+	}
+	if got["investment"]["amount"] != float64(400000) {
+		t.Errorf("investment = %v, want the amount", got["investment"])
+	}
+	if _, ok := got["investment"]["investorID"]; ok {
+		t.Error("investment has the investor, partners must not see it")
+	}
+}
+
+func newTestWebhookService(t *testing.T, policy entity.WebhookPolicy) *webhookService {
+	t.Helper()
+	conn, err := gorm.Open(driver.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	migration.Migrate(conn)
+	return NewWebhookService().
+		SetWebhookSubscriptionRepository(sqlite.NewWebhookSubscriptionRepository().SetDBConnection(conn).Build()).
+		SetWebhookEventRepository(sqlite.NewWebhookEventRepository().SetDBConnection(conn).Build()).
+		SetWebhookDeliveryRepository(sqlite.NewWebhookDeliveryRepository().SetDBConnection(conn).Build()).
+		SetPolicy(policy).
+		Build().(*webhookService)
+}
+
+func TestWebhookDispatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		maxAttempts int
+		wantStatus  entity.WebhookDeliveryStatus
+		wantError   string
+	}{
+		{name: "delivered", status: http.StatusNoContent, maxAttempts: 3, wantStatus: entity.WebhookDeliveryStatusDelivered},
+		{name: "retried", status: http.StatusInternalServerError, maxAttempts: 3, wantStatus: entity.WebhookDeliveryStatusPending, wantError: "500 Internal Server Error: try again"},
+		{name: "out of attempts", status: http.StatusBadGateway, maxAttempts: 1, wantStatus: entity.WebhookDeliveryStatusDead, wantError: "502 Bad Gateway: try again"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const secret = "whsec_test"
+			received := make(chan *http.Request, 1)
+			var receivedBody []byte
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				receivedBody, _ = io.ReadAll(r.Body)
+				received <- r
+				w.WriteHeader(tt.status)
+				_, _ = io.WriteString(w, "try again")
+			}))
+			defer server.Close()
+
+			s := newTestWebhookService(t, entity.WebhookPolicy{
+				PollInterval:         time.Hour,
+				MaxAttempts:          tt.maxAttempts,
+				Backoff:              time.Minute,
+				MaxBackoff:           time.Hour,
+				LockTimeout:          time.Minute,
+				Timeout:              5 * time.Second,
+				AllowPrivateNetworks: true,
+			})
+			ctx := context.Background()
+			subscription, err := s.AddWebhookSubscription(ctx, entity.AddWebhookSubscriptionInput{
+				URL:        server.URL + "/hook",
+				Secret:     secret,
+				EventTypes: []entity.WebhookEventType{entity.WebhookEventTypeLoanFunded},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			event, err := s.Emit(ctx, entity.EmitWebhookEventInput{
+				Key:    "LOAN_FUNDED:7",
+				Type:   entity.WebhookEventTypeLoanFunded,
+				LoanID: 7,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			processed, err := s.Dispatch(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if processed != 1 {
+				t.Fatalf("Dispatch() processed %d deliveries, want 1", processed)
+			}
+
+			r := <-received
+			if r.Method != http.MethodPost || r.URL.Path != "/hook" {
+				t.Errorf("request = %s %s, want POST /hook", r.Method, r.URL.Path)
+			}
+			if r.Header.Get(WebhookHeaderEventID) != strconv.Itoa(event.ID) || r.Header.Get(WebhookHeaderEventType) != string(entity.WebhookEventTypeLoanFunded) {
+				t.Errorf("event headers = %s %s", r.Header.Get(WebhookHeaderEventID), r.Header.Get(WebhookHeaderEventType))
+			}
+			wantSignature := "sha256=" + signWebhook(secret, r.Header.Get(WebhookHeaderTimestamp), receivedBody)
+			if r.Header.Get(WebhookHeaderSignature) != wantSignature {
+				t.Errorf("signature = %s, want %s", r.Header.Get(WebhookHeaderSignature), wantSignature)
+			}
+
+			deliveries, err := s.WebhookDeliveries(ctx, entity.WebhookDeliveriesInput{SubscriptionID: &subscription.ID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(deliveries) != 1 {
+				t.Fatalf("got %d deliveries, want 1", len(deliveries))
+			}
+			delivery := deliveries[0]
+			if delivery.Status != tt.wantStatus || delivery.Attempts != 1 {
+				t.Errorf("delivery status = %s after %d attempts, want %s after 1", delivery.Status, delivery.Attempts, tt.wantStatus)
+			}
+			if delivery.ResponseStatus == nil || *delivery.ResponseStatus != tt.status {
+				t.Errorf("delivery response status = %v, want %d", delivery.ResponseStatus, tt.status)
+			}
+			if tt.wantError == "" {
+				if delivery.DeliveredAt == nil {
+					t.Error("delivered delivery has no deliveredAt")
+				}
+				return
+			}
+			if delivery.LastError == nil || *delivery.LastError != tt.wantError {
+				t.Errorf("delivery last error = %v, want %q", delivery.LastError, tt.wantError)
+			}
+			if tt.wantStatus == entity.WebhookDeliveryStatusPending && !delivery.AvailableAt.After(time.Now()) {
+				t.Errorf("retried delivery is available at %s, want a backoff", delivery.AvailableAt)
+			}
+		})
+	}
+}
+
+func TestWebhookRefusesPrivateNetworks(t *testing.T) {
+	var called bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	s := newTestWebhookService(t, entity.WebhookPolicy{
+		PollInterval: time.Hour,
+		MaxAttempts:  1,
+		Backoff:      time.Minute,
+		MaxBackoff:   time.Hour,
+		LockTimeout:  time.Minute,
+		Timeout:      5 * time.Second,
+	})
+	ctx := context.Background()
+
+	_, err := s.AddWebhookSubscription(ctx, entity.AddWebhookSubscriptionInput{
+		URL:        server.URL,
+		EventTypes: []entity.WebhookEventType{entity.WebhookEventTypeLoanFunded},
+	})
+	if !errors.Is(err, errWebhookAddress) {
+		t.Errorf("AddWebhookSubscription() of a loopback url error = %v, want %v", err, errWebhookAddress)
+	}
+
+	// an endpoint that resolved to a public address when subscribing is checked again when dialed
+	_, err = s.client.Get(server.URL)
+	if err == nil || !strings.Contains(err.Error(), errWebhookAddress.Error()) {
+		t.Errorf("client dialed a loopback address, error = %v", err)
+	}
+	if called {
+		t.Error("loopback endpoint received a request")
+	}
+
+	redirect, err := http.NewRequest(http.MethodGet, "http://169.254.169.254/latest/meta-data", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.client.CheckRedirect(redirect, []*http.Request{{}})
+	if !errors.Is(err, errWebhookAddress) {
+		t.Errorf("redirect to a link-local address error = %v, want %v", err, errWebhookAddress)
+	}
+}